@@ -0,0 +1,111 @@
+// Command galaxy-bots load tests a running server with headless bots, see
+// loadtest.BotClient. It is built separately from the server:
+//
+//	go run ./cmd/galaxy-bots -url ws://localhost:8080/game -bots 200
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"galaxy.io/server/loadtest"
+)
+
+const (
+	// REPORT_INTERVAL is how often the stats of the bots are logged by
+	// default.
+	REPORT_INTERVAL = 5 * time.Second
+
+	// DEFAULT_RAMP_UP is how long connecting every bot takes by default,
+	// so the server isn't hit by all of them at once.
+	DEFAULT_RAMP_UP = 5 * time.Second
+)
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/game", "game endpoint of the server")
+	bots := flag.Int("bots", 10, "number of bots")
+	rate := flag.Int("rate", loadtest.DEFAULT_INPUT_RATE, "inputs per second of every bot")
+	deltas := flag.Bool("deltas", false, "the server sends delta updates")
+	duration := flag.Duration("duration", 0, "how long to run, 0 until interrupted")
+	rampUp := flag.Duration("ramp-up", DEFAULT_RAMP_UP, "how long connecting every bot takes")
+	report := flag.Duration("report", REPORT_INTERVAL, "how often to log stats")
+	flag.Parse()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *duration)
+		defer cancel()
+	}
+
+	clients := make([]*loadtest.BotClient, *bots)
+	var wg sync.WaitGroup
+	for i := range clients {
+		clients[i] = loadtest.NewBotClient(loadtest.BotConfig{URL: *url, InputRate: *rate, Deltas: *deltas})
+		wg.Add(1)
+		go func(bot *loadtest.BotClient, delay time.Duration) {
+			defer wg.Done()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+			if err := bot.Run(ctx); err != nil {
+				log.Printf("bot stopped: %v", err)
+			}
+		}(clients[i], *rampUp*time.Duration(i)/time.Duration(max(1, *bots)))
+	}
+
+	go func() {
+		ticker := time.NewTicker(*report)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				logStats(clients)
+			}
+		}
+	}()
+
+	log.Printf("running %v bots against %v", *bots, *url)
+	wg.Wait()
+	logStats(clients)
+}
+
+// logStats logs the stats of every bot added up, and their average RTT
+// and snapshot rate.
+func logStats(clients []*loadtest.BotClient) {
+	var total loadtest.Stats
+	var rtt time.Duration
+	var measured int
+	for _, client := range clients {
+		stats := client.Stats()
+		total.Snapshots += stats.Snapshots
+		total.SnapshotRate += stats.SnapshotRate
+		total.Reconnects += stats.Reconnects
+		total.Deaths += stats.Deaths
+		total.DecodeErrors += stats.DecodeErrors
+		if stats.RTT > 0 {
+			rtt += stats.RTT
+			measured++
+		}
+	}
+	if measured > 0 {
+		total.RTT = rtt / time.Duration(measured)
+	}
+	if len(clients) > 0 {
+		total.SnapshotRate /= float64(len(clients))
+	}
+
+	log.Printf("bots: rtt %v, %.1f snapshots/s each, %v snapshots, %v reconnects, %v deaths, %v decode errors",
+		total.RTT, total.SnapshotRate, total.Snapshots, total.Reconnects, total.Deaths, total.DecodeErrors)
+}
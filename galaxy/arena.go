@@ -0,0 +1,96 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// WorldShape is the shape of the playable area of a world, see
+// GameConfig.Shape.
+type WorldShape uint8
+
+const (
+	// ShapeRectangle makes the whole of GameConfig.Bounds playable.
+	ShapeRectangle WorldShape = iota
+
+	// ShapeCircle makes the largest disc centered in GameConfig.Bounds
+	// playable, for arena modes.
+	ShapeCircle
+)
+
+func (s WorldShape) String() string {
+	switch s {
+	case ShapeRectangle:
+		return "rectangle"
+	case ShapeCircle:
+		return "circle"
+	default:
+		return fmt.Sprintf("WorldShape(%d)", uint8(s))
+	}
+}
+
+// arena is the playable area of a world: everything is clamped to it, and
+// spawns in it.
+type arena struct {
+	bounds utils.Rect
+
+	// circle is the playable disc of circular worlds, nil for rectangles.
+	circle *utils.Circle
+}
+
+// arena returns the playable area of the game.
+func (g *Game) arena() arena {
+	return newArena(g.config.Shape, g.config.Bounds)
+}
+
+func newArena(shape WorldShape, bounds utils.Rect) arena {
+	if shape != ShapeCircle {
+		return arena{bounds: bounds}
+	}
+	circle := &utils.Circle{
+		Center: bounds.Min.Lerp(bounds.Max, 0.5),
+		Radius: min(bounds.Width(), bounds.Height()) / 2,
+	}
+	return arena{bounds: circle.BoundingRect(), circle: circle}
+}
+
+// Clamp returns the point of the arena closest to point.
+func (a arena) Clamp(point utils.Vector2D) utils.Vector2D {
+	if a.circle != nil {
+		return a.circle.Clamp(point)
+	}
+	return a.bounds.Clamp(point)
+}
+
+// Bounce clamps position to the arena and reflects velocity off its edge.
+func (a arena) Bounce(position utils.Vector2D, velocity utils.Vector2D) (utils.Vector2D, utils.Vector2D) {
+	if a.circle != nil {
+		return a.circle.Bounce(position, velocity)
+	}
+	return a.bounds.Bounce(position, velocity)
+}
+
+// Area returns the surface of the arena.
+func (a arena) Area() float64 {
+	if a.circle != nil {
+		return math.Pi * a.circle.Radius * a.circle.Radius
+	}
+	return a.bounds.Width() * a.bounds.Height()
+}
+
+// random returns a position picked uniformly in the arena with r.
+func (a arena) random(r *rand.Rand) utils.Vector2D {
+	if a.circle != nil {
+		// The square root spreads points evenly over the disc rather than
+		// crowding them in the center.
+		distance := a.circle.Radius * math.Sqrt(r.Float64())
+		return a.circle.Center.Add(utils.FromAngle(r.Float64() * 2 * math.Pi).Scale(distance))
+	}
+	return utils.Vector2D{
+		X: a.bounds.Min.X + r.Float64()*a.bounds.Width(),
+		Y: a.bounds.Min.Y + r.Float64()*a.bounds.Height(),
+	}
+}
@@ -0,0 +1,146 @@
+package galaxy
+
+import (
+	"log"
+	"net"
+	"net/netip"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+// AddressedConnection is implemented by connections that know the address
+// of their client, which the BanList needs to ban it.
+type AddressedConnection interface {
+	RemoteAddr() net.Addr
+}
+
+// remoteAddr returns the IP address of the client of conn, if it knows it.
+func remoteAddr(conn ClientConnection) netip.Addr {
+	addressed, ok := conn.(AddressedConnection)
+	if !ok || addressed.RemoteAddr() == nil {
+		return netip.Addr{}
+	}
+	if tcp, ok := addressed.RemoteAddr().(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap()
+	}
+	addrPort, err := netip.ParseAddrPort(addressed.RemoteAddr().String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+// BanList holds the client addresses and session tokens that can't join
+// games, checked whenever a client connects. It is safe for concurrent use,
+// rooms can share one through GameConfig.Bans.
+type BanList struct {
+	mutex    sync.RWMutex
+	addrs    map[netip.Addr]struct{}
+	sessions map[uuid.UUID]struct{}
+}
+
+func NewBanList() *BanList {
+	return &BanList{
+		addrs:    make(map[netip.Addr]struct{}),
+		sessions: make(map[uuid.UUID]struct{}),
+	}
+}
+
+// BanAddr bans the clients connecting from addr.
+func (b *BanList) BanAddr(addr netip.Addr) {
+	b.mutex.Lock()
+	b.addrs[addr.Unmap()] = struct{}{}
+	b.mutex.Unlock()
+}
+
+func (b *BanList) UnbanAddr(addr netip.Addr) {
+	b.mutex.Lock()
+	delete(b.addrs, addr.Unmap())
+	b.mutex.Unlock()
+}
+
+// BanSession bans the clients resuming the session token.
+func (b *BanList) BanSession(token uuid.UUID) {
+	b.mutex.Lock()
+	b.sessions[token] = struct{}{}
+	b.mutex.Unlock()
+}
+
+func (b *BanList) UnbanSession(token uuid.UUID) {
+	b.mutex.Lock()
+	delete(b.sessions, token)
+	b.mutex.Unlock()
+}
+
+// Banned reports whether a client connecting from addr with the session
+// token is banned. Invalid addresses and nil tokens are never banned.
+func (b *BanList) Banned(addr netip.Addr, token uuid.UUID) bool {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+
+	if _, banned := b.addrs[addr.Unmap()]; addr.IsValid() && banned {
+		return true
+	}
+	_, banned := b.sessions[token]
+	return token != uuid.Nil && banned
+}
+
+// Kick removes the player with the given ID and its split cells from the
+// game, closing its connection with reason. It is safe to call while the
+// game runs.
+func (g *Game) Kick(id uuid.UUID, reason string) error {
+	g.Lock()
+	player, exists := g.players[id]
+	if !exists || player.IsCell() {
+		g.Unlock()
+		return ErrorPlayerNotFound
+	}
+	conn := g.evict(player)
+	g.Unlock()
+
+	g.flushEvents()
+	log.Printf("kicked player %v: %s", id, reason)
+	if conn != nil {
+		// Closing waits for the client to acknowledge the close frame.
+		go closeWithReason(conn, CLOSE_KICKED, reason)
+	}
+	return nil
+}
+
+// Ban adds the address and session of the player with the given ID to the
+// BanList of the game and kicks it.
+func (g *Game) Ban(id uuid.UUID, reason string) error {
+	player, exists := g.Player(id)
+	if !exists {
+		return ErrorPlayerNotFound
+	}
+
+	player.RLock()
+	conn, session := player.conn, player.session
+	player.RUnlock()
+
+	if addr := remoteAddr(conn); addr.IsValid() {
+		g.bans.BanAddr(addr)
+	}
+	if session != uuid.Nil {
+		g.bans.BanSession(session)
+	}
+	return g.Kick(id, reason)
+}
+
+// Bans returns the BanList checked when clients join the game.
+func (g *Game) Bans() *BanList {
+	return g.bans
+}
+
+// banned reports whether the client of conn, resuming the session token
+// if any, is banned.
+func (g *Game) banned(conn ClientConnection, token uuid.UUID) bool {
+	addr := remoteAddr(conn)
+	if !g.bans.Banned(addr, token) {
+		return false
+	}
+	log.Printf("rejecting banned client from %v", addr)
+	return true
+}
@@ -0,0 +1,57 @@
+package galaxy
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// BOOST_MASS is the mass a boost costs.
+	BOOST_MASS = 10
+
+	// MIN_BOOST_MASS is the smallest mass a player can boost at, so
+	// boosting never leaves it below a starting player.
+	MIN_BOOST_MASS = STARTING_MASS + BOOST_MASS
+
+	// BOOST_SPEED_MULTIPLIER is how much faster a boosting player moves,
+	// for BOOST_DURATION.
+	BOOST_SPEED_MULTIPLIER = 1.6
+	BOOST_DURATION         = 750 * time.Millisecond
+)
+
+var ErrorBoostTooSmall = fmt.Errorf("Player is too small to boost")
+
+// Boost takes BOOST_MASS from p and makes it move BOOST_SPEED_MULTIPLIER
+// times faster for BOOST_DURATION. Boosting again while boosting restarts
+// the boost.
+func (p *Player) Boost() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.Mass < MIN_BOOST_MASS {
+		return ErrorBoostTooSmall
+	}
+
+	p.Mass -= BOOST_MASS
+	p.recomputeRadius()
+	p.boostLeft = BOOST_DURATION
+	return nil
+}
+
+// Boosting reports whether p is currently boosted.
+func (p *Player) Boosting() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.boostLeft > 0
+}
+
+// boost makes player and its split cells boost, so they stay together,
+// the caller must hold the lock.
+func (g *Game) boost(player *Player) {
+	for _, cell := range append([]*Player{player}, g.cells(player.PlayerID)...) {
+		// Too small cells just don't boost.
+		if cell.Boost() == nil {
+			g.reindex(cell)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package galaxy
+
+import (
+	"testing"
+	"time"
+)
+
+// quietConfig is testConfig without food nor decay, so masses only change
+// through what a test does.
+func quietConfig() GameConfig {
+	config := testConfig()
+	config.FoodCount = 0
+	config.FoodDensity = 0
+	config.DecayRate = 0
+	return config
+}
+
+func TestBoostCostsMassOnCooldown(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	player := joinTestPlayer(t, g, 100, 1000, 1000)
+	boost := func() {
+		player.SetInput(PlayerInput{Actions: ActionBoost})
+		g.Tick(50 * time.Millisecond)
+	}
+
+	boost()
+	if !player.Boosting() {
+		t.Fatal("player not boosting after a boost")
+	}
+	if mass := player.Score(); mass != 100-BOOST_MASS {
+		t.Fatalf("mass %d after boosting, want %d", mass, 100-BOOST_MASS)
+	}
+
+	boost()
+	if mass := player.Score(); mass != 100-BOOST_MASS {
+		t.Errorf("boosting again on cooldown cost mass, %d left", mass)
+	}
+
+	for elapsed := time.Duration(0); elapsed < BOOST_ACTION_COOLDOWN; elapsed += 100 * time.Millisecond {
+		g.Tick(100 * time.Millisecond)
+	}
+	if player.Boosting() {
+		t.Errorf("boost lasted past BOOST_DURATION")
+	}
+	boost()
+	if mass := player.Score(); mass != 100-2*BOOST_MASS {
+		t.Errorf("mass %d after boosting past the cooldown, want %d", mass, 100-2*BOOST_MASS)
+	}
+}
+
+func TestBoostNeedsMass(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	player := joinTestPlayer(t, g, MIN_BOOST_MASS-1, 1000, 1000)
+
+	player.SetInput(PlayerInput{Actions: ActionBoost})
+	g.Tick(50 * time.Millisecond)
+	if player.Boosting() {
+		t.Error("player too small to boost is boosting")
+	}
+	if mass := player.Score(); mass != MIN_BOOST_MASS-1 {
+		t.Errorf("refused boost changed the mass to %d", mass)
+	}
+}
@@ -0,0 +1,186 @@
+package galaxy
+
+import (
+	"math"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// BOT_THINK_INTERVAL is how often bots look around and pick where to
+	// go, they keep heading the same way in between.
+	BOT_THINK_INTERVAL = 200 * time.Millisecond
+
+	// BOT_SIGHT is how far past their own edge bots notice other players.
+	BOT_SIGHT = 500
+
+	// BOT_CANDIDATES is the number of nearest players a bot weighs when
+	// looking for threats and prey.
+	BOT_CANDIDATES = 8
+)
+
+// botNames are picked from for the usernames of bots, so they read like
+// players.
+var botNames = []string{"orbit", "nova", "pulsar", "comet", "quasar", "nebula", "zenith", "vega", "sirius", "altair"}
+
+// Bot is an AI player the game spawns to fill sparse rooms, see
+// GameConfig.Bots. It flees the nearest player able to eat it, chases the
+// nearest one it can eat and otherwise heads for the nearest pellet. Bots
+// play through SetInput like the clients of humans, so they follow the
+// same rules.
+type Bot struct {
+	Player *Player
+
+	// thinkIn is the time left until the bot picks a direction again.
+	thinkIn time.Duration
+}
+
+// Bots returns the players of the game that are bots.
+func (g *Game) Bots() []*Player {
+	g.RLock()
+	defer g.RUnlock()
+
+	bots := make([]*Player, 0, len(g.bots))
+	for _, bot := range inOrder(g, g.bots) {
+		bots = append(bots, bot.Player)
+	}
+	return bots
+}
+
+// IsBot reports whether the player id, or the player owning the cell id,
+// is a bot.
+func (g *Game) IsBot(id uuid.UUID) bool {
+	g.RLock()
+	defer g.RUnlock()
+	player, exists := g.players[id]
+	return exists && g.isBot(player)
+}
+
+// isBot implements IsBot, the caller must hold the lock.
+func (g *Game) isBot(player *Player) bool {
+	_, isBot := g.bots[player.Owner()]
+	return isBot
+}
+
+// humans counts the players that aren't bots, split cells aside.
+func (g *Game) humans() int {
+	return int(g.humanCount.Load())
+}
+
+// driveBots keeps the room at GameConfig.Bots players: bots join while
+// fewer humans play and leave as they join, smallest first. It then lets
+// the bots due to think pick their input. The caller must hold the lock.
+func (g *Game) driveBots(dt time.Duration) {
+	want := max(g.config.Bots-g.humans(), 0)
+	if g.config.MaxPlayers > 0 {
+		want = min(want, max(g.config.MaxPlayers-g.humans(), 0))
+	}
+	for len(g.bots) > want {
+		g.removeBot(g.smallestBot())
+	}
+	for len(g.bots) < want {
+		g.spawnBot()
+	}
+
+	for _, bot := range inOrder(g, g.bots) {
+		bot.thinkIn -= dt
+		if bot.thinkIn > 0 {
+			continue
+		}
+		bot.thinkIn = BOT_THINK_INTERVAL
+
+		if !bot.Player.IsAlive() {
+			// Crowded worlds may have no room, the bot tries again later.
+			g.respawn(bot.Player)
+			continue
+		}
+		bot.Player.SetInput(g.think(bot.Player))
+	}
+}
+
+// spawnBot adds a bot to the game, the caller must hold the lock.
+func (g *Game) spawnBot() {
+	player := g.newPlayer(uuid.Nil)
+	player.Username = botNames[g.rand.IntN(len(botNames))]
+
+	g.bots[player.PlayerID] = &Bot{Player: player}
+	g.addPlayer(player)
+}
+
+// smallestBot returns the bot with the least mass, the caller must hold
+// the lock and there must be one.
+func (g *Game) smallestBot() *Bot {
+	var smallest *Bot
+	for _, bot := range inOrder(g, g.bots) {
+		if smallest == nil || bot.Player.Score() < smallest.Player.Score() {
+			smallest = bot
+		}
+	}
+	return smallest
+}
+
+// removeBot removes bot and its split cells from the game, the caller must
+// hold the lock.
+func (g *Game) removeBot(bot *Bot) {
+	id := bot.Player.PlayerID
+	for _, cell := range g.cells(id) {
+		g.removePlayer(cell.PlayerID)
+	}
+	g.removePlayer(id)
+	delete(g.bots, id)
+	g.emit(EventPlayerLeft, id, Event{})
+}
+
+// think picks the input of the bot player: away from the nearest player
+// within sight able to eat it, else towards the nearest one it can eat,
+// else towards the nearest pellet. The caller must hold the lock.
+func (g *Game) think(player *Player) PlayerInput {
+	position, radius := player.circle()
+	sight := radius + BOT_SIGHT
+
+	var threat, prey *Player
+	isPlayer := func(entity Entity) bool { return entity.Kind == EntityPlayer }
+	for _, entity := range g.nearest(position, BOT_CANDIDATES, isPlayer) {
+		other, exists := g.players[entity.ID]
+		if !exists || position.Distance(entity.Position)-float64(entity.Radius) > sight {
+			continue
+		}
+		if threat == nil && other.mayEat(player) && g.outsizes(other, player) {
+			threat = other
+		} else if prey == nil && player.mayEat(other) && g.outsizes(player, other) {
+			prey = other
+		}
+	}
+
+	var direction utils.Vector2D
+	switch {
+	case threat != nil:
+		direction = position.Sub(threat.GetPosition())
+	case prey != nil:
+		direction = prey.GetPosition().Sub(position)
+	default:
+		isFood := func(entity Entity) bool { return entity.Kind == EntityFood || entity.Kind == EntityEjectedMass }
+		if food := g.nearest(position, 1, isFood); len(food) > 0 {
+			direction = food[0].Position.Sub(position)
+		}
+	}
+
+	if direction.LengthSquared() < SAME_SPOT_EPSILON*SAME_SPOT_EPSILON {
+		// Nothing to go for, or right on top of it: wander on.
+		if current := player.Direction(); current != (utils.Vector2D{}) {
+			return PlayerInput{Direction: current}
+		}
+		return PlayerInput{Direction: utils.FromAngle(g.rand.Float64() * 2 * math.Pi)}
+	}
+	return PlayerInput{Direction: direction.Normalize()}
+}
+
+// outsizes reports whether p is big enough to eat other, wherever they
+// are.
+func (g *Game) outsizes(p *Player, other *Player) bool {
+	_, radius := p.circle()
+	_, otherRadius := other.circle()
+	return radius >= g.rules.eatSize*otherRadius
+}
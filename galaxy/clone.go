@@ -0,0 +1,173 @@
+package galaxy
+
+import (
+	"maps"
+	"math/rand/v2"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+// Clone returns an independent copy of the game, to tick ahead of it, such
+// as for a bot to see where a move leads, or to compare a tick with the
+// state before it. The clone has the players, bots, pellets, viruses,
+// ejected mass, bounds, network IDs and randomness of the game, so ticking
+// both the same way plays out the same, and they share no mutable state:
+// ticking or changing one never changes the other.
+//
+// A clone sends nothing to anyone: its players have no connection, it has
+// no spectators, sessions, recorder, event sink, metrics nor OnDeath
+// callback. Randomness is only carried over from the *rand.PCG and
+// *rand.ChaCha8 sources, the ones GameConfig.Seed gives included. A clone
+// of a game drawing from another GameConfig.Rand draws from a new source
+// seeded with Seed instead, and plays out differently.
+func (g *Game) Clone() *Game {
+	g.RLock()
+	defer g.RUnlock()
+
+	config := g.config
+	config.FoodTiers = slices.Clone(config.FoodTiers)
+	config.Regions = slices.Clone(config.Regions)
+	config.MassThresholds = slices.Clone(config.MassThresholds)
+	config.Metrics, config.Events, config.Bans = nil, nil, nil
+
+	clone := &Game{
+		config:  config,
+		players: make(map[uuid.UUID]*Player, len(g.players)),
+		food:    make(map[uuid.UUID]*Food, len(g.food)),
+		viruses: make(map[uuid.UUID]*Virus, len(g.viruses)),
+		ejected: make(map[uuid.UUID]*EjectedMass, len(g.ejected)),
+		resized: g.resized,
+
+		bots:       make(map[uuid.UUID]*Bot, len(g.bots)),
+		spectators: make(map[uuid.UUID]*Spectator),
+		sessions:   make(map[uuid.UUID]uuid.UUID),
+		seed:       g.seed,
+		rules:      g.rules,
+		netIDs:     g.netIDs.clone(),
+		bans:       NewBanList(),
+
+		state:     g.state,
+		lobbyLeft: g.lobbyLeft,
+		announced: g.announced,
+
+		metrics: NopMetrics{},
+		board:   slices.Clone(g.board),
+		sink:    NopEventSink{},
+		leader:  g.leader,
+		closed:  make(chan struct{}),
+	}
+	clone.source = cloneSource(g.source, g.seed)
+	clone.config.Rand = clone.source
+	clone.rand = rand.New(clone.source)
+	clone.dispatcher = clone.newDispatcher()
+	clone.paused.Store(g.paused.Load())
+	clone.sequence.Store(g.sequence.Load())
+	clone.droppedInputs.Store(g.droppedInputs.Load())
+	clone.humanCount.Store(g.humanCount.Load())
+	clone.botCount.Store(g.botCount.Load())
+
+	for id, player := range g.players {
+		clone.players[id] = player.clone(&clone.rules)
+	}
+	for id, bot := range g.bots {
+		clone.bots[id] = &Bot{Player: clone.players[id], thinkIn: bot.thinkIn}
+	}
+	for id, food := range g.food {
+		food := *food
+		clone.food[id] = &food
+	}
+	for id, virus := range g.viruses {
+		virus := *virus
+		clone.viruses[id] = &virus
+	}
+	for id, ejected := range g.ejected {
+		ejected := *ejected
+		clone.ejected[id] = &ejected
+	}
+	clone.rebuildIndex()
+
+	g.statsMutex.Lock()
+	clone.tickStats, clone.tickTotal, clone.worldStats = g.tickStats, g.tickTotal, g.worldStats
+	g.statsMutex.Unlock()
+	return clone
+}
+
+// cloneSource returns a copy of source drawing the same numbers from then
+// on, or a new PCG seeded with seed if source can't be copied.
+func cloneSource(source rand.Source, seed uint64) rand.Source {
+	switch source := source.(type) {
+	case *rand.PCG:
+		clone := *source
+		return &clone
+	case *rand.ChaCha8:
+		clone := *source
+		return &clone
+	default:
+		return rand.NewPCG(seed, seed)
+	}
+}
+
+func (n *netIDs) clone() *netIDs {
+	return &netIDs{
+		ids:      maps.Clone(n.ids),
+		entities: maps.Clone(n.entities),
+		free:     slices.Clone(n.free),
+		next:     n.next,
+		removed:  slices.Clone(n.removed),
+	}
+}
+
+// clone returns a copy of p following rules, for Game.Clone. The copy has
+// no connection, delta encoder nor removals to send, and hasn't said hello.
+func (p *Player) clone(rules *massRules) *Player {
+	p.RLock()
+	defer p.RUnlock()
+
+	clone := &Player{
+		PlayerID:     p.PlayerID,
+		OwnerID:      p.OwnerID,
+		ConnectionID: p.ConnectionID,
+		Position:     p.Position,
+		Velocity:     p.Velocity,
+		Mass:         p.Mass,
+		Radius:       p.Radius,
+		Alive:        p.Alive,
+		Username:     p.Username,
+		TeamID:       p.TeamID,
+		Color:        p.Color,
+
+		direction: p.direction,
+		actions:   p.actions,
+		cooldowns: CooldownTracker{
+			Durations: maps.Clone(p.cooldowns.Durations),
+			readyAt:   maps.Clone(p.cooldowns.readyAt),
+		},
+		clock:         p.clock,
+		throttled:     p.throttled,
+		boostLeft:     p.boostLeft,
+		protection:    p.protection,
+		decayDebt:     p.decayDebt,
+		impulse:       p.impulse,
+		mergeCooldown: p.mergeCooldown,
+		level:         p.level,
+		thresholds:    p.thresholds,
+		splitCells:    p.splitCells,
+		restored:      p.restored,
+
+		generation:     p.generation,
+		disconnectedAt: p.disconnectedAt,
+		lastInput:      p.lastInput,
+		rules:          rules,
+	}
+	if p.Skin != nil {
+		skin := *p.Skin
+		clone.Skin = &skin
+	}
+
+	p.Stats.Lock()
+	clone.Stats.Score, clone.Stats.KilledPlayers = p.Stats.Score, p.Stats.KilledPlayers
+	clone.Stats.TimeStart, clone.Stats.TimeEnd = p.Stats.TimeStart, p.Stats.TimeEnd
+	p.Stats.Unlock()
+	return clone
+}
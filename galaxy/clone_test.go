@@ -0,0 +1,53 @@
+package galaxy
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// steer gives the copy of player in g the input of tick.
+func steer(g *Game, player *Player, tick int) {
+	if player, exists := g.Player(player.PlayerID); exists {
+		player.SetInput(PlayerInput{Direction: utils.FromAngle(float64(tick) / 3)})
+	}
+}
+
+func TestTickingACloneLeavesTheGameAlone(t *testing.T) {
+	config := testConfig()
+	config.VirusCount = 5
+	config.Bots = 3
+	g := newTestGame(t, config)
+	player := joinTestPlayer(t, g, 200, 1000, 1000)
+	for range 5 {
+		g.Tick(time.Second / DEFAULT_TICK_RATE)
+	}
+
+	before := encodedWorld(g)
+	clone := g.Clone()
+	for tick := range 30 {
+		steer(clone, player, tick)
+		clone.Tick(time.Second / DEFAULT_TICK_RATE)
+	}
+
+	if !bytes.Equal(encodedWorld(g), before) {
+		t.Fatal("ticking the clone changed the game")
+	}
+	if bytes.Equal(encodedWorld(clone), before) {
+		t.Fatal("ticking the clone didn't change it")
+	}
+	if moved, _ := clone.Player(player.PlayerID); moved == player {
+		t.Fatal("the clone shares its players with the game")
+	}
+
+	// Ticked the same way, the game catches up with its clone.
+	for tick := range 30 {
+		steer(g, player, tick)
+		g.Tick(time.Second / DEFAULT_TICK_RATE)
+	}
+	if !bytes.Equal(encodedWorld(g), encodedWorld(clone)) {
+		t.Error("the game and its clone diverged ticked the same way")
+	}
+}
@@ -0,0 +1,83 @@
+package galaxy
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+var ErrorGameClosed = fmt.Errorf("Game is closed")
+
+// Close shuts the game down for good: Run returns, every player and
+// spectator is disconnected with CLOSE_GAME_CLOSED and the world, spatial
+// index included, is emptied so nothing of it stays in memory. New
+// connections are refused with ErrorGameClosed. Close is safe to call while
+// the game ticks, only the first call has an effect.
+func (g *Game) Close() {
+	g.closeOnce.Do(func() {
+		close(g.closed)
+
+		g.Lock()
+		var conns []ClientConnection
+		for _, player := range g.players {
+			player.Lock()
+			// Its onClose no longer matches the generation of the player.
+			player.generation++
+			if player.conn != nil {
+				conns = append(conns, player.conn)
+				player.conn = nil
+			}
+			player.Unlock()
+		}
+		for _, spectator := range g.spectators {
+			spectator.Lock()
+			if spectator.conn != nil {
+				conns = append(conns, spectator.conn)
+				spectator.conn = nil
+			}
+			spectator.Unlock()
+		}
+
+		g.players = make(map[uuid.UUID]*Player)
+		g.food = make(map[uuid.UUID]*Food)
+		g.viruses = make(map[uuid.UUID]*Virus)
+		g.ejected = make(map[uuid.UUID]*EjectedMass)
+		g.bots = make(map[uuid.UUID]*Bot)
+		g.spectators = make(map[uuid.UUID]*Spectator)
+		g.humanCount.Store(0)
+		g.botCount.Store(0)
+		g.spectatorCount.Store(0)
+		g.sessions = make(map[uuid.UUID]uuid.UUID)
+		g.netIDs = newNetIDs()
+		g.index = netIndex{SpatialIndex: newIndex(g.config), ids: g.netIDs}
+		g.events = nil
+		g.Unlock()
+
+		for _, conn := range conns {
+			// Closing waits for the client to acknowledge the close frame.
+			go closeWithReason(conn, CLOSE_GAME_CLOSED, "game closed")
+		}
+		log.Printf("room %q shut down, dropping %v connections", g.room, len(conns))
+	})
+}
+
+// Closed reports whether Close was called.
+func (g *Game) Closed() bool {
+	select {
+	case <-g.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// refuseClosed answers requests to a closed game, returning true if it did.
+func (g *Game) refuseClosed(w http.ResponseWriter) bool {
+	if !g.Closed() {
+		return false
+	}
+	http.Error(w, ErrorGameClosed.Error(), http.StatusServiceUnavailable)
+	return true
+}
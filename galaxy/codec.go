@@ -0,0 +1,206 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+var ErrorCodecMismatch = fmt.Errorf("Codec doesn't round-trip")
+
+// StateSnapshot is what an OpStateSnapshot frame tells a client: the
+// entities of its viewport and the network IDs of the ones it was sent that
+// left the game since, see DecodeRemovals.
+type StateSnapshot struct {
+	Header   SnapshotHeader
+	Entities []Entity
+	Removed  []uint32
+}
+
+// SnapshotCodec is the wire format of the payloads of the frames Game
+// exchanges with clients, so teams can speak protobuf, flatbuffers or
+// whatever their clients already use without touching the game. The
+// opcode framing and the handshake stay the same whatever the codec, see
+// EncodeFrame. Codecs must be safe for concurrent use, CheckCodec tells
+// whether one keeps what it encodes.
+type SnapshotCodec interface {
+	// EncodeSnapshot and DecodeSnapshot handle OpStateSnapshot payloads.
+	// Entities only need their Kind, NetID, Position, Radius and Color to
+	// survive the trip, positions within half a unit.
+	EncodeSnapshot(snapshot StateSnapshot) []byte
+	DecodeSnapshot(payload []byte) (StateSnapshot, error)
+
+	// EncodeInput and DecodeInput handle OpInput payloads. DecodeInput
+	// gets whatever clients send, it must reject directions that aren't
+	// finite.
+	EncodeInput(input PlayerInput) []byte
+	DecodeInput(payload []byte) (PlayerInput, error)
+
+	// EncodeDeath and DecodeDeath handle OpDeath payloads, only EatenBy
+	// and Mass are sent, to the player that died.
+	EncodeDeath(death Death) []byte
+	DecodeDeath(payload []byte) (Death, error)
+}
+
+// BinaryCodec is the compact little endian format of the game, the
+// default SnapshotCodec. Snapshots are those of REMOVALS_PROTOCOL_VERSION:
+// the SnapshotHeader, the entities as encodeEntities, or encodeQuantized
+// within Bounds when Quantize is set, and the removals.
+type BinaryCodec struct {
+	Bounds   utils.Rect
+	Quantize bool
+}
+
+func (c BinaryCodec) EncodeSnapshot(snapshot StateSnapshot) []byte {
+	var body []byte
+	if c.Quantize {
+		body = encodeQuantized(snapshot.Entities, c.Bounds)
+	} else {
+		body = encodeEntities(snapshot.Entities)
+	}
+	return encodeSnapshot(snapshot.Header, appendRemovals(body, snapshot.Removed))
+}
+
+func (c BinaryCodec) DecodeSnapshot(payload []byte) (StateSnapshot, error) {
+	header, body, err := DecodeSnapshotHeader(payload)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	body, removed, err := DecodeRemovals(body)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	entities, err := DecodeEntities(body)
+	if err != nil {
+		return StateSnapshot{}, err
+	}
+	return StateSnapshot{Header: header, Entities: entities, Removed: removed}, nil
+}
+
+func (BinaryCodec) EncodeInput(input PlayerInput) []byte {
+	return EncodeInput(input)
+}
+
+func (BinaryCodec) DecodeInput(payload []byte) (PlayerInput, error) {
+	return DecodeInput(payload)
+}
+
+func (BinaryCodec) EncodeDeath(death Death) []byte {
+	return encodeDeath(death)
+}
+
+func (BinaryCodec) DecodeDeath(payload []byte) (Death, error) {
+	if len(payload) < 16+8 {
+		return Death{}, ErrorShortBuffer
+	}
+	return Death{
+		EatenBy: uuid.UUID(payload[:16]),
+		Mass:    binary.LittleEndian.Uint64(payload[16:24]),
+	}, nil
+}
+
+// codec returns the SnapshotCodec of the game, see GameConfig.Codec.
+func (g *Game) codec() SnapshotCodec {
+	if g.config.Codec != nil {
+		return g.config.Codec
+	}
+	return BinaryCodec{Bounds: g.config.Bounds, Quantize: g.config.QuantizePositions}
+}
+
+// encodeState encodes the payload of the OpStateSnapshot frame of a client
+// with the codec of the game. Delta updates and clients older than
+// REMOVALS_PROTOCOL_VERSION, tracked false, only exist in the binary
+// format: body encodes their entities, as encodeViewport.
+func (g *Game) encodeState(header SnapshotHeader, entities []Entity, removed []uint32, tracked bool, body func() []byte) []byte {
+	if g.config.KeyframeInterval <= 0 && tracked {
+		return g.codec().EncodeSnapshot(StateSnapshot{Header: header, Entities: entities, Removed: removed})
+	}
+
+	data := body()
+	if tracked {
+		data = appendRemovals(data, removed)
+	}
+	return encodeSnapshot(header, data)
+}
+
+// CheckCodec reports whether codec decodes what it encodes, as every
+// SnapshotCodec must: run it from the tests of a codec. It returns an
+// error wrapping ErrorCodecMismatch describing the first difference.
+func CheckCodec(codec SnapshotCodec) error {
+	snapshots := []StateSnapshot{
+		{Header: SnapshotHeader{Sequence: 1, Time: time.UnixMilli(1700000000000)}},
+		{
+			Header: SnapshotHeader{Sequence: math.MaxUint32, Time: time.UnixMilli(1700000000123)},
+			Entities: []Entity{
+				{Kind: EntityPlayer, NetID: 1, Position: utils.Vector2D{X: 10.25, Y: 9990.5}, Radius: 42, Color: 0xFF0000},
+				{Kind: EntityFood, NetID: 2, Position: utils.Vector2D{X: 5000, Y: 0}, Radius: 5, Color: 0x00FF00},
+				{Kind: EntityVirus, NetID: math.MaxUint32, Position: worldBounds.Max, Radius: DEFAULT_VIRUS_RADIUS, Color: VIRUS_COLOR},
+				{Kind: EntityEjectedMass, NetID: 7, Position: worldBounds.Min, Radius: EJECT_RADIUS, Color: 0x0000FF},
+			},
+			Removed: []uint32{3, 4, math.MaxUint32},
+		},
+	}
+	for _, snapshot := range snapshots {
+		decoded, err := codec.DecodeSnapshot(codec.EncodeSnapshot(snapshot))
+		if err != nil {
+			return fmt.Errorf("%w: decoding snapshot %d: %w", ErrorCodecMismatch, snapshot.Header.Sequence, err)
+		}
+		if err := compareSnapshots(snapshot, decoded); err != nil {
+			return fmt.Errorf("%w: snapshot %d: %w", ErrorCodecMismatch, snapshot.Header.Sequence, err)
+		}
+	}
+
+	inputs := []PlayerInput{
+		{},
+		{Direction: utils.Vector2D{X: 1}, Actions: ActionSplit},
+		{Direction: utils.FromAngle(2), Actions: ActionSplit | ActionEject | ActionBoost},
+	}
+	for _, input := range inputs {
+		decoded, err := codec.DecodeInput(codec.EncodeInput(input))
+		if err != nil {
+			return fmt.Errorf("%w: decoding input %+v: %w", ErrorCodecMismatch, input, err)
+		}
+		if !decoded.Direction.EqualWithin(input.Direction, 1e-6) || decoded.Actions != input.Actions {
+			return fmt.Errorf("%w: input %+v decoded as %+v", ErrorCodecMismatch, input, decoded)
+		}
+	}
+	nan := PlayerInput{Direction: utils.Vector2D{X: math.NaN(), Y: 1}}
+	if _, err := codec.DecodeInput(codec.EncodeInput(nan)); err == nil {
+		return fmt.Errorf("%w: accepted a direction that isn't finite", ErrorCodecMismatch)
+	}
+
+	death := Death{EatenBy: uuid.MustParse("6f1c1d2e-3a4b-4c5d-8e9f-a0b1c2d3e4f5"), Mass: math.MaxUint64}
+	decoded, err := codec.DecodeDeath(codec.EncodeDeath(death))
+	if err != nil {
+		return fmt.Errorf("%w: decoding death: %w", ErrorCodecMismatch, err)
+	}
+	if decoded.EatenBy != death.EatenBy || decoded.Mass != death.Mass {
+		return fmt.Errorf("%w: death %+v decoded as %+v", ErrorCodecMismatch, death, decoded)
+	}
+	return nil
+}
+
+func compareSnapshots(want StateSnapshot, got StateSnapshot) error {
+	if got.Header.Sequence != want.Header.Sequence || got.Header.Time.UnixMilli() != want.Header.Time.UnixMilli() {
+		return fmt.Errorf("header %+v decoded as %+v", want.Header, got.Header)
+	}
+	if len(got.Entities) != len(want.Entities) {
+		return fmt.Errorf("%d entities decoded as %d", len(want.Entities), len(got.Entities))
+	}
+	for i, entity := range want.Entities {
+		other := got.Entities[i]
+		if other.Kind != entity.Kind || other.NetID != entity.NetID || other.Radius != entity.Radius ||
+			other.Color != entity.Color || !other.Position.EqualWithin(entity.Position, 0.5) {
+			return fmt.Errorf("entity %+v decoded as %+v", entity, other)
+		}
+	}
+	if !slices.Equal(got.Removed, want.Removed) && (len(got.Removed) > 0 || len(want.Removed) > 0) {
+		return fmt.Errorf("removals %v decoded as %v", want.Removed, got.Removed)
+	}
+	return nil
+}
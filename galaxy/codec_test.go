@@ -0,0 +1,72 @@
+package galaxy
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestBinaryCodecContract(t *testing.T) {
+	codecs := map[string]SnapshotCodec{
+		"binary":    BinaryCodec{},
+		"quantized": BinaryCodec{Bounds: worldBounds, Quantize: true},
+	}
+	for name, codec := range codecs {
+		if err := CheckCodec(codec); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// lossyCodec drops the colors of the entities it decodes.
+type lossyCodec struct {
+	BinaryCodec
+}
+
+func (c lossyCodec) DecodeSnapshot(payload []byte) (StateSnapshot, error) {
+	snapshot, err := c.BinaryCodec.DecodeSnapshot(payload)
+	for i := range snapshot.Entities {
+		snapshot.Entities[i].Color = 0
+	}
+	return snapshot, err
+}
+
+func TestCheckCodecCatchesMismatches(t *testing.T) {
+	if err := CheckCodec(lossyCodec{}); !errors.Is(err, ErrorCodecMismatch) {
+		t.Errorf("CheckCodec of a codec losing colors: got %v, want %v", err, ErrorCodecMismatch)
+	}
+}
+
+// taggedCodec appends a tag to the snapshots it encodes.
+type taggedCodec struct {
+	BinaryCodec
+}
+
+func (c taggedCodec) EncodeSnapshot(snapshot StateSnapshot) []byte {
+	return append(c.BinaryCodec.EncodeSnapshot(snapshot), "tagged"...)
+}
+
+func TestGameEncodesWithItsCodec(t *testing.T) {
+	config := testConfig()
+	config.Codec = taggedCodec{}
+	g := newTestGame(t, config)
+	conn := &fakeConn{}
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	player.Lock()
+	player.conn, player.protocol = conn, PROTOCOL_VERSION
+	player.Unlock()
+
+	g.Broadcast()
+
+	sent := conn.sent()
+	if len(sent) != 1 {
+		t.Fatalf("broadcast sent %d frames, want 1", len(sent))
+	}
+	op, payload, err := DecodeFrame(sent[0])
+	if err != nil || op != OpStateSnapshot {
+		t.Fatalf("broadcast sent a %v frame, %v", op, err)
+	}
+	if !bytes.HasSuffix(payload, []byte("tagged")) {
+		t.Error("snapshot not encoded with the codec of the game")
+	}
+}
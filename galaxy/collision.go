@@ -0,0 +1,159 @@
+package galaxy
+
+import (
+	"cmp"
+	"slices"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+const (
+	// EAT_SIZE_RATIO is how many times bigger than its prey a player's
+	// radius must be to eat it.
+	EAT_SIZE_RATIO = 1.1
+
+	// EAT_OVERLAP_RATIO is the fraction of the prey's diameter the eater
+	// must cover to eat it.
+	EAT_OVERLAP_RATIO = 0.33
+)
+
+// circle returns the player's position and radius as a consistent pair.
+func (p *Player) circle() (utils.Vector2D, float64) {
+	p.RLock()
+	defer p.RUnlock()
+	return p.Position, float64(p.Radius)
+}
+
+// Overlaps reports whether the two players' circles intersect. Players that
+// are exactly touching don't overlap.
+func (p *Player) Overlaps(other *Player) bool {
+	position, radius := p.circle()
+	otherPosition, otherRadius := other.circle()
+
+	reach := radius + otherRadius
+	return position.DistanceSquared(otherPosition) < reach*reach
+}
+
+// CanEat reports whether p is big enough to eat other and covers enough of
+// it, using the EatSizeRatio and EatOverlapRatio of its game, by default
+// EAT_SIZE_RATIO and EAT_OVERLAP_RATIO. Players of the same team can't eat
+// each other.
+func (p *Player) CanEat(other *Player) bool {
+	rules := p.massRules()
+	return p.canEat(other, rules.eatSize, rules.eatOverlap)
+}
+
+func (p *Player) canEat(other *Player, sizeRatio float64, overlapRatio float64) bool {
+	if !p.mayEat(other) {
+		return false
+	}
+
+	position, radius := p.circle()
+	otherPosition, otherRadius := other.circle()
+	return covers(position, radius, otherPosition, otherRadius, sizeRatio, overlapRatio)
+}
+
+// canEatAlong is CanEat anywhere along the moves of p and other this tick,
+// which started at from and otherFrom, see GameConfig.SweptEating.
+func (p *Player) canEatAlong(other *Player, from utils.Vector2D, otherFrom utils.Vector2D) bool {
+	if !p.mayEat(other) {
+		return false
+	}
+
+	rules := p.massRules()
+	position, radius := p.circle()
+	otherPosition, otherRadius := other.circle()
+	return sweptCovers(from, position, radius, otherFrom, otherPosition, otherRadius, rules.eatSize, rules.eatOverlap)
+}
+
+// mayEat reports whether p may eat other if big and close enough: cells of
+// the same player merge instead, teammates never eat each other and
+// players under spawn protection can't be eaten, nor eat if it is
+// passive, see GameConfig.SpawnProtection.
+func (p *Player) mayEat(other *Player) bool {
+	if p == other || p.Owner() == other.Owner() {
+		return false
+	}
+	if p.TeamID != 0 && p.TeamID == other.TeamID {
+		return false
+	}
+	if other.Protected() {
+		return false
+	}
+	return !p.Protected() || !p.massRules().passiveProtection
+}
+
+// covers reports whether the first circle is at least sizeRatio times
+// bigger than the other and covers at least overlapRatio of its diameter.
+func covers(position utils.Vector2D, radius float64, otherPosition utils.Vector2D, otherRadius float64, sizeRatio float64, overlapRatio float64) bool {
+	if radius < sizeRatio*otherRadius {
+		return false
+	}
+
+	// Covering overlapRatio of the prey's diameter means the centers are at
+	// most this far apart.
+	reach := radius + otherRadius - 2*otherRadius*overlapRatio
+	if reach < 0 {
+		return false
+	}
+	return position.DistanceSquared(otherPosition) <= reach*reach
+}
+
+// sweptCovers is covers anywhere along the moves of both circles over a
+// tick, from the from positions to the to ones, both moving in a straight
+// line at a constant speed. The radii are those at the end of the tick.
+func sweptCovers(from utils.Vector2D, to utils.Vector2D, radius float64, otherFrom utils.Vector2D, otherTo utils.Vector2D, otherRadius float64, sizeRatio float64, overlapRatio float64) bool {
+	if radius < sizeRatio*otherRadius {
+		return false
+	}
+	reach := radius + otherRadius - 2*otherRadius*overlapRatio
+	if reach < 0 {
+		return false
+	}
+
+	// The other circle moves from start to end as seen from the first one,
+	// find where it comes closest.
+	start, end := otherFrom.Sub(from), otherTo.Sub(to)
+	path := end.Sub(start)
+	closest := end
+	if length := path.LengthSquared(); length > 0 {
+		t := min(max(-start.Dot(path)/length, 0), 1)
+		closest = start.Add(path.Scale(t))
+	}
+	return closest.LengthSquared() <= reach*reach
+}
+
+// sweptArea returns the area covered by a circle moving from from to to,
+// grown by radius.
+func sweptArea(from utils.Vector2D, to utils.Vector2D, radius float64) utils.Rect {
+	return utils.Rect{
+		Min: utils.Vector2D{X: min(from.X, to.X) - radius, Y: min(from.Y, to.Y) - radius},
+		Max: utils.Vector2D{X: max(from.X, to.X) + radius, Y: max(from.Y, to.Y) + radius},
+	}
+}
+
+// startOfTick returns where p was at the start of the tick, players created
+// during it, such as split cells, haven't moved.
+func startOfTick(from map[*Player]utils.Vector2D, p *Player) utils.Vector2D {
+	if position, moved := from[p]; moved {
+		return position
+	}
+	return p.GetPosition()
+}
+
+// eatOrder returns the players of the game in the order their eats
+// resolve: heaviest first, ties by network ID. An overlap then has the same
+// outcome whatever the order players joined in, the biggest player eating
+// whoever it can before any of them eats anyone, and the same state always
+// plays out the same. The caller must hold the lock.
+func (g *Game) eatOrder() []*Player {
+	players := inOrder(g, g.players)
+	mass := make(map[*Player]uint64, len(players))
+	for _, player := range players {
+		mass[player] = player.Score()
+	}
+	slices.SortStableFunc(players, func(a, b *Player) int {
+		return cmp.Compare(mass[b], mass[a])
+	})
+	return players
+}
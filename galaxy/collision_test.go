@@ -0,0 +1,213 @@
+package galaxy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+func TestCanEatBoundaries(t *testing.T) {
+	config := testConfig()
+	config.EatSizeRatio = 1.25
+	config.EatOverlapRatio = 0.5
+	config.SpawnProtection = 0
+	g := newTestGame(t, config)
+
+	eater := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	prey := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	place := func(radius uint32, distance float64) {
+		eater.Lock()
+		eater.Radius = radius
+		eater.Unlock()
+		prey.Lock()
+		prey.Radius = 100
+		prey.Position.X = 1000 + distance
+		prey.Unlock()
+	}
+
+	// An eater of radius 125 covers half of the prey's diameter with the
+	// centers at most 125 + 100 - 100 apart.
+	tests := []struct {
+		name     string
+		radius   uint32
+		distance float64
+		want     bool
+	}{
+		{"exactly big enough, on top", 125, 0, true},
+		{"just too small, on top", 124, 0, false},
+		{"exactly covering enough", 125, 125, true},
+		{"just not covering enough", 125, 125.5, false},
+		{"much bigger, barely covering", 300, 300, true},
+		{"much bigger, touching", 300, 400, false},
+	}
+	for _, test := range tests {
+		place(test.radius, test.distance)
+		if got := eater.CanEat(prey); got != test.want {
+			t.Errorf("%s: CanEat = %v, want %v", test.name, got, test.want)
+		}
+	}
+
+	place(125, 0)
+	if prey.CanEat(eater) {
+		t.Error("the smaller player can eat the bigger one")
+	}
+}
+
+func TestEatRatiosValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    float64
+		overlap float64
+		valid   bool
+	}{
+		{"defaults", 0, 0, true},
+		{"same size", 1, 0.5, true},
+		{"full overlap", 1.1, 1, true},
+		{"smaller than prey", 0.9, 0.5, false},
+		{"negative overlap", 1.1, -0.1, false},
+		{"more than full overlap", 1.1, 1.5, false},
+	}
+	for _, test := range tests {
+		config := testConfig()
+		config.EatSizeRatio, config.EatOverlapRatio = test.size, test.overlap
+		err := config.Validate()
+		if test.valid && err != nil {
+			t.Errorf("%s: Validate() = %v", test.name, err)
+		}
+		if !test.valid && !errors.Is(err, ErrorInvalidConfig) {
+			t.Errorf("%s: Validate() = %v, want %v", test.name, err, ErrorInvalidConfig)
+		}
+	}
+}
+
+// eatConfig is a quiet world with the default eat ratios and no spawn
+// protection.
+func eatConfig() GameConfig {
+	config := quietConfig()
+	config.EatSizeRatio = 1.25
+	config.EatOverlapRatio = 0.5
+	config.SpawnProtection = 0
+	return config
+}
+
+// place moves player to x, y and reindexes it.
+func place(g *Game, player *Player, x, y float64) {
+	g.Lock()
+	player.Lock()
+	player.Position = utils.Vector2D{X: x, Y: y}
+	player.Unlock()
+	g.reindex(player)
+	g.Unlock()
+}
+
+func TestEatingTransfersExactlyTheMassEaten(t *testing.T) {
+	g := newTestGame(t, eatConfig())
+	eater := joinTestPlayer(t, g, 2000, 5000, 5000)
+	prey := joinTestPlayer(t, g, 600, 5000, 5000)
+	if err := g.SplitPlayer(prey.PlayerID); err != nil {
+		t.Fatalf("SplitPlayer: %v", err)
+	}
+	for _, cell := range append(g.cells(prey.PlayerID), prey) {
+		place(g, cell, 5000, 5000)
+	}
+
+	before, preyMass := eater.Score(), prey.Score()
+	for _, cell := range g.cells(prey.PlayerID) {
+		preyMass += cell.Score()
+	}
+	g.Tick(time.Millisecond)
+
+	if prey.IsAlive() {
+		t.Fatal("prey survived")
+	}
+	if gained := eater.Score() - before; gained != preyMass {
+		t.Errorf("eater gained %d, want the %d the prey weighed", gained, preyMass)
+	}
+	var transferred uint64
+	for _, event := range g.events {
+		if event.Type != EventMassTransfer {
+			continue
+		}
+		if event.PlayerID != eater.PlayerID || event.Eaten != prey.PlayerID || event.EatenKind != EntityPlayer {
+			t.Errorf("transfer of %v eating %v (%v), want %v eating the player %v",
+				event.PlayerID, event.Eaten, event.EatenKind, eater.PlayerID, prey.PlayerID)
+		}
+		transferred += event.Mass
+	}
+	if transferred != preyMass {
+		t.Errorf("transfer events carry %d, want %d", transferred, preyMass)
+	}
+}
+
+func TestThreeWayOverlapHasOneWinner(t *testing.T) {
+	// big can eat middle, and middle can eat small, but small is out of
+	// reach of big: big eats middle first, which eats nobody.
+	masses := []uint64{2000, 800, 200}
+	for _, order := range [][]int{{0, 1, 2}, {2, 1, 0}, {1, 2, 0}} {
+		g := newTestGame(t, eatConfig())
+		players := make([]*Player, 3)
+		for i, which := range order {
+			players[which] = joinTestPlayer(t, g, masses[which], 1000+3000*float64(i), 1000)
+		}
+		big, middle, small := players[0], players[1], players[2]
+
+		x := 5000 + float64(big.Radius) - 1
+		place(g, big, 5000, 5000)
+		place(g, middle, x, 5000)
+		place(g, small, x+float64(middle.Radius), 5000)
+		if !big.CanEat(middle) || !middle.CanEat(small) || big.CanEat(small) {
+			t.Fatal("players not placed in a chain")
+		}
+
+		g.Tick(time.Millisecond)
+		alive := slices.DeleteFunc(slices.Clone(players), func(p *Player) bool { return !p.IsAlive() })
+		if len(alive) != 2 || alive[0] != big || alive[1] != small {
+			t.Fatalf("joining in order %v, %v are alive, want the big and small players", order, names(players, alive))
+		}
+		if big.Score() != 2800 || small.Score() != 200 {
+			t.Errorf("joining in order %v, big and small players weigh %d and %d, want 2800 and 200",
+				order, big.Score(), small.Score())
+		}
+	}
+}
+
+// names returns which of big, middle and small players are.
+func names(all []*Player, players []*Player) []string {
+	var names []string
+	for _, player := range players {
+		names = append(names, []string{"big", "middle", "small"}[slices.Index(all, player)])
+	}
+	return names
+}
+
+func TestSweptEatingCatchesPassThrough(t *testing.T) {
+	// passThrough sends a small player across a big one within a single
+	// tick, and returns whether it is still alive and where it ended up.
+	passThrough := func(swept bool) (bool, utils.Vector2D) {
+		config := eatConfig()
+		config.SweptEating = swept
+		g := newTestGame(t, config)
+		eater := joinTestPlayer(t, g, 5000, 5000, 5000)
+		prey := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+
+		gap := float64(eater.Radius+prey.Radius) + 10
+		place(g, prey, 5000-gap, 5000)
+		prey.SetDirection(utils.Vector2D{X: 1})
+		g.Tick(time.Duration(2 * gap / prey.MaxSpeed() * float64(time.Second)))
+		return prey.IsAlive(), prey.GetPosition()
+	}
+
+	alive, position := passThrough(false)
+	if !alive {
+		t.Fatal("prey eaten without swept eating, the pass-through isn't one")
+	}
+	if position.X <= 5000 {
+		t.Fatalf("prey stopped at %v, before passing the eater", position)
+	}
+	if alive, _ := passThrough(true); alive {
+		t.Error("prey passed through the eater unharmed with swept eating")
+	}
+}
@@ -1,6 +1,7 @@
 package galaxy
 
 import (
+	"fmt"
 	"net/http"
 	pb "galaxy.io/server/proto"
 )
@@ -8,10 +9,172 @@ import (
 type ClientConnection interface {
 	SendEvent(event *pb.Event) error
 
+	// SendBinary sends an already encoded frame, used by Game. The
+	// connection may keep data until it is written, so callers must not
+	// modify or reuse it afterwards. Game never reuses a sent frame.
+	// ErrorFrameDropped doesn't mean data wasn't sent.
+	SendBinary(data []byte) error
+
 	Close()
 }
 
+// SaturatedConnection is implemented by connections that can tell when
+// their client isn't keeping up, Game then skips its state updates until
+// it catches up.
+type SaturatedConnection interface {
+	Saturated() bool
+}
+
+// UrgentSender is implemented by connections that can send a frame ahead
+// of the state updates queued before it, and keep sending such frames to
+// clients that aren't keeping up with state updates.
+type UrgentSender interface {
+	SendUrgent(data []byte) error
+}
+
+// sendUrgent sends data to conn ahead of other frames if it supports it.
+func sendUrgent(conn ClientConnection, data []byte) error {
+	if sender, ok := conn.(UrgentSender); ok {
+		return sender.SendUrgent(data)
+	}
+	return conn.SendBinary(data)
+}
+
+// Websocket close codes of the game, from the range reserved for
+// applications.
+const (
+	// CLOSE_IDLE closes players disconnected for being idle.
+	CLOSE_IDLE = 4000
+
+	// CLOSE_REPLACED closes players kicked by another one joining with the
+	// same ID, CLOSE_DUPLICATE players rejected for it.
+	CLOSE_REPLACED  = 4001
+	CLOSE_DUPLICATE = 4002
+
+	// CLOSE_KICKED closes players an admin kicked, CLOSE_BANNED clients
+	// rejected by the BanList.
+	CLOSE_KICKED = 4003
+	CLOSE_BANNED = 4004
+
+	// CLOSE_UNSUPPORTED_VERSION closes clients speaking a protocol version
+	// too old, or not starting with an OpHello frame.
+	CLOSE_UNSUPPORTED_VERSION = 4005
+
+	// CLOSE_ROOM_FULL closes players losing the race for the last seat of
+	// a game, see GameConfig.MaxPlayers.
+	CLOSE_ROOM_FULL = 4006
+
+	// CLOSE_TARGET_GONE closes observers once the player they watch died
+	// or left, see Game.Observe.
+	CLOSE_TARGET_GONE = 4007
+
+	// CLOSE_GAME_CLOSED closes every client of a game shut down with
+	// Game.Close.
+	CLOSE_GAME_CLOSED = 4008
+
+	// CLOSE_HEARTBEAT closes players whose client stopped echoing
+	// heartbeats, see GameConfig.HeartbeatTimeout.
+	CLOSE_HEARTBEAT = 4009
+)
+
+// ReasonCloser is implemented by connections that can tell their client why
+// they are closed.
+type ReasonCloser interface {
+	CloseWithReason(code int, reason string)
+}
+
+// closeWithReason closes conn, with code and reason if it supports them.
+func closeWithReason(conn ClientConnection, code int, reason string) {
+	if closer, ok := conn.(ReasonCloser); ok {
+		closer.CloseWithReason(code, reason)
+		return
+	}
+	conn.Close()
+}
+
+func isSaturated(conn ClientConnection) bool {
+	saturated, ok := conn.(SaturatedConnection)
+	return ok && saturated.Saturated()
+}
+
+// ErrorNotWebSocket is wrapped by the errors of ConnectionFactory when the
+// request isn't a websocket handshake at all, such as a browser or a
+// health check getting the endpoint. The factory already answered it.
+var ErrorNotWebSocket = fmt.Errorf("Not a websocket request")
+
+// ErrorFrameDropped is returned when sending a frame made a connection
+// that isn't keeping up discard an older queued one to make room. The frame
+// itself was queued, so it isn't a failure: callers only interested in
+// whether the connection is still usable ignore it.
+var ErrorFrameDropped = fmt.Errorf("Older frame dropped to make room")
+
+// CloseReason tells why a connection closed, see ConnectionFactory.
+type CloseReason int
+
+const (
+	// CloseNormal is a connection closed on purpose, by the server with
+	// Close or by the peer with a close frame.
+	CloseNormal CloseReason = iota
+
+	// CloseKicked is a connection the server closed with a close code,
+	// such as CLOSE_IDLE or CLOSE_KICKED, see ReasonCloser.
+	CloseKicked
+
+	// CloseIdle is a connection whose peer stopped answering pings in
+	// time.
+	CloseIdle
+
+	// CloseNetworkError is a connection that failed to read or write.
+	CloseNetworkError
+
+	// CloseOverflow is a connection closed for not keeping up with what
+	// was sent to it.
+	CloseOverflow
+
+	// CloseRateLimited is a connection closed for sending faster than its
+	// rate limit.
+	CloseRateLimited
+
+	// CloseTimeout is a connection closed because handling one of its
+	// messages took too long.
+	CloseTimeout
+
+	// CloseInternal is a connection closed because handling one of its
+	// messages failed on the server.
+	CloseInternal
+)
+
+func (r CloseReason) String() string {
+	switch r {
+	case CloseNormal:
+		return "closed"
+	case CloseKicked:
+		return "kicked"
+	case CloseIdle:
+		return "timed out"
+	case CloseNetworkError:
+		return "network error"
+	case CloseOverflow:
+		return "send buffer overflow"
+	case CloseRateLimited:
+		return "rate limited"
+	case CloseTimeout:
+		return "handler timed out"
+	case CloseInternal:
+		return "internal error"
+	default:
+		return fmt.Sprintf("CloseReason(%d)", int(r))
+	}
+}
 
 type ConnectionFactory interface {
-	NewConnection(w http.ResponseWriter, r *http.Request, operationHandler func(*pb.Operation)) (ClientConnection, error)
+	// NewConnection upgrades the request, delivering every operation to
+	// operationHandler and calling onClose once the connection is gone
+	// with why, along with the error that caused it, nil for clean
+	// closes.
+	NewConnection(w http.ResponseWriter, r *http.Request, operationHandler func(*pb.Operation), onClose func(reason CloseReason, err error)) (ClientConnection, error)
+
+	// NewFrameConnection upgrades the request like NewConnection, but
+	// delivers every message as is to frameHandler, used by Game.
+	NewFrameConnection(w http.ResponseWriter, r *http.Request, frameHandler func([]byte), onClose func(reason CloseReason, err error)) (ClientConnection, error)
 }
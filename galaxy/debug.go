@@ -0,0 +1,102 @@
+package galaxy
+
+import (
+	"encoding/json"
+	"log"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// DEBUG_SUBPROTOCOL is the websocket subprotocol debugging clients
+// negotiate to receive frames as JSON text instead of binary. JSON frames
+// are many times bigger, it is a development aid only.
+const DEBUG_SUBPROTOCOL = "galaxy.debug"
+
+// DebugConnection is implemented by connections that can send text frames.
+// Debug reports whether the client negotiated DEBUG_SUBPROTOCOL, Game then
+// sends it JSON frames, see debugFrame.
+type DebugConnection interface {
+	Debug() bool
+	SendText(text string) error
+}
+
+// debugFrame is the JSON form of the frames sent to debugging clients. Op
+// is the name of the opcode, the field matching it is set.
+type debugFrame struct {
+	Op        string          `json:"op"`
+	Header    *SnapshotHeader `json:"header,omitempty"`
+	Entities  []Entity        `json:"entities,omitempty"`
+	Removed   []uint32        `json:"removed,omitempty"`
+	Death     *Death          `json:"death,omitempty"`
+	Session   *uuid.UUID      `json:"session,omitempty"`
+	Countdown *int            `json:"countdown,omitempty"`
+	Bounds    *utils.Rect     `json:"bounds,omitempty"`
+	HUD       *HUD            `json:"hud,omitempty"`
+	Heartbeat *Heartbeat      `json:"heartbeat,omitempty"`
+
+	// Interval is in microseconds.
+	Interval *int64 `json:"interval,omitempty"`
+
+	Leaderboard []LeaderboardEntry `json:"leaderboard,omitempty"`
+}
+
+func (f debugFrame) encode() string {
+	data, err := json.Marshal(f)
+	if err != nil {
+		log.Printf("error encoding debug frame: %v", err)
+		return ""
+	}
+	return string(data)
+}
+
+func isDebug(conn ClientConnection) bool {
+	debug, ok := conn.(DebugConnection)
+	return ok && debug.Debug()
+}
+
+// sendText sends text to conn if it is a debugging connection.
+func sendText(conn ClientConnection, text string) error {
+	if debug, ok := conn.(DebugConnection); ok && debug.Debug() {
+		return debug.SendText(text)
+	}
+	return nil
+}
+
+// debug reports whether the client of p wants JSON frames.
+func (p *Player) debug() bool {
+	p.RLock()
+	conn := p.conn
+	p.RUnlock()
+	return isDebug(conn)
+}
+
+func (p *Player) sendText(text string) error {
+	p.RLock()
+	conn := p.conn
+	p.RUnlock()
+
+	if conn == nil {
+		return nil
+	}
+	return sendText(conn, text)
+}
+
+// debug reports whether the client of s wants JSON frames.
+func (s *Spectator) debug() bool {
+	s.Lock()
+	conn := s.conn
+	s.Unlock()
+	return isDebug(conn)
+}
+
+func (s *Spectator) sendText(text string) error {
+	s.Lock()
+	conn := s.conn
+	s.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return sendText(conn, text)
+}
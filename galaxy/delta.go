@@ -0,0 +1,302 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"math"
+	"slices"
+)
+
+const (
+	// DELTA_VERSION is the first byte of every encoded delta.
+	DELTA_VERSION = 2
+
+	// deltaKeyframe flags a delta that replaces the whole state of the
+	// client instead of patching it.
+	deltaKeyframe = 1 << 0
+)
+
+// Change mask bits, telling which fields of an entity follow its network
+// ID.
+const (
+	deltaKind = 1 << iota
+	deltaX
+	deltaY
+	deltaRadius
+	deltaColor
+
+	deltaAll = deltaKind | deltaX | deltaY | deltaRadius | deltaColor
+)
+
+// DeltaEncoder encodes successive entity lists sent to a client as the
+// differences with the previous one:
+// version (1) | flags (1) | removed uint32 (4) | removed network IDs
+// uint32 (4 each) | changed uint32 (4) | changed entities.
+// Every changed entity is its network ID (4), a change mask (1) and then the
+// fields set in the mask, in the order kind (1), x float32 (4),
+// y float32 (4), radius uint32 (4) and color uint32 (4), all little endian.
+// A keyframe has every entity with all its fields and no removals.
+// DeltaEncoder isn't safe for concurrent use.
+type DeltaEncoder struct {
+	previous map[uint32]Entity
+
+	// keyframeInterval is the number of deltas between keyframes, 0 only
+	// sends the first one.
+	keyframeInterval int
+	sinceKeyframe    int
+	forceKeyframe    bool
+}
+
+// NewDeltaEncoder creates an encoder sending a keyframe first and then
+// every keyframeInterval deltas, so desynced clients eventually recover.
+func NewDeltaEncoder(keyframeInterval int) *DeltaEncoder {
+	return &DeltaEncoder{
+		previous:         make(map[uint32]Entity),
+		keyframeInterval: keyframeInterval,
+		forceKeyframe:    true,
+	}
+}
+
+// ForceKeyframe makes the next Encode send the whole state, for clients
+// that lost track of it.
+func (e *DeltaEncoder) ForceKeyframe() {
+	e.forceKeyframe = true
+}
+
+// Encode returns the delta between the last encoded entities and entities.
+func (e *DeltaEncoder) Encode(entities []Entity) []byte {
+	keyframe := e.forceKeyframe || (e.keyframeInterval > 0 && e.sinceKeyframe >= e.keyframeInterval)
+	current := make(map[uint32]Entity, len(entities))
+	for _, entity := range entities {
+		current[entity.NetID] = entity
+	}
+
+	var data []byte
+	if keyframe {
+		data = appendKeyframe(nil, entities)
+		e.forceKeyframe = false
+		e.sinceKeyframe = 0
+	} else {
+		data = appendDelta(nil, e.previous, entities, current)
+		e.sinceKeyframe++
+	}
+
+	e.previous = current
+	return data
+}
+
+func appendKeyframe(data []byte, entities []Entity) []byte {
+	data = append(data, DELTA_VERSION, deltaKeyframe)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(entities)))
+	for _, entity := range entities {
+		data = entity.appendDelta(data, deltaAll)
+	}
+	return data
+}
+
+// appendDelta appends the delta from previous to entities, indexed by
+// network ID in current. Removals are sorted and changes follow the order
+// of entities, so the same states always encode to the same bytes.
+func appendDelta(data []byte, previous map[uint32]Entity, entities []Entity, current map[uint32]Entity) []byte {
+	data = append(data, DELTA_VERSION, 0)
+
+	var removed []uint32
+	for id := range previous {
+		if _, exists := current[id]; !exists {
+			removed = append(removed, id)
+		}
+	}
+	slices.Sort(removed)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(removed)))
+	for _, id := range removed {
+		data = binary.LittleEndian.AppendUint32(data, id)
+	}
+
+	// The count is only known after comparing, patch it in afterwards.
+	countAt := len(data)
+	data = binary.LittleEndian.AppendUint32(data, 0)
+	var changed uint32
+	for _, entity := range entities {
+		mask := byte(deltaAll)
+		if old, exists := previous[entity.NetID]; exists {
+			mask = entity.changes(old)
+		}
+		if mask == 0 {
+			continue
+		}
+		data = entity.appendDelta(data, mask)
+		changed++
+	}
+	binary.LittleEndian.PutUint32(data[countAt:], changed)
+	return data
+}
+
+// changes returns the mask of the fields of e that differ from old, as
+// they are encoded.
+func (e Entity) changes(old Entity) byte {
+	var mask byte
+	if e.Kind != old.Kind {
+		mask |= deltaKind
+	}
+	if float32(e.Position.X) != float32(old.Position.X) {
+		mask |= deltaX
+	}
+	if float32(e.Position.Y) != float32(old.Position.Y) {
+		mask |= deltaY
+	}
+	if e.Radius != old.Radius {
+		mask |= deltaRadius
+	}
+	if e.Color != old.Color {
+		mask |= deltaColor
+	}
+	return mask
+}
+
+func (e Entity) appendDelta(data []byte, mask byte) []byte {
+	data = binary.LittleEndian.AppendUint32(data, e.NetID)
+	data = append(data, mask)
+	if mask&deltaKind != 0 {
+		data = append(data, byte(e.Kind))
+	}
+	if mask&deltaX != 0 {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(e.Position.X)))
+	}
+	if mask&deltaY != 0 {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(e.Position.Y)))
+	}
+	if mask&deltaRadius != 0 {
+		data = binary.LittleEndian.AppendUint32(data, e.Radius)
+	}
+	if mask&deltaColor != 0 {
+		data = binary.LittleEndian.AppendUint32(data, e.Color)
+	}
+	return data
+}
+
+// DeltaDecoder rebuilds the entities seen by a client from the deltas of a
+// DeltaEncoder. It isn't safe for concurrent use.
+type DeltaDecoder struct {
+	entities map[uint32]Entity
+}
+
+func NewDeltaDecoder() *DeltaDecoder {
+	return &DeltaDecoder{
+		entities: make(map[uint32]Entity),
+	}
+}
+
+// Apply updates the entities with an encoded delta. A malformed delta
+// leaves the entities untouched, the client should wait for a keyframe.
+func (d *DeltaDecoder) Apply(data []byte) error {
+	if len(data) < 2 {
+		return ErrorShortBuffer
+	}
+	if data[0] != DELTA_VERSION {
+		return ErrorUnsupportedFormat
+	}
+
+	entities := make(map[uint32]Entity, len(d.entities))
+	if data[1]&deltaKeyframe == 0 {
+		for id, entity := range d.entities {
+			entities[id] = entity
+		}
+	}
+	data = data[2:]
+
+	removed, data, err := readCount(data, 4)
+	if err != nil {
+		return err
+	}
+	for range removed {
+		delete(entities, binary.LittleEndian.Uint32(data))
+		data = data[4:]
+	}
+
+	changed, data, err := readCount(data, 5)
+	if err != nil {
+		return err
+	}
+	for range changed {
+		if len(data) < 5 {
+			return ErrorShortBuffer
+		}
+		id, mask := binary.LittleEndian.Uint32(data), data[4]
+		entity := entities[id]
+		entity.NetID = id
+		if data, err = entity.decodeDelta(data[5:], mask); err != nil {
+			return err
+		}
+		entities[id] = entity
+	}
+
+	d.entities = entities
+	return nil
+}
+
+// readCount reads a uint32 count of items of at least size bytes, checking
+// they can all be in data.
+func readCount(data []byte, size uint64) (uint32, []byte, error) {
+	if len(data) < 4 {
+		return 0, nil, ErrorShortBuffer
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	if uint64(len(data)) < uint64(count)*size {
+		return 0, nil, ErrorShortBuffer
+	}
+	return count, data, nil
+}
+
+func (e *Entity) decodeDelta(data []byte, mask byte) ([]byte, error) {
+	size := 0
+	if mask&deltaKind != 0 {
+		size++
+	}
+	for _, bit := range []byte{deltaX, deltaY, deltaRadius, deltaColor} {
+		if mask&bit != 0 {
+			size += 4
+		}
+	}
+	if len(data) < size {
+		return nil, ErrorShortBuffer
+	}
+
+	if mask&deltaKind != 0 {
+		e.Kind = EntityKind(data[0])
+		data = data[1:]
+	}
+	if mask&deltaX != 0 {
+		e.Position.X = float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+		data = data[4:]
+	}
+	if mask&deltaY != 0 {
+		e.Position.Y = float64(math.Float32frombits(binary.LittleEndian.Uint32(data)))
+		data = data[4:]
+	}
+	if mask&deltaRadius != 0 {
+		e.Radius = binary.LittleEndian.Uint32(data)
+		data = data[4:]
+	}
+	if mask&deltaColor != 0 {
+		e.Color = binary.LittleEndian.Uint32(data)
+		data = data[4:]
+	}
+	return data, nil
+}
+
+// Entities returns the current entities, in no particular order.
+func (d *DeltaDecoder) Entities() []Entity {
+	entities := make([]Entity, 0, len(d.entities))
+	for _, entity := range d.entities {
+		entities = append(entities, entity)
+	}
+	return entities
+}
+
+// Entity returns the entity with the given network ID, if the client
+// knows it.
+func (d *DeltaDecoder) Entity(id uint32) (Entity, bool) {
+	entity, exists := d.entities[id]
+	return entity, exists
+}
@@ -0,0 +1,135 @@
+package galaxy
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// mutator adds, moves and removes entities at random, like a viewport
+// changing between state updates.
+type mutator struct {
+	random   *rand.Rand
+	entities []Entity
+	nextID   uint32
+}
+
+func newMutator(seed uint64) *mutator {
+	return &mutator{random: rand.New(rand.NewPCG(seed, seed))}
+}
+
+// position returns a random position, exact in float32 like the encoded
+// ones.
+func (m *mutator) position() utils.Vector2D {
+	return utils.Vector2D{
+		X: float64(float32(m.random.Float64() * WORLD_WIDTH)),
+		Y: float64(float32(m.random.Float64() * WORLD_HEIGHT)),
+	}
+}
+
+func (m *mutator) step() []Entity {
+	kept := m.entities[:0]
+	for _, entity := range m.entities {
+		switch m.random.IntN(10) {
+		case 0:
+			continue
+		case 1, 2:
+			entity.Position = m.position()
+		case 3:
+			entity.Radius = m.random.Uint32N(500)
+		case 4:
+			entity.Color = m.random.Uint32()
+		}
+		kept = append(kept, entity)
+	}
+	m.entities = kept
+	for range m.random.IntN(8) {
+		m.nextID++
+		m.entities = append(m.entities, Entity{
+			Kind:     EntityKind(1 + m.random.IntN(4)),
+			NetID:    m.nextID,
+			Position: m.position(),
+			Radius:   m.random.Uint32N(500),
+			Color:    m.random.Uint32(),
+		})
+	}
+	return slices.Clone(m.entities)
+}
+
+func byNetID(entities []Entity) []Entity {
+	entities = slices.Clone(entities)
+	slices.SortFunc(entities, func(a, b Entity) int { return int(a.NetID) - int(b.NetID) })
+	return entities
+}
+
+func TestDeltaRoundTrip(t *testing.T) {
+	mutator := newMutator(1)
+	encoder := NewDeltaEncoder(10)
+	decoder := NewDeltaDecoder()
+
+	for i := range 500 {
+		entities := mutator.step()
+		if err := decoder.Apply(encoder.Encode(entities)); err != nil {
+			t.Fatalf("step %d: Apply: %v", i, err)
+		}
+		if got, want := byNetID(decoder.Entities()), byNetID(entities); !slices.Equal(got, want) {
+			t.Fatalf("step %d: decoded %d entities differing from the %d encoded", i, len(got), len(want))
+		}
+	}
+}
+
+func TestDeltaEncodingIsDeterministic(t *testing.T) {
+	first, second := newMutator(2), newMutator(2)
+	firstEncoder, secondEncoder := NewDeltaEncoder(0), NewDeltaEncoder(0)
+
+	for i := range 100 {
+		a, b := firstEncoder.Encode(first.step()), secondEncoder.Encode(second.step())
+		if !bytes.Equal(a, b) {
+			t.Fatalf("step %d: the same states encoded to different deltas", i)
+		}
+	}
+}
+
+func TestKeyframesResyncDecoders(t *testing.T) {
+	// lose applies every delta of encoder but the second one, calling
+	// resync before every step, and checks the decoder catches up.
+	lose := func(encoder *DeltaEncoder, resync func(step int)) {
+		t.Helper()
+
+		mutator := newMutator(3)
+		decoder := NewDeltaDecoder()
+		var entities []Entity
+		for i := range 20 {
+			resync(i)
+			entities = mutator.step()
+			data := encoder.Encode(entities)
+			if i == 1 {
+				continue
+			}
+			if err := decoder.Apply(data); err != nil {
+				t.Fatalf("step %d: Apply: %v", i, err)
+			}
+			if i == 2 && slices.Equal(byNetID(decoder.Entities()), byNetID(entities)) {
+				t.Fatal("decoder in sync despite the lost delta")
+			}
+		}
+		if !slices.Equal(byNetID(decoder.Entities()), byNetID(entities)) {
+			t.Error("decoder still out of sync after a keyframe")
+		}
+	}
+
+	t.Run("forced", func(t *testing.T) {
+		encoder := NewDeltaEncoder(0)
+		lose(encoder, func(step int) {
+			if step == 10 {
+				encoder.ForceKeyframe()
+			}
+		})
+	})
+	t.Run("interval", func(t *testing.T) {
+		lose(NewDeltaEncoder(8), func(int) {})
+	})
+}
@@ -0,0 +1,64 @@
+package galaxy
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Dispatcher routes the frames received from players to the function
+// registered for their opcode. Empty frames and frames with an opcode
+// nothing handles are counted and dropped, without logging since a client
+// can send one every frame. It is safe for concurrent use.
+type Dispatcher struct {
+	mutex    sync.RWMutex
+	handlers map[Opcode]func(player *Player, payload []byte)
+	dropped  atomic.Uint64
+}
+
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[Opcode]func(player *Player, payload []byte)),
+	}
+}
+
+// Handle registers fn for the frames with opcode op, replacing the one
+// registered before. The payload shares memory with the received frame.
+func (d *Dispatcher) Handle(op Opcode, fn func(player *Player, payload []byte)) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.handlers[op] = fn
+}
+
+// MessageHandler returns the function to hand the frames received from
+// player to, see Dispatch.
+func (d *Dispatcher) MessageHandler(player *Player) func(frame []byte) {
+	return func(frame []byte) {
+		d.Dispatch(player, frame)
+	}
+}
+
+// Dispatch decodes frame and calls the function registered for its opcode.
+// Malformed frames and frames nothing is registered for are dropped.
+func (d *Dispatcher) Dispatch(player *Player, frame []byte) {
+	if len(frame) < 1 {
+		d.dropped.Add(1)
+		return
+	}
+
+	op, payload := Opcode(frame[0]), frame[1:]
+	d.mutex.RLock()
+	fn, exists := d.handlers[op]
+	d.mutex.RUnlock()
+
+	if !exists {
+		d.dropped.Add(1)
+		return
+	}
+	fn(player, payload)
+}
+
+// Dropped returns how many frames were dropped because they were empty or
+// nothing handles their opcode.
+func (d *Dispatcher) Dropped() uint64 {
+	return d.dropped.Load()
+}
@@ -0,0 +1,36 @@
+package galaxy
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestDispatcherRoutesByOpcode(t *testing.T) {
+	dispatcher := NewDispatcher()
+	var inputs, heartbeats [][]byte
+	dispatcher.Handle(OpInput, func(player *Player, payload []byte) {
+		inputs = append(inputs, payload)
+	})
+	dispatcher.Handle(OpHeartbeat, func(player *Player, payload []byte) {
+		heartbeats = append(heartbeats, payload)
+	})
+
+	player := NewPlayer(uuid.New(), nil)
+	handle := dispatcher.MessageHandler(player)
+	handle(EncodeFrame(OpInput, []byte("input")))
+	handle(EncodeFrame(OpHeartbeat, []byte("beat")))
+	handle(EncodeFrame(OpRespawn, nil))
+	handle(nil)
+
+	if len(inputs) != 1 || string(inputs[0]) != "input" {
+		t.Errorf("input handler got %q, want one %q", inputs, "input")
+	}
+	if len(heartbeats) != 1 || string(heartbeats[0]) != "beat" {
+		t.Errorf("heartbeat handler got %q, want one %q", heartbeats, "beat")
+	}
+	// The respawn nothing handles and the empty frame.
+	if dropped := dispatcher.Dropped(); dropped != 2 {
+		t.Errorf("Dropped() = %d, want 2", dropped)
+	}
+}
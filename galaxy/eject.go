@@ -0,0 +1,121 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// EJECT_MASS is the mass a player shoots at once.
+	EJECT_MASS = 4
+
+	// MIN_EJECT_MASS is the smallest mass a player can eject at.
+	MIN_EJECT_MASS = STARTING_MASS + 2*EJECT_MASS
+
+	// EJECT_SPEED is the initial speed of ejected mass, in world units per
+	// second.
+	EJECT_SPEED = 1500
+
+	// EJECT_DAMPING is how fast ejected mass slows down, a rate per second,
+	// stopping it within a few ticks.
+	EJECT_DAMPING = 8
+
+	// EJECT_RADIUS is the size ejected mass is drawn and eaten with.
+	EJECT_RADIUS = MASS_TO_RADIUS_K * 2
+)
+
+var ErrorEjectTooSmall = fmt.Errorf("Player is too small to eject mass")
+
+// EjectedMass is a blob of mass shot by a player. It flies forward, slows
+// down and is then eaten like food by anyone.
+type EjectedMass struct {
+	ID       uuid.UUID
+	Position utils.Vector2D
+	Velocity utils.Vector2D
+	Mass     uint64
+	Color    uint32
+}
+
+// Eject takes EJECT_MASS from p and shoots it in the direction dir, or
+// the direction p is moving in when dir is zero.
+func (p *Player) Eject(dir utils.Vector2D) (*EjectedMass, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.Mass < MIN_EJECT_MASS {
+		return nil, ErrorEjectTooSmall
+	}
+
+	dir = dir.Normalize()
+	if dir == (utils.Vector2D{}) {
+		dir = p.direction.Normalize()
+	}
+	if dir == (utils.Vector2D{}) {
+		dir = utils.Vector2D{X: 1}
+	}
+
+	p.Mass -= EJECT_MASS
+	p.recomputeRadius()
+
+	// Start outside of p so it doesn't eat it right back.
+	return &EjectedMass{
+		ID:       uuid.New(),
+		Position: p.Position.Add(dir.Scale(float64(p.Radius) + EJECT_RADIUS)),
+		Velocity: dir.Scale(EJECT_SPEED),
+		Mass:     EJECT_MASS,
+		Color:    p.Color,
+	}, nil
+}
+
+func (e *EjectedMass) entity() Entity {
+	return Entity{
+		Kind:     EntityEjectedMass,
+		ID:       e.ID,
+		Position: e.Position,
+		Radius:   EJECT_RADIUS,
+		Color:    e.Color,
+	}
+}
+
+// move advances e by dt, slowing it down and bouncing it off the edges of
+// the world.
+func (e *EjectedMass) move(dt time.Duration, bounds arena) {
+	if e.Velocity == (utils.Vector2D{}) {
+		return
+	}
+
+	// Nothing speeds ejected mass up, it only slows down from EJECT_SPEED.
+	e.Velocity = e.Velocity.ClampLength(EJECT_SPEED)
+	e.Position, e.Velocity = bounds.Bounce(e.Position.Add(e.Velocity.Scale(dt.Seconds())), e.Velocity)
+	e.Velocity = e.Velocity.Scale(math.Exp(-EJECT_DAMPING * dt.Seconds()))
+	if e.Velocity.LengthSquared() < 1 {
+		e.Velocity = utils.Vector2D{}
+	}
+}
+
+// eject makes player and its split cells eject mass in the direction
+// player is moving in, the caller must hold the lock.
+func (g *Game) eject(player *Player) {
+	dir := player.Direction()
+	for _, cell := range append([]*Player{player}, g.cells(player.PlayerID)...) {
+		ejected, err := cell.Eject(dir)
+		if err != nil {
+			continue
+		}
+
+		ejected.ID = g.newID()
+		ejected.Position = g.arena().Clamp(ejected.Position)
+		g.ejected[ejected.ID] = ejected
+		g.index.Insert(ejected.ID, ejected.Position, EJECT_RADIUS)
+		g.reindex(cell)
+	}
+}
+
+func (g *Game) removeEjected(id uuid.UUID) {
+	delete(g.ejected, id)
+	g.index.Remove(id)
+}
@@ -0,0 +1,157 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// PLAYER_STATE_VERSION is the first byte of every encoded player state,
+	// bump it whenever the layout changes.
+	PLAYER_STATE_VERSION = 1
+
+	// PLAYER_STATE_SIZE is the encoded size of a player state:
+	// version (1) | player ID (16) | x float32 (4) | y float32 (4) |
+	// radius uint32 (4) | color uint32 (4), all little endian.
+	PLAYER_STATE_SIZE = 1 + 16 + 4 + 4 + 4 + 4
+
+	// SNAPSHOT_VERSION is the first byte of every encoded entity list.
+	SNAPSHOT_VERSION = 2
+
+	// ENTITY_SIZE is the encoded size of an entity:
+	// kind (1) | network ID uint32 (4) | x float32 (4) | y float32 (4) |
+	// radius uint32 (4) | color uint32 (4), all little endian.
+	ENTITY_SIZE = 1 + 4 + 4 + 4 + 4 + 4
+)
+
+var (
+	ErrorShortBuffer       = fmt.Errorf("Buffer too short")
+	ErrorUnsupportedFormat = fmt.Errorf("Unsupported format version")
+)
+
+// MarshalBinary encodes the player state in the fixed layout described by
+// PLAYER_STATE_SIZE.
+func (s PlayerSnapshot) MarshalBinary() ([]byte, error) {
+	return s.appendBinary(make([]byte, 0, PLAYER_STATE_SIZE)), nil
+}
+
+func (s PlayerSnapshot) appendBinary(data []byte) []byte {
+	data = append(data, PLAYER_STATE_VERSION)
+	data = append(data, s.PlayerID[:]...)
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(s.Position.X)))
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(s.Position.Y)))
+	data = binary.LittleEndian.AppendUint32(data, s.Radius)
+	data = binary.LittleEndian.AppendUint32(data, s.Color)
+	return data
+}
+
+func (s *PlayerSnapshot) UnmarshalBinary(data []byte) error {
+	if len(data) < PLAYER_STATE_SIZE {
+		return ErrorShortBuffer
+	}
+	if data[0] != PLAYER_STATE_VERSION {
+		return ErrorUnsupportedFormat
+	}
+
+	playerID, err := uuid.FromBytes(data[1:17])
+	if err != nil {
+		return err
+	}
+
+	s.PlayerID = playerID
+	s.Position = utils.Vector2D{
+		X: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[17:21]))),
+		Y: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[21:25]))),
+	}
+	s.Radius = binary.LittleEndian.Uint32(data[25:29])
+	s.Color = binary.LittleEndian.Uint32(data[29:33])
+	return nil
+}
+
+// MarshalBinary encodes the current state of the player, see
+// PlayerSnapshot.MarshalBinary.
+func (p *Player) MarshalBinary() ([]byte, error) {
+	return p.snapshot().MarshalBinary()
+}
+
+// UnmarshalBinary overwrites the player's ID, position, radius and color
+// with the encoded state, the mass following the radius, see UpdateRadius.
+func (p *Player) UnmarshalBinary(data []byte) error {
+	var s PlayerSnapshot
+	if err := s.UnmarshalBinary(data); err != nil {
+		return err
+	}
+
+	p.Lock()
+	p.PlayerID = s.PlayerID
+	p.Position = s.Position
+	p.Color = s.Color
+	p.Unlock()
+	p.UpdateRadius(s.Radius)
+	return nil
+}
+
+// encodeEntities encodes a list of entities as:
+// version (1) | count uint32 (4) | count entities of ENTITY_SIZE bytes.
+func encodeEntities(entities []Entity) []byte {
+	data := make([]byte, 0, 1+4+len(entities)*ENTITY_SIZE)
+	data = append(data, SNAPSHOT_VERSION)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(entities)))
+	for _, entity := range entities {
+		data = entity.appendBinary(data)
+	}
+	return data
+}
+
+// DecodeEntities reverses the encoding of the entity lists sent by Game,
+// quantized or not, see GameConfig.QuantizePositions.
+func DecodeEntities(data []byte) ([]Entity, error) {
+	if len(data) < 5 {
+		return nil, ErrorShortBuffer
+	}
+	if data[0] == QUANTIZED_SNAPSHOT_VERSION {
+		return decodeQuantized(data[1:])
+	}
+	if data[0] != SNAPSHOT_VERSION {
+		return nil, ErrorUnsupportedFormat
+	}
+
+	count := binary.LittleEndian.Uint32(data[1:5])
+	data = data[5:]
+	if uint64(len(data)) < uint64(count)*ENTITY_SIZE {
+		return nil, ErrorShortBuffer
+	}
+
+	entities := make([]Entity, count)
+	for i := range entities {
+		entities[i].decodeBinary(data[:ENTITY_SIZE])
+		data = data[ENTITY_SIZE:]
+	}
+	return entities, nil
+}
+
+func (e Entity) appendBinary(data []byte) []byte {
+	data = append(data, byte(e.Kind))
+	data = binary.LittleEndian.AppendUint32(data, e.NetID)
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(e.Position.X)))
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(e.Position.Y)))
+	data = binary.LittleEndian.AppendUint32(data, e.Radius)
+	data = binary.LittleEndian.AppendUint32(data, e.Color)
+	return data
+}
+
+// decodeBinary decodes an entity from exactly ENTITY_SIZE bytes.
+func (e *Entity) decodeBinary(data []byte) {
+	e.Kind = EntityKind(data[0])
+	e.NetID = binary.LittleEndian.Uint32(data[1:5])
+	e.Position = utils.Vector2D{
+		X: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[5:9]))),
+		Y: float64(math.Float32frombits(binary.LittleEndian.Uint32(data[9:13]))),
+	}
+	e.Radius = binary.LittleEndian.Uint32(data[13:17])
+	e.Color = binary.LittleEndian.Uint32(data[17:21])
+}
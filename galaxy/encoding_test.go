@@ -0,0 +1,97 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+func TestPlayerSnapshotRoundTrip(t *testing.T) {
+	snapshot := PlayerSnapshot{
+		PlayerID: uuid.New(),
+		Position: utils.Vector2D{X: 1234.5, Y: 0.25},
+		Radius:   123,
+		Color:    0xff8800,
+	}
+	data, err := snapshot.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+	if len(data) != PLAYER_STATE_SIZE {
+		t.Fatalf("encoded %d bytes, want %d", len(data), PLAYER_STATE_SIZE)
+	}
+
+	var decoded PlayerSnapshot
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	// The mass isn't sent, clients derive what they draw from the radius.
+	if decoded != snapshot {
+		t.Errorf("decoded %+v, want %+v", decoded, snapshot)
+	}
+}
+
+func TestPlayerRoundTrip(t *testing.T) {
+	player := NewPlayer(uuid.New(), nil)
+	player.PlayerID = uuid.New()
+	player.Position = utils.Vector2D{X: 500, Y: 700}
+	player.Mass = 400
+	player.recomputeRadius()
+	data, err := player.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	decoded := NewPlayer(uuid.New(), nil)
+	if err := decoded.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+	if decoded.PlayerID != player.PlayerID || decoded.Position != player.Position || decoded.Color != player.Color {
+		t.Errorf("decoded %v at %v colored %x, want %v at %v colored %x",
+			decoded.PlayerID, decoded.Position, decoded.Color, player.PlayerID, player.Position, player.Color)
+	}
+	if decoded.Radius != player.Radius {
+		t.Errorf("decoded radius %d, want %d", decoded.Radius, player.Radius)
+	}
+
+	// The mass follows the radius like it does in the game.
+	radius := decoded.Radius
+	decoded.recomputeRadius()
+	if decoded.Radius != radius {
+		t.Errorf("mass %d of the decoded player makes a radius of %d, not %d", decoded.Mass, decoded.Radius, radius)
+	}
+}
+
+func TestUnmarshalRejectsBadBuffers(t *testing.T) {
+	data, _ := PlayerSnapshot{PlayerID: uuid.New(), Radius: 50}.MarshalBinary()
+	unsupported := append([]byte{PLAYER_STATE_VERSION + 1}, data[1:]...)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{"empty", nil, ErrorShortBuffer},
+		{"short", data[:PLAYER_STATE_SIZE-1], ErrorShortBuffer},
+		{"unsupported version", unsupported, ErrorUnsupportedFormat},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var snapshot PlayerSnapshot
+			if err := snapshot.UnmarshalBinary(test.data); !errors.Is(err, test.want) {
+				t.Errorf("PlayerSnapshot.UnmarshalBinary: got %v, want %v", err, test.want)
+			}
+
+			player := NewPlayer(uuid.New(), nil)
+			before := player.snapshot()
+			if err := player.UnmarshalBinary(test.data); !errors.Is(err, test.want) {
+				t.Errorf("Player.UnmarshalBinary: got %v, want %v", err, test.want)
+			}
+			if player.snapshot() != before {
+				t.Error("a rejected buffer changed the player")
+			}
+		})
+	}
+}
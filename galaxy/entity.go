@@ -0,0 +1,52 @@
+package galaxy
+
+import (
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// EntityKind identifies what an Entity is.
+type EntityKind uint8
+
+const (
+	EntityPlayer EntityKind = iota + 1
+	EntityFood
+	EntityVirus
+	EntityEjectedMass
+)
+
+const (
+	// FOOD_RADIUS is the size pellets are drawn and culled with.
+	FOOD_RADIUS = 10
+)
+
+// Entity is anything in a game that clients render. Clients only know it
+// by NetID, ID stays on the server and is zero in decoded entities.
+type Entity struct {
+	Kind     EntityKind     `json:"kind"`
+	ID       uuid.UUID      `json:"-"`
+	NetID    uint32         `json:"netId"`
+	Position utils.Vector2D `json:"position"`
+	Radius   uint32         `json:"radius"`
+	Color    uint32         `json:"color"`
+}
+
+func (s PlayerSnapshot) entity() Entity {
+	return Entity{
+		Kind:     EntityPlayer,
+		ID:       s.PlayerID,
+		Position: s.Position,
+		Radius:   s.Radius,
+		Color:    s.Color,
+	}
+}
+
+func (f *Food) entity() Entity {
+	return Entity{
+		Kind:     EntityFood,
+		ID:       f.ID,
+		Position: f.Position,
+		Radius:   FOOD_RADIUS,
+		Color:    f.Color,
+	}
+}
@@ -0,0 +1,183 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EventType tells what an Event is about.
+type EventType int
+
+const (
+	// EventPlayerJoined is emitted when a player joins the game.
+	EventPlayerJoined EventType = iota + 1
+
+	// EventPlayerLeft is emitted when a player is removed from the game.
+	EventPlayerLeft
+
+	// EventPlayerEaten is emitted when a player loses its last cell,
+	// EatenBy and Mass are set.
+	EventPlayerEaten
+
+	// EventLevelUp is emitted when a player reaches a new Level this life.
+	EventLevelUp
+
+	// EventTopPlayer is emitted when a player takes the lead of the
+	// leaderboard, Mass is its score.
+	EventTopPlayer
+
+	// EventMassThreshold is emitted when the mass of a player rises to one
+	// of GameConfig.MassThresholds, Mass is the threshold. It is emitted
+	// again if the player shrinks below it and grows back.
+	EventMassThreshold
+
+	// EventMassTransfer is emitted whenever a player eats something,
+	// another player, one of its cells, a pellet or ejected mass: Eaten,
+	// EatenKind and Mass are set, Mass being exactly what the player
+	// gained, the mass of what it ate when it ate it. Eaten players and
+	// cells are told by the ID of the player they belong to.
+	EventMassTransfer
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPlayerJoined:
+		return "player joined"
+	case EventPlayerLeft:
+		return "player left"
+	case EventPlayerEaten:
+		return "player eaten"
+	case EventLevelUp:
+		return "level up"
+	case EventTopPlayer:
+		return "top player"
+	case EventMassThreshold:
+		return "mass threshold"
+	case EventMassTransfer:
+		return "mass transfer"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event is something that happened to a player, for analytics. Unlike
+// Metrics, which are aggregates, every event is reported on its own.
+type Event struct {
+	Type     EventType
+	PlayerID uuid.UUID
+	Time     time.Time
+
+	// Room is the room of the game, see Metrics.
+	Room string
+
+	EatenBy uuid.UUID
+	Mass    uint64
+	Level   int
+
+	Eaten     uuid.UUID
+	EatenKind EntityKind
+}
+
+// EventSink receives the events of games. Emit is called from the tick
+// loop, implementations should hand events off rather than block on I/O,
+// and must be safe for concurrent use.
+type EventSink interface {
+	Emit(event Event)
+}
+
+// NopEventSink discards every event, it is the default.
+type NopEventSink struct{}
+
+func (NopEventSink) Emit(Event) {}
+
+// level is the number of times mass doubled the starting mass.
+func level(mass uint64, start uint64) int {
+	if mass <= start {
+		return 0
+	}
+	return int(math.Log2(float64(mass) / float64(start)))
+}
+
+// emit queues an event of player for flushEvents, the caller must hold
+// the lock.
+func (g *Game) emit(eventType EventType, player uuid.UUID, event Event) {
+	event.Type = eventType
+	event.PlayerID = player
+	event.Time = time.Now()
+	event.Room = g.room
+	g.events = append(g.events, event)
+}
+
+// emitTransfer queues an EventMassTransfer of eater gaining mass by eating
+// eaten, the caller must hold the lock.
+func (g *Game) emitTransfer(eater *Player, eaten uuid.UUID, kind EntityKind, mass uint64) {
+	g.emit(EventMassTransfer, eater.Owner(), Event{Eaten: eaten, EatenKind: kind, Mass: mass})
+}
+
+// flushEvents hands the queued events to the sink, outside the lock so a
+// slow sink doesn't stall the game.
+func (g *Game) flushEvents() {
+	g.Lock()
+	events := g.events
+	g.events = nil
+	g.Unlock()
+
+	for _, event := range events {
+		g.sink.Emit(event)
+	}
+}
+
+// crossed is the number of thresholds at or below mass, thresholds being
+// ascending.
+func crossed(thresholds []uint64, mass uint64) int {
+	return sort.Search(len(thresholds), func(i int) bool {
+		return thresholds[i] > mass
+	})
+}
+
+// trackProgress emits the level ups and mass thresholds of the tick and who
+// leads the game, the caller must hold the lock.
+func (g *Game) trackProgress() {
+	thresholds := g.config.MassThresholds
+	for id, player := range g.players {
+		if player.IsCell() {
+			continue
+		}
+
+		player.Lock()
+		reached := level(player.Mass, g.rules.start)
+		leveledUp := player.Alive && reached > player.level
+		if leveledUp {
+			player.level = reached
+		}
+		// Shrinking below a threshold forgets it silently, so growing
+		// back to it counts as a new crossing.
+		below := player.thresholds
+		if player.Alive {
+			player.thresholds = crossed(thresholds, player.Mass)
+		}
+		above := player.thresholds
+		player.Unlock()
+
+		if leveledUp {
+			g.emit(EventLevelUp, id, Event{Level: reached})
+		}
+		for _, threshold := range thresholds[min(below, above):above] {
+			g.emit(EventMassThreshold, id, Event{Mass: threshold})
+		}
+	}
+
+	top := g.leaderboard(1)
+	if len(top) == 0 {
+		g.leader = uuid.Nil
+		return
+	}
+	if top[0].PlayerID != g.leader {
+		g.leader = top[0].PlayerID
+		g.emit(EventTopPlayer, g.leader, Event{Mass: top[0].Score})
+	}
+}
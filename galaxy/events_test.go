@@ -0,0 +1,53 @@
+package galaxy
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+// takeEvents returns the masses of the queued events of type eventType,
+// and forgets every queued event.
+func takeEvents(g *Game, eventType EventType) []uint64 {
+	g.Lock()
+	defer g.Unlock()
+	var masses []uint64
+	for _, event := range g.events {
+		if event.Type == eventType {
+			masses = append(masses, event.Mass)
+		}
+	}
+	g.events = nil
+	return masses
+}
+
+func TestMassThresholdsFireOncePerCrossing(t *testing.T) {
+	config := quietConfig()
+	config.MassThresholds = []uint64{150, 300}
+	g := newTestGame(t, config)
+	player := joinTestPlayer(t, g, 100, 1000, 1000)
+	takeEvents(g, EventMassThreshold)
+
+	steps := []struct {
+		name string
+		mass uint64
+		want []uint64
+	}{
+		{"below", 120, nil},
+		{"crossing the first", 200, []uint64{150}},
+		{"staying above", 250, nil},
+		{"shrinking below", 140, nil},
+		{"crossing again", 160, []uint64{150}},
+		{"crossing both", 40, nil},
+		{"crossing both again", 500, []uint64{150, 300}},
+	}
+	for _, step := range steps {
+		player.Lock()
+		player.Mass = step.mass
+		player.Unlock()
+		g.Tick(time.Millisecond)
+		if got := takeEvents(g, EventMassThreshold); !slices.Equal(got, step.want) {
+			t.Errorf("%s at %d: thresholds %v, want %v", step.name, step.mass, got, step.want)
+		}
+	}
+}
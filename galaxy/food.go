@@ -1,6 +1,16 @@
 package galaxy
 
-import "math/rand"
+import (
+	"math/rand"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// FOOD_VALUE is the mass a player gets from eating a pellet.
+	FOOD_VALUE = 1
+)
 
 // Colors
 const (
@@ -39,8 +49,11 @@ var FoodColors = []uint32{
 }
 // Food represents an alive food item in a game.
 type Food struct {
-	position Vector2D
-	color    uint32
+	ID       uuid.UUID
+	Kind     FoodKind
+	Position utils.Vector2D
+	Value    uint32
+	Color    uint32
 }
 
 func createRandomFood() []Food {
@@ -48,8 +61,10 @@ func createRandomFood() []Food {
 	var food []Food
 	for i := 0; i < 800; i++ {
 		food = append(food, Food{
-			position: *randomPosition(),
-			color: randomColor(),
+			ID:       uuid.New(),
+			Position: randomPosition().toVector(),
+			Value:    FOOD_VALUE,
+			Color:    randomColor(),
 		})
 	}
 
@@ -0,0 +1,175 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// FoodKind tells pellets of different tiers apart, see FoodTier.
+type FoodKind uint8
+
+const (
+	FoodCommon FoodKind = iota
+	FoodLarge
+	FoodGolden
+)
+
+const (
+	// LARGE_FOOD_VALUE is the mass of the large pellets of
+	// DefaultFoodTiers, GOLDEN_FOOD_VALUE that of the golden ones.
+	LARGE_FOOD_VALUE  = 5
+	GOLDEN_FOOD_VALUE = 10
+
+	// GOLDEN_FOOD_BOOST is how long eating a golden pellet boosts a
+	// player for free, see Boost.
+	GOLDEN_FOOD_BOOST = 3 * time.Second
+
+	GOLDEN_FOOD_COLOR uint32 = 0xFFD700
+)
+
+// FoodTier is a kind of pellet the game spawns, such as common pellets
+// worth little and rare ones worth more.
+type FoodTier struct {
+	Kind FoodKind
+
+	// Value is the mass a player gets from eating a pellet of the tier,
+	// before the FoodMultiplier of its region.
+	Value uint32
+
+	// Weight is the chance of a spawned pellet being of the tier, relative
+	// to the weights of the other tiers.
+	Weight float64
+
+	// Color is the color of the pellets of the tier, 0 picks one of
+	// FoodColors for each pellet.
+	Color uint32
+
+	// Boost makes eating a pellet of the tier boost the player for that
+	// long without costing it mass. 0 grants nothing.
+	Boost time.Duration
+}
+
+// DefaultFoodTiers returns the pellets of a standard public game: mostly
+// common ones, some large ones and rare golden ones granting a boost.
+func DefaultFoodTiers() []FoodTier {
+	return []FoodTier{
+		{Kind: FoodCommon, Value: FOOD_VALUE, Weight: 0.9},
+		{Kind: FoodLarge, Value: LARGE_FOOD_VALUE, Weight: 0.09},
+		{Kind: FoodGolden, Value: GOLDEN_FOOD_VALUE, Weight: 0.01, Color: GOLDEN_FOOD_COLOR, Boost: GOLDEN_FOOD_BOOST},
+	}
+}
+
+func (t FoodTier) validate() error {
+	if t.Value == 0 {
+		return fmt.Errorf("%w: Value of food tier %d must be positive", ErrorInvalidConfig, t.Kind)
+	}
+	if t.Weight < 0 || math.IsInf(t.Weight, 0) || math.IsNaN(t.Weight) {
+		return fmt.Errorf("%w: Weight of food tier %d must not be negative, got %v", ErrorInvalidConfig, t.Kind, t.Weight)
+	}
+	if t.Boost < 0 {
+		return fmt.Errorf("%w: Boost of food tier %d must not be negative, got %v", ErrorInvalidConfig, t.Kind, t.Boost)
+	}
+	return nil
+}
+
+// validateFoodTiers reports whether tiers have distinct kinds and can
+// spawn something.
+func validateFoodTiers(tiers []FoodTier) error {
+	if len(tiers) == 0 {
+		return nil
+	}
+	var total float64
+	seen := make(map[FoodKind]bool, len(tiers))
+	for _, tier := range tiers {
+		if err := tier.validate(); err != nil {
+			return err
+		}
+		if seen[tier.Kind] {
+			return fmt.Errorf("%w: food tier %d listed twice", ErrorInvalidConfig, tier.Kind)
+		}
+		seen[tier.Kind] = true
+		total += tier.Weight
+	}
+	if !(total > 0) {
+		return fmt.Errorf("%w: the Weight of some food tier must be positive", ErrorInvalidConfig)
+	}
+	return nil
+}
+
+// foodTiers returns the tiers pellets spawn from, common pellets worth
+// FOOD_VALUE unless GameConfig.FoodTiers lists others.
+func (g *Game) foodTiers() []FoodTier {
+	if len(g.config.FoodTiers) > 0 {
+		return g.config.FoodTiers
+	}
+	return []FoodTier{{Kind: FoodCommon, Value: FOOD_VALUE, Weight: 1}}
+}
+
+// randomTier picks the tier of a new pellet according to their weights.
+// The caller must hold the lock.
+func (g *Game) randomTier() FoodTier {
+	tiers := g.foodTiers()
+	if len(tiers) == 1 {
+		return tiers[0]
+	}
+
+	var total float64
+	for _, tier := range tiers {
+		total += tier.Weight
+	}
+	pick := g.rand.Float64() * total
+	for _, tier := range tiers {
+		if pick < tier.Weight {
+			return tier
+		}
+		pick -= tier.Weight
+	}
+	// Rounding left pick above the last weight.
+	for i := len(tiers) - 1; ; i-- {
+		if tiers[i].Weight > 0 {
+			return tiers[i]
+		}
+	}
+}
+
+// foodTier returns the tier of the pellets of kind, false if the game
+// doesn't spawn them, as pellets restored from a snapshot may be.
+func (g *Game) foodTier(kind FoodKind) (FoodTier, bool) {
+	for _, tier := range g.foodTiers() {
+		if tier.Kind == kind {
+			return tier, true
+		}
+	}
+	return FoodTier{}, false
+}
+
+// newFood creates a pellet of a random tier at a random position, the
+// caller must hold the lock.
+func (g *Game) newFood() *Food {
+	tier := g.randomTier()
+	food := &Food{
+		ID:       g.newID(),
+		Kind:     tier.Kind,
+		Position: g.randomPosition(),
+		Value:    tier.Value,
+		Color:    tier.Color,
+	}
+	if food.Color == 0 {
+		food.Color = FoodColors[g.rand.IntN(len(FoodColors))]
+	}
+	return food
+}
+
+// eatFood gives food to player, with the boost its tier grants. The caller
+// must hold the lock, it returns the mass the player gained.
+func (g *Game) eatFood(player *Player, food *Food) uint64 {
+	value := g.foodValue(food.Value, player.GetPosition())
+	player.addMass(value)
+	if tier, ok := g.foodTier(food.Kind); ok && tier.Boost > 0 {
+		player.Lock()
+		player.boostLeft = max(player.boostLeft, tier.Boost)
+		player.Unlock()
+	}
+	return value
+}
@@ -0,0 +1,78 @@
+package galaxy
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestFoodSpawnsByTierWeight(t *testing.T) {
+	config := quietConfig()
+	config.FoodTiers = []FoodTier{
+		{Kind: FoodCommon, Value: FOOD_VALUE, Weight: 7},
+		{Kind: FoodLarge, Value: LARGE_FOOD_VALUE, Weight: 2},
+		{Kind: FoodGolden, Value: GOLDEN_FOOD_VALUE, Weight: 1, Color: GOLDEN_FOOD_COLOR, Boost: GOLDEN_FOOD_BOOST},
+		{Kind: FoodGolden + 1, Value: 100, Weight: 0},
+	}
+	config.MaxEntities = 0
+	g := newTestGame(t, config)
+
+	const spawned = 20000
+	if n := g.SpawnFood(spawned); n != spawned {
+		t.Fatalf("spawned %d pellets, want %d", n, spawned)
+	}
+	counts := make(map[FoodKind]int)
+	for _, food := range g.Snapshot().Food {
+		counts[food.Kind]++
+		tier, _ := g.foodTier(food.Kind)
+		if food.Value != tier.Value {
+			t.Fatalf("pellet of kind %d worth %d, want %d", food.Kind, food.Value, tier.Value)
+		}
+		if food.Kind == FoodGolden && food.Color != GOLDEN_FOOD_COLOR {
+			t.Fatalf("golden pellet colored %06x", food.Color)
+		}
+	}
+	for _, tier := range config.FoodTiers {
+		share, want := float64(counts[tier.Kind])/spawned, tier.Weight/10
+		if math.Abs(share-want) > 0.01 {
+			t.Errorf("%.3f of the pellets are of kind %d, want %.3f", share, tier.Kind, want)
+		}
+	}
+}
+
+func TestGoldenFoodBoosts(t *testing.T) {
+	config := quietConfig()
+	config.FoodTiers = DefaultFoodTiers()
+	g := newTestGame(t, config)
+	player := joinTestPlayer(t, g, 500, 1000, 1000)
+
+	g.Lock()
+	golden := &Food{Kind: FoodGolden, Value: GOLDEN_FOOD_VALUE}
+	gained := g.eatFood(player, golden)
+	g.Unlock()
+	if gained != GOLDEN_FOOD_VALUE || player.Score() != 500+GOLDEN_FOOD_VALUE {
+		t.Errorf("golden pellet gave %d, player weighs %d, want %d and %d", gained, player.Score(), GOLDEN_FOOD_VALUE, 500+GOLDEN_FOOD_VALUE)
+	}
+	if player.boostLeft != GOLDEN_FOOD_BOOST {
+		t.Errorf("golden pellet boosts for %v, want %v", player.boostLeft, GOLDEN_FOOD_BOOST)
+	}
+}
+
+func TestFoodTiersValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		tiers []FoodTier
+	}{
+		{"worthless", []FoodTier{{Kind: FoodCommon, Weight: 1}}},
+		{"negative weight", []FoodTier{{Kind: FoodCommon, Value: 1, Weight: -1}}},
+		{"no weight", []FoodTier{{Kind: FoodCommon, Value: 1}}},
+		{"kind twice", []FoodTier{{Kind: FoodCommon, Value: 1, Weight: 1}, {Kind: FoodCommon, Value: 2, Weight: 1}}},
+	}
+	for _, test := range tests {
+		config := testConfig()
+		config.FoodTiers = test.tiers
+		if err := config.Validate(); !errors.Is(err, ErrorInvalidConfig) {
+			t.Errorf("%s: Validate() = %v, want %v", test.name, err, ErrorInvalidConfig)
+		}
+	}
+}
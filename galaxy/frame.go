@@ -0,0 +1,119 @@
+package galaxy
+
+import (
+	"fmt"
+)
+
+// Opcode is the first byte of every frame exchanged with Game clients,
+// telling how to read the rest of it.
+type Opcode uint8
+
+const (
+	// OpInput is sent by clients with their movement and actions.
+	OpInput Opcode = iota + 1
+
+	// OpStateSnapshot carries the entities of a player's viewport after a
+	// SnapshotHeader, either as encodeEntities or as a DeltaEncoder delta,
+	// and then the entities that left the game, see DecodeRemovals.
+	OpStateSnapshot
+
+	// OpLeaderboard carries the best players of the game whenever they
+	// change, see Game.announceLeaderboard.
+	OpLeaderboard
+
+	// OpDeath tells a player it was eaten:
+	// eaten by ID (16) | lost mass uint64 (8), little endian.
+	OpDeath
+
+	// OpPing is echoed back with the same payload, so clients can measure
+	// their latency.
+	OpPing
+
+	// OpSession gives a player the token resuming it after its connection
+	// drops, see GameConfig.SessionGracePeriod: token (16).
+	OpSession
+
+	// OpHello starts every connection, clients send the protocol version
+	// they speak and the game answers whether it accepts it, see handshake.
+	OpHello
+
+	// OpCountdown tells clients how long until the match starts, see
+	// Game.announceCountdown.
+	OpCountdown
+
+	// OpBounds tells clients the new bounds of the world after
+	// Game.Resize, see DecodeBounds.
+	OpBounds
+
+	// OpBroadcastInterval tells clients the new interval between state
+	// updates after Game.SetBroadcastRate, see DecodeInterval.
+	OpBroadcastInterval
+
+	// OpHUD tells a player its rank, score and the threats around it,
+	// every HUD_INTERVAL, see DecodeHUD.
+	OpHUD
+
+	// OpHeartbeat is sent to clients every GameConfig.HeartbeatInterval
+	// and echoed back by them as is, see DecodeHeartbeat.
+	OpHeartbeat
+
+	// OpRespawn is sent by eaten players to play again, see Game.Respawn.
+	// It has no payload, clients retry it while the world is full.
+	OpRespawn
+)
+
+var ErrorUnknownOpcode = fmt.Errorf("Unknown opcode")
+
+func (op Opcode) String() string {
+	switch op {
+	case OpInput:
+		return "input"
+	case OpStateSnapshot:
+		return "state snapshot"
+	case OpLeaderboard:
+		return "leaderboard"
+	case OpDeath:
+		return "death"
+	case OpPing:
+		return "ping"
+	case OpSession:
+		return "session"
+	case OpHello:
+		return "hello"
+	case OpCountdown:
+		return "countdown"
+	case OpBounds:
+		return "bounds"
+	case OpBroadcastInterval:
+		return "broadcast interval"
+	case OpHUD:
+		return "hud"
+	case OpHeartbeat:
+		return "heartbeat"
+	case OpRespawn:
+		return "respawn"
+	default:
+		return fmt.Sprintf("opcode(%d)", uint8(op))
+	}
+}
+
+// EncodeFrame prefixes payload with op.
+func EncodeFrame(op Opcode, payload []byte) []byte {
+	frame := make([]byte, 0, 1+len(payload))
+	frame = append(frame, byte(op))
+	return append(frame, payload...)
+}
+
+// DecodeFrame splits a frame into its opcode and payload, the payload
+// shares memory with frame.
+func DecodeFrame(frame []byte) (Opcode, []byte, error) {
+	if len(frame) < 1 {
+		return 0, nil, ErrorShortBuffer
+	}
+
+	op := Opcode(frame[0])
+	if op < OpInput || op > OpRespawn {
+		return 0, nil, ErrorUnknownOpcode
+	}
+	return op, frame[1:], nil
+}
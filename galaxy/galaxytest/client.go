@@ -0,0 +1,75 @@
+package galaxytest
+
+import (
+	"encoding/binary"
+	"net/http/httptest"
+	"net/url"
+
+	"galaxy.io/server/galaxy"
+	"github.com/google/uuid"
+)
+
+// MemoryClient plays a game over InMemoryConnections the way a websocket
+// client would, to test what happens across reconnects: Drop loses the
+// connection like a dead socket and Reconnect resumes the session within
+// the grace period, see galaxy.GameConfig.SessionGracePeriod.
+type MemoryClient struct {
+	game    *galaxy.Game
+	factory *InMemoryFactory
+	conn    *InMemoryConnection
+	session uuid.UUID
+}
+
+// JoinInMemory joins game as a new player and says hello.
+func JoinInMemory(game *galaxy.Game) *MemoryClient {
+	c := &MemoryClient{game: game, factory: NewInMemoryFactory()}
+	c.connect(url.Values{})
+	return c
+}
+
+// connect joins the game with query, says hello and keeps the session
+// token it gets back.
+func (c *MemoryClient) connect(query url.Values) {
+	r := httptest.NewRequest("GET", "/game?"+query.Encode(), nil)
+	c.game.HandleNewConnection(c.factory, httptest.NewRecorder(), r)
+
+	connections := c.factory.Connections()
+	c.conn = connections[len(connections)-1]
+	hello := binary.LittleEndian.AppendUint16(nil, galaxy.PROTOCOL_VERSION)
+	c.conn.Inject(galaxy.EncodeFrame(galaxy.OpHello, hello))
+	c.takeSession()
+}
+
+// takeSession keeps the token of the last OpSession frame received, if
+// any. The frames received so far are the handshake's, they are dropped.
+func (c *MemoryClient) takeSession() {
+	for _, frame := range c.conn.TakeSent() {
+		op, payload, err := galaxy.DecodeFrame(frame)
+		if err == nil && op == galaxy.OpSession && len(payload) >= 16 {
+			c.session = uuid.UUID(payload[:16])
+		}
+	}
+}
+
+// Conn returns the current connection of the client.
+func (c *MemoryClient) Conn() *InMemoryConnection {
+	return c.conn
+}
+
+// Session returns the session token the game gave the client, uuid.Nil if
+// it keeps no disconnected players.
+func (c *MemoryClient) Session() uuid.UUID {
+	return c.session
+}
+
+// Drop closes the connection without telling the game, like a socket
+// dying under a client.
+func (c *MemoryClient) Drop() {
+	c.conn.Close()
+}
+
+// Reconnect resumes the session of the client on a new connection. It
+// joins as a new player if the session already expired.
+func (c *MemoryClient) Reconnect() {
+	c.connect(url.Values{"session": {c.session.String()}})
+}
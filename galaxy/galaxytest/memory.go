@@ -0,0 +1,156 @@
+// Package galaxytest runs games over connections without sockets, for
+// testing what is built on top of them without a network.
+package galaxytest
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"galaxy.io/server/galaxy"
+	pb "galaxy.io/server/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+var ErrorConnectionClosed = fmt.Errorf("Connection closed")
+
+// InMemoryConnection is a connection without a socket, for testing what is
+// built on top of connections. Inbound messages are injected with Inject
+// and delivered synchronously, outbound ones are kept until TakeSent.
+// It is safe for concurrent use.
+type InMemoryConnection struct {
+	mutex   sync.Mutex
+	handler func([]byte)
+	onClose func(galaxy.CloseReason, error)
+	sent    [][]byte
+	closed  bool
+}
+
+// NewInMemoryConnection creates a connection delivering injected messages
+// to handler and calling onClose once it closes, always with CloseNormal,
+// both may be nil.
+func NewInMemoryConnection(handler func([]byte), onClose func(galaxy.CloseReason, error)) *InMemoryConnection {
+	return &InMemoryConnection{
+		handler: handler,
+		onClose: onClose,
+	}
+}
+
+// Inject delivers data to the handler as if the peer sent it.
+func (c *InMemoryConnection) Inject(data []byte) error {
+	c.mutex.Lock()
+	closed, handler := c.closed, c.handler
+	c.mutex.Unlock()
+
+	if closed {
+		return ErrorConnectionClosed
+	}
+	if handler != nil {
+		handler(data)
+	}
+	return nil
+}
+
+// SendBinary records a copy of data, returned by TakeSent.
+func (c *InMemoryConnection) SendBinary(data []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return ErrorConnectionClosed
+	}
+	c.sent = append(c.sent, append([]byte(nil), data...))
+	return nil
+}
+
+func (c *InMemoryConnection) SendEvent(event *pb.Event) error {
+	data, err := proto.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return c.SendBinary(data)
+}
+
+// TakeSent returns the messages sent since the last call, in order.
+func (c *InMemoryConnection) TakeSent() [][]byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	sent := c.sent
+	c.sent = nil
+	return sent
+}
+
+// Close closes the connection, calling onClose the first time.
+func (c *InMemoryConnection) Close() {
+	c.mutex.Lock()
+	if c.closed {
+		c.mutex.Unlock()
+		return
+	}
+	c.closed = true
+	onClose := c.onClose
+	c.mutex.Unlock()
+
+	if onClose != nil {
+		onClose(galaxy.CloseNormal, nil)
+	}
+}
+
+func (c *InMemoryConnection) IsClosed() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.closed
+}
+
+// InMemoryFactory creates InMemoryConnections in place of a websocket
+// factory, ignoring the requests. It is safe for concurrent use.
+type InMemoryFactory struct {
+	mutex       sync.Mutex
+	connections []*InMemoryConnection
+}
+
+func NewInMemoryFactory() *InMemoryFactory {
+	return &InMemoryFactory{}
+}
+
+// Connections returns the connections created so far, in order.
+func (f *InMemoryFactory) Connections() []*InMemoryConnection {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return append([]*InMemoryConnection(nil), f.connections...)
+}
+
+func (f *InMemoryFactory) NewConnection(
+	w http.ResponseWriter,
+	r *http.Request,
+	operationHandler func(*pb.Operation),
+	onClose func(galaxy.CloseReason, error),
+) (galaxy.ClientConnection, error) {
+	handler := func(data []byte) {
+		operation := &pb.Operation{}
+		if err := proto.Unmarshal(data, operation); err != nil {
+			log.Printf("Error unmarshing operation: %v", err)
+			return
+		}
+		operationHandler(operation)
+	}
+	return f.add(NewInMemoryConnection(handler, onClose)), nil
+}
+
+func (f *InMemoryFactory) NewFrameConnection(
+	w http.ResponseWriter,
+	r *http.Request,
+	frameHandler func([]byte),
+	onClose func(galaxy.CloseReason, error),
+) (galaxy.ClientConnection, error) {
+	return f.add(NewInMemoryConnection(frameHandler, onClose)), nil
+}
+
+func (f *InMemoryFactory) add(c *InMemoryConnection) *InMemoryConnection {
+	f.mutex.Lock()
+	f.connections = append(f.connections, c)
+	f.mutex.Unlock()
+	return c
+}
@@ -0,0 +1,62 @@
+package galaxytest
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"galaxy.io/server/galaxy"
+)
+
+func newGame(t *testing.T, config galaxy.GameConfig) *galaxy.Game {
+	t.Helper()
+
+	game, err := galaxy.NewGame(config)
+	if err != nil {
+		t.Fatalf("NewGame: %v", err)
+	}
+	t.Cleanup(game.Close)
+	return game
+}
+
+func TestInMemoryConnectionsCarryFrames(t *testing.T) {
+	game := newGame(t, galaxy.DefaultGameConfig())
+	factory := NewInMemoryFactory()
+	game.HandleNewConnection(factory, httptest.NewRecorder(), httptest.NewRequest("GET", "/game", nil))
+
+	connections := factory.Connections()
+	if len(connections) != 1 {
+		t.Fatalf("factory created %d connections, want 1", len(connections))
+	}
+	conn := connections[0]
+	hello := binary.LittleEndian.AppendUint16(nil, galaxy.PROTOCOL_VERSION)
+	if err := conn.Inject(galaxy.EncodeFrame(galaxy.OpHello, hello)); err != nil {
+		t.Fatalf("Inject: %v", err)
+	}
+
+	sent := conn.TakeSent()
+	if len(sent) == 0 {
+		t.Fatal("game answered nothing to the hello")
+	}
+	op, payload, err := galaxy.DecodeFrame(sent[0])
+	if err != nil || op != galaxy.OpHello || len(payload) == 0 || payload[0] != 1 {
+		t.Fatalf("first frame is %v %v, want an accepting hello", op, err)
+	}
+	if len(conn.TakeSent()) != 0 {
+		t.Error("TakeSent returned the same frames twice")
+	}
+
+	conn.Close()
+	game.ForEachPlayer(func(p galaxy.PlayerView) {
+		if p.Connected {
+			t.Errorf("player %v still connected after its connection closed", p.ID)
+		}
+	})
+	if err := conn.Inject(galaxy.EncodeFrame(galaxy.OpPing, nil)); !errors.Is(err, ErrorConnectionClosed) {
+		t.Errorf("Inject after Close: got %v, want %v", err, ErrorConnectionClosed)
+	}
+	if err := conn.SendBinary([]byte("late")); !errors.Is(err, ErrorConnectionClosed) {
+		t.Errorf("SendBinary after Close: got %v, want %v", err, ErrorConnectionClosed)
+	}
+}
@@ -0,0 +1,109 @@
+package galaxytest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// sessionConfig keeps disconnected players for grace, with nothing in the
+// world changing their mass.
+func sessionConfig(grace time.Duration) galaxy.GameConfig {
+	config := galaxy.DefaultGameConfig()
+	config.Seed = 1
+	config.FoodCount = 0
+	config.FoodDensity = 0
+	config.VirusCount = 0
+	config.DecayRate = 0
+	config.SessionGracePeriod = grace
+	return config
+}
+
+// players returns the views of the players of game.
+func players(game *galaxy.Game) []galaxy.PlayerView {
+	var views []galaxy.PlayerView
+	game.ForEachPlayer(func(p galaxy.PlayerView) { views = append(views, p) })
+	return views
+}
+
+// onlyPlayer returns the view of the single player of game.
+func onlyPlayer(t *testing.T, game *galaxy.Game) galaxy.PlayerView {
+	t.Helper()
+
+	views := players(game)
+	if len(views) != 1 {
+		t.Fatalf("game has %d players, want 1", len(views))
+	}
+	return views[0]
+}
+
+func TestResumeKeepsMassAndPosition(t *testing.T) {
+	game := newGame(t, sessionConfig(time.Minute))
+	client := JoinInMemory(game)
+	if client.Session() == uuid.Nil {
+		t.Fatal("client got no session token")
+	}
+
+	spawn := onlyPlayer(t, game).Position
+	input := galaxy.EncodeInput(galaxy.PlayerInput{Direction: utils.Vector2D{X: 1}})
+	client.Conn().Inject(galaxy.EncodeFrame(galaxy.OpInput, input))
+	for range 10 {
+		game.Tick(50 * time.Millisecond)
+	}
+	before := onlyPlayer(t, game)
+	if before.Position == spawn {
+		t.Fatal("player didn't move before dropping")
+	}
+	player, _ := game.Player(before.ID)
+	score := player.Score()
+
+	client.Drop()
+	// The player is frozen while its client is away.
+	for range 10 {
+		game.Tick(50 * time.Millisecond)
+	}
+	client.Reconnect()
+
+	after := onlyPlayer(t, game)
+	if after.ID != before.ID {
+		t.Fatalf("resumed as player %v, want %v", after.ID, before.ID)
+	}
+	if !after.Connected {
+		t.Error("resumed player isn't connected")
+	}
+	if after.Mass != before.Mass || player.Score() != score {
+		t.Errorf("mass %d and score %d after resuming, want %d and %d", after.Mass, player.Score(), before.Mass, score)
+	}
+	if after.Position != before.Position {
+		t.Errorf("position %v after resuming, want %v", after.Position, before.Position)
+	}
+}
+
+func TestExpiredSessionJoinsAsNewPlayer(t *testing.T) {
+	game := newGame(t, sessionConfig(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go game.Run(ctx)
+
+	client := JoinInMemory(game)
+	dropped := onlyPlayer(t, game)
+	client.Drop()
+
+	// Players are reaped every galaxy.SESSION_REAP_INTERVAL.
+	deadline := time.Now().Add(3 * galaxy.SESSION_REAP_INTERVAL)
+	for game.PlayerCount() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("player kept after its grace period ran out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	client.Reconnect()
+	if resumed := onlyPlayer(t, game); resumed.ID == dropped.ID {
+		t.Errorf("expired session resumed player %v", dropped.ID)
+	}
+}
@@ -0,0 +1,1378 @@
+package galaxy
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// worldBounds is the area of a standard world.
+var worldBounds = utils.Rect{
+	Max: utils.Vector2D{X: WORLD_WIDTH, Y: WORLD_HEIGHT},
+}
+
+const (
+	DEFAULT_TICK_RATE = 30
+
+	DEFAULT_FOOD_COUNT = 800
+
+	// DEFAULT_FOOD_DENSITY is the number of pellets per million square
+	// world units of a standard game, DEFAULT_FOOD_COUNT in a standard
+	// world.
+	DEFAULT_FOOD_DENSITY = 8
+
+	// DEFAULT_MAX_ENTITIES caps the entities of a standard game.
+	DEFAULT_MAX_ENTITIES = 5000
+
+	// FOOD_RESPAWN_PER_TICK is the most pellets replacing eaten ones every
+	// tick, so food grows back gradually.
+	FOOD_RESPAWN_PER_TICK = 10
+
+	DEFAULT_VIEWPORT_WIDTH  = 1920
+	DEFAULT_VIEWPORT_HEIGHT = 1080
+
+	// MAX_VIEWPORT_ZOOM bounds how far out big players see, so a giant
+	// player isn't sent the whole world.
+	MAX_VIEWPORT_ZOOM = 4
+)
+
+var (
+	ErrorPlayerNotFound  = fmt.Errorf("Player not found")
+	ErrorDuplicatePlayer = fmt.Errorf("Player already in the game")
+	ErrorInvalidConfig   = fmt.Errorf("Invalid game config")
+)
+
+// GameConfig holds the tunables of a Game.
+type GameConfig struct {
+	// Bounds is the area of the world, every position is clamped to it.
+	Bounds utils.Rect
+
+	// Shape is the shape of the playable area within Bounds, the whole of
+	// it by default. Circular worlds clamp positions to the largest disc
+	// centered in Bounds, pulling them back towards its center.
+	Shape WorldShape
+
+	// TickRate is the number of simulation steps per second run by Run.
+	TickRate int
+
+	// BroadcastRate is the number of state updates per second Run sends
+	// clients, at most TickRate, to save bandwidth without coarsening the
+	// simulation. 0 broadcasts after every tick.
+	BroadcastRate int
+
+	// ViewportWidth and ViewportHeight are the area around a player, on top
+	// of its own size, whose entities are sent to it. Bigger players see
+	// further, see Game.ViewportArea.
+	ViewportWidth  float64
+	ViewportHeight float64
+
+	// CellSize is the side of the spatial grid cells. It should be around
+	// the size of a typical player, too small cells make big entities span
+	// many cells and too big ones make every query scan many entities.
+	CellSize float64
+
+	// Index selects the spatial index, a Grid unless worlds are crowded
+	// enough to prefer a Quadtree.
+	Index IndexKind
+
+	// KeyframeInterval enables delta updates, see DeltaEncoder: players get
+	// the whole viewport every KeyframeInterval broadcasts and only what
+	// changed in between. 0 always sends the whole viewport.
+	KeyframeInterval int
+
+	// Teams enables team mode with that many teams: players joining are
+	// assigned to the smallest team and can't eat their teammates. 0 is
+	// free-for-all.
+	Teams int
+
+	// FoodCount is the number of pellets the game keeps, eaten ones are
+	// replaced over time. FoodDensity overrides it.
+	FoodCount int
+
+	// FoodDensity is the number of pellets per million square units of
+	// the world the game keeps, so bigger worlds get more food. 0 uses
+	// FoodCount.
+	FoodDensity float64
+
+	// FoodTiers are the kinds of pellets spawned, each with its mass and
+	// how often it spawns, see FoodTier. None spawns only common pellets
+	// worth FOOD_VALUE.
+	FoodTiers []FoodTier
+
+	// MaxEntities caps the players, pellets, viruses and ejected mass in
+	// the world, food stops spawning once it is reached. 0 means no limit.
+	MaxEntities int
+
+	// VirusCount is the number of viruses in the game, popped viruses are
+	// replaced somewhere else.
+	VirusCount int
+
+	// VirusRadius is the size of the viruses.
+	VirusRadius float64
+
+	// VirusFeedMass is the ejected mass a virus absorbs before shooting a
+	// new virus away from the side it was fed from, the way to pop a big
+	// player from a distance. Shot viruses popped aren't replaced. 0 lets
+	// ejected mass pass over viruses.
+	VirusFeedMass uint64
+
+	// Metrics receives the tick timings and player counts, nil discards
+	// them.
+	Metrics Metrics
+
+	// Events receives what happens to players, nil discards it.
+	Events EventSink
+
+	// DecayRate is the fraction of its mass a player loses every second,
+	// never going below MinMass. 0 disables decay.
+	DecayRate float64
+
+	// StartMass is the mass players join and respawn with, MinMass the
+	// mass decay stops at and MaxMass the most a player grows to. The
+	// radius of a player follows from its mass as
+	// radius = MassToRadiusK * sqrt(mass), so its area grows linearly with
+	// its mass. Zero values take the defaults, STARTING_MASS,
+	// DEFAULT_MAX_MASS and MASS_TO_RADIUS_K.
+	StartMass     uint64
+	MinMass       uint64
+	MaxMass       uint64
+	MassToRadiusK float64
+
+	// EatSizeRatio is how many times bigger than its prey the radius of a
+	// player must be to eat it, and EatOverlapRatio the fraction of the
+	// diameter of the prey it must cover. They apply to viruses too. Zero
+	// values take the defaults, EAT_SIZE_RATIO and EAT_OVERLAP_RATIO.
+	EatSizeRatio    float64
+	EatOverlapRatio float64
+
+	// MinSplitMass is the smallest mass a player can split at, and MaxCells
+	// the most cells a player has at once, itself included, whether from
+	// splitting or bursting on viruses. Zero values take the defaults,
+	// MIN_SPLIT_MASS, or twice MinMass if more, and DEFAULT_MAX_CELLS.
+	MinSplitMass uint64
+	MaxCells     int
+
+	// SessionGracePeriod is how long players stay in the game, frozen,
+	// after their connection drops, waiting for their client to resume
+	// the session. 0 removes them right away.
+	SessionGracePeriod time.Duration
+
+	// Rand is the source of every random choice of the game: where food,
+	// viruses and respawning players go and the IDs of what it spawns, so
+	// games with equally seeded sources and the same inputs spawn the
+	// same. Sources aren't safe for concurrent use, a source must not be
+	// shared between games. nil seeds one with Seed.
+	Rand rand.Source
+
+	// Seed seeds the source of randomness of games without a Rand, so a
+	// match replayed with the same seed and inputs plays out the same, see
+	// Game.Seed. 0 seeds it from the time.
+	Seed uint64
+
+	// Bans rejects the banned clients joining the game, rooms can share
+	// one. nil gives the game its own empty list, see Game.Bans.
+	Bans *BanList
+
+	// ReplaceDuplicates makes players joining with the ID of a player
+	// already in the game kick it, see ReplacePlayer. Otherwise the new
+	// connection is rejected.
+	ReplaceDuplicates bool
+
+	// MaxPlayers caps the players of the game, split cells, bots and
+	// spectators don't count. Joining a full game fails with ErrorRoomFull, 0 means no
+	// limit.
+	MaxPlayers int
+
+	// Bots fills the game with AI players while fewer than that many
+	// humans play, so sparse rooms feel alive, see Bot. Bots leave as
+	// humans join, never taking the seats of MaxPlayers. 0 spawns none.
+	Bots int
+
+	// LobbyDuration makes the game start in StateLobby, counting down that
+	// long before the match starts, or until it is full. 0 starts it right
+	// away.
+	LobbyDuration time.Duration
+
+	// Regions are the areas of the map with their own rules, see Region.
+	// None makes the map uniform.
+	Regions []Region
+
+	// MassThresholds are the masses, ascending, whose crossing emits an
+	// EventMassThreshold, for achievements.
+	MassThresholds []uint64
+
+	// SkinPalette restricts the colors players can pick, see ValidateSkin.
+	// Colors are RGB, without bits outside SKIN_MASK. None, the default,
+	// accepts any RGB color.
+	SkinPalette []uint32
+
+	// LeaderboardSize is the number of players of the OpLeaderboard
+	// frames, 0 takes LEADERBOARD_SIZE.
+	LeaderboardSize int
+
+	// IdleTimeout disconnects players sending no input for that long, so
+	// AFK players don't linger as immobile blobs. 0 never does.
+	IdleTimeout time.Duration
+
+	// HeartbeatInterval sends players an OpHeartbeat frame that often,
+	// which their client echoes, so both sides can measure the round trip
+	// and tell the connection is alive at the application layer, for
+	// client stacks and proxies that don't surface websocket pings. 0, the
+	// default, sends none.
+	HeartbeatInterval time.Duration
+
+	// HeartbeatTimeout disconnects players with CLOSE_HEARTBEAT once their
+	// client owes an echo for that long, catching half-open connections.
+	// It must be longer than HeartbeatInterval. 0 never does.
+	HeartbeatTimeout time.Duration
+
+	// SpawnProtection keeps players joining or respawning from being eaten
+	// for that long, so they aren't eaten as soon as they spawn next to a
+	// crowd. PassiveProtection also keeps them from eating meanwhile. 0
+	// disables it.
+	SpawnProtection   time.Duration
+	PassiveProtection bool
+
+	// QuantizePositions sends the positions of entities as uint16 steps
+	// within Bounds instead of float32, halving their size for around a
+	// tenth of a unit of precision, see QUANTIZATION_STEPS. Delta updates
+	// don't support it.
+	QuantizePositions bool
+
+	// Codec is the wire format of the frames sent to and received from
+	// clients, nil takes BinaryCodec. Custom codecs don't support delta
+	// updates.
+	Codec SnapshotCodec
+
+	// SweptEating checks whether players eat each other anywhere along
+	// their moves during a tick, not only where they end up, so a fast
+	// player can't pass through a bigger one unharmed. It costs a wider
+	// search every tick, see sweptCovers.
+	SweptEating bool
+}
+
+// DefaultGameConfig returns the configuration of a standard public game.
+func DefaultGameConfig() GameConfig {
+	return GameConfig{
+		Bounds:         worldBounds,
+		TickRate:       DEFAULT_TICK_RATE,
+		ViewportWidth:  DEFAULT_VIEWPORT_WIDTH,
+		ViewportHeight: DEFAULT_VIEWPORT_HEIGHT,
+		CellSize:       DEFAULT_CELL_SIZE,
+		FoodCount:      DEFAULT_FOOD_COUNT,
+		FoodDensity:    DEFAULT_FOOD_DENSITY,
+		FoodTiers:      DefaultFoodTiers(),
+		MaxEntities:    DEFAULT_MAX_ENTITIES,
+		VirusCount:     DEFAULT_VIRUS_COUNT,
+		VirusRadius:    DEFAULT_VIRUS_RADIUS,
+		VirusFeedMass:  DEFAULT_VIRUS_FEED_MASS,
+
+		SessionGracePeriod: DEFAULT_SESSION_GRACE_PERIOD,
+
+		StartMass:     STARTING_MASS,
+		MinMass:       STARTING_MASS,
+		MaxMass:       DEFAULT_MAX_MASS,
+		MassToRadiusK: MASS_TO_RADIUS_K,
+
+		EatSizeRatio:    EAT_SIZE_RATIO,
+		EatOverlapRatio: EAT_OVERLAP_RATIO,
+	}
+}
+
+// withDefaults fills in the zero mass and eat tunables.
+func (c GameConfig) withDefaults() GameConfig {
+	if c.StartMass == 0 {
+		c.StartMass = defaultMassRules.start
+	}
+	if c.MinMass == 0 {
+		c.MinMass = defaultMassRules.min
+	}
+	if c.MaxMass == 0 {
+		c.MaxMass = defaultMassRules.max
+	}
+	if c.MassToRadiusK == 0 {
+		c.MassToRadiusK = defaultMassRules.k
+	}
+	if c.EatSizeRatio == 0 {
+		c.EatSizeRatio = defaultMassRules.eatSize
+	}
+	if c.EatOverlapRatio == 0 {
+		c.EatOverlapRatio = defaultMassRules.eatOverlap
+	}
+	if c.MinSplitMass == 0 {
+		c.MinSplitMass = max(defaultMassRules.minSplit, 2*c.MinMass)
+	}
+	if c.MaxCells == 0 {
+		c.MaxCells = defaultMassRules.maxCells
+	}
+	return c
+}
+
+// Validate reports whether the rates, mass and eat tunables are consistent,
+// zero values standing for the defaults. A player must be bigger than its prey,
+// EatSizeRatio at least 1, and cover part of it, EatOverlapRatio in (0, 1].
+func (c GameConfig) Validate() error {
+	c = c.withDefaults()
+	if !(c.MassToRadiusK > 0) || math.IsInf(c.MassToRadiusK, 0) {
+		return fmt.Errorf("%w: MassToRadiusK must be positive, got %v", ErrorInvalidConfig, c.MassToRadiusK)
+	}
+	if c.MinMass > c.StartMass || c.StartMass > c.MaxMass {
+		return fmt.Errorf("%w: expected MinMass <= StartMass <= MaxMass, got %d, %d and %d", ErrorInvalidConfig, c.MinMass, c.StartMass, c.MaxMass)
+	}
+	if c.Shape > ShapeCircle {
+		return fmt.Errorf("%w: unknown Shape %v", ErrorInvalidConfig, c.Shape)
+	}
+	tickRate := c.TickRate
+	if tickRate <= 0 {
+		tickRate = DEFAULT_TICK_RATE
+	}
+	if c.BroadcastRate < 0 || c.BroadcastRate > tickRate {
+		return fmt.Errorf("%w: BroadcastRate must be at most TickRate, got %d and %d", ErrorInvalidConfig, c.BroadcastRate, tickRate)
+	}
+	if !(c.EatSizeRatio >= 1) || math.IsInf(c.EatSizeRatio, 0) {
+		return fmt.Errorf("%w: EatSizeRatio must be at least 1, got %v", ErrorInvalidConfig, c.EatSizeRatio)
+	}
+	if !(c.EatOverlapRatio > 0 && c.EatOverlapRatio <= 1) {
+		return fmt.Errorf("%w: EatOverlapRatio must be in (0, 1], got %v", ErrorInvalidConfig, c.EatOverlapRatio)
+	}
+	if c.MinSplitMass < 2*c.MinMass {
+		return fmt.Errorf("%w: MinSplitMass must be at least twice MinMass, got %d and %d", ErrorInvalidConfig, c.MinSplitMass, c.MinMass)
+	}
+	if c.MaxCells < 1 {
+		return fmt.Errorf("%w: MaxCells must be positive, got %d", ErrorInvalidConfig, c.MaxCells)
+	}
+	if c.SpawnProtection < 0 {
+		return fmt.Errorf("%w: SpawnProtection must not be negative, got %v", ErrorInvalidConfig, c.SpawnProtection)
+	}
+	if c.LobbyDuration < 0 {
+		return fmt.Errorf("%w: LobbyDuration must not be negative, got %v", ErrorInvalidConfig, c.LobbyDuration)
+	}
+	if c.HeartbeatInterval < 0 {
+		return fmt.Errorf("%w: HeartbeatInterval must not be negative, got %v", ErrorInvalidConfig, c.HeartbeatInterval)
+	}
+	if c.HeartbeatTimeout < 0 || (c.HeartbeatTimeout > 0 && c.HeartbeatTimeout <= c.HeartbeatInterval) {
+		return fmt.Errorf("%w: HeartbeatTimeout must be longer than HeartbeatInterval, got %v and %v", ErrorInvalidConfig, c.HeartbeatTimeout, c.HeartbeatInterval)
+	}
+	if err := validateFoodTiers(c.FoodTiers); err != nil {
+		return err
+	}
+	for _, region := range c.Regions {
+		if err := region.validate(); err != nil {
+			return err
+		}
+	}
+	for _, color := range c.SkinPalette {
+		if color&^SKIN_MASK != 0 {
+			return fmt.Errorf("%w: SkinPalette colors must be RGB, got %08x", ErrorInvalidConfig, color)
+		}
+	}
+	for i := 1; i < len(c.MassThresholds); i++ {
+		if c.MassThresholds[i] <= c.MassThresholds[i-1] {
+			return fmt.Errorf("%w: MassThresholds must be ascending, got %v", ErrorInvalidConfig, c.MassThresholds)
+		}
+	}
+	if c.MaxPlayers < 0 {
+		return fmt.Errorf("%w: MaxPlayers must not be negative, got %d", ErrorInvalidConfig, c.MaxPlayers)
+	}
+	if c.Bots < 0 {
+		return fmt.Errorf("%w: Bots must not be negative, got %d", ErrorInvalidConfig, c.Bots)
+	}
+	if c.Codec != nil && c.KeyframeInterval > 0 {
+		return fmt.Errorf("%w: Codec doesn't support delta updates, got KeyframeInterval %d", ErrorInvalidConfig, c.KeyframeInterval)
+	}
+	if c.QuantizePositions && c.KeyframeInterval > 0 {
+		return fmt.Errorf("%w: QuantizePositions doesn't support delta updates, got KeyframeInterval %d", ErrorInvalidConfig, c.KeyframeInterval)
+	}
+	return nil
+}
+
+// Game is a server authoritative simulation. Unlike World, which relays the
+// positions and sizes reported by clients, players only send the direction
+// they want to move in and the game moves them and resolves who eats whom
+// on every tick. It is safe for concurrent use, inputs arrive from
+// connection goroutines while the tick loop runs on its own.
+type Game struct {
+	sync.RWMutex
+	config  GameConfig
+	players map[uuid.UUID]*Player
+	food    map[uuid.UUID]*Food
+	viruses map[uuid.UUID]*Virus
+	ejected map[uuid.UUID]*EjectedMass
+
+	// resized is set once Resize changed config.Bounds, so joining clients
+	// are told, see sendResized.
+	resized bool
+
+	// bots are the AI players of the game by player ID, see
+	// GameConfig.Bots.
+	bots map[uuid.UUID]*Bot
+
+	// spectators watch the game, they are never part of the index.
+	spectators map[uuid.UUID]*Spectator
+
+	// sessions maps session tokens to the players they resume.
+	sessions map[uuid.UUID]uuid.UUID
+
+	// rand is the source of randomness of the game, guarded by the lock,
+	// seeded with seed unless GameConfig.Rand was given. source is what it
+	// draws from, see Clone.
+	rand   *rand.Rand
+	source rand.Source
+	seed   uint64
+
+	// rules are the mass rules of the players, from the config.
+	rules massRules
+
+	// index holds every living player and pellet, keeping collisions and
+	// viewport queries proportional to what is nearby rather than to the
+	// world.
+	index SpatialIndex
+
+	// netIDs are the network IDs of the entities in the index.
+	netIDs *netIDs
+
+	// bans are checked whenever a client connects.
+	bans *BanList
+
+	// paused freezes Run, see Pause.
+	paused atomic.Bool
+
+	// sequence is the sequence of the last broadcast, see SnapshotHeader.
+	// broadcasting is held while state updates are encoded and queued, by
+	// Broadcast and sendInitialState.
+	sequence     atomic.Uint32
+	broadcasting sync.Mutex
+
+	// humanCount, botCount and spectatorCount follow the players that
+	// aren't bots, split cells aside, the bots and the spectators as they
+	// come and go under the lock, so counting them doesn't take it. See
+	// PlayerCount.
+	humanCount     atomic.Int64
+	botCount       atomic.Int64
+	spectatorCount atomic.Int64
+
+	// state is the phase of the match, lobbyLeft the time left until it
+	// starts and announced the last second of it told to clients.
+	state     GameState
+	lobbyLeft time.Duration
+	announced int
+
+	// dispatcher routes the frames received from players, droppedInputs
+	// counts the malformed OpInput frames it dropped.
+	dispatcher    *Dispatcher
+	droppedInputs atomic.Uint64
+
+	onDeath func(Death)
+
+	// recorder, when set, records the inputs and state of the game.
+	recorder *Recorder
+
+	// room is the ID the game reports its metrics under.
+	room    string
+	metrics Metrics
+
+	// onReaped, when set, is called without the lock after players whose
+	// grace period ran out were removed, see RoomManager.
+	onReaped func()
+
+	// board is the leaderboard last sent to clients, see
+	// announceLeaderboard.
+	board []LeaderboardEntry
+
+	// sink receives the events queued since the last flushEvents, leader
+	// is the last known top player.
+	sink   EventSink
+	events []Event
+	leader uuid.UUID
+
+	statsMutex sync.Mutex
+	tickStats  TickStats
+	tickTotal  time.Duration
+	worldStats worldStats
+	onSlowTick func(took time.Duration, budget time.Duration)
+
+	// closed is closed by Close, once.
+	closeOnce sync.Once
+	closed    chan struct{}
+}
+
+// TickResult describes what happened during a tick.
+type TickResult struct {
+	// EatenPlayers are the players removed from the game because another
+	// player ate them.
+	EatenPlayers []uuid.UUID
+
+	// EatenFood are the pellets and ejected mass consumed by players.
+	EatenFood []uuid.UUID
+
+	// PoppedViruses are the viruses that burst a player.
+	PoppedViruses []uuid.UUID
+
+	// ShotViruses are the viruses shot by fed ones, see
+	// GameConfig.VirusFeedMass.
+	ShotViruses []uuid.UUID
+
+	// MergedCells are the split cells that merged back into their owner.
+	MergedCells []uuid.UUID
+
+	// Deaths are the players that lost all their cells, they stay in the
+	// game until they respawn.
+	Deaths []Death
+}
+
+// PlayerSnapshot is the state of a player at a point in time.
+type PlayerSnapshot struct {
+	PlayerID uuid.UUID
+	Position utils.Vector2D
+	Mass     uint64
+	Radius   uint32
+	Color    uint32
+}
+
+// Snapshot is the state of the whole game at a point in time.
+type Snapshot struct {
+	Players []PlayerSnapshot
+	Food    []Food
+	Viruses []Virus
+}
+
+// NewGame creates a game with the configured food and viruses. It fails
+// if config isn't valid, see GameConfig.Validate.
+func NewGame(config GameConfig) (*Game, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	config = config.withDefaults()
+
+	g := &Game{
+		config:  config,
+		players: make(map[uuid.UUID]*Player),
+		food:    make(map[uuid.UUID]*Food),
+		viruses: make(map[uuid.UUID]*Virus),
+		ejected: make(map[uuid.UUID]*EjectedMass),
+
+		bots:       make(map[uuid.UUID]*Bot),
+		spectators: make(map[uuid.UUID]*Spectator),
+		sessions:   make(map[uuid.UUID]uuid.UUID),
+		rules: massRules{
+			start: config.StartMass,
+			min:   config.MinMass,
+			max:   config.MaxMass,
+			k:     config.MassToRadiusK,
+
+			eatSize:    config.EatSizeRatio,
+			eatOverlap: config.EatOverlapRatio,
+
+			minSplit: config.MinSplitMass,
+			maxCells: config.MaxCells,
+
+			spawnProtection:   config.SpawnProtection,
+			passiveProtection: config.PassiveProtection,
+
+			palette: slices.Clone(config.SkinPalette),
+		},
+		metrics: config.Metrics,
+		closed:  make(chan struct{}),
+	}
+	g.resetIndex()
+	g.dispatcher = g.newDispatcher()
+	if g.metrics == nil {
+		g.metrics = NopMetrics{}
+	}
+	source := config.Rand
+	if source == nil {
+		g.seed = config.Seed
+		if g.seed == 0 {
+			g.seed = uint64(time.Now().UnixNano())
+		}
+		source = rand.NewPCG(g.seed, g.seed)
+	}
+	g.rand, g.source = rand.New(source), source
+	g.sink = config.Events
+	if g.sink == nil {
+		g.sink = NopEventSink{}
+	}
+	g.bans = config.Bans
+	if g.bans == nil {
+		g.bans = NewBanList()
+	}
+	g.state = StateActive
+	if config.LobbyDuration > 0 {
+		g.state, g.lobbyLeft, g.announced = StateLobby, config.LobbyDuration, -1
+	}
+	g.SpawnFood(g.foodTarget())
+	for range config.VirusCount {
+		g.spawnVirus()
+	}
+	return g, nil
+}
+
+// AddPlayer adds p to the game under its PlayerID, in team mode it joins
+// the smallest team. It returns ErrorDuplicatePlayer, leaving the game as
+// it was, if another player has the same ID, see ReplacePlayer, and
+// ErrorRoomFull if the game has MaxPlayers players already.
+func (g *Game) AddPlayer(p *Player) error {
+	g.Lock()
+	if _, exists := g.players[p.PlayerID]; exists {
+		g.Unlock()
+		return ErrorDuplicatePlayer
+	}
+	if !p.IsCell() && g.full() {
+		g.Unlock()
+		return ErrorRoomFull
+	}
+	g.addPlayer(p)
+	g.Unlock()
+
+	g.flushEvents()
+	return nil
+}
+
+// ReplacePlayer adds p to the game like AddPlayer, kicking the player with
+// the same ID if there is one: it is removed along with its split cells
+// and its connection is closed. Replacing a player always fits, adding a
+// new one fails with ErrorRoomFull if the game is full.
+func (g *Game) ReplacePlayer(p *Player) error {
+	g.Lock()
+	var stale ClientConnection
+	if old, exists := g.players[p.PlayerID]; exists {
+		stale = g.evict(old)
+		log.Printf("player %v joined again, kicking the previous one", p.PlayerID)
+	} else if !p.IsCell() && g.full() {
+		g.Unlock()
+		return ErrorRoomFull
+	}
+	g.addPlayer(p)
+	g.Unlock()
+
+	g.flushEvents()
+	if stale != nil {
+		go closeWithReason(stale, CLOSE_REPLACED, "replaced")
+	}
+	return nil
+}
+
+// Full reports whether the game has MaxPlayers players, bots aside, new
+// players joining it are rejected with ErrorRoomFull.
+func (g *Game) Full() bool {
+	g.RLock()
+	defer g.RUnlock()
+	return g.full()
+}
+
+// full implements Full, the caller must hold the lock.
+func (g *Game) full() bool {
+	if g.config.MaxPlayers <= 0 {
+		return false
+	}
+	// Bots make room for humans, see driveBots.
+	return g.humans() >= g.config.MaxPlayers
+}
+
+// evict removes player and its split cells from the game and detaches its
+// connection, which the caller closes outside of the lock. The caller must
+// hold the lock.
+func (g *Game) evict(player *Player) ClientConnection {
+	player.Lock()
+	// Its onClose no longer matches the generation of the player.
+	player.generation++
+	conn := player.conn
+	player.conn = nil
+	player.Unlock()
+
+	for _, cell := range g.cells(player.PlayerID) {
+		g.removePlayer(cell.PlayerID)
+	}
+	g.removePlayer(player.PlayerID)
+	g.emit(EventPlayerLeft, player.PlayerID, Event{})
+	return conn
+}
+
+// addPlayer adds p to the game, the caller must hold the lock.
+func (g *Game) addPlayer(p *Player) {
+	p.Lock()
+	p.Position = g.arena().Clamp(p.Position)
+	p.rules = &g.rules
+	p.recomputeRadius()
+	p.lastInput = time.Now()
+	if !p.IsCell() {
+		p.protection = g.rules.spawnProtection
+	}
+	p.Unlock()
+
+	if old, exists := g.players[p.PlayerID]; exists {
+		g.count(old, -1)
+	}
+	g.players[p.PlayerID] = p
+	g.count(p, 1)
+	if g.config.Teams > 0 {
+		g.assignTeam(p)
+	}
+	g.reindex(p)
+	if !p.IsCell() {
+		g.emit(EventPlayerJoined, p.PlayerID, Event{})
+	}
+	g.recordJoin(p)
+}
+
+// RemovePlayer removes a player and its split cells from the game.
+func (g *Game) RemovePlayer(id uuid.UUID) {
+	g.Lock()
+	for _, cell := range g.cells(id) {
+		g.removePlayer(cell.PlayerID)
+	}
+	if _, exists := g.players[id]; exists {
+		g.emit(EventPlayerLeft, id, Event{})
+	}
+	g.removePlayer(id)
+	g.Unlock()
+
+	g.flushEvents()
+}
+
+func (g *Game) removePlayer(id uuid.UUID) {
+	if player, exists := g.players[id]; exists {
+		player.RLock()
+		delete(g.sessions, player.session)
+		player.RUnlock()
+		g.count(player, -1)
+	}
+	delete(g.players, id)
+	g.index.Remove(id)
+}
+
+// count adds delta to the count of players p belongs to, split cells
+// aside. The caller must hold the lock, bots must be in g.bots while
+// counted.
+func (g *Game) count(p *Player, delta int64) {
+	switch {
+	case p.IsCell():
+	case g.isBot(p):
+		g.botCount.Add(delta)
+	default:
+		g.humanCount.Add(delta)
+	}
+}
+
+func (g *Game) removeFood(id uuid.UUID) {
+	delete(g.food, id)
+	g.index.Remove(id)
+}
+
+// reindex updates the position and size of p in the spatial index.
+func (g *Game) reindex(p *Player) {
+	position, radius := p.circle()
+	g.index.Move(p.PlayerID, position, radius)
+}
+
+// SpawnFood scatters up to n pellets at random positions of the world,
+// stopping once it holds MaxEntities. It returns how many were spawned.
+func (g *Game) SpawnFood(n int) int {
+	g.Lock()
+	defer g.Unlock()
+	return g.spawnFood(n)
+}
+
+// spawnFood implements SpawnFood, the caller must hold the lock.
+func (g *Game) spawnFood(n int) int {
+	if limit := g.config.MaxEntities; limit > 0 {
+		n = min(n, limit-g.index.Len())
+	}
+
+	for i := 0; i < n; i++ {
+		food := g.newFood()
+		g.food[food.ID] = food
+		g.index.Insert(food.ID, food.Position, FOOD_RADIUS)
+	}
+	return max(n, 0)
+}
+
+// newID returns a random version 4 UUID drawn from the game's source of
+// randomness, for the entities it spawns. The caller must hold the lock.
+func (g *Game) newID() uuid.UUID {
+	var id uuid.UUID
+	binary.LittleEndian.PutUint64(id[:8], g.rand.Uint64())
+	binary.LittleEndian.PutUint64(id[8:], g.rand.Uint64())
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return id
+}
+
+// foodTarget is the number of pellets the game keeps.
+func (g *Game) foodTarget() int {
+	if g.config.FoodDensity > 0 {
+		return int(g.config.FoodDensity * g.arena().Area() / 1e6)
+	}
+	return g.config.FoodCount
+}
+
+// EntityCount returns the number of entities in the world: living players,
+// pellets, viruses and ejected mass.
+func (g *Game) EntityCount() int {
+	g.RLock()
+	defer g.RUnlock()
+	return g.index.Len()
+}
+
+// Bounds returns the area of the world.
+func (g *Game) Bounds() utils.Rect {
+	g.RLock()
+	defer g.RUnlock()
+	return g.config.Bounds
+}
+
+// Seed returns the seed of the source of randomness of the game, so a
+// match can be reproduced by creating a game with it as GameConfig.Seed and
+// feeding it the recorded inputs. It is 0 for games given their own
+// GameConfig.Rand.
+func (g *Game) Seed() uint64 {
+	return g.seed
+}
+
+func (g *Game) randomPosition() utils.Vector2D {
+	return g.arena().random(g.rand)
+}
+
+func (g *Game) Player(id uuid.UUID) (*Player, bool) {
+	g.RLock()
+	defer g.RUnlock()
+	player, exists := g.players[id]
+	return player, exists
+}
+
+// Tick advances the simulation by dt: every player applies the actions it
+// requested and moves towards its last requested direction, viruses absorb
+// the ejected mass reaching them, players eat the pellets under them, burst
+// on the viruses they cover and then overlapping players eat each other,
+// heaviest first, see eatOrder. Last, split cells whose cooldown is over
+// merge back into their owner.
+// Games in their lobby only count down, see GameConfig.LobbyDuration.
+func (g *Game) Tick(dt time.Duration) TickResult {
+	g.Lock()
+	defer g.Unlock()
+
+	// Only the countdown runs before the match and nothing after it.
+	if g.state != StateActive {
+		if g.state == StateLobby {
+			g.countDown(dt)
+		}
+		return TickResult{}
+	}
+
+	g.driveBots(dt)
+
+	// Players are gone through in order, so the same inputs from the same
+	// seed play out the same. Cells split here aren't visited, they have no
+	// actions.
+	for _, player := range inOrder(g, g.players) {
+		if !player.IsAlive() {
+			continue
+		}
+
+		actions := player.takeActions()
+		if actions&ActionSplit != 0 {
+			// Too small or already split players just don't split.
+			g.split(player)
+		}
+		if actions&ActionEject != 0 {
+			g.eject(player)
+		}
+		if actions&ActionBoost != 0 {
+			g.boost(player)
+		}
+	}
+
+	world := g.arena()
+
+	// Where players started the tick, and the farthest any moved, for
+	// SweptEating.
+	var from map[*Player]utils.Vector2D
+	var travel float64
+	if g.config.SweptEating {
+		from = make(map[*Player]utils.Vector2D, len(g.players))
+	}
+	for _, player := range inOrder(g, g.players) {
+		if !player.IsAlive() {
+			continue
+		}
+
+		if from != nil {
+			from[player] = player.GetPosition()
+		}
+		player.move(g.direction(player), dt, world)
+		if from != nil {
+			travel = max(travel, from[player].Distance(player.GetPosition()))
+		}
+		player.cooldown(dt)
+		if g.config.DecayRate > 0 {
+			rate := g.config.DecayRate * g.regionAt(player.GetPosition()).decayMultiplier()
+			player.decay(rate, dt)
+		}
+		g.reindex(player)
+	}
+	for _, spectator := range g.spectators {
+		spectator.move(dt, world)
+	}
+	for _, ejected := range g.ejected {
+		ejected.move(dt, world)
+		g.index.Move(ejected.ID, ejected.Position, EJECT_RADIUS)
+	}
+	for _, virus := range g.viruses {
+		if virus.Velocity != (utils.Vector2D{}) {
+			virus.move(dt, world)
+			g.index.Move(virus.ID, virus.Position, float64(virus.Radius))
+		}
+	}
+
+	var result TickResult
+	result.ShotViruses = g.feedViruses()
+	for _, player := range inOrder(g, g.players) {
+		if !player.IsAlive() {
+			continue
+		}
+
+		position, radius := player.circle()
+		for _, id := range g.inRange(utils.RectAround(position, radius, radius)) {
+			if food, isFood := g.food[id]; isFood && position.DistanceSquared(food.Position) < radius*radius {
+				value := g.eatFood(player, food)
+				g.removeFood(id)
+				g.emitTransfer(player, id, EntityFood, value)
+				result.EatenFood = append(result.EatenFood, id)
+			} else if ejected, isEjected := g.ejected[id]; isEjected && position.DistanceSquared(ejected.Position) < radius*radius {
+				player.addMass(ejected.Mass)
+				g.removeEjected(id)
+				g.emitTransfer(player, id, EntityEjectedMass, ejected.Mass)
+				result.EatenFood = append(result.EatenFood, id)
+			}
+		}
+		g.reindex(player)
+	}
+
+	for _, player := range inOrder(g, g.players) {
+		if !player.IsAlive() {
+			continue
+		}
+
+		position, radius := player.circle()
+		for _, id := range g.inRange(utils.RectAround(position, radius, radius)) {
+			virus, isVirus := g.viruses[id]
+			if !isVirus || !covers(position, radius, virus.Position, float64(virus.Radius), g.rules.eatSize, g.rules.eatOverlap) {
+				continue
+			}
+			if !g.burst(player) {
+				continue
+			}
+
+			g.removeVirus(id)
+			if !virus.shot {
+				g.spawnVirus()
+			}
+			result.PoppedViruses = append(result.PoppedViruses, id)
+			break
+		}
+	}
+
+	for _, eater := range g.eatOrder() {
+		// Players eaten earlier in the loop don't eat anymore, the cells
+		// eaten are out of the game.
+		if !eater.IsAlive() || g.players[eater.PlayerID] != eater {
+			continue
+		}
+
+		position, radius := eater.circle()
+		area := utils.RectAround(position, radius, radius)
+		if from != nil {
+			area = sweptArea(startOfTick(from, eater), position, radius+travel)
+		}
+		for _, id := range g.inRange(area) {
+			prey, isPlayer := g.players[id]
+			if !isPlayer || !prey.IsAlive() {
+				continue
+			}
+			if from != nil {
+				if !eater.canEatAlong(prey, startOfTick(from, eater), startOfTick(from, prey)) {
+					continue
+				}
+			} else if !eater.CanEat(prey) {
+				continue
+			}
+
+			g.kill(prey, eater, &result)
+		}
+		g.reindex(eater)
+	}
+
+	result.MergedCells = g.merge()
+	if missing := g.foodTarget() - len(g.food); missing > 0 {
+		g.spawnFood(min(missing, FOOD_RESPAWN_PER_TICK))
+	}
+	g.trackProgress()
+	g.updateStats()
+	return result
+}
+
+// Snapshot returns the current state of every player, pellet and virus,
+// each ordered by network ID so that the same state gives the same
+// snapshot.
+func (g *Game) Snapshot() Snapshot {
+	g.RLock()
+	defer g.RUnlock()
+
+	snapshot := Snapshot{
+		Players: make([]PlayerSnapshot, 0, len(g.players)),
+	}
+	for _, player := range inOrder(g, g.players) {
+		if player.IsAlive() {
+			snapshot.Players = append(snapshot.Players, player.snapshot())
+		}
+	}
+	snapshot.Food = make([]Food, 0, len(g.food))
+	for _, food := range inOrder(g, g.food) {
+		snapshot.Food = append(snapshot.Food, *food)
+	}
+	snapshot.Viruses = make([]Virus, 0, len(g.viruses))
+	for _, virus := range inOrder(g, g.viruses) {
+		snapshot.Viruses = append(snapshot.Viruses, *virus)
+	}
+	return snapshot
+}
+
+// Viewport returns the entities p can see: every player and pellet touching
+// its ViewportArea, p included.
+func (g *Game) Viewport(p *Player) []Entity {
+	g.RLock()
+	defer g.RUnlock()
+	return g.viewport(p)
+}
+
+// ViewportArea returns the area p can see: the configured viewport size
+// centered on p, zoomed out with the square root of its mass up to
+// MAX_VIEWPORT_ZOOM, grown by its radius and clamped to the world.
+func (g *Game) ViewportArea(p *Player) utils.Rect {
+	p.RLock()
+	position, radius, mass := p.Position, float64(p.Radius), p.Mass
+	p.RUnlock()
+
+	zoom := min(max(math.Sqrt(float64(mass)/float64(g.rules.start)), 1), MAX_VIEWPORT_ZOOM)
+	area := utils.RectAround(position, g.config.ViewportWidth/2*zoom+radius, g.config.ViewportHeight/2*zoom+radius)
+	bounds := g.arena().bounds
+	return utils.Rect{
+		Min: bounds.Clamp(area.Min),
+		Max: bounds.Clamp(area.Max),
+	}
+}
+
+func (g *Game) viewport(p *Player) []Entity {
+	return g.viewportIn(g.ViewportArea(p))
+}
+
+// viewportAt returns the entities in the viewport centered on position,
+// grown by radius.
+func (g *Game) viewportAt(position utils.Vector2D, radius float64) []Entity {
+	return g.viewportIn(utils.RectAround(position, g.config.ViewportWidth/2+radius, g.config.ViewportHeight/2+radius))
+}
+
+// viewportIn returns the entities touching area, ordered by network ID.
+func (g *Game) viewportIn(area utils.Rect) []Entity {
+	var entities []Entity
+	for _, id := range g.index.QueryRange(area) {
+		entity, exists := g.entityOf(id)
+		if exists && area.IntersectsCircle(entity.Position, float64(entity.Radius)) {
+			entities = append(entities, entity)
+		}
+	}
+	sortByNetID(entities)
+	return entities
+}
+
+// entityOf returns the entity id with its network ID, false if it isn't in
+// the game anymore.
+func (g *Game) entityOf(id uuid.UUID) (Entity, bool) {
+	var entity Entity
+	if player, isPlayer := g.players[id]; isPlayer {
+		entity = player.snapshot().entity()
+	} else if food, isFood := g.food[id]; isFood {
+		entity = food.entity()
+	} else if virus, isVirus := g.viruses[id]; isVirus {
+		entity = virus.entity()
+	} else if ejected, isEjected := g.ejected[id]; isEjected {
+		entity = ejected.entity()
+	} else {
+		return Entity{}, false
+	}
+	return g.withNetID(entity), true
+}
+
+func (g *Game) encodeViewport(p *Player, entities []Entity) []byte {
+	if g.config.KeyframeInterval <= 0 {
+		return g.encodeEntities(entities)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	if p.encoder == nil {
+		p.encoder = NewDeltaEncoder(g.config.KeyframeInterval)
+	}
+	return p.encoder.Encode(entities)
+}
+
+// Broadcast sends every player the entities in its viewport, and every
+// spectator those around its camera or in the viewport of the player it
+// observes, see Observe, along with the entities they were sent that left
+// the game since, see DecodeRemovals. Clients that aren't keeping up are
+// skipped until they catch up, see SaturatedConnection. It must not
+// be called concurrently, delta updates depend on the previous broadcast.
+func (g *Game) Broadcast() {
+	g.broadcasting.Lock()
+	defer g.broadcasting.Unlock()
+
+	header := g.nextSnapshotHeader()
+	removed := g.drainRemovals()
+	g.RLock()
+	updates := make([]update, 0, len(g.players)+len(g.spectators))
+	for _, player := range g.players {
+		player.forgetRemoved(removed)
+		// Skipping happens before encoding, so delta encoders don't count
+		// the update as sent. Clients get no state before their hello.
+		if player.saturated() || player.ProtocolVersion() == 0 {
+			continue
+		}
+		updates = append(updates, g.playerUpdate(player, header))
+	}
+	var detached []*Spectator
+	if len(g.spectators) > 0 {
+		leader, found := g.leaderPosition()
+		for _, spectator := range g.spectators {
+			spectator.forgetRemoved(removed)
+			target, present := g.observed(spectator)
+			if !present {
+				detached = append(detached, spectator)
+				continue
+			}
+			if spectator.saturated() || spectator.ProtocolVersion() == 0 {
+				continue
+			}
+
+			var entities []Entity
+			if target != nil {
+				entities = g.viewport(target)
+			} else {
+				entities = g.viewportAt(spectator.follow(leader, found), 0)
+			}
+			var gone []uint32
+			tracked := spectator.ProtocolVersion() >= REMOVALS_PROTOCOL_VERSION
+			if tracked {
+				gone = spectator.takeRemovals(entities)
+			}
+			if spectator.debug() {
+				frame := debugFrame{Op: OpStateSnapshot.String(), Header: &header, Entities: entities, Removed: gone}
+				updates = append(updates, update{client: spectator, text: frame.encode()})
+				continue
+			}
+			body := g.encodeState(header, entities, gone, tracked, func() []byte {
+				return g.encodeSpectatorView(spectator, entities)
+			})
+			updates = append(updates, update{client: spectator, data: EncodeFrame(OpStateSnapshot, body)})
+		}
+	}
+	g.RUnlock()
+	g.detachObservers(detached)
+
+	// Send outside the lock so a slow connection doesn't stall the game.
+	for _, u := range updates {
+		u.send()
+	}
+}
+
+// update is the OpStateSnapshot frame of a client, or its JSON form for
+// debugging clients.
+type update struct {
+	client client
+	data   []byte
+	text   string
+}
+
+func (u update) send() {
+	if u.data == nil {
+		u.client.sendText(u.text)
+		return
+	}
+	u.client.SendBinary(u.data)
+}
+
+// playerUpdate encodes the entities in the viewport of player under
+// header. The caller must hold the lock.
+func (g *Game) playerUpdate(player *Player, header SnapshotHeader) update {
+	entities := g.viewport(player)
+	var gone []uint32
+	tracked := player.ProtocolVersion() >= REMOVALS_PROTOCOL_VERSION
+	if tracked {
+		gone = player.takeRemovals(entities)
+	}
+	if player.debug() {
+		frame := debugFrame{Op: OpStateSnapshot.String(), Header: &header, Entities: entities, Removed: gone}
+		return update{client: player, text: frame.encode()}
+	}
+	body := g.encodeState(header, entities, gone, tracked, func() []byte {
+		return g.encodeViewport(player, entities)
+	})
+	return update{client: player, data: EncodeFrame(OpStateSnapshot, body)}
+}
+
+// sendInitialState sends a player that just said hello the whole of its
+// viewport right away, so it doesn't wait for the next broadcast, nor for
+// the next keyframe of delta updates, to see the world. The snapshot
+// carries the sequence of the last broadcast, the next one follows it.
+func (g *Game) sendInitialState(player *Player) {
+	// Deltas mustn't be encoded against the keyframe before it is queued.
+	g.broadcasting.Lock()
+	defer g.broadcasting.Unlock()
+
+	g.RLock()
+	if g.players[player.PlayerID] != player {
+		g.RUnlock()
+		return
+	}
+	player.Lock()
+	if player.encoder != nil {
+		player.encoder.ForceKeyframe()
+	}
+	player.Unlock()
+	u := g.playerUpdate(player, SnapshotHeader{Sequence: g.sequence.Load(), Time: time.Now()})
+	g.RUnlock()
+	u.send()
+}
+
+// client is a player or spectator receiving the frames of the game.
+type client interface {
+	SendBinary(data []byte) error
+	sendText(text string) error
+	debug() bool
+	ProtocolVersion() uint16
+}
+
+// clients returns the players and spectators of the game, split cells
+// aside. Games only ever send to their own clients, so nothing leaks
+// between the rooms of a RoomManager. The caller must hold the lock.
+func (g *Game) clients() []client {
+	clients := make([]client, 0, len(g.players)+len(g.spectators))
+	for _, player := range g.players {
+		if !player.IsCell() {
+			clients = append(clients, player)
+		}
+	}
+	for _, spectator := range g.spectators {
+		clients = append(clients, spectator)
+	}
+	return clients
+}
+
+// SendAll sends frame to every player and spectator of the game that said
+// hello, and only to them. Like SendBinary, the frame must not be modified
+// afterwards.
+func (g *Game) SendAll(frame []byte) {
+	g.RLock()
+	clients := g.clients()
+	g.RUnlock()
+
+	for _, client := range clients {
+		if client.ProtocolVersion() != 0 {
+			client.SendBinary(frame)
+		}
+	}
+}
+
+// Run ticks the game at the configured tick rate with a fixed timestep, see
+// GameLoop, broadcasting the latest state at the broadcast rate and the
+// HUD of every player every HUD_INTERVAL, until ctx is done or the game is
+// closed.
+func (g *Game) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	if g.config.SessionGracePeriod > 0 || g.config.IdleTimeout > 0 {
+		go g.reapSessions(ctx)
+	}
+	if g.config.HeartbeatInterval > 0 {
+		go g.sendHeartbeats(ctx)
+	}
+
+	loop := NewGameLoop(g)
+	interval := loop.Interval()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	var sinceHUD time.Duration
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-g.closed:
+			return
+		case now := <-ticker.C:
+			// Time spent paused is skipped rather than caught up on,
+			// which would teleport everyone on resume.
+			if g.Paused() {
+				last = now
+				continue
+			}
+
+			start := time.Now()
+			steps := loop.Advance(now.Sub(last))
+			last = now
+			if steps == 0 {
+				continue
+			}
+
+			g.metrics.TickDuration(g.room, time.Since(start))
+			g.metrics.Players(g.room, g.PlayerCount())
+			if loop.BroadcastDue() {
+				g.Broadcast()
+				g.announceLeaderboard()
+			}
+			if sinceHUD += time.Duration(steps) * interval; sinceHUD >= HUD_INTERVAL {
+				sinceHUD = 0
+				g.announceHUD()
+			}
+			g.observeTick(time.Since(start), interval)
+		}
+	}
+}
+
+// notifyDeaths sends an OpDeath frame to every eaten player and calls the
+// function registered with SetOnDeath.
+func (g *Game) notifyDeaths(deaths []Death) {
+	g.RLock()
+	onDeath := g.onDeath
+	g.RUnlock()
+
+	for _, death := range deaths {
+		if player, exists := g.Player(death.PlayerID); exists && player.ProtocolVersion() != 0 {
+			if player.debug() {
+				player.sendText(debugFrame{Op: OpDeath.String(), Death: &death}.encode())
+			} else {
+				player.sendUrgent(EncodeFrame(OpDeath, g.codec().EncodeDeath(death)))
+			}
+		}
+		if onDeath != nil {
+			onDeath(death)
+		}
+	}
+}
+
+// absorb adds the mass of prey to p and returns it.
+func (p *Player) absorb(prey *Player) uint64 {
+	mass := prey.Score()
+	p.addMass(mass)
+	return mass
+}
@@ -0,0 +1,465 @@
+package galaxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	pb "galaxy.io/server/proto"
+	"github.com/google/uuid"
+)
+
+// testConfig is a small game without viruses, bots nor randomness from the
+// clock, so that tests play out the same on every run.
+func testConfig() GameConfig {
+	config := DefaultGameConfig()
+	config.VirusCount = 0
+	config.Seed = 1
+	return config
+}
+
+func newTestGame(t testing.TB, config GameConfig) *Game {
+	t.Helper()
+
+	g, err := NewGame(config)
+	if err != nil {
+		t.Fatalf("NewGame: %v", err)
+	}
+	return g
+}
+
+// joinTestPlayer adds a player without a connection at position to g.
+func joinTestPlayer(t testing.TB, g *Game, mass uint64, x, y float64) *Player {
+	t.Helper()
+
+	player := NewPlayer(uuid.New(), nil)
+	player.PlayerID = uuid.New()
+	player.Mass = mass
+	player.Position.X, player.Position.Y = x, y
+	if err := g.AddPlayer(player); err != nil {
+		t.Fatalf("AddPlayer: %v", err)
+	}
+	return player
+}
+
+// fakeConn is a ClientConnection keeping what is sent to it.
+type fakeConn struct {
+	sync.Mutex
+	frames  [][]byte
+	code    int
+	closed  bool
+	onClose func(reason CloseReason, err error)
+	handler func(frame []byte)
+
+	// maxMessageSize is reported as the MessageSizeLimiter of c.
+	maxMessageSize int64
+}
+
+func (c *fakeConn) MaxMessageSize() int64 {
+	return c.maxMessageSize
+}
+
+func (c *fakeConn) SendEvent(event *pb.Event) error {
+	return nil
+}
+
+func (c *fakeConn) SendBinary(data []byte) error {
+	c.Lock()
+	defer c.Unlock()
+	c.frames = append(c.frames, data)
+	return nil
+}
+
+func (c *fakeConn) Close() {
+	c.close(CloseNormal, 0)
+}
+
+func (c *fakeConn) CloseWithReason(code int, reason string) {
+	c.close(CloseKicked, code)
+}
+
+func (c *fakeConn) close(reason CloseReason, code int) {
+	c.Lock()
+	if c.closed {
+		c.Unlock()
+		return
+	}
+	c.closed, c.code = true, code
+	onClose := c.onClose
+	c.Unlock()
+
+	if onClose != nil {
+		onClose(reason, nil)
+	}
+}
+
+// isClosed returns whether c is closed and with which code.
+func (c *fakeConn) isClosed() (bool, int) {
+	c.Lock()
+	defer c.Unlock()
+	return c.closed, c.code
+}
+
+// sent returns the frames sent to c.
+func (c *fakeConn) sent() [][]byte {
+	c.Lock()
+	defer c.Unlock()
+	return slices.Clone(c.frames)
+}
+
+// payloads returns the payloads of the frames with opcode op sent to c.
+func (c *fakeConn) payloads(op Opcode) [][]byte {
+	var payloads [][]byte
+	for _, frame := range c.sent() {
+		if frameOp, payload, err := DecodeFrame(frame); err == nil && frameOp == op {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
+
+// connectTestPlayer gives player a fake connection that said hello with the
+// current protocol version.
+func connectTestPlayer(player *Player) *fakeConn {
+	conn := &fakeConn{}
+	player.Lock()
+	player.conn = conn
+	player.protocol = PROTOCOL_VERSION
+	player.Unlock()
+	return conn
+}
+
+// fakeFactory is a ConnectionFactory of fakeConns, upgrading every request.
+type fakeFactory struct {
+	sync.Mutex
+	conns []*fakeConn
+
+	// maxMessageSize is the MaxMessageSize of the connections upgraded.
+	maxMessageSize int64
+}
+
+func (f *fakeFactory) NewConnection(w http.ResponseWriter, r *http.Request, operationHandler func(*pb.Operation), onClose func(reason CloseReason, err error)) (ClientConnection, error) {
+	return nil, ErrorNotWebSocket
+}
+
+func (f *fakeFactory) NewFrameConnection(w http.ResponseWriter, r *http.Request, frameHandler func([]byte), onClose func(reason CloseReason, err error)) (ClientConnection, error) {
+	f.Lock()
+	conn := &fakeConn{onClose: onClose, handler: frameHandler, maxMessageSize: f.maxMessageSize}
+	f.conns = append(f.conns, conn)
+	f.Unlock()
+	return conn, nil
+}
+
+// last returns the last connection f upgraded.
+func (f *fakeFactory) last() *fakeConn {
+	f.Lock()
+	defer f.Unlock()
+	if len(f.conns) == 0 {
+		return nil
+	}
+	return f.conns[len(f.conns)-1]
+}
+
+// connect sends a request to /game with query through handle, such as
+// RoomManager.HandleNewConnection, returning the response.
+func connect(handle func(w http.ResponseWriter, r *http.Request), query string) *httptest.ResponseRecorder {
+	recorder := httptest.NewRecorder()
+	handle(recorder, httptest.NewRequest(http.MethodGet, "/game?"+query, nil))
+	return recorder
+}
+
+func TestNewGameRejectsInvalidConfig(t *testing.T) {
+	config := testConfig()
+	config.MinMass, config.StartMass = 200, 100
+
+	if _, err := NewGame(config); !errors.Is(err, ErrorInvalidConfig) {
+		t.Fatalf("NewGame with MinMass above StartMass: got %v, want %v", err, ErrorInvalidConfig)
+	}
+}
+
+func TestConfiguredStartMassSetsBaseSpeed(t *testing.T) {
+	config := testConfig()
+	config.StartMass, config.MinMass = 100, 100
+	g := newTestGame(t, config)
+
+	player := joinTestPlayer(t, g, 100, 100, 100)
+	if speed := player.MaxSpeed(); math.Abs(speed-PLAYER_BASE_SPEED) > 1e-9 {
+		t.Errorf("player of the starting mass moves at %v, want %v", speed, PLAYER_BASE_SPEED)
+	}
+}
+
+// encodedWorld encodes every entity of g.
+func encodedWorld(g *Game) []byte {
+	g.RLock()
+	defer g.RUnlock()
+	return encodeEntities(g.entities())
+}
+
+// playSeeded plays a game of config with one client sending inputs, and
+// returns the snapshots it was sent, past their header holding the time.
+func playSeeded(t *testing.T, config GameConfig) (*Game, [][]byte) {
+	t.Helper()
+
+	g := newTestGame(t, config)
+	factory := &fakeFactory{}
+	connect(func(w http.ResponseWriter, r *http.Request) {
+		g.HandleNewConnection(factory, w, r)
+	}, "")
+	conn := factory.last()
+	conn.handler(EncodeFrame(OpHello, binary.LittleEndian.AppendUint16(nil, PROTOCOL_VERSION)))
+
+	for tick := range 20 {
+		input := PlayerInput{Direction: utils.FromAngle(float64(tick) / 4)}
+		if tick == 10 {
+			input.Actions = ActionSplit
+		}
+		conn.handler(EncodeFrame(OpInput, EncodeInput(input)))
+		g.Tick(time.Second / DEFAULT_TICK_RATE)
+		g.Broadcast()
+	}
+
+	var snapshots [][]byte
+	for _, frame := range conn.sent() {
+		op, payload, err := DecodeFrame(frame)
+		if err != nil || op != OpStateSnapshot {
+			continue
+		}
+		_, body, err := DecodeSnapshotHeader(payload)
+		if err != nil {
+			t.Fatalf("DecodeSnapshotHeader: %v", err)
+		}
+		snapshots = append(snapshots, body)
+	}
+	return g, snapshots
+}
+
+func TestSameSeedPlaysOutTheSame(t *testing.T) {
+	config := testConfig()
+	config.Seed = 42
+	config.VirusCount = 5
+	config.Bots = 3
+	first, played := playSeeded(t, config)
+	second, replayed := playSeeded(t, config)
+
+	if first.Seed() != 42 || second.Seed() != 42 {
+		t.Fatalf("seeds %d and %d, want 42", first.Seed(), second.Seed())
+	}
+	if len(played) == 0 || len(played) != len(replayed) {
+		t.Fatalf("clients got %d and %d snapshots", len(played), len(replayed))
+	}
+	for i := range played {
+		if !bytes.Equal(played[i], replayed[i]) {
+			t.Fatalf("snapshot %d differs between games of the same seed and inputs", i)
+		}
+	}
+	if !bytes.Equal(encodedWorld(first), encodedWorld(second)) {
+		t.Error("games with the same seed and inputs diverged")
+	}
+}
+
+func TestCreateRoomWithSeed(t *testing.T) {
+	rooms := NewRoomManager(&fakeFactory{}, testConfig(), 0, 10)
+	defer rooms.Close()
+
+	game, err := rooms.CreateRoomWithSeed("replay", 7)
+	if err != nil {
+		t.Fatalf("CreateRoomWithSeed: %v", err)
+	}
+	if game.Seed() != 7 {
+		t.Errorf("room seeded with %d, want 7", game.Seed())
+	}
+}
+
+// ids returns the IDs of entities.
+func ids(entities []Entity) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(entities))
+	for _, entity := range entities {
+		set[entity.ID] = true
+	}
+	return set
+}
+
+func TestViewportExcludesFarPlayers(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	near := joinTestPlayer(t, g, STARTING_MASS, 1200, 1100)
+	far := joinTestPlayer(t, g, STARTING_MASS, 9000, 9000)
+
+	visible := ids(g.Viewport(player))
+	if !visible[player.PlayerID] || !visible[near.PlayerID] {
+		t.Error("viewport misses the player or its neighbor")
+	}
+	if visible[far.PlayerID] {
+		t.Error("viewport includes a player across the world")
+	}
+}
+
+func TestViewportGrowsWithMass(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	player := joinTestPlayer(t, g, STARTING_MASS, WORLD_WIDTH/2, WORLD_HEIGHT/2)
+
+	area := func(mass uint64) float64 {
+		player.Lock()
+		player.Mass = mass
+		player.recomputeRadius()
+		player.Unlock()
+		viewport := g.ViewportArea(player)
+		return viewport.Width() * viewport.Height()
+	}
+
+	smallest := area(g.rules.start)
+	previous := smallest
+	for mass := g.rules.start; mass <= g.rules.max; mass *= 2 {
+		current := area(mass)
+		if current < previous {
+			t.Fatalf("viewport shrank from %v to %v growing to a mass of %d", previous, current, mass)
+		}
+		previous = current
+	}
+	if previous <= smallest {
+		t.Error("viewport of the biggest player no larger than of a new one")
+	}
+
+	// Past MAX_VIEWPORT_ZOOM only the radius of the player grows it.
+	area(64 * g.rules.start)
+	player.RLock()
+	radius := float64(player.Radius)
+	player.RUnlock()
+	if width := g.ViewportArea(player).Width() - 2*radius; width != g.config.ViewportWidth*MAX_VIEWPORT_ZOOM {
+		t.Errorf("viewport %v wide past the zoom cap, want %v", width, g.config.ViewportWidth*MAX_VIEWPORT_ZOOM)
+	}
+}
+
+func TestDuplicatePlayerIDs(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	first := joinTestPlayer(t, g, STARTING_MASS, 100, 100)
+	firstConn := &fakeConn{}
+	first.conn = firstConn
+
+	duplicate := NewPlayer(uuid.New(), &fakeConn{})
+	duplicate.PlayerID = first.PlayerID
+	if err := g.AddPlayer(duplicate); !errors.Is(err, ErrorDuplicatePlayer) {
+		t.Fatalf("adding a duplicate player: got %v, want %v", err, ErrorDuplicatePlayer)
+	}
+	if player, _ := g.Player(first.PlayerID); player != first {
+		t.Fatal("the duplicate player overwrote the first one")
+	}
+	if closed, _ := firstConn.isClosed(); closed {
+		t.Error("the refused duplicate closed the connection of the first player")
+	}
+
+	if err := g.ReplacePlayer(duplicate); err != nil {
+		t.Fatalf("ReplacePlayer: %v", err)
+	}
+	if player, _ := g.Player(first.PlayerID); player != duplicate {
+		t.Error("ReplacePlayer kept the first player")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for closed, code := firstConn.isClosed(); !closed || code != CLOSE_REPLACED; closed, code = firstConn.isClosed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("connection of the replaced player closed %v with code %d, want %d", closed, code, CLOSE_REPLACED)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestLateJoinersStartWithAKeyframe(t *testing.T) {
+	config := testConfig()
+	config.KeyframeInterval = 30
+	g := newTestGame(t, config)
+	factory := &fakeFactory{}
+	sayHello(t, g, factory, PROTOCOL_VERSION)
+	for range 5 {
+		g.Tick(time.Second / DEFAULT_TICK_RATE)
+		g.Broadcast()
+	}
+
+	// snapshot decodes the nth snapshot sent to conn.
+	snapshot := func(conn *fakeConn, n int) (SnapshotHeader, []byte) {
+		t.Helper()
+		payloads := conn.payloads(OpStateSnapshot)
+		if len(payloads) <= n {
+			t.Fatalf("client sent %d snapshots, want more than %d", len(payloads), n)
+		}
+		header, body, err := DecodeSnapshotHeader(payloads[n])
+		if err != nil {
+			t.Fatalf("DecodeSnapshotHeader: %v", err)
+		}
+		delta, _, err := DecodeRemovals(body)
+		if err != nil {
+			t.Fatalf("DecodeRemovals: %v", err)
+		}
+		return header, delta
+	}
+
+	// sent returns entities as clients decode them, in NetID order: with
+	// float32 positions and without their IDs.
+	sent := func(entities []Entity) []Entity {
+		for i := range entities {
+			entities[i].ID = uuid.Nil
+			entities[i].Position.X = float64(float32(entities[i].Position.X))
+			entities[i].Position.Y = float64(float32(entities[i].Position.Y))
+		}
+		return byNetID(entities)
+	}
+
+	conn, _ := sayHello(t, g, factory, PROTOCOL_VERSION)
+	late := onlyConnected(t, g, conn)
+	header, delta := snapshot(conn, 0)
+	if delta[1]&deltaKeyframe == 0 {
+		t.Fatal("first snapshot of the late joiner isn't a keyframe")
+	}
+	decoder := NewDeltaDecoder()
+	if err := decoder.Apply(delta); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got, want := byNetID(decoder.Entities()), sent(g.Viewport(late)); !slices.Equal(got, want) {
+		t.Errorf("keyframe has %d entities, want the %d of the viewport", len(got), len(want))
+	}
+	if header.Sequence != g.sequence.Load() {
+		t.Errorf("keyframe has sequence %d, want the %d of the last broadcast", header.Sequence, g.sequence.Load())
+	}
+
+	// The next broadcast follows the keyframe.
+	g.Tick(time.Second / DEFAULT_TICK_RATE)
+	g.Broadcast()
+	next, delta := snapshot(conn, 1)
+	if next.Sequence != header.Sequence+1 {
+		t.Errorf("next snapshot has sequence %d, want %d", next.Sequence, header.Sequence+1)
+	}
+	if delta[1]&deltaKeyframe != 0 {
+		t.Error("next snapshot is another keyframe")
+	}
+	if err := decoder.Apply(delta); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if got, want := byNetID(decoder.Entities()), sent(g.Viewport(late)); !slices.Equal(got, want) {
+		t.Errorf("decoder has %d entities after the next delta, want the %d of the viewport", len(got), len(want))
+	}
+}
+
+// onlyConnected returns the player of g connected through conn.
+func onlyConnected(t *testing.T, g *Game, conn *fakeConn) *Player {
+	t.Helper()
+
+	g.RLock()
+	defer g.RUnlock()
+	for _, player := range g.players {
+		player.RLock()
+		connected := player.conn == ClientConnection(conn)
+		player.RUnlock()
+		if connected {
+			return player
+		}
+	}
+	t.Fatal("no player connected through the connection")
+	return nil
+}
@@ -0,0 +1,136 @@
+package galaxy
+
+import (
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_CELL_SIZE = 256
+)
+
+type cellKey struct {
+	x int
+	y int
+}
+
+type gridEntry struct {
+	bounds utils.Rect
+	min    cellKey
+	max    cellKey
+}
+
+// Grid is a uniform spatial grid bucketing entities into square cells, so
+// looking up what is in an area only visits the cells it covers. Entities
+// are stored in every cell their bounding box touches.
+// Grid is not safe for concurrent use, Game guards it with its own lock.
+type Grid struct {
+	cellSize float64
+	cells    map[cellKey]map[uuid.UUID]struct{}
+	entries  map[uuid.UUID]gridEntry
+}
+
+func NewGrid(cellSize float64) *Grid {
+	return &Grid{
+		cellSize: cellSize,
+		cells:    make(map[cellKey]map[uuid.UUID]struct{}),
+		entries:  make(map[uuid.UUID]gridEntry),
+	}
+}
+
+func (g *Grid) cellOf(point utils.Vector2D) cellKey {
+	return cellKey{
+		x: int(math.Floor(point.X / g.cellSize)),
+		y: int(math.Floor(point.Y / g.cellSize)),
+	}
+}
+
+// Insert adds the entity id, a circle at position. Inserting an id already
+// in the grid moves it.
+func (g *Grid) Insert(id uuid.UUID, position utils.Vector2D, radius float64) {
+	if _, exists := g.entries[id]; exists {
+		g.Remove(id)
+	}
+
+	bounds := utils.RectAround(position, radius, radius)
+	entry := gridEntry{
+		bounds: bounds,
+		min:    g.cellOf(bounds.Min),
+		max:    g.cellOf(bounds.Max),
+	}
+	g.entries[id] = entry
+
+	for x := entry.min.x; x <= entry.max.x; x++ {
+		for y := entry.min.y; y <= entry.max.y; y++ {
+			key := cellKey{x: x, y: y}
+			cell, exists := g.cells[key]
+			if !exists {
+				cell = make(map[uuid.UUID]struct{})
+				g.cells[key] = cell
+			}
+			cell[id] = struct{}{}
+		}
+	}
+}
+
+func (g *Grid) Remove(id uuid.UUID) {
+	entry, exists := g.entries[id]
+	if !exists {
+		return
+	}
+	delete(g.entries, id)
+
+	for x := entry.min.x; x <= entry.max.x; x++ {
+		for y := entry.min.y; y <= entry.max.y; y++ {
+			key := cellKey{x: x, y: y}
+			delete(g.cells[key], id)
+			if len(g.cells[key]) == 0 {
+				delete(g.cells, key)
+			}
+		}
+	}
+}
+
+// Move updates the position and radius of id, only touching the cells when
+// it changed cells.
+func (g *Grid) Move(id uuid.UUID, position utils.Vector2D, radius float64) {
+	entry, exists := g.entries[id]
+	bounds := utils.RectAround(position, radius, radius)
+	if exists && g.cellOf(bounds.Min) == entry.min && g.cellOf(bounds.Max) == entry.max {
+		entry.bounds = bounds
+		g.entries[id] = entry
+		return
+	}
+	g.Insert(id, position, radius)
+}
+
+// QueryRange returns the entities whose bounding box intersects area.
+func (g *Grid) QueryRange(area utils.Rect) []uuid.UUID {
+	from := g.cellOf(area.Min)
+	to := g.cellOf(area.Max)
+
+	var found []uuid.UUID
+	for x := from.x; x <= to.x; x++ {
+		for y := from.y; y <= to.y; y++ {
+			for id := range g.cells[cellKey{x: x, y: y}] {
+				entry := g.entries[id]
+				// Entities spanning several cells are only reported from
+				// the first of them the area covers.
+				if x != max(entry.min.x, from.x) || y != max(entry.min.y, from.y) {
+					continue
+				}
+				if entry.bounds.Intersects(area) {
+					found = append(found, id)
+				}
+			}
+		}
+	}
+	return found
+}
+
+// Len returns the number of entities in the grid.
+func (g *Grid) Len() int {
+	return len(g.entries)
+}
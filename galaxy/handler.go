@@ -0,0 +1,301 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/google/uuid"
+)
+
+// HandleNewConnection upgrades the request and adds a new player to the
+// game, removed again once its connection closes. Clients exchange frames
+// prefixed by an Opcode, starting with an OpHello, see handshake. Requests
+// with ?spectate=1 join as spectators, watching the player of
+// ?observe=<id> if given, see Observe. Requests with ?session=<token>
+// resume the player of an OpSession token and requests with ?player=<id>
+// take back a player restored by LoadSnapshot.
+func (g *Game) HandleNewConnection(factory ConnectionFactory, w http.ResponseWriter, r *http.Request) {
+	if g.refuseClosed(w) {
+		return
+	}
+	if r.URL.Query().Get("spectate") == "1" {
+		g.handleNewSpectator(factory, w, r)
+		return
+	}
+
+	if token, err := uuid.Parse(r.URL.Query().Get("session")); err == nil {
+		if player, generation, claimed := g.claimSession(token); claimed {
+			g.handleResume(factory, player, generation, w, r)
+			return
+		}
+	}
+
+	if id, err := uuid.Parse(r.URL.Query().Get("player")); err == nil {
+		if player, claimed := g.claimRestored(id); claimed {
+			g.handleReconnect(factory, player, w, r)
+			return
+		}
+	}
+
+	// Refuse before upgrading when possible, a join can still race another
+	// one for the last seat.
+	if g.Full() {
+		http.Error(w, ErrorRoomFull.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	g.Lock()
+	player := g.newPlayer(uuid.New())
+	g.Unlock()
+
+	handshake, frameHandler := g.playerHandshake(player)
+	onClose := func(reason CloseReason, err error) {
+		g.disconnect(player, 0, reason, err)
+	}
+
+	conn, err := factory.NewFrameConnection(w, r, frameHandler, onClose)
+	if err != nil {
+		logConnectionError(r, err)
+		return
+	}
+
+	if g.banned(conn, uuid.Nil) {
+		// Its onClose must not remove a player with the same ID.
+		player.Lock()
+		player.generation++
+		player.Unlock()
+		handshake.start(conn)
+		closeWithReason(conn, CLOSE_BANNED, "banned")
+		return
+	}
+
+	player.setConnection(conn)
+	add := g.AddPlayer
+	if g.config.ReplaceDuplicates {
+		add = g.ReplacePlayer
+	}
+	if err := add(player); err != nil {
+		log.Printf("rejecting player %v: %v", player.PlayerID, err)
+		// Its onClose must not remove the player already in the game.
+		player.Lock()
+		player.generation++
+		player.Unlock()
+		handshake.start(conn)
+		if errors.Is(err, ErrorRoomFull) {
+			closeWithReason(conn, CLOSE_ROOM_FULL, "room full")
+		} else {
+			closeWithReason(conn, CLOSE_DUPLICATE, "duplicate player")
+		}
+		return
+	}
+	// Frames flow once the player is in the game, so its hello can issue
+	// its session.
+	handshake.start(conn)
+	log.Printf("player %v joined the game", player.PlayerID)
+}
+
+// claimRestored returns the restored player with the given ID, so only one
+// connection can take it back.
+func (g *Game) claimRestored(id uuid.UUID) (*Player, bool) {
+	player, exists := g.Player(id)
+	if !exists {
+		return nil, false
+	}
+
+	player.Lock()
+	defer player.Unlock()
+	if !player.restored {
+		return nil, false
+	}
+	player.restored = false
+	return player, true
+}
+
+func (g *Game) handleReconnect(factory ConnectionFactory, player *Player, w http.ResponseWriter, r *http.Request) {
+	handshake, frameHandler := g.playerHandshake(player)
+	player.RLock()
+	generation := player.generation
+	player.RUnlock()
+	onClose := func(reason CloseReason, err error) {
+		g.disconnect(player, generation, reason, err)
+	}
+
+	conn, err := factory.NewFrameConnection(w, r, frameHandler, onClose)
+	if err != nil {
+		logConnectionError(r, err)
+		player.Lock()
+		player.restored = true
+		player.Unlock()
+		return
+	}
+
+	if g.banned(conn, uuid.Nil) {
+		// The player stays restored, its onClose must not remove it.
+		player.Lock()
+		player.generation++
+		player.restored = true
+		player.Unlock()
+		handshake.start(conn)
+		closeWithReason(conn, CLOSE_BANNED, "banned")
+		return
+	}
+
+	player.setConnection(conn)
+	handshake.start(conn)
+	log.Printf("player %v reconnected to the game", player.PlayerID)
+}
+
+// handleResume connects a client to the player of the session it claimed,
+// see claimSession. If the upgrade fails the player stays disconnected and
+// is reaped once its grace period runs out.
+func (g *Game) handleResume(factory ConnectionFactory, player *Player, generation uint64, w http.ResponseWriter, r *http.Request) {
+	handshake, frameHandler := g.playerHandshake(player)
+	onClose := func(reason CloseReason, err error) {
+		g.disconnect(player, generation, reason, err)
+	}
+
+	conn, err := factory.NewFrameConnection(w, r, frameHandler, onClose)
+	if err != nil {
+		logConnectionError(r, err)
+		return
+	}
+
+	player.RLock()
+	token := player.session
+	player.RUnlock()
+	if g.banned(conn, token) {
+		// The player stays disconnected until its grace period runs out.
+		handshake.start(conn)
+		closeWithReason(conn, CLOSE_BANNED, "banned")
+		return
+	}
+
+	if !g.attach(player, generation, conn) {
+		handshake.start(conn)
+		conn.Close()
+		return
+	}
+	handshake.start(conn)
+	log.Printf("player %v resumed its session", player.PlayerID)
+}
+
+func (g *Game) handleNewSpectator(factory ConnectionFactory, w http.ResponseWriter, r *http.Request) {
+	spectator := &Spectator{ID: uuid.New()}
+
+	handshake := newHandshake(g.limits)
+	onAccept := func(version uint16) {
+		spectator.acceptVersion(handshake.conn, version)
+		g.sendBoard(spectator)
+		g.sendResized(spectator)
+	}
+	frameHandler := handshake.gate(onAccept, func(frame []byte) {
+		op, payload, err := DecodeFrame(frame)
+		if err != nil || op != OpInput {
+			return
+		}
+		if input, err := g.codec().DecodeInput(payload); err == nil {
+			spectator.SetInput(input)
+		}
+	})
+	onClose := func(reason CloseReason, err error) {
+		logClose("spectator", spectator.ID, reason, err)
+		g.RemoveSpectator(spectator.ID)
+	}
+
+	conn, err := factory.NewFrameConnection(w, r, frameHandler, onClose)
+	if err != nil {
+		logConnectionError(r, err)
+		return
+	}
+
+	spectator.Lock()
+	spectator.conn = conn
+	spectator.Unlock()
+	handshake.start(conn)
+
+	if target, err := uuid.Parse(r.URL.Query().Get("observe")); err == nil {
+		if err := g.observe(spectator, target); err != nil {
+			log.Printf("rejecting spectator %v observing %v: %v", spectator.ID, target, err)
+			closeWithReason(conn, CLOSE_TARGET_GONE, "target gone")
+			return
+		}
+		log.Printf("spectator %v observes player %v", spectator.ID, target)
+		return
+	}
+	g.AddSpectator(spectator)
+	log.Printf("spectator %v joined the game", spectator.ID)
+}
+
+// logClose logs why the connection of a client closed, from its onClose.
+func logClose(who string, id uuid.UUID, reason CloseReason, err error) {
+	if err != nil {
+		log.Printf("%s %v left: %v: %v", who, id, reason, err)
+		return
+	}
+	log.Printf("%s %v left: %v", who, id, reason)
+}
+
+// logConnectionError logs why the connection of r couldn't be established.
+// Plain HTTP requests are only noted, they aren't a failure of the server.
+func logConnectionError(r *http.Request, err error) {
+	if errors.Is(err, ErrorNotWebSocket) {
+		log.Printf("ignoring %v %v from %v: %v", r.Method, r.URL.Path, r.RemoteAddr, err)
+		return
+	}
+	log.Printf("error establishing connection: %v", err)
+}
+
+// newDispatcher routes the frames players send to the game.
+func (g *Game) newDispatcher() *Dispatcher {
+	d := NewDispatcher()
+	d.Handle(OpInput, func(player *Player, payload []byte) {
+		// Malformed inputs are dropped, they never close the connection.
+		// They are only counted, a client can send one every frame.
+		input, err := g.codec().DecodeInput(payload)
+		if err != nil {
+			g.droppedInputs.Add(1)
+			g.metrics.InputDropped(g.room)
+			return
+		}
+		player.SetInput(input)
+		// Record the decoded input, not whatever else the client sent.
+		g.record(player.PlayerID, EncodeFrame(OpInput, EncodeInput(input)))
+	})
+	d.Handle(OpPing, func(player *Player, payload []byte) {
+		player.SendBinary(EncodeFrame(OpPing, payload))
+	})
+	d.Handle(OpHeartbeat, g.echoed)
+	d.Handle(OpRespawn, func(player *Player, payload []byte) {
+		err := g.Respawn(player.PlayerID)
+		if errors.Is(err, ErrorPlayerAlive) {
+			return
+		}
+		if err != nil {
+			log.Printf("player %v can't respawn: %v", player.PlayerID, err)
+			return
+		}
+		g.record(player.PlayerID, EncodeFrame(OpRespawn, nil))
+	})
+	return d
+}
+
+// DroppedInputs returns how many malformed OpInput frames were dropped, too
+// short or with a direction that isn't finite.
+func (g *Game) DroppedInputs() uint64 {
+	return g.droppedInputs.Load()
+}
+
+// Dispatcher returns the dispatcher routing the frames of players, new
+// message types are added by registering a handler for their opcode.
+func (g *Game) Dispatcher() *Dispatcher {
+	return g.dispatcher
+}
+
+// encodeDeath encodes the payload of an OpDeath frame.
+func encodeDeath(death Death) []byte {
+	data := make([]byte, 0, 16+8)
+	data = append(data, death.EatenBy[:]...)
+	return binary.LittleEndian.AppendUint64(data, death.Mass)
+}
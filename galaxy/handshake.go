@@ -0,0 +1,166 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"sync"
+)
+
+const (
+	// PROTOCOL_VERSION is the version of the frames the game speaks, bump
+	// it whenever their layout changes. MIN_PROTOCOL_VERSION is the oldest
+	// version clients can still speak, older ones are told to update.
+	PROTOCOL_VERSION     = 11
+	MIN_PROTOCOL_VERSION = 3
+)
+
+var ErrorUnsupportedVersion = fmt.Errorf("Unsupported protocol version")
+
+// negotiate returns the protocol version spoken with a client speaking up
+// to version: the newest both sides know. Clients newer than the game are
+// expected to speak older versions too.
+func negotiate(version uint16) (uint16, error) {
+	if version < MIN_PROTOCOL_VERSION {
+		return 0, fmt.Errorf("%w: client speaks %d, expected at least %d", ErrorUnsupportedVersion, version, MIN_PROTOCOL_VERSION)
+	}
+	return min(version, PROTOCOL_VERSION), nil
+}
+
+// encodeHello encodes the payload of the OpHello frame answering a client:
+// accepted (1) | server version uint16 (2) | negotiated version uint16 (2),
+// little endian. Accepted clients of LIMITS_PROTOCOL_VERSION or newer get
+// the Limits of the game after it, see appendLimits.
+func encodeHello(accepted bool, negotiated uint16) []byte {
+	data := make([]byte, 0, 5)
+	if accepted {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	data = binary.LittleEndian.AppendUint16(data, PROTOCOL_VERSION)
+	return binary.LittleEndian.AppendUint16(data, negotiated)
+}
+
+// handshake holds back the frames of a connection until its client said
+// hello. Clients must start with an OpHello frame carrying the version they
+// speak, uint16 little endian. The game answers with its own OpHello, and
+// no game state flows until it accepted the version.
+type handshake struct {
+	// ready is closed once the connection is known, frames wait for it.
+	once  sync.Once
+	ready chan struct{}
+	conn  ClientConnection
+
+	// limits returns the Limits sent to the client of conn.
+	limits func(conn ClientConnection) Limits
+
+	// accepted and version are only touched by the goroutine delivering
+	// the frames.
+	accepted bool
+	version  uint16
+}
+
+func newHandshake(limits func(conn ClientConnection) Limits) *handshake {
+	return &handshake{ready: make(chan struct{}), limits: limits}
+}
+
+// start lets the frames of conn through the handshake once conn is the
+// connection of its client. It must be called before closing conn too, or
+// the close waits on the blocked frames.
+func (h *handshake) start(conn ClientConnection) {
+	h.once.Do(func() {
+		h.conn = conn
+		close(h.ready)
+	})
+}
+
+// gate returns a frame handler passing the frames after an accepted hello
+// to next. onAccept is called with the negotiated version.
+func (h *handshake) gate(onAccept func(version uint16), next func(frame []byte)) func(frame []byte) {
+	return func(frame []byte) {
+		<-h.ready
+		if h.accepted {
+			next(frame)
+			return
+		}
+
+		op, payload, err := DecodeFrame(frame)
+		if err == nil && op != OpHello {
+			err = fmt.Errorf("%w: expected a hello, got a %v frame", ErrorUnsupportedVersion, op)
+		} else if err == nil && len(payload) < 2 {
+			err = ErrorShortBuffer
+		}
+		var version uint16
+		if err == nil {
+			version, err = negotiate(binary.LittleEndian.Uint16(payload))
+		}
+		if err != nil {
+			log.Printf("rejecting client: %v", err)
+			sendUrgent(h.conn, EncodeFrame(OpHello, encodeHello(false, 0)))
+			// Closing waits for this goroutine to read the close frame.
+			go closeWithReason(h.conn, CLOSE_UNSUPPORTED_VERSION, "unsupported protocol version, please update")
+			return
+		}
+
+		h.accepted = true
+		h.version = version
+		hello := encodeHello(true, version)
+		if version >= LIMITS_PROTOCOL_VERSION {
+			hello = appendLimits(hello, h.limits(h.conn), version)
+		}
+		sendUrgent(h.conn, EncodeFrame(OpHello, hello))
+		onAccept(version)
+	}
+}
+
+// acceptVersion records the protocol version negotiated by conn, unless
+// another connection took over p since.
+func (p *Player) acceptVersion(conn ClientConnection, version uint16) bool {
+	p.Lock()
+	defer p.Unlock()
+	if p.conn != conn {
+		return false
+	}
+	p.protocol = version
+	return true
+}
+
+// ProtocolVersion returns the protocol version negotiated with the client
+// of p, 0 until it said hello. Encoders can branch on it.
+func (p *Player) ProtocolVersion() uint16 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.protocol
+}
+
+func (s *Spectator) acceptVersion(conn ClientConnection, version uint16) {
+	s.Lock()
+	if s.conn == conn {
+		s.protocol = version
+	}
+	s.Unlock()
+}
+
+// ProtocolVersion returns the protocol version negotiated with the client
+// of s, 0 until it said hello.
+func (s *Spectator) ProtocolVersion() uint16 {
+	s.Lock()
+	defer s.Unlock()
+	return s.protocol
+}
+
+// playerHandshake gates the frames of player on a handshake, issuing the
+// session of the player once it is accepted.
+func (g *Game) playerHandshake(player *Player) (*handshake, func(frame []byte)) {
+	h := newHandshake(g.limits)
+	onAccept := func(version uint16) {
+		if player.acceptVersion(h.conn, version) {
+			g.issueSession(player)
+			g.sendBoard(player)
+			g.sendResized(player)
+			g.sendInitialState(player)
+		}
+	}
+	return h, h.gate(onAccept, g.dispatcher.MessageHandler(player))
+}
@@ -0,0 +1,149 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"net/http"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// sayHello connects a client to g speaking version, and returns its
+// connection and the payload of the OpHello frame answering it.
+func sayHello(t *testing.T, g *Game, factory *fakeFactory, version uint16) (*fakeConn, []byte) {
+	t.Helper()
+
+	connect(func(w http.ResponseWriter, r *http.Request) {
+		g.HandleNewConnection(factory, w, r)
+	}, "")
+	conn := factory.last()
+	conn.handler(EncodeFrame(OpHello, binary.LittleEndian.AppendUint16(nil, version)))
+
+	sent := conn.sent()
+	if len(sent) == 0 {
+		t.Fatal("client sent nothing back")
+	}
+	op, payload, err := DecodeFrame(sent[0])
+	if err != nil || op != OpHello || len(payload) < 5 {
+		t.Fatalf("first frame is a %v frame (%v), want a hello", op, err)
+	}
+	return conn, payload
+}
+
+func TestHandshake(t *testing.T) {
+	tests := []struct {
+		name       string
+		version    uint16
+		negotiated uint16
+	}{
+		{"same version", PROTOCOL_VERSION, PROTOCOL_VERSION},
+		{"newer client", PROTOCOL_VERSION + 5, PROTOCOL_VERSION},
+		{"oldest supported", MIN_PROTOCOL_VERSION, MIN_PROTOCOL_VERSION},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			g := newTestGame(t, testConfig())
+			conn, hello := sayHello(t, g, &fakeFactory{}, test.version)
+
+			if hello[0] != 1 {
+				t.Fatal("client rejected")
+			}
+			if server := binary.LittleEndian.Uint16(hello[1:]); server != PROTOCOL_VERSION {
+				t.Errorf("server says it speaks %d, want %d", server, PROTOCOL_VERSION)
+			}
+			if negotiated := binary.LittleEndian.Uint16(hello[3:]); negotiated != test.negotiated {
+				t.Errorf("negotiated %d, want %d", negotiated, test.negotiated)
+			}
+			if version := onlyPlayer(t, g).ProtocolVersion(); version != test.negotiated {
+				t.Errorf("player speaks %d, want %d", version, test.negotiated)
+			}
+			if len(conn.sent()) < 2 {
+				t.Error("accepted client sent no game state")
+			}
+			if closed, _ := conn.isClosed(); closed {
+				t.Error("accepted client closed")
+			}
+		})
+	}
+}
+
+func TestHandshakeRejectsUnsupportedVersions(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	conn, hello := sayHello(t, g, &fakeFactory{}, MIN_PROTOCOL_VERSION-1)
+
+	if hello[0] != 0 {
+		t.Fatal("client of an unsupported version accepted")
+	}
+	deadline := time.Now().Add(time.Second)
+	for {
+		closed, code := conn.isClosed()
+		if closed {
+			if code != CLOSE_UNSUPPORTED_VERSION {
+				t.Errorf("closed with %d, want %d", code, CLOSE_UNSUPPORTED_VERSION)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rejected client never closed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// No game state flows, even for frames arriving meanwhile.
+	conn.handler(EncodeFrame(OpInput, EncodeInput(PlayerInput{})))
+	if sent, hellos := len(conn.sent()), len(conn.payloads(OpHello)); sent != hellos {
+		t.Errorf("rejected client sent %d frames besides hellos", sent-hellos)
+	}
+}
+
+func TestHandshakeExpectsHelloFirst(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	factory := &fakeFactory{}
+	connect(func(w http.ResponseWriter, r *http.Request) {
+		g.HandleNewConnection(factory, w, r)
+	}, "")
+	conn := factory.last()
+	conn.handler(EncodeFrame(OpInput, EncodeInput(PlayerInput{})))
+
+	payloads := conn.payloads(OpHello)
+	if len(payloads) != 1 || payloads[0][0] != 0 {
+		t.Errorf("client starting without a hello got %d hellos, want one rejecting it", len(payloads))
+	}
+}
+
+func TestHelloCarriesLimits(t *testing.T) {
+	config := testConfig()
+	config.TickRate, config.BroadcastRate = 40, 20
+	config.Bounds = utils.Rect{Min: utils.Vector2D{X: -500, Y: 0}, Max: utils.Vector2D{X: 4000, Y: 3000}}
+	g := newTestGame(t, config)
+	factory := &fakeFactory{maxMessageSize: 1 << 16}
+
+	want := Limits{
+		MaxMessageSize:    1 << 16,
+		TickRate:          40,
+		BroadcastInterval: time.Second / 20,
+		Bounds:            config.Bounds,
+	}
+	_, hello := sayHello(t, g, factory, PROTOCOL_VERSION)
+	if limits, err := DecodeLimits(hello); err != nil || limits != want {
+		t.Errorf("hello carries %+v (%v), want %+v", limits, err, want)
+	}
+
+	// Before INTERVAL_PROTOCOL_VERSION the limits end with the bounds.
+	want.BroadcastInterval = 0
+	_, hello = sayHello(t, g, factory, INTERVAL_PROTOCOL_VERSION-1)
+	if limits, err := DecodeLimits(hello); err != nil || limits != want {
+		t.Errorf("hello of version %d carries %+v (%v), want %+v", INTERVAL_PROTOCOL_VERSION-1, limits, err, want)
+	}
+
+	// Before LIMITS_PROTOCOL_VERSION the hello has no limits at all.
+	_, hello = sayHello(t, g, factory, LIMITS_PROTOCOL_VERSION-1)
+	if len(hello) != 5 {
+		t.Errorf("hello of version %d is %d bytes long, want 5 without the limits", LIMITS_PROTOCOL_VERSION-1, len(hello))
+	}
+
+	if limits := g.Limits(); limits.MaxMessageSize != 0 || limits.TickRate != 40 || limits.Bounds != config.Bounds {
+		t.Errorf("Limits() = %+v", limits)
+	}
+}
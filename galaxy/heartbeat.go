@@ -0,0 +1,154 @@
+package galaxy
+
+import (
+	"context"
+	"encoding/binary"
+	"log"
+	"time"
+)
+
+// HEARTBEAT_PROTOCOL_VERSION is the first protocol version sent OpHeartbeat
+// frames, see GameConfig.HeartbeatInterval.
+const HEARTBEAT_PROTOCOL_VERSION = 11
+
+// Heartbeat is the payload of an OpHeartbeat frame.
+type Heartbeat struct {
+	// Sequence counts the heartbeats sent to the client.
+	Sequence uint32 `json:"sequence"`
+
+	// Time is when the server sent the heartbeat, by its clock.
+	Time time.Time `json:"time"`
+}
+
+// heartbeats is where a player stands with its heartbeats, guarded by its
+// lock.
+type heartbeats struct {
+	// sent and echoed are the sequences of the last heartbeats sent and
+	// echoed.
+	sent   uint32
+	echoed uint32
+
+	// owed is since when the client owes an echo, zero while it doesn't.
+	owed time.Time
+
+	// rtt is the round trip of the last echo.
+	rtt time.Duration
+}
+
+// HeartbeatRTT returns the round-trip time of the last heartbeat the client
+// of p echoed, or zero until it echoes one, see GameConfig.HeartbeatInterval.
+func (p *Player) HeartbeatRTT() time.Duration {
+	p.RLock()
+	defer p.RUnlock()
+	return p.heartbeats.rtt
+}
+
+// sendHeartbeats sends every HeartbeatInterval an OpHeartbeat frame to the
+// players of HEARTBEAT_PROTOCOL_VERSION or newer, disconnecting those whose
+// client owes an echo for longer than HeartbeatTimeout, until ctx is done.
+func (g *Game) sendHeartbeats(ctx context.Context) {
+	ticker := time.NewTicker(g.config.HeartbeatInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			g.beat(now)
+		}
+	}
+}
+
+func (g *Game) beat(now time.Time) {
+	type beat struct {
+		player *Player
+		frame  []byte
+		text   string
+	}
+
+	var beats []beat
+	var late []ClientConnection
+	g.RLock()
+	for _, player := range g.players {
+		player.Lock()
+		switch {
+		case player.IsCell() || player.conn == nil || player.protocol < HEARTBEAT_PROTOCOL_VERSION:
+		case g.config.HeartbeatTimeout > 0 && !player.heartbeats.owed.IsZero() && now.Sub(player.heartbeats.owed) > g.config.HeartbeatTimeout:
+			late = append(late, player.conn)
+			log.Printf("player %v didn't echo a heartbeat since %v, disconnecting it", player.PlayerID, player.heartbeats.owed)
+		default:
+			if player.heartbeats.owed.IsZero() {
+				player.heartbeats.owed = now
+			}
+			player.heartbeats.sent++
+			heartbeat := Heartbeat{Sequence: player.heartbeats.sent, Time: now}
+			beats = append(beats, beat{
+				player: player,
+				frame:  EncodeFrame(OpHeartbeat, encodeHeartbeat(heartbeat)),
+				text:   debugFrame{Op: OpHeartbeat.String(), Heartbeat: &heartbeat}.encode(),
+			})
+		}
+		player.Unlock()
+	}
+	g.RUnlock()
+
+	for _, beat := range beats {
+		if beat.player.debug() {
+			beat.player.sendText(beat.text)
+		} else {
+			beat.player.sendUrgent(beat.frame)
+		}
+	}
+	// Closing runs onClose, which takes the locks, and waits for the
+	// client to acknowledge the close frame.
+	for _, conn := range late {
+		go closeWithReason(conn, CLOSE_HEARTBEAT, "heartbeat timeout")
+	}
+}
+
+// echoed handles the client of player echoing the OpHeartbeat frame
+// carrying payload. Echoes of heartbeats it wasn't sent, or already echoed,
+// are ignored.
+func (g *Game) echoed(player *Player, payload []byte) {
+	heartbeat, err := DecodeHeartbeat(payload)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	player.Lock()
+	defer player.Unlock()
+	if heartbeat.Sequence <= player.heartbeats.echoed || heartbeat.Sequence > player.heartbeats.sent {
+		return
+	}
+	player.heartbeats.echoed = heartbeat.Sequence
+	player.heartbeats.rtt = now.Sub(heartbeat.Time)
+	// A client echoing late still owes the heartbeats sent since, but it is
+	// alive.
+	if player.heartbeats.echoed == player.heartbeats.sent {
+		player.heartbeats.owed = time.Time{}
+	} else {
+		player.heartbeats.owed = now
+	}
+}
+
+// encodeHeartbeat encodes the payload of an OpHeartbeat frame, which
+// clients echo as is: sequence uint32 (4) | time int64 (8) in unix
+// nanoseconds, little endian.
+func encodeHeartbeat(heartbeat Heartbeat) []byte {
+	data := make([]byte, 0, 4+8)
+	data = binary.LittleEndian.AppendUint32(data, heartbeat.Sequence)
+	return binary.LittleEndian.AppendUint64(data, uint64(heartbeat.Time.UnixNano()))
+}
+
+// DecodeHeartbeat decodes the payload of an OpHeartbeat frame.
+func DecodeHeartbeat(payload []byte) (Heartbeat, error) {
+	if len(payload) < 4+8 {
+		return Heartbeat{}, ErrorShortBuffer
+	}
+	return Heartbeat{
+		Sequence: binary.LittleEndian.Uint32(payload),
+		Time:     time.Unix(0, int64(binary.LittleEndian.Uint64(payload[4:]))),
+	}, nil
+}
@@ -0,0 +1,82 @@
+package galaxy
+
+import (
+	"testing"
+	"time"
+)
+
+// heartbeatPlayer joins a player whose client speaks heartbeats to g,
+// returning it with its connection.
+func heartbeatPlayer(t *testing.T, g *Game) (*Player, *fakeConn) {
+	t.Helper()
+
+	conn := &fakeConn{}
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	player.Lock()
+	player.conn, player.protocol = conn, HEARTBEAT_PROTOCOL_VERSION
+	player.Unlock()
+	return player, conn
+}
+
+// lastHeartbeat returns the last OpHeartbeat frame sent to conn.
+func lastHeartbeat(t *testing.T, conn *fakeConn) []byte {
+	t.Helper()
+
+	sent := conn.sent()
+	for i := len(sent) - 1; i >= 0; i-- {
+		if op, _, err := DecodeFrame(sent[i]); err == nil && op == OpHeartbeat {
+			return sent[i]
+		}
+	}
+	t.Fatal("no heartbeat sent")
+	return nil
+}
+
+func TestHeartbeatEchoMeasuresRTT(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	player, conn := heartbeatPlayer(t, g)
+
+	g.beat(time.Now().Add(-50 * time.Millisecond))
+	frame := lastHeartbeat(t, conn)
+	g.Dispatcher().Dispatch(player, frame)
+
+	if rtt := player.HeartbeatRTT(); rtt < 50*time.Millisecond || rtt > time.Second {
+		t.Errorf("HeartbeatRTT() = %v, want around 50ms", rtt)
+	}
+
+	// Echoing the same heartbeat again is ignored.
+	g.beat(time.Now())
+	g.Dispatcher().Dispatch(player, frame)
+	player.RLock()
+	owed := player.heartbeats.owed
+	player.RUnlock()
+	if owed.IsZero() {
+		t.Error("a repeated echo paid for the next heartbeat")
+	}
+}
+
+func TestMissingEchoesDisconnect(t *testing.T) {
+	config := testConfig()
+	config.HeartbeatTimeout = time.Second
+	g := newTestGame(t, config)
+	echoing, echoingConn := heartbeatPlayer(t, g)
+	_, silentConn := heartbeatPlayer(t, g)
+
+	now := time.Now()
+	g.beat(now)
+	g.Dispatcher().Dispatch(echoing, lastHeartbeat(t, echoingConn))
+	g.beat(now.Add(500 * time.Millisecond))
+	g.Dispatcher().Dispatch(echoing, lastHeartbeat(t, echoingConn))
+	g.beat(now.Add(2 * time.Second))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for closed, code := silentConn.isClosed(); !closed || code != CLOSE_HEARTBEAT; closed, code = silentConn.isClosed() {
+		if time.Now().After(deadline) {
+			t.Fatalf("silent client closed %v with code %d, want %d", closed, code, CLOSE_HEARTBEAT)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if closed, _ := echoingConn.isClosed(); closed {
+		t.Error("client echoing its heartbeats was disconnected")
+	}
+}
@@ -0,0 +1,134 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// HUD_PROTOCOL_VERSION is the first protocol version sent OpHUD
+	// frames, older clients compute what they show themselves.
+	HUD_PROTOCOL_VERSION = 10
+
+	// HUD_INTERVAL is how often Run sends every player its HUD.
+	HUD_INTERVAL = 500 * time.Millisecond
+)
+
+// HUD is what clients show a player about itself, computed by the game so
+// every client shows the same.
+type HUD struct {
+	// Rank is the position of the player in the whole leaderboard, 1 for
+	// the best, 0 while dead.
+	Rank int `json:"rank"`
+
+	// Score is the mass of the player, split cells included.
+	Score uint64 `json:"score"`
+
+	// Threats is the number of players in the viewport of the player big
+	// enough to eat it, as bots see them.
+	Threats int `json:"threats"`
+
+	Alive bool `json:"alive"`
+}
+
+// PlayerHUD returns the HUD of player id, ErrorPlayerNotFound if it isn't
+// in the game or is a split cell.
+func (g *Game) PlayerHUD(id uuid.UUID) (HUD, error) {
+	g.RLock()
+	defer g.RUnlock()
+
+	player, exists := g.players[id]
+	if !exists || player.IsCell() {
+		return HUD{}, ErrorPlayerNotFound
+	}
+	return g.hud(player, ranked(g.standings())), nil
+}
+
+// ranked returns the HUD of every player of standings, ranked and scored
+// but not counting threats yet.
+func ranked(standings []LeaderboardEntry) map[uuid.UUID]HUD {
+	huds := make(map[uuid.UUID]HUD, len(standings))
+	for i, entry := range standings {
+		huds[entry.PlayerID] = HUD{Rank: i + 1, Score: entry.Score, Alive: true}
+	}
+	return huds
+}
+
+// hud computes the HUD of player from its ranked one, see ranked. The
+// caller must hold the lock.
+func (g *Game) hud(player *Player, huds map[uuid.UUID]HUD) HUD {
+	hud, alive := huds[player.PlayerID]
+	if !alive {
+		return HUD{}
+	}
+
+	threats := make(map[uuid.UUID]bool)
+	for _, entity := range g.viewport(player) {
+		other, isPlayer := g.players[entity.ID]
+		if isPlayer && other.mayEat(player) && g.outsizes(other, player) {
+			threats[other.Owner()] = true
+		}
+	}
+	hud.Threats = len(threats)
+	return hud
+}
+
+// announceHUD sends every player that said hello its HUD in an OpHUD
+// frame, see Run.
+func (g *Game) announceHUD() {
+	type sent struct {
+		player *Player
+		hud    HUD
+	}
+
+	g.RLock()
+	var huds []sent
+	var ranks map[uuid.UUID]HUD
+	for _, player := range inOrder(g, g.players) {
+		if player.IsCell() || player.ProtocolVersion() < HUD_PROTOCOL_VERSION {
+			continue
+		}
+		if ranks == nil {
+			ranks = ranked(g.standings())
+		}
+		huds = append(huds, sent{player: player, hud: g.hud(player, ranks)})
+	}
+	g.RUnlock()
+
+	for _, sent := range huds {
+		if sent.player.debug() {
+			sent.player.sendText(debugFrame{Op: OpHUD.String(), HUD: &sent.hud}.encode())
+		} else {
+			sent.player.SendBinary(EncodeFrame(OpHUD, encodeHUD(sent.hud)))
+		}
+	}
+}
+
+// encodeHUD encodes the payload of an OpHUD frame: rank uint32 (4) |
+// score uint64 (8) | threats uint16 (2) | alive (1), little endian.
+func encodeHUD(hud HUD) []byte {
+	data := make([]byte, 0, 4+8+2+1)
+	data = binary.LittleEndian.AppendUint32(data, uint32(min(max(hud.Rank, 0), math.MaxUint32)))
+	data = binary.LittleEndian.AppendUint64(data, hud.Score)
+	data = binary.LittleEndian.AppendUint16(data, uint16(min(max(hud.Threats, 0), math.MaxUint16)))
+	if hud.Alive {
+		return append(data, 1)
+	}
+	return append(data, 0)
+}
+
+// DecodeHUD decodes the payload of an OpHUD frame.
+func DecodeHUD(payload []byte) (HUD, error) {
+	if len(payload) < 4+8+2+1 {
+		return HUD{}, ErrorShortBuffer
+	}
+	return HUD{
+		Rank:    int(binary.LittleEndian.Uint32(payload)),
+		Score:   binary.LittleEndian.Uint64(payload[4:]),
+		Threats: int(binary.LittleEndian.Uint16(payload[12:])),
+		Alive:   payload[14] != 0,
+	}, nil
+}
@@ -0,0 +1,97 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// threatsIn counts the players owning a cell in the viewport of p at least
+// EatSizeRatio times bigger than it.
+func threatsIn(g *Game, p *Player) int {
+	g.RLock()
+	defer g.RUnlock()
+
+	owners := make(map[uuid.UUID]bool)
+	for _, entity := range g.viewport(p) {
+		other, isPlayer := g.players[entity.ID]
+		if isPlayer && other.Owner() != p.PlayerID && float64(entity.Radius) >= g.config.EatSizeRatio*float64(p.Radius) {
+			owners[other.Owner()] = true
+		}
+	}
+	return len(owners)
+}
+
+func TestPlayerHUD(t *testing.T) {
+	g := newTestGame(t, eatConfig())
+	me := joinTestPlayer(t, g, 1000, 5000, 5000)
+	near := joinTestPlayer(t, g, 4000, 5300, 5000)
+	joinTestPlayer(t, g, 5000, 9000, 9000)
+	joinTestPlayer(t, g, 1100, 5000, 5200)
+	small := joinTestPlayer(t, g, 300, 4800, 5000)
+	// A split threat counts once.
+	near.SetDirection(utils.Vector2D{X: -1})
+	if err := g.SplitPlayer(near.PlayerID); err != nil {
+		t.Fatalf("SplitPlayer: %v", err)
+	}
+
+	leaderboard := g.Leaderboard(10)
+	for _, player := range []*Player{me, small} {
+		hud, err := g.PlayerHUD(player.PlayerID)
+		if err != nil {
+			t.Fatalf("PlayerHUD: %v", err)
+		}
+		rank := 0
+		for i, entry := range leaderboard {
+			if entry.PlayerID == player.PlayerID {
+				rank = i + 1
+			}
+		}
+		if hud.Rank != rank || hud.Score != player.Score() || !hud.Alive {
+			t.Errorf("HUD %+v, want rank %d of the leaderboard and score %d alive", hud, rank, player.Score())
+		}
+		if want := threatsIn(g, player); hud.Threats != want {
+			t.Errorf("HUD of a player of mass %d counts %d threats, want the %d of its viewport", player.Score(), hud.Threats, want)
+		}
+	}
+	// The biggest player is out of sight and the next one too small.
+	if hud, _ := g.PlayerHUD(me.PlayerID); hud.Threats != 1 {
+		t.Errorf("HUD counts %d threats, want 1", hud.Threats)
+	}
+
+	cell := g.cells(near.PlayerID)[0]
+	for _, id := range []uuid.UUID{uuid.New(), cell.PlayerID} {
+		if _, err := g.PlayerHUD(id); !errors.Is(err, ErrorPlayerNotFound) {
+			t.Errorf("PlayerHUD(%v): got %v, want %v", id, err, ErrorPlayerNotFound)
+		}
+	}
+}
+
+func TestHUDFrames(t *testing.T) {
+	g := newTestGame(t, eatConfig())
+	me := joinTestPlayer(t, g, 1000, 5000, 5000)
+	old := joinTestPlayer(t, g, 2000, 9000, 9000)
+	conn := connectTestPlayer(me)
+	oldConn := connectTestPlayer(old)
+	old.Lock()
+	old.protocol = HUD_PROTOCOL_VERSION - 1
+	old.Unlock()
+
+	g.announceHUD()
+	payloads := conn.payloads(OpHUD)
+	if len(payloads) != 1 {
+		t.Fatalf("client got %d HUD frames, want 1", len(payloads))
+	}
+	want, _ := g.PlayerHUD(me.PlayerID)
+	if hud, err := DecodeHUD(payloads[0]); err != nil || hud != want {
+		t.Errorf("HUD frame carries %+v (%v), want %+v", hud, err, want)
+	}
+	if payloads := oldConn.payloads(OpHUD); len(payloads) != 0 {
+		t.Errorf("client of version %d got %d HUD frames, want none", HUD_PROTOCOL_VERSION-1, len(payloads))
+	}
+	if _, err := DecodeHUD(payloads[0][:len(payloads[0])-1]); !errors.Is(err, ErrorShortBuffer) {
+		t.Errorf("DecodeHUD of a short payload: got %v, want %v", err, ErrorShortBuffer)
+	}
+}
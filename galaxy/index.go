@@ -0,0 +1,41 @@
+package galaxy
+
+import (
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// SpatialIndex looks up the entities in an area of the world, see Grid and
+// Quadtree. Entities are circles identified by their ID.
+type SpatialIndex interface {
+	// Insert adds the entity id, inserting an id already in the index
+	// moves it.
+	Insert(id uuid.UUID, position utils.Vector2D, radius float64)
+	Remove(id uuid.UUID)
+	Move(id uuid.UUID, position utils.Vector2D, radius float64)
+
+	// QueryRange returns the entities whose bounding box intersects area.
+	QueryRange(area utils.Rect) []uuid.UUID
+	Len() int
+}
+
+// IndexKind selects the SpatialIndex of a game.
+type IndexKind int
+
+const (
+	// IndexGrid is a Grid with square cells of GameConfig.CellSize, the
+	// best fit for entities spread over the whole world.
+	IndexGrid IndexKind = iota
+
+	// IndexQuadtree is a Quadtree over GameConfig.Bounds, which adapts to
+	// crowded areas where a grid would have hot cells.
+	IndexQuadtree
+)
+
+// newIndex creates the spatial index selected by config.
+func newIndex(config GameConfig) SpatialIndex {
+	if config.Index == IndexQuadtree {
+		return NewQuadtree(config.Bounds)
+	}
+	return NewGrid(config.CellSize)
+}
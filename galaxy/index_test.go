@@ -0,0 +1,248 @@
+package galaxy
+
+import (
+	"cmp"
+	"math/rand/v2"
+	"slices"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// indexed is an entity of the spatial index tests.
+type indexed struct {
+	id       uuid.UUID
+	position utils.Vector2D
+	radius   float64
+}
+
+// uniformEntities returns n entities spread over the whole world.
+func uniformEntities(n int) []indexed {
+	random := rand.New(rand.NewPCG(1, 1))
+	entities := make([]indexed, n)
+	for i := range entities {
+		entities[i] = indexed{
+			id:       uuid.New(),
+			position: utils.Vector2D{X: random.Float64() * WORLD_WIDTH, Y: random.Float64() * WORLD_HEIGHT},
+			radius:   5 + random.Float64()*100,
+		}
+	}
+	return entities
+}
+
+// clusteredEntities returns n entities crowded around a few spawn points.
+func clusteredEntities(n int) []indexed {
+	random := rand.New(rand.NewPCG(3, 3))
+	clusters := []utils.Vector2D{{X: 2000, Y: 2000}, {X: 7000, Y: 3000}, {X: 5000, Y: 8000}}
+	entities := make([]indexed, n)
+	for i := range entities {
+		center := clusters[i%len(clusters)]
+		offset := utils.Vector2D{X: random.NormFloat64() * 150, Y: random.NormFloat64() * 150}
+		entities[i] = indexed{
+			id:       uuid.New(),
+			position: worldBounds.Clamp(center.Add(offset)),
+			radius:   5 + random.Float64()*20,
+		}
+	}
+	return entities
+}
+
+// naiveRange is the scan the spatial indexes replace.
+func naiveRange(entities []indexed, area utils.Rect) []uuid.UUID {
+	var found []uuid.UUID
+	for _, entity := range entities {
+		if utils.RectAround(entity.position, entity.radius, entity.radius).Intersects(area) {
+			found = append(found, entity.id)
+		}
+	}
+	return found
+}
+
+// queryAreas returns n viewport sized areas over the world.
+func queryAreas(n int) []utils.Rect {
+	random := rand.New(rand.NewPCG(2, 2))
+	areas := make([]utils.Rect, n)
+	for i := range areas {
+		center := utils.Vector2D{X: random.Float64() * WORLD_WIDTH, Y: random.Float64() * WORLD_HEIGHT}
+		areas[i] = utils.RectAround(center, DEFAULT_VIEWPORT_WIDTH/2, DEFAULT_VIEWPORT_HEIGHT/2)
+	}
+	return areas
+}
+
+func indexOf(entities []indexed, index SpatialIndex) SpatialIndex {
+	for _, entity := range entities {
+		index.Insert(entity.id, entity.position, entity.radius)
+	}
+	return index
+}
+
+func sorted(ids []uuid.UUID) []uuid.UUID {
+	ids = slices.Clone(ids)
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return slices.Compare(a[:], b[:]) })
+	return ids
+}
+
+func TestGridMatchesNaiveScan(t *testing.T) {
+	entities := uniformEntities(1000)
+	grid := indexOf(entities, NewGrid(DEFAULT_CELL_SIZE))
+
+	for _, area := range queryAreas(50) {
+		want := sorted(naiveRange(entities, area))
+		if got := sorted(grid.QueryRange(area)); !slices.Equal(got, want) {
+			t.Fatalf("QueryRange(%v) found %d entities, want %d", area, len(got), len(want))
+		}
+	}
+}
+
+func TestQuadtreeMatchesNaiveScan(t *testing.T) {
+	for name, entities := range map[string][]indexed{
+		"uniform":   uniformEntities(1000),
+		"clustered": clusteredEntities(1000),
+	} {
+		quadtree := indexOf(entities, NewQuadtree(worldBounds))
+		areas := append(queryAreas(50), collisionAreas(entities[:50])...)
+		for _, area := range areas {
+			want := sorted(naiveRange(entities, area))
+			if got := sorted(quadtree.QueryRange(area)); !slices.Equal(got, want) {
+				t.Fatalf("%s: QueryRange(%v) found %d entities, want %d", name, area, len(got), len(want))
+			}
+		}
+	}
+}
+
+// naiveNearest is the scan Game.Nearest replaces.
+func naiveNearest(g *Game, position utils.Vector2D, n int, filter func(Entity) bool) []Entity {
+	g.RLock()
+	defer g.RUnlock()
+
+	var entities []Entity
+	for _, ids := range [][]uuid.UUID{idsOf(g.players), idsOf(g.food), idsOf(g.viruses), idsOf(g.ejected)} {
+		for _, id := range ids {
+			if entity, _ := g.entityOf(id); filter == nil || filter(entity) {
+				entities = append(entities, entity)
+			}
+		}
+	}
+	slices.SortFunc(entities, func(a, b Entity) int {
+		if c := cmp.Compare(position.Distance(a.Position), position.Distance(b.Position)); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.NetID, b.NetID)
+	})
+	return entities[:min(n, len(entities))]
+}
+
+func idsOf[V any](entities map[uuid.UUID]V) []uuid.UUID {
+	ids := make([]uuid.UUID, 0, len(entities))
+	for id := range entities {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func TestNearestMatchesNaiveScan(t *testing.T) {
+	config := testConfig()
+	config.VirusCount = 10
+	for seed := range uint64(5) {
+		config.Seed = seed + 1
+		g := newTestGame(t, config)
+		random := rand.New(rand.NewPCG(seed, seed))
+		for range 30 {
+			joinTestPlayer(t, g, 25+random.Uint64N(2000), random.Float64()*WORLD_WIDTH, random.Float64()*WORLD_HEIGHT)
+		}
+		g.SpawnFood(500)
+		g.SpawnVirus(10)
+
+		players := func(e Entity) bool { return e.Kind == EntityPlayer }
+		for _, filter := range []func(Entity) bool{nil, players} {
+			for range 20 {
+				position := utils.Vector2D{X: random.Float64() * WORLD_WIDTH, Y: random.Float64() * WORLD_HEIGHT}
+				n := 1 + random.IntN(40)
+				want := naiveNearest(g, position, n, filter)
+				if got := g.Nearest(position, n, filter); !slices.Equal(got, want) {
+					t.Fatalf("seed %d: Nearest(%v, %d) differs from a scan of the world", seed, position, n)
+				}
+			}
+		}
+	}
+}
+
+func TestGridMoveAndRemove(t *testing.T) {
+	grid := NewGrid(DEFAULT_CELL_SIZE)
+	id := uuid.New()
+	grid.Insert(id, utils.Vector2D{X: 100, Y: 100}, 10)
+
+	grid.Move(id, utils.Vector2D{X: 5000, Y: 5000}, 10)
+	if found := grid.QueryRange(utils.RectAround(utils.Vector2D{X: 100, Y: 100}, 50, 50)); len(found) != 0 {
+		t.Errorf("entity still found where it was before moving")
+	}
+	if found := grid.QueryRange(utils.RectAround(utils.Vector2D{X: 5000, Y: 5000}, 50, 50)); len(found) != 1 {
+		t.Errorf("entity not found where it moved")
+	}
+
+	grid.Remove(id)
+	if grid.Len() != 0 {
+		t.Errorf("Len() = %d after removing the only entity", grid.Len())
+	}
+}
+
+// collisionAreas returns the bounds of entities, what collisions query.
+func collisionAreas(entities []indexed) []utils.Rect {
+	areas := make([]utils.Rect, len(entities))
+	for i, entity := range entities {
+		areas[i] = utils.RectAround(entity.position, entity.radius, entity.radius)
+	}
+	return areas
+}
+
+func BenchmarkQueryRange(b *testing.B) {
+	entities := uniformEntities(5000)
+	workloads := []struct {
+		name  string
+		areas []utils.Rect
+	}{
+		{"viewport", queryAreas(64)},
+		{"collision", collisionAreas(entities)},
+	}
+
+	for _, workload := range workloads {
+		areas := workload.areas
+		b.Run(workload.name+"/naive", func(b *testing.B) {
+			for i := range b.N {
+				naiveRange(entities, areas[i%len(areas)])
+			}
+		})
+		b.Run(workload.name+"/grid", func(b *testing.B) {
+			grid := indexOf(entities, NewGrid(DEFAULT_CELL_SIZE))
+			b.ResetTimer()
+			for i := range b.N {
+				grid.QueryRange(areas[i%len(areas)])
+			}
+		})
+	}
+}
+
+// BenchmarkClusteredQueryRange runs the collision queries of crowded spawn
+// points, where grid cells get hot.
+func BenchmarkClusteredQueryRange(b *testing.B) {
+	entities := clusteredEntities(5000)
+	areas := collisionAreas(entities)
+	indexes := []struct {
+		name  string
+		index func() SpatialIndex
+	}{
+		{"grid", func() SpatialIndex { return NewGrid(DEFAULT_CELL_SIZE) }},
+		{"quadtree", func() SpatialIndex { return NewQuadtree(worldBounds) }},
+	}
+
+	for _, index := range indexes {
+		b.Run(index.name, func(b *testing.B) {
+			index := indexOf(entities, index.index())
+			b.ResetTimer()
+			for i := range b.N {
+				index.QueryRange(areas[i%len(areas)])
+			}
+		})
+	}
+}
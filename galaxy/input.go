@@ -0,0 +1,165 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// INPUT_SIZE is the encoded size of a player input:
+// direction x float32 (4) | direction y float32 (4) | actions (1), little
+// endian.
+const INPUT_SIZE = 4 + 4 + 1
+
+// Action bits of PlayerInput.Actions.
+const (
+	ActionSplit uint8 = 1 << iota
+	ActionEject
+	ActionBoost
+
+	// ACTION_MASK has the bits of every known action, DecodeInput clears
+	// the others.
+	ACTION_MASK = ActionSplit | ActionEject | ActionBoost
+)
+
+const (
+	// SPLIT_ACTION_COOLDOWN, EJECT_ACTION_COOLDOWN and BOOST_ACTION_COOLDOWN
+	// are the minimum time between two actions of a player, requests in
+	// between are ignored so clients can't spam them.
+	SPLIT_ACTION_COOLDOWN = 500 * time.Millisecond
+	EJECT_ACTION_COOLDOWN = 100 * time.Millisecond
+	BOOST_ACTION_COOLDOWN = 3 * time.Second
+)
+
+// actionCooldowns are the cooldowns of the actions by default, see
+// CooldownTracker.
+var actionCooldowns = map[uint8]time.Duration{
+	ActionSplit: SPLIT_ACTION_COOLDOWN,
+	ActionEject: EJECT_ACTION_COOLDOWN,
+	ActionBoost: BOOST_ACTION_COOLDOWN,
+}
+
+// CooldownTracker throttles actions, keyed by their PlayerInput action
+// bit: once triggered, an action isn't ready again until its cooldown
+// passed. Times are those of a simulated clock, such as the time a player
+// was alive for, not the wall clock, so cooldowns follow ticks and replays
+// throttle the same. The zero value is ready to use with the default
+// cooldowns. It isn't safe for concurrent use.
+type CooldownTracker struct {
+	// Durations are the cooldowns of the actions, ones missing have none.
+	// nil takes SPLIT_ACTION_COOLDOWN, EJECT_ACTION_COOLDOWN and
+	// BOOST_ACTION_COOLDOWN.
+	Durations map[uint8]time.Duration
+
+	// readyAt is when each triggered action is ready again.
+	readyAt map[uint8]time.Duration
+}
+
+// Ready reports whether action can be triggered at now.
+func (t *CooldownTracker) Ready(action uint8, now time.Duration) bool {
+	return now >= t.readyAt[action]
+}
+
+// Trigger starts the cooldown of action at now if it is ready, reporting
+// whether it was.
+func (t *CooldownTracker) Trigger(action uint8, now time.Duration) bool {
+	if !t.Ready(action, now) {
+		return false
+	}
+	if t.readyAt == nil {
+		t.readyAt = make(map[uint8]time.Duration)
+	}
+	durations := t.Durations
+	if durations == nil {
+		durations = actionCooldowns
+	}
+	t.readyAt[action] = now + durations[action]
+	return true
+}
+
+var ErrorInvalidInput = fmt.Errorf("Invalid input")
+
+// PlayerInput is what a client sends in OpInput frames: where it wants to
+// move and the actions it triggers.
+type PlayerInput struct {
+	// Direction is normalized, or zero to stop.
+	Direction utils.Vector2D
+	Actions   uint8
+}
+
+// DecodeInput decodes the payload of an OpInput frame, rejecting
+// directions that aren't finite. Inputs only carry a direction and action
+// bits: bytes past INPUT_SIZE and unknown action bits are dropped, clients
+// have no say over anything else, such as their mass or radius.
+func DecodeInput(data []byte) (PlayerInput, error) {
+	if len(data) < INPUT_SIZE {
+		return PlayerInput{}, ErrorShortBuffer
+	}
+
+	x := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[0:4])))
+	y := float64(math.Float32frombits(binary.LittleEndian.Uint32(data[4:8])))
+	if math.IsNaN(x) || math.IsInf(x, 0) || math.IsNaN(y) || math.IsInf(y, 0) {
+		return PlayerInput{}, ErrorInvalidInput
+	}
+
+	return PlayerInput{
+		Direction: utils.Vector2D{X: x, Y: y}.Normalize(),
+		Actions:   data[8] & ACTION_MASK,
+	}, nil
+}
+
+// EncodeInput encodes the payload of an OpInput frame, see DecodeInput.
+func EncodeInput(input PlayerInput) []byte {
+	data := make([]byte, 0, INPUT_SIZE)
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(input.Direction.X)))
+	data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(input.Direction.Y)))
+	return append(data, input.Actions)
+}
+
+// SetInput records the input of the client, applied on the next tick.
+// Inputs aren't queued: only the latest direction is kept, the ones it
+// supersedes before the tick are dropped on purpose since only the newest
+// matters, so a client sending faster than the tick rate costs no memory.
+// Actions add up until then so none is lost between ticks. Directions
+// longer than a unit vector, which would make the player faster, are
+// normalized.
+func (p *Player) SetInput(input PlayerInput) {
+	if input.Direction.LengthSquared() > 1+1e-9 {
+		input.Direction = input.Direction.Normalize()
+	}
+
+	p.Lock()
+	p.direction = input.Direction
+	p.actions |= input.Actions
+	p.lastInput = time.Now()
+	p.Unlock()
+}
+
+// takeActions returns and clears the pending actions of p, leaving out the
+// ones still on cooldown.
+func (p *Player) takeActions() uint8 {
+	p.Lock()
+	defer p.Unlock()
+
+	requested := p.actions
+	p.actions = 0
+
+	actions := requested
+	// The action bits are contiguous, starting from the lowest.
+	for action := uint8(1); action&ACTION_MASK != 0; action <<= 1 {
+		if actions&action != 0 && !p.cooldowns.Trigger(action, p.clock) {
+			actions &^= action
+		}
+	}
+
+	// Log once per burst, a spamming client would flood the log otherwise.
+	if actions != requested && !p.throttled {
+		log.Printf("throttling actions of %v", p.PlayerID)
+	}
+	p.throttled = actions != requested
+	return actions
+}
@@ -0,0 +1,88 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+func TestInputCarriesNoMass(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	player := joinTestPlayer(t, g, 100, 1000, 1000)
+	player.RLock()
+	radius := player.Radius
+	player.RUnlock()
+
+	// A tampered input claiming a huge mass and radius behind the
+	// direction, with unknown action bits set.
+	payload := EncodeInput(PlayerInput{Direction: utils.Vector2D{X: 1}})
+	payload[INPUT_SIZE-1] = 0xf0
+	payload = binary.LittleEndian.AppendUint64(payload, math.MaxUint64/2)
+	payload = binary.LittleEndian.AppendUint32(payload, 5000)
+	g.Dispatcher().Dispatch(player, EncodeFrame(OpInput, payload))
+	g.Tick(50 * time.Millisecond)
+
+	if direction := player.Direction(); direction != (utils.Vector2D{X: 1}) {
+		t.Errorf("direction %v, want the one of the input", direction)
+	}
+	player.RLock()
+	defer player.RUnlock()
+	if player.Mass != 100 || player.Radius != radius {
+		t.Errorf("mass %d and radius %d after the input, want %d and %d", player.Mass, player.Radius, 100, radius)
+	}
+	if player.actions != 0 || player.boostLeft > 0 {
+		t.Errorf("unknown action bits were applied")
+	}
+}
+
+func FuzzDecodeInput(f *testing.F) {
+	f.Add([]byte{})
+	f.Add(EncodeInput(PlayerInput{}))
+	f.Add(EncodeInput(PlayerInput{Direction: utils.Vector2D{X: 1}, Actions: ActionSplit | ActionBoost}))
+	f.Add(append(EncodeInput(PlayerInput{Direction: utils.FromAngle(1)}), 0xff, 0xff, 0xff))
+	f.Add(EncodeInput(PlayerInput{Direction: utils.Vector2D{X: math.Inf(1)}})[:INPUT_SIZE-1])
+	f.Add(EncodeInput(PlayerInput{Direction: utils.Vector2D{X: math.NaN(), Y: 1}}))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		input, err := DecodeInput(data)
+		if err != nil {
+			return
+		}
+		if length := input.Direction.Length(); math.IsNaN(length) || (length != 0 && math.Abs(length-1) > 1e-9) {
+			t.Fatalf("decoded direction %v of length %v, want a unit or zero vector", input.Direction, length)
+		}
+		if input.Actions&^ACTION_MASK != 0 {
+			t.Fatalf("decoded unknown actions %08b", input.Actions)
+		}
+
+		again, err := DecodeInput(EncodeInput(input))
+		if err != nil {
+			t.Fatalf("decoding a decoded input again: %v", err)
+		}
+		if !again.Direction.EqualWithin(input.Direction, 1e-6) || again.Actions != input.Actions {
+			t.Fatalf("input %+v decoded again as %+v", input, again)
+		}
+	})
+}
+
+func TestMalformedInputsAreDropped(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	conn := &fakeConn{}
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	player.conn = conn
+
+	nan := EncodeInput(PlayerInput{Direction: utils.Vector2D{X: math.NaN()}})
+	for _, payload := range [][]byte{nil, nan[:INPUT_SIZE-1], nan} {
+		g.Dispatcher().Dispatch(player, EncodeFrame(OpInput, payload))
+	}
+
+	if dropped := g.DroppedInputs(); dropped != 3 {
+		t.Errorf("DroppedInputs() = %d, want 3", dropped)
+	}
+	if closed, _ := conn.isClosed(); closed {
+		t.Error("malformed inputs closed the connection")
+	}
+}
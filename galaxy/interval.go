@@ -0,0 +1,88 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+)
+
+// INTERVAL_PROTOCOL_VERSION is the first protocol version told the
+// interval between state updates, in its Limits and by OpBroadcastInterval
+// frames.
+const INTERVAL_PROTOCOL_VERSION = 9
+
+// BroadcastInterval returns the interval between the state updates Run
+// sends, so clients can size their interpolation buffer, see
+// SetBroadcastRate.
+func (g *Game) BroadcastInterval() time.Duration {
+	g.RLock()
+	defer g.RUnlock()
+	return g.broadcastInterval()
+}
+
+// broadcastInterval implements BroadcastInterval, the caller must hold the
+// lock.
+func (g *Game) broadcastInterval() time.Duration {
+	rate := g.config.BroadcastRate
+	if rate <= 0 {
+		rate = g.tickRate()
+	}
+	return time.Second / time.Duration(rate)
+}
+
+// tickRate returns the number of ticks per second, see GameConfig.TickRate.
+func (g *Game) tickRate() int {
+	if g.config.TickRate <= 0 {
+		return DEFAULT_TICK_RATE
+	}
+	return g.config.TickRate
+}
+
+// SetBroadcastRate changes the number of state updates per second Run
+// sends while the game runs, such as to save bandwidth in crowded rooms.
+// Like GameConfig.BroadcastRate, 0 sends one every tick and rates above
+// the tick rate fail with ErrorInvalidConfig. Clients are sent the new
+// interval in an OpBroadcastInterval frame.
+func (g *Game) SetBroadcastRate(rate int) error {
+	g.Lock()
+	if rate < 0 || rate > g.tickRate() {
+		g.Unlock()
+		return fmt.Errorf("%w: BroadcastRate must be at most TickRate, got %d and %d", ErrorInvalidConfig, rate, g.tickRate())
+	}
+	g.config.BroadcastRate = rate
+	interval := g.broadcastInterval()
+	clients := g.clients()
+	g.Unlock()
+
+	frame := EncodeFrame(OpBroadcastInterval, encodeInterval(interval))
+	text := debugFrame{Op: OpBroadcastInterval.String(), Interval: durationMicros(interval)}.encode()
+	for _, client := range clients {
+		switch {
+		case client.ProtocolVersion() < INTERVAL_PROTOCOL_VERSION:
+		case client.debug():
+			client.sendText(text)
+		default:
+			client.SendBinary(frame)
+		}
+	}
+	return nil
+}
+
+// encodeInterval encodes the payload of an OpBroadcastInterval frame:
+// interval uint32 (4), in microseconds, little endian.
+func encodeInterval(interval time.Duration) []byte {
+	return binary.LittleEndian.AppendUint32(nil, uint32(interval.Microseconds()))
+}
+
+// DecodeInterval decodes the payload of an OpBroadcastInterval frame.
+func DecodeInterval(payload []byte) (time.Duration, error) {
+	if len(payload) < 4 {
+		return 0, ErrorShortBuffer
+	}
+	return time.Duration(binary.LittleEndian.Uint32(payload)) * time.Microsecond, nil
+}
+
+func durationMicros(d time.Duration) *int64 {
+	micros := d.Microseconds()
+	return &micros
+}
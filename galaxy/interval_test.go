@@ -0,0 +1,70 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestSetBroadcastRateAnnouncesTheInterval(t *testing.T) {
+	config := testConfig()
+	config.TickRate, config.BroadcastRate = 40, 20
+	g := newTestGame(t, config)
+	factory := &fakeFactory{}
+	current, _ := sayHello(t, g, factory, PROTOCOL_VERSION)
+	old, _ := sayHello(t, g, factory, INTERVAL_PROTOCOL_VERSION-1)
+
+	if err := g.SetBroadcastRate(10); err != nil {
+		t.Fatalf("SetBroadcastRate: %v", err)
+	}
+	if interval := g.BroadcastInterval(); interval != 100*time.Millisecond {
+		t.Errorf("BroadcastInterval() = %v, want 100ms", interval)
+	}
+	payloads := current.payloads(OpBroadcastInterval)
+	if len(payloads) != 1 {
+		t.Fatalf("client got %d interval frames, want 1", len(payloads))
+	}
+	if interval, err := DecodeInterval(payloads[0]); err != nil || interval != 100*time.Millisecond {
+		t.Errorf("interval frame carries %v (%v), want 100ms", interval, err)
+	}
+	if payloads := old.payloads(OpBroadcastInterval); len(payloads) != 0 {
+		t.Errorf("client of version %d got %d interval frames, want none", INTERVAL_PROTOCOL_VERSION-1, len(payloads))
+	}
+
+	// Clients joining later are told the new interval in their hello.
+	_, hello := sayHello(t, g, factory, PROTOCOL_VERSION)
+	if limits, err := DecodeLimits(hello); err != nil || limits.BroadcastInterval != 100*time.Millisecond {
+		t.Errorf("hello carries an interval of %v (%v), want 100ms", limits.BroadcastInterval, err)
+	}
+
+	// 0 broadcasts every tick.
+	if err := g.SetBroadcastRate(0); err != nil {
+		t.Fatalf("SetBroadcastRate(0): %v", err)
+	}
+	payloads = current.payloads(OpBroadcastInterval)
+	if len(payloads) != 2 {
+		t.Fatalf("client got %d interval frames, want 2", len(payloads))
+	}
+	if interval, err := DecodeInterval(payloads[1]); err != nil || interval != time.Second/40 {
+		t.Errorf("interval frame carries %v (%v), want %v", interval, err, time.Second/40)
+	}
+}
+
+func TestSetBroadcastRateRejectsRatesAboveTheTickRate(t *testing.T) {
+	config := testConfig()
+	config.TickRate, config.BroadcastRate = 40, 20
+	g := newTestGame(t, config)
+	conn, _ := sayHello(t, g, &fakeFactory{}, PROTOCOL_VERSION)
+
+	for _, rate := range []int{-1, 41} {
+		if err := g.SetBroadcastRate(rate); !errors.Is(err, ErrorInvalidConfig) {
+			t.Errorf("SetBroadcastRate(%d): got %v, want %v", rate, err, ErrorInvalidConfig)
+		}
+	}
+	if interval := g.BroadcastInterval(); interval != time.Second/20 {
+		t.Errorf("BroadcastInterval() = %v after refused rates, want %v", interval, time.Second/20)
+	}
+	if payloads := conn.payloads(OpBroadcastInterval); len(payloads) != 0 {
+		t.Errorf("client got %d interval frames for refused rates, want none", len(payloads))
+	}
+}
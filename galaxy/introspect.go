@@ -0,0 +1,97 @@
+package galaxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"log"
+	"net/http"
+	"slices"
+)
+
+// roomStatus is the JSON description of a room served by DebugHandler.
+type roomStatus struct {
+	ID          string  `json:"id"`
+	State       string  `json:"state"`
+	Paused      bool    `json:"paused"`
+	Connections int     `json:"connections"`
+	Players     int     `json:"players"`
+	Spectators  int     `json:"spectators"`
+	Food        int     `json:"food"`
+	TotalMass   uint64  `json:"total_mass"`
+	Leader      string  `json:"leader,omitempty"`
+	TickRate    int     `json:"tick_rate"`
+	AverageTick float64 `json:"average_tick_ms"`
+	Seed        uint64  `json:"seed"`
+}
+
+// serverStatus is the JSON document served by DebugHandler.
+type serverStatus struct {
+	Rooms   []roomStatus `json:"rooms"`
+	Players int          `json:"players"`
+}
+
+// DebugHandler serves a JSON description of the running rooms, their
+// player counts and Game.Stats, meant to be mounted at /debug/game. It
+// only takes the locks Stats and PlayerCount take, so polling it doesn't
+// hold up ticking. Every request goes through auth, which is expected to
+// refuse the ones that aren't from an operator; a nil auth refuses them
+// all, so the handler is never exposed by accident.
+func (m *RoomManager) DebugHandler(auth func(next http.Handler) http.Handler) http.Handler {
+	if auth == nil {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+		})
+	}
+	return auth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(m.status()); err != nil {
+			log.Printf("error writing debug status: %v", err)
+		}
+	}))
+}
+
+// status describes the rooms ordered by ID. The games are only looked at
+// once the lock of the manager is released.
+func (m *RoomManager) status() serverStatus {
+	type seat struct {
+		id      string
+		game    *Game
+		members int
+	}
+	m.Lock()
+	seats := make([]seat, 0, len(m.rooms))
+	for id, r := range m.rooms {
+		seats = append(seats, seat{id: id, game: r.game, members: r.members})
+	}
+	m.Unlock()
+	slices.SortFunc(seats, func(a, b seat) int { return cmp.Compare(a.id, b.id) })
+
+	status := serverStatus{Rooms: make([]roomStatus, 0, len(seats))}
+	for _, seat := range seats {
+		stats := seat.game.Stats()
+		room := roomStatus{
+			ID:          seat.id,
+			State:       seat.game.State().String(),
+			Paused:      seat.game.Paused(),
+			Connections: seat.members,
+			Players:     seat.game.PlayerCount(),
+			Spectators:  seat.game.SpectatorCount(),
+			Food:        stats.Food,
+			TotalMass:   stats.TotalMass,
+			Leader:      stats.Leader,
+			TickRate:    stats.TickRate,
+			AverageTick: float64(stats.AverageTick.Microseconds()) / 1000,
+			Seed:        seat.game.Seed(),
+		}
+		status.Rooms = append(status.Rooms, room)
+		status.Players += room.Players
+	}
+	return status
+}
@@ -0,0 +1,259 @@
+package galaxy
+
+import (
+	"bytes"
+	"container/heap"
+	"encoding/binary"
+	"slices"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// LEADERBOARD_PROTOCOL_VERSION is the first protocol version with
+	// OpLeaderboard frames, older clients aren't sent any.
+	LEADERBOARD_PROTOCOL_VERSION = 6
+
+	// LEADERBOARD_SIZE is the number of players OpLeaderboard frames carry
+	// by default.
+	LEADERBOARD_SIZE = 10
+
+	// LEADERBOARD_SCORE_CHANGE is the fraction of its score a player of
+	// the leaderboard must gain or lose since the last OpLeaderboard frame
+	// for a new one to be sent, when nobody changed rank.
+	LEADERBOARD_SCORE_CHANGE = 0.05
+)
+
+// LeaderboardEntry is a player's position in the leaderboard.
+type LeaderboardEntry struct {
+	PlayerID uuid.UUID
+	Username string
+	Score    uint64
+
+	// NetID is the network ID of the player, the only ID OpLeaderboard
+	// frames carry.
+	NetID uint32
+}
+
+// ranksAbove reports whether e goes before other in the leaderboard: higher
+// scores first, ties broken by player ID so the order is stable.
+func (e LeaderboardEntry) ranksAbove(other LeaderboardEntry) bool {
+	if e.Score != other.Score {
+		return e.Score > other.Score
+	}
+	return bytes.Compare(e.PlayerID[:], other.PlayerID[:]) < 0
+}
+
+// leaderboardHeap is a min-heap keeping the worst ranked entry on top, so
+// the top n of m players costs O(m log n) instead of sorting everyone.
+type leaderboardHeap []LeaderboardEntry
+
+func (h leaderboardHeap) Len() int           { return len(h) }
+func (h leaderboardHeap) Less(i, j int) bool { return h[j].ranksAbove(h[i]) }
+func (h leaderboardHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+func (h *leaderboardHeap) Push(x any)        { *h = append(*h, x.(LeaderboardEntry)) }
+func (h *leaderboardHeap) Pop() any {
+	old := *h
+	entry := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return entry
+}
+
+// Leaderboard returns the n players with the highest score, best first.
+// The score of a split player is the mass of all its cells.
+func (g *Game) Leaderboard(n int) []LeaderboardEntry {
+	g.RLock()
+	defer g.RUnlock()
+	return g.leaderboard(n)
+}
+
+func (g *Game) leaderboard(n int) []LeaderboardEntry {
+	if n <= 0 {
+		return nil
+	}
+
+	cellMass := g.cellMass()
+	top := make(leaderboardHeap, 0, n+1)
+	for _, player := range g.players {
+		if player.IsCell() || !player.IsAlive() {
+			continue
+		}
+
+		entry := g.entry(player, cellMass)
+		if len(top) == n && !entry.ranksAbove(top[0]) {
+			continue
+		}
+		heap.Push(&top, entry)
+		if len(top) > n {
+			heap.Pop(&top)
+		}
+	}
+
+	sortLeaderboard(top)
+	return top
+}
+
+// standings returns the entries of every player alive, best first, the
+// whole leaderboard. The caller must hold the lock.
+func (g *Game) standings() []LeaderboardEntry {
+	cellMass := g.cellMass()
+	entries := make([]LeaderboardEntry, 0, len(g.players))
+	for _, player := range g.players {
+		if !player.IsCell() && player.IsAlive() {
+			entries = append(entries, g.entry(player, cellMass))
+		}
+	}
+	sortLeaderboard(entries)
+	return entries
+}
+
+// cellMass returns the mass of the split cells of every player that has
+// some. The caller must hold the lock.
+func (g *Game) cellMass() map[uuid.UUID]uint64 {
+	cellMass := make(map[uuid.UUID]uint64)
+	for _, player := range g.players {
+		if player.IsCell() {
+			cellMass[player.OwnerID] += player.Score()
+		}
+	}
+	return cellMass
+}
+
+// entry returns the leaderboard entry of player given the mass of its
+// split cells, see cellMass. The caller must hold the lock.
+func (g *Game) entry(player *Player, cellMass map[uuid.UUID]uint64) LeaderboardEntry {
+	player.RLock()
+	entry := LeaderboardEntry{
+		PlayerID: player.PlayerID,
+		Username: player.Username,
+		Score:    player.Mass + cellMass[player.PlayerID],
+	}
+	player.RUnlock()
+	entry.NetID = g.netIDs.ids[entry.PlayerID]
+	return entry
+}
+
+func sortLeaderboard(entries []LeaderboardEntry) {
+	slices.SortFunc(entries, func(a, b LeaderboardEntry) int {
+		if a.ranksAbove(b) {
+			return -1
+		}
+		return 1
+	})
+}
+
+// announceLeaderboard sends the leaderboard to every client in an
+// OpLeaderboard frame, see encodeLeaderboard, but only if it changed since
+// the last one: a player changed rank or scored LEADERBOARD_SCORE_CHANGE
+// more or less. Clients joining later are sent the last one, see
+// sendLeaderboard.
+func (g *Game) announceLeaderboard() {
+	g.Lock()
+	board := g.leaderboard(g.leaderboardSize())
+	if !leaderboardChanged(g.board, board) {
+		g.Unlock()
+		return
+	}
+	g.board = board
+	clients := g.clients()
+	g.Unlock()
+
+	frame := EncodeFrame(OpLeaderboard, encodeLeaderboard(board))
+	text := debugFrame{Op: OpLeaderboard.String(), Leaderboard: board}.encode()
+	for _, client := range clients {
+		sendLeaderboard(client, frame, text)
+	}
+}
+
+// sendBoard sends the last leaderboard announced to a client that just said
+// hello.
+func (g *Game) sendBoard(client client) {
+	g.RLock()
+	board := g.board
+	g.RUnlock()
+	if board == nil {
+		return
+	}
+
+	frame := EncodeFrame(OpLeaderboard, encodeLeaderboard(board))
+	sendLeaderboard(client, frame, debugFrame{Op: OpLeaderboard.String(), Leaderboard: board}.encode())
+}
+
+func sendLeaderboard(client client, frame []byte, text string) {
+	switch {
+	case client.ProtocolVersion() < LEADERBOARD_PROTOCOL_VERSION:
+	case client.debug():
+		client.sendText(text)
+	default:
+		client.SendBinary(frame)
+	}
+}
+
+func (g *Game) leaderboardSize() int {
+	if g.config.LeaderboardSize <= 0 {
+		return LEADERBOARD_SIZE
+	}
+	return g.config.LeaderboardSize
+}
+
+// leaderboardChanged reports whether next is worth sending to clients that
+// were sent prev.
+func leaderboardChanged(prev []LeaderboardEntry, next []LeaderboardEntry) bool {
+	if prev == nil || len(prev) != len(next) {
+		return true
+	}
+	for i := range next {
+		if prev[i].PlayerID != next[i].PlayerID || prev[i].Username != next[i].Username {
+			return true
+		}
+		change := float64(max(prev[i].Score, next[i].Score) - min(prev[i].Score, next[i].Score))
+		if change > LEADERBOARD_SCORE_CHANGE*float64(prev[i].Score) {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeLeaderboard encodes the payload of an OpLeaderboard frame, best
+// first: count (1) | count entries of network ID uint32 (4) |
+// score uint64 (8) | username length (1) | username, little endian.
+func encodeLeaderboard(board []LeaderboardEntry) []byte {
+	board = board[:min(len(board), 255)]
+	data := make([]byte, 0, 1+len(board)*(4+8+1+MAX_USERNAME_LENGTH))
+	data = append(data, byte(len(board)))
+	for _, entry := range board {
+		name := entry.Username[:min(len(entry.Username), 255)]
+		data = binary.LittleEndian.AppendUint32(data, entry.NetID)
+		data = binary.LittleEndian.AppendUint64(data, entry.Score)
+		data = append(data, byte(len(name)))
+		data = append(data, name...)
+	}
+	return data
+}
+
+// DecodeLeaderboard decodes the payload of an OpLeaderboard frame, the
+// entries have no PlayerID.
+func DecodeLeaderboard(payload []byte) ([]LeaderboardEntry, error) {
+	if len(payload) < 1 {
+		return nil, ErrorShortBuffer
+	}
+
+	board := make([]LeaderboardEntry, payload[0])
+	payload = payload[1:]
+	for i := range board {
+		if len(payload) < 4+8+1 {
+			return nil, ErrorShortBuffer
+		}
+		length := int(payload[12])
+		if len(payload) < 4+8+1+length {
+			return nil, ErrorShortBuffer
+		}
+		board[i] = LeaderboardEntry{
+			NetID:    binary.LittleEndian.Uint32(payload[:4]),
+			Score:    binary.LittleEndian.Uint64(payload[4:12]),
+			Username: string(payload[13 : 13+length]),
+		}
+		payload = payload[13+length:]
+	}
+	return board, nil
+}
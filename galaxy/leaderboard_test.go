@@ -0,0 +1,67 @@
+package galaxy
+
+import "testing"
+
+func TestLeaderboardFrame(t *testing.T) {
+	config := quietConfig()
+	config.LeaderboardSize = 3
+	g := newTestGame(t, config)
+
+	small := joinTestPlayer(t, g, 100, 1000, 1000)
+	large := joinTestPlayer(t, g, 800, 3000, 1000)
+	split := joinTestPlayer(t, g, 600, 5000, 1000)
+	middle := joinTestPlayer(t, g, 500, 7000, 1000)
+	for i, player := range []*Player{small, large, split, middle} {
+		player.Username = string(rune('a' + i))
+	}
+	// A split player scores the mass of all its cells.
+	if err := g.SplitPlayer(split.PlayerID); err != nil {
+		t.Fatalf("SplitPlayer: %v", err)
+	}
+	conn := connectTestPlayer(small)
+
+	g.announceLeaderboard()
+	payloads := conn.payloads(OpLeaderboard)
+	if len(payloads) != 1 {
+		t.Fatalf("client sent %d leaderboard frames, want 1", len(payloads))
+	}
+	board, err := DecodeLeaderboard(payloads[0])
+	if err != nil {
+		t.Fatalf("DecodeLeaderboard: %v", err)
+	}
+
+	want := []struct {
+		player *Player
+		score  uint64
+	}{{large, 800}, {split, 600}, {middle, 500}}
+	if len(board) != len(want) {
+		t.Fatalf("leaderboard has %d entries, want %d", len(board), len(want))
+	}
+	for i, entry := range board {
+		w := want[i]
+		if entry.NetID != g.netIDs.ids[w.player.PlayerID] || entry.Username != w.player.Username || entry.Score != w.score {
+			t.Errorf("entry %d is %q (%d) scoring %d, want %q (%d) scoring %d",
+				i, entry.Username, entry.NetID, entry.Score, w.player.Username, g.netIDs.ids[w.player.PlayerID], w.score)
+		}
+	}
+
+	// Nothing changed, nothing is sent.
+	g.announceLeaderboard()
+	if sent := len(conn.payloads(OpLeaderboard)); sent != 1 {
+		t.Errorf("client sent %d leaderboard frames for an unchanged leaderboard, want 1", sent)
+	}
+
+	// The small player overtakes everyone.
+	small.Lock()
+	small.Mass = 1000
+	small.Unlock()
+	g.announceLeaderboard()
+	payloads = conn.payloads(OpLeaderboard)
+	if len(payloads) != 2 {
+		t.Fatalf("client sent %d leaderboard frames after a rank change, want 2", len(payloads))
+	}
+	board, _ = DecodeLeaderboard(payloads[1])
+	if len(board) != 3 || board[0].Username != small.Username || board[1].Username != large.Username {
+		t.Errorf("leaderboard after overtaking is %+v, want %q first and %q second", board, small.Username, large.Username)
+	}
+}
@@ -0,0 +1,107 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// LIMITS_PROTOCOL_VERSION is the first protocol version whose accepting
+// OpHello frames carry the Limits of the game, see DecodeLimits.
+const LIMITS_PROTOCOL_VERSION = 8
+
+// Limits are what clients need to know of a game to size their buffers and
+// predict movement between state updates.
+type Limits struct {
+	// MaxMessageSize is the size in bytes of the largest frame the
+	// connection of the client accepts, 0 if it doesn't say, see
+	// MessageSizeLimiter.
+	MaxMessageSize int64
+
+	// TickRate is the number of simulation steps per second.
+	TickRate int
+
+	// BroadcastInterval is the time between state updates, to size
+	// interpolation buffers with. It is only sent to clients of
+	// INTERVAL_PROTOCOL_VERSION or newer, which are told when it changes.
+	BroadcastInterval time.Duration
+
+	// Bounds is the area of the world, see Game.Resize.
+	Bounds utils.Rect
+}
+
+// MessageSizeLimiter is implemented by connections that bound the size of
+// the messages they read, sent to clients as Limits.MaxMessageSize.
+type MessageSizeLimiter interface {
+	MaxMessageSize() int64
+}
+
+// Limits returns the limits of the game. MaxMessageSize depends on the
+// connection of each client and is left 0.
+func (g *Game) Limits() Limits {
+	g.RLock()
+	defer g.RUnlock()
+	return g.limitsFor(nil)
+}
+
+// limits returns the limits sent to the client of conn, see Limits.
+func (g *Game) limits(conn ClientConnection) Limits {
+	g.RLock()
+	defer g.RUnlock()
+	return g.limitsFor(conn)
+}
+
+// limitsFor implements limits, the caller must hold the lock. conn may be
+// nil.
+func (g *Game) limitsFor(conn ClientConnection) Limits {
+	limits := Limits{TickRate: g.tickRate(), BroadcastInterval: g.broadcastInterval(), Bounds: g.config.Bounds}
+	if limiter, ok := conn.(MessageSizeLimiter); ok {
+		limits.MaxMessageSize = limiter.MaxMessageSize()
+	}
+	return limits
+}
+
+// appendLimits appends limits to an OpHello payload negotiating version
+// as: max message size uint32 (4) | tick rate uint16 (2) |
+// min x | min y | max x | max y, float64 (8) each, and from
+// INTERVAL_PROTOCOL_VERSION on | broadcast interval uint32 (4) in
+// microseconds, little endian.
+func appendLimits(data []byte, limits Limits, version uint16) []byte {
+	data = binary.LittleEndian.AppendUint32(data, uint32(min(max(limits.MaxMessageSize, 0), math.MaxUint32)))
+	data = binary.LittleEndian.AppendUint16(data, uint16(min(max(limits.TickRate, 0), math.MaxUint16)))
+	for _, v := range []float64{limits.Bounds.Min.X, limits.Bounds.Min.Y, limits.Bounds.Max.X, limits.Bounds.Max.Y} {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	if version >= INTERVAL_PROTOCOL_VERSION {
+		data = append(data, encodeInterval(limits.BroadcastInterval)...)
+	}
+	return data
+}
+
+// DecodeLimits decodes the limits carried by the payload of an OpHello
+// frame accepting a client of LIMITS_PROTOCOL_VERSION or newer,
+// BroadcastInterval only from INTERVAL_PROTOCOL_VERSION on.
+func DecodeLimits(payload []byte) (Limits, error) {
+	if len(payload) < 5+4+2+4*8 {
+		return Limits{}, ErrorShortBuffer
+	}
+	data := payload[5:]
+	v := func(i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(data[6+i*8:]))
+	}
+	limits := Limits{
+		MaxMessageSize: int64(binary.LittleEndian.Uint32(data)),
+		TickRate:       int(binary.LittleEndian.Uint16(data[4:])),
+		Bounds: utils.Rect{
+			Min: utils.Vector2D{X: v(0), Y: v(1)},
+			Max: utils.Vector2D{X: v(2), Y: v(3)},
+		},
+	}
+	// Older versions end with the bounds.
+	if interval, err := DecodeInterval(data[6+4*8:]); err == nil {
+		limits.BroadcastInterval = interval
+	}
+	return limits, nil
+}
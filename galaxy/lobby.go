@@ -0,0 +1,108 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// COUNTDOWN_PROTOCOL_VERSION is the first protocol version with
+// OpCountdown frames, older clients aren't sent any.
+const COUNTDOWN_PROTOCOL_VERSION = 4
+
+// GameState is the phase of a match, see GameConfig.LobbyDuration.
+type GameState uint8
+
+const (
+	// StateLobby gathers players before the match, nothing moves nor eats
+	// until it starts.
+	StateLobby GameState = iota
+
+	// StateActive is the match itself, games without a lobby start there.
+	StateActive
+
+	// StateEnded freezes the game for good, see Game.End.
+	StateEnded
+)
+
+func (s GameState) String() string {
+	switch s {
+	case StateLobby:
+		return "lobby"
+	case StateActive:
+		return "active"
+	case StateEnded:
+		return "ended"
+	default:
+		return fmt.Sprintf("GameState(%d)", uint8(s))
+	}
+}
+
+// State returns the phase the game is in.
+func (g *Game) State() GameState {
+	g.RLock()
+	defer g.RUnlock()
+	return g.state
+}
+
+// Start ends the lobby right away, it does nothing once the game started.
+func (g *Game) Start() {
+	g.Lock()
+	defer g.Unlock()
+	if g.state == StateLobby {
+		g.start()
+	}
+}
+
+// End freezes the game, for match-based modes: ticks no longer change it,
+// though players stay connected and keep receiving its final state.
+func (g *Game) End() {
+	g.Lock()
+	g.state = StateEnded
+	g.Unlock()
+}
+
+// start makes the game active, the caller must hold the lock.
+func (g *Game) start() {
+	g.state = StateActive
+	g.lobbyLeft = 0
+}
+
+// countDown advances the lobby by dt, starting the match once the
+// countdown runs out or the game is full. The caller must hold the lock.
+func (g *Game) countDown(dt time.Duration) {
+	g.lobbyLeft = max(0, g.lobbyLeft-dt)
+	if g.lobbyLeft == 0 || g.full() {
+		g.start()
+	}
+}
+
+// announceCountdown sends the seconds left until the match starts to every
+// client in an OpCountdown frame, once per second until it started: seconds
+// left uint16 (2) | game state (1), little endian.
+func (g *Game) announceCountdown() {
+	g.Lock()
+	seconds := int(math.Ceil(g.lobbyLeft.Seconds()))
+	if g.config.LobbyDuration <= 0 || seconds == g.announced {
+		g.Unlock()
+		return
+	}
+	g.announced = seconds
+	state := g.state
+	clients := g.clients()
+	g.Unlock()
+
+	data := binary.LittleEndian.AppendUint16(nil, uint16(seconds))
+	frame := EncodeFrame(OpCountdown, append(data, byte(state)))
+	text := debugFrame{Op: OpCountdown.String(), Countdown: &seconds}.encode()
+	for _, client := range clients {
+		switch {
+		case client.ProtocolVersion() < COUNTDOWN_PROTOCOL_VERSION:
+		case client.debug():
+			client.sendText(text)
+		default:
+			client.SendBinary(frame)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+func lobbyConfig() GameConfig {
+	config := quietConfig()
+	config.LobbyDuration = 2 * time.Second
+	return config
+}
+
+func TestLobbyStartsWhenTheCountdownRunsOut(t *testing.T) {
+	g := newTestGame(t, lobbyConfig())
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	conn := connectTestPlayer(player)
+	player.SetDirection(utils.Vector2D{X: 1})
+
+	// countdown returns the seconds left and state of the last OpCountdown
+	// frame sent to the player.
+	countdown := func() (uint16, GameState) {
+		t.Helper()
+		payloads := conn.payloads(OpCountdown)
+		if len(payloads) == 0 {
+			t.Fatal("client sent no countdown")
+		}
+		last := payloads[len(payloads)-1]
+		return binary.LittleEndian.Uint16(last), GameState(last[2])
+	}
+
+	if g.State() != StateLobby {
+		t.Fatalf("game starts %v, want %v", g.State(), StateLobby)
+	}
+	g.announceCountdown()
+	if seconds, state := countdown(); seconds != 2 || state != StateLobby {
+		t.Errorf("countdown says %ds in %v, want 2s in %v", seconds, state, StateLobby)
+	}
+
+	g.Tick(time.Second)
+	g.announceCountdown()
+	if g.State() != StateLobby {
+		t.Fatalf("game %v after 1s of a 2s lobby", g.State())
+	}
+	if seconds, _ := countdown(); seconds != 1 {
+		t.Errorf("countdown says %ds after 1s, want 1s", seconds)
+	}
+	if position := player.GetPosition(); position != (utils.Vector2D{X: 1000, Y: 1000}) {
+		t.Errorf("player moved to %v in the lobby", position)
+	}
+	// Once a second only.
+	g.announceCountdown()
+	if sent := len(conn.payloads(OpCountdown)); sent != 2 {
+		t.Errorf("client sent %d countdowns after 1s, want 2", sent)
+	}
+
+	g.Tick(time.Second)
+	g.announceCountdown()
+	if g.State() != StateActive {
+		t.Fatalf("game %v once the countdown ran out, want %v", g.State(), StateActive)
+	}
+	if seconds, state := countdown(); seconds != 0 || state != StateActive {
+		t.Errorf("countdown says %ds in %v, want 0s in %v", seconds, state, StateActive)
+	}
+	g.Tick(time.Second)
+	if position := player.GetPosition(); position == (utils.Vector2D{X: 1000, Y: 1000}) {
+		t.Error("player didn't move once the match started")
+	}
+}
+
+func TestLobbyStartsWhenFull(t *testing.T) {
+	config := lobbyConfig()
+	config.MaxPlayers = 2
+	g := newTestGame(t, config)
+
+	joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	g.Tick(time.Millisecond)
+	if g.State() != StateLobby {
+		t.Fatalf("game %v with one of two players, want %v", g.State(), StateLobby)
+	}
+	joinTestPlayer(t, g, STARTING_MASS, 3000, 1000)
+	g.Tick(time.Millisecond)
+	if g.State() != StateActive {
+		t.Errorf("full game %v, want %v", g.State(), StateActive)
+	}
+}
+
+func TestEndedGamesStayFrozen(t *testing.T) {
+	g := newTestGame(t, lobbyConfig())
+	g.Start()
+	if g.State() != StateActive {
+		t.Fatalf("game %v after Start, want %v", g.State(), StateActive)
+	}
+
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	player.SetDirection(utils.Vector2D{X: 1})
+	g.End()
+	g.Start()
+	g.Tick(time.Second)
+	if g.State() != StateEnded {
+		t.Errorf("ended game %v after Start, want %v", g.State(), StateEnded)
+	}
+	if position := player.GetPosition(); position != (utils.Vector2D{X: 1000, Y: 1000}) {
+		t.Errorf("player moved to %v after the game ended", position)
+	}
+}
@@ -0,0 +1,89 @@
+package galaxy
+
+import (
+	"time"
+)
+
+// MAX_CATCH_UP_STEPS bounds the steps a GameLoop runs at once after falling
+// behind, so a slow tick can't make the next one even slower. Time beyond
+// it is dropped and the game just runs slower.
+const MAX_CATCH_UP_STEPS = 5
+
+// GameLoop drives a game with a fixed timestep: real time accumulates and
+// the game ticks in constant steps of 1/TickRate, carrying the remainder
+// over. The simulation doesn't depend on how regular the timer is, which
+// keeps it deterministic and replays reproducible. GameLoop isn't safe for
+// concurrent use.
+type GameLoop struct {
+	game        *Game
+	step        time.Duration
+	accumulator time.Duration
+
+	// broadcastStep is the interval between broadcasts, sinceBroadcast the
+	// simulated time since the last one.
+	broadcastStep  time.Duration
+	sinceBroadcast time.Duration
+}
+
+func NewGameLoop(game *Game) *GameLoop {
+	return &GameLoop{
+		game:          game,
+		step:          time.Second / time.Duration(game.tickRate()),
+		broadcastStep: game.BroadcastInterval(),
+	}
+}
+
+// Interval returns the fixed timestep.
+func (l *GameLoop) Interval() time.Duration {
+	return l.step
+}
+
+// BroadcastInterval returns the interval between broadcasts, at least the
+// timestep.
+func (l *GameLoop) BroadcastInterval() time.Duration {
+	return l.broadcastStep
+}
+
+// BroadcastDue reports whether the steps run since the last broadcast add
+// up to the broadcast interval, in which case the caller broadcasts the
+// latest state. Broadcasts don't pile up, a loop that fell behind sends a
+// single one. The interval follows Game.SetBroadcastRate.
+func (l *GameLoop) BroadcastDue() bool {
+	l.broadcastStep = l.game.BroadcastInterval()
+	if l.sinceBroadcast < l.broadcastStep {
+		return false
+	}
+	l.sinceBroadcast -= l.broadcastStep
+	if l.sinceBroadcast >= l.broadcastStep {
+		l.sinceBroadcast = 0
+	}
+	return true
+}
+
+// Advance adds elapsed real time and runs as many fixed steps as it now
+// covers, up to MAX_CATCH_UP_STEPS. It returns the number of steps run.
+func (l *GameLoop) Advance(elapsed time.Duration) int {
+	l.accumulator += elapsed
+	steps := int(l.accumulator / l.step)
+	if steps > MAX_CATCH_UP_STEPS {
+		steps = MAX_CATCH_UP_STEPS
+		l.accumulator = 0
+	} else {
+		l.accumulator -= time.Duration(steps) * l.step
+	}
+
+	l.Step(steps)
+	l.sinceBroadcast += time.Duration(steps) * l.step
+	return steps
+}
+
+// Step runs n fixed steps right away, without broadcasting.
+func (l *GameLoop) Step(n int) {
+	for range n {
+		result := l.game.Tick(l.step)
+		l.game.recordTick()
+		l.game.notifyDeaths(result.Deaths)
+		l.game.announceCountdown()
+		l.game.flushEvents()
+	}
+}
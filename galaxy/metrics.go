@@ -0,0 +1,26 @@
+package galaxy
+
+import "time"
+
+// Metrics receives the activity of games, so it can be exported to
+// Prometheus, statsd or anything else. Games run by a RoomManager report
+// under their room ID, other games under "". Implementations must be safe
+// for concurrent use.
+type Metrics interface {
+	// TickDuration is called with the time every tick took to simulate.
+	TickDuration(room string, d time.Duration)
+
+	// Players is called with the number of players after every tick.
+	Players(room string, count int)
+
+	// InputDropped is called for every malformed OpInput frame dropped,
+	// see Game.DroppedInputs.
+	InputDropped(room string)
+}
+
+// NopMetrics discards every metric, it is the default.
+type NopMetrics struct{}
+
+func (NopMetrics) TickDuration(string, time.Duration) {}
+func (NopMetrics) Players(string, int)                {}
+func (NopMetrics) InputDropped(string)                {}
@@ -0,0 +1,36 @@
+package galaxy
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// MAX_USERNAME_LENGTH is the maximum length of a username, in runes.
+	MAX_USERNAME_LENGTH = 16
+
+	// DEFAULT_USERNAME is given to players without a usable username.
+	DEFAULT_USERNAME = "anonymous"
+)
+
+// SanitizeName makes a client provided username safe to show to other
+// players: control characters are dropped, surrounding whitespace is
+// trimmed and the result is cut to MAX_USERNAME_LENGTH runes. Empty names
+// become DEFAULT_USERNAME.
+func SanitizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) || r == unicode.ReplacementChar {
+			return -1
+		}
+		return r
+	}, name)
+	name = strings.TrimSpace(name)
+
+	if runes := []rune(name); len(runes) > MAX_USERNAME_LENGTH {
+		name = strings.TrimSpace(string(runes[:MAX_USERNAME_LENGTH]))
+	}
+	if name == "" {
+		return DEFAULT_USERNAME
+	}
+	return name
+}
@@ -0,0 +1,28 @@
+package galaxy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSanitizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"alice", "alice"},
+		{"  bob \t", "bob"},
+		{"car\x00ol\n", "carol"},
+		{"da\uFFFDve", "dave"},
+		{"", DEFAULT_USERNAME},
+		{" \x07 ", DEFAULT_USERNAME},
+		{strings.Repeat("é", MAX_USERNAME_LENGTH+4), strings.Repeat("é", MAX_USERNAME_LENGTH)},
+		// Cutting doesn't leave trailing spaces.
+		{strings.Repeat("a", MAX_USERNAME_LENGTH-1) + " b", strings.Repeat("a", MAX_USERNAME_LENGTH-1)},
+	}
+	for _, test := range tests {
+		if got := SanitizeName(test.name); got != test.want {
+			t.Errorf("SanitizeName(%q) = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
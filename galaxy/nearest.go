@@ -0,0 +1,88 @@
+package galaxy
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// Nearest returns the n entities closest to position, nearest first,
+// keeping only those filter accepts, all of them if filter is nil. Bots
+// pick their targets and threats with it, such as the nearest smaller or
+// bigger player, see Bot. Distances are between centers, ties are broken
+// by network ID.
+//
+// It searches the spatial index in squares doubling in size around
+// position, so it only looks at the neighborhood of position unless there
+// are fewer than n matching entities around. filter is called with the
+// lock of the game held and must not call the game.
+func (g *Game) Nearest(position utils.Vector2D, n int, filter func(Entity) bool) []Entity {
+	g.RLock()
+	defer g.RUnlock()
+	return g.nearest(position, n, filter)
+}
+
+// nearest implements Nearest, the caller must hold the lock.
+func (g *Game) nearest(position utils.Vector2D, n int, filter func(Entity) bool) []Entity {
+	if n <= 0 {
+		return nil
+	}
+
+	type candidate struct {
+		entity   Entity
+		distance float64
+	}
+
+	bounds := g.arena().bounds
+	// Beyond reach every center in the world is within the square.
+	reach := 0.0
+	for _, corner := range []utils.Vector2D{bounds.Min, bounds.Max, {X: bounds.Min.X, Y: bounds.Max.Y}, {X: bounds.Max.X, Y: bounds.Min.Y}} {
+		reach = max(reach, position.DistanceSquared(corner))
+	}
+	reach = math.Sqrt(reach)
+
+	size := g.config.CellSize
+	if !(size > 0) {
+		size = DEFAULT_CELL_SIZE
+	}
+	var candidates []candidate
+	for {
+		candidates = candidates[:0]
+		within := 0
+		// Every center is within bounds, so is the part of the square worth
+		// querying.
+		square := utils.RectAround(position, size, size)
+		square = utils.Rect{Min: bounds.Clamp(square.Min), Max: bounds.Clamp(square.Max)}
+		for _, id := range g.index.QueryRange(square) {
+			entity, exists := g.entityOf(id)
+			if !exists || (filter != nil && !filter(entity)) {
+				continue
+			}
+			distance := position.Distance(entity.Position)
+			candidates = append(candidates, candidate{entity: entity, distance: distance})
+			if distance <= size {
+				within++
+			}
+		}
+		// Entities further than size may be outside the square, the n
+		// nearest are only known once that many are within it.
+		if within >= n || size >= reach {
+			break
+		}
+		size *= 2
+	}
+
+	slices.SortFunc(candidates, func(a, b candidate) int {
+		if c := cmp.Compare(a.distance, b.distance); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.entity.NetID, b.entity.NetID)
+	})
+	entities := make([]Entity, 0, min(n, len(candidates)))
+	for _, candidate := range candidates[:min(n, len(candidates))] {
+		entities = append(entities, candidate.entity)
+	}
+	return entities
+}
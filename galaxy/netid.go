@@ -0,0 +1,175 @@
+package galaxy
+
+import (
+	"bytes"
+	"cmp"
+	"maps"
+	"slices"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// NETID_RECYCLE_THRESHOLD is how many released network IDs are kept
+	// before the oldest is handed out again, so clients have long since
+	// seen an entity leave when its ID comes back.
+	NETID_RECYCLE_THRESHOLD = 1024
+)
+
+// netIDs gives every entity of a game a compact network ID for as long as
+// it is part of it, sent to clients in place of its UUID. Network IDs are
+// per game: the same ID is a different entity in another room. 0 is never
+// assigned. netIDs isn't safe for concurrent use, the game lock guards it.
+type netIDs struct {
+	ids      map[uuid.UUID]uint32
+	entities map[uint32]uuid.UUID
+
+	// free are the released IDs, oldest first, next is the first ID never
+	// assigned.
+	free []uint32
+	next uint32
+
+	// removed are the IDs released since the last broadcast, see removals.
+	removed []uint32
+}
+
+func newNetIDs() *netIDs {
+	return &netIDs{
+		ids:      make(map[uuid.UUID]uint32),
+		entities: make(map[uint32]uuid.UUID),
+		next:     1,
+	}
+}
+
+// assign returns the network ID of id, assigning it one if it has none.
+func (n *netIDs) assign(id uuid.UUID) uint32 {
+	if netID, exists := n.ids[id]; exists {
+		return netID
+	}
+
+	var netID uint32
+	if len(n.free) > NETID_RECYCLE_THRESHOLD {
+		netID = n.free[0]
+		n.free = n.free[1:]
+	} else {
+		netID = n.next
+		n.next++
+	}
+	n.ids[id] = netID
+	n.entities[netID] = id
+	return netID
+}
+
+// release frees the network ID of id, if it has one.
+func (n *netIDs) release(id uuid.UUID) {
+	netID, exists := n.ids[id]
+	if !exists {
+		return
+	}
+	delete(n.ids, id)
+	delete(n.entities, netID)
+	n.free = append(n.free, netID)
+	n.removed = append(n.removed, netID)
+}
+
+// netIndex assigns network IDs to the entities of a SpatialIndex as they
+// enter it, and releases them as they leave.
+type netIndex struct {
+	SpatialIndex
+	ids *netIDs
+}
+
+func (i netIndex) Insert(id uuid.UUID, position utils.Vector2D, radius float64) {
+	i.ids.assign(id)
+	i.SpatialIndex.Insert(id, position, radius)
+}
+
+func (i netIndex) Move(id uuid.UUID, position utils.Vector2D, radius float64) {
+	i.ids.assign(id)
+	i.SpatialIndex.Move(id, position, radius)
+}
+
+func (i netIndex) Remove(id uuid.UUID) {
+	i.ids.release(id)
+	i.SpatialIndex.Remove(id)
+}
+
+// NetIDFor returns the network ID clients know the entity id by, as long
+// as it is in the game. Network IDs are only meaningful within this game.
+func (g *Game) NetIDFor(id uuid.UUID) (uint32, bool) {
+	g.RLock()
+	defer g.RUnlock()
+	netID, exists := g.netIDs.ids[id]
+	return netID, exists
+}
+
+// EntityID returns the entity clients know by netID, the reverse of
+// NetIDFor.
+func (g *Game) EntityID(netID uint32) (uuid.UUID, bool) {
+	g.RLock()
+	defer g.RUnlock()
+	id, exists := g.netIDs.entities[netID]
+	return id, exists
+}
+
+// withNetID returns entity with its network ID, the caller must hold the
+// lock.
+func (g *Game) withNetID(entity Entity) Entity {
+	entity.NetID = g.netIDs.ids[entity.ID]
+	return entity
+}
+
+// resetIndex empties the spatial index, forgetting every network ID.
+// Clients are told the entities they knew are gone, and new IDs don't
+// reuse theirs.
+func (g *Game) resetIndex() {
+	old := g.netIDs
+	g.netIDs = newNetIDs()
+	if old != nil {
+		g.netIDs.next = old.next
+		g.netIDs.removed = old.removed
+		for netID := range old.entities {
+			g.netIDs.removed = append(g.netIDs.removed, netID)
+		}
+	}
+	g.index = netIndex{SpatialIndex: newIndex(g.config), ids: g.netIDs}
+}
+
+// compareIDs orders entities by network ID, and those without one, such as
+// dead players, by ID. The caller must hold the lock.
+func (g *Game) compareIDs(a, b uuid.UUID) int {
+	if c := cmp.Compare(g.netIDs.ids[a], g.netIDs.ids[b]); c != 0 {
+		return c
+	}
+	return bytes.Compare(a[:], b[:])
+}
+
+// inOrder returns the values of m, a map of the game, ordered by
+// compareIDs so iterating over them is reproducible unlike ranging over
+// m. The caller must hold the lock.
+func inOrder[T any](g *Game, m map[uuid.UUID]*T) []*T {
+	ids := slices.SortedFunc(maps.Keys(m), g.compareIDs)
+	values := make([]*T, len(ids))
+	for i, id := range ids {
+		values[i] = m[id]
+	}
+	return values
+}
+
+// inRange returns the entities of the index overlapping area ordered by
+// compareIDs, so ticks handle them in the same order given the same state.
+// The caller must hold the lock.
+func (g *Game) inRange(area utils.Rect) []uuid.UUID {
+	ids := g.index.QueryRange(area)
+	slices.SortFunc(ids, g.compareIDs)
+	return ids
+}
+
+// sortByNetID orders entities by network ID, so consecutive updates list
+// the entities they share in the same order.
+func sortByNetID(entities []Entity) {
+	slices.SortFunc(entities, func(a, b Entity) int {
+		return cmp.Compare(a.NetID, b.NetID)
+	})
+}
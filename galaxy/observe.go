@@ -0,0 +1,76 @@
+package galaxy
+
+import (
+	"log"
+
+	"github.com/google/uuid"
+)
+
+// Observe makes conn receive the viewport of the player targetID, as that
+// player sees it, until the player dies or leaves the game: conn is then
+// closed with CLOSE_TARGET_GONE. Observers are spectators, they don't count
+// as players and their input is ignored. conn must be ready for state
+// updates, connections coming through HandleNewConnection with ?observe=
+// say hello first. It returns ErrorPlayerNotFound unless targetID is a
+// living player.
+func (g *Game) Observe(targetID uuid.UUID, conn ClientConnection) (*Spectator, error) {
+	observer := &Spectator{ID: uuid.New(), conn: conn, protocol: PROTOCOL_VERSION}
+	if err := g.observe(observer, targetID); err != nil {
+		return nil, err
+	}
+	return observer, nil
+}
+
+// observe adds observer to the game, watching the player targetID.
+func (g *Game) observe(observer *Spectator, targetID uuid.UUID) error {
+	g.Lock()
+	defer g.Unlock()
+
+	target, exists := g.players[targetID]
+	if !exists || target.IsCell() || !target.IsAlive() {
+		return ErrorPlayerNotFound
+	}
+
+	observer.Lock()
+	observer.target = targetID
+	observer.Camera = target.GetPosition()
+	observer.Unlock()
+	g.spectators[observer.ID] = observer
+	g.spectatorCount.Store(int64(len(g.spectators)))
+	return nil
+}
+
+// observed returns the player s observes, and whether it is still there
+// to observe. It returns nil for spectators not observing anyone. The
+// caller must hold the lock.
+func (g *Game) observed(s *Spectator) (*Player, bool) {
+	s.Lock()
+	targetID := s.target
+	s.Unlock()
+	if targetID == uuid.Nil {
+		return nil, true
+	}
+
+	target, exists := g.players[targetID]
+	if !exists || !target.IsAlive() {
+		return nil, false
+	}
+	return target, true
+}
+
+// detachObservers removes the observers whose target is gone and tells
+// them why.
+func (g *Game) detachObservers(observers []*Spectator) {
+	for _, observer := range observers {
+		g.RemoveSpectator(observer.ID)
+
+		observer.Lock()
+		conn := observer.conn
+		observer.Unlock()
+		log.Printf("spectator %v lost its target, detaching it", observer.ID)
+		if conn != nil {
+			// Closing waits for the client to acknowledge the close frame.
+			go closeWithReason(conn, CLOSE_TARGET_GONE, "target gone")
+		}
+	}
+}
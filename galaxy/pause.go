@@ -0,0 +1,43 @@
+package galaxy
+
+import (
+	"log"
+	"time"
+)
+
+// Pause freezes the game: Run stops ticking and broadcasting, while
+// connections stay open, kept alive by their pings. Inputs still arrive,
+// players resume with the latest direction and the actions queued in the
+// meantime. Idle players aren't disconnected while paused.
+func (g *Game) Pause() {
+	if !g.paused.Swap(true) {
+		log.Printf("room %q paused", g.room)
+	}
+}
+
+// Resume unfreezes a paused game. The time spent paused isn't simulated,
+// the game goes on from where it stopped, and doesn't count towards the
+// idle timeout of players either.
+func (g *Game) Resume() {
+	if !g.paused.Load() {
+		return
+	}
+
+	g.RLock()
+	now := time.Now()
+	for _, player := range g.players {
+		player.Lock()
+		player.lastInput = now
+		player.Unlock()
+	}
+	g.RUnlock()
+
+	if g.paused.Swap(false) {
+		log.Printf("room %q resumed", g.room)
+	}
+}
+
+// Paused reports whether the game is paused.
+func (g *Game) Paused() bool {
+	return g.paused.Load()
+}
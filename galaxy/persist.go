@@ -0,0 +1,248 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// GAME_SNAPSHOT_MAGIC starts every saved game.
+	GAME_SNAPSHOT_MAGIC = "GXSV"
+
+	// GAME_SNAPSHOT_VERSION follows the magic, bump it whenever the layout
+	// changes.
+	GAME_SNAPSHOT_VERSION = 3
+)
+
+var ErrorNotASnapshot = fmt.Errorf("Not a game snapshot")
+
+// SaveSnapshot writes the whole state of the game, bounds, players, food
+// and viruses, so it can be restored with LoadSnapshot after a restart.
+// Connections, spectators and bots are not saved.
+//
+// The layout is, all little endian:
+// magic (4) | version uint16 (2) | bounds 4 * float64 (32) |
+// players uint32 (4) | players | food uint32 (4) | food |
+// viruses uint32 (4) | viruses.
+func (g *Game) SaveSnapshot(w io.Writer) error {
+	g.RLock()
+	data := g.appendSnapshot(nil)
+	g.RUnlock()
+
+	_, err := w.Write(data)
+	return err
+}
+
+func (g *Game) appendSnapshot(data []byte) []byte {
+	data = append(data, GAME_SNAPSHOT_MAGIC...)
+	data = binary.LittleEndian.AppendUint16(data, GAME_SNAPSHOT_VERSION)
+	data = appendVector(data, g.config.Bounds.Min)
+	data = appendVector(data, g.config.Bounds.Max)
+
+	// Bots are spawned again as needed rather than restored.
+	players := slices.DeleteFunc(inOrder(g, g.players), g.isBot)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(players)))
+	for _, player := range players {
+		data = player.appendState(data)
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(g.food)))
+	for _, food := range inOrder(g, g.food) {
+		data = append(data, food.ID[:]...)
+		data = append(data, byte(food.Kind))
+		data = appendVector(data, food.Position)
+		data = binary.LittleEndian.AppendUint32(data, food.Value)
+		data = binary.LittleEndian.AppendUint32(data, food.Color)
+	}
+
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(g.viruses)))
+	for _, virus := range inOrder(g, g.viruses) {
+		data = append(data, virus.ID[:]...)
+		data = appendVector(data, virus.Position)
+		data = binary.LittleEndian.AppendUint32(data, virus.Radius)
+	}
+	return data
+}
+
+// appendState encodes what a player needs to resume playing:
+// player ID (16) | owner ID (16) | position (16) | impulse (16) |
+// mass uint64 (8) | alive (1) | color uint32 (4) | merge cooldown int64 (8) |
+// split cells uint32 (4) | team (1) | username length uint16 (2) |
+// username.
+func (p *Player) appendState(data []byte) []byte {
+	p.RLock()
+	defer p.RUnlock()
+
+	data = append(data, p.PlayerID[:]...)
+	data = append(data, p.OwnerID[:]...)
+	data = appendVector(data, p.Position)
+	data = appendVector(data, p.impulse)
+	data = binary.LittleEndian.AppendUint64(data, p.Mass)
+	if p.Alive {
+		data = append(data, 1)
+	} else {
+		data = append(data, 0)
+	}
+	data = binary.LittleEndian.AppendUint32(data, p.Color)
+	data = binary.LittleEndian.AppendUint64(data, uint64(p.mergeCooldown))
+	data = binary.LittleEndian.AppendUint32(data, uint32(p.splitCells))
+	data = append(data, p.TeamID)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(p.Username)))
+	return append(data, p.Username...)
+}
+
+func appendVector(data []byte, v utils.Vector2D) []byte {
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v.X))
+	return binary.LittleEndian.AppendUint64(data, math.Float64bits(v.Y))
+}
+
+// LoadSnapshot replaces the state of the game with one written by
+// SaveSnapshot. Restored players have no connection until their client
+// reconnects with their ID, see HandleNewConnection. On error the game is
+// left untouched.
+func (g *Game) LoadSnapshot(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	if len(data) < len(GAME_SNAPSHOT_MAGIC)+2 {
+		return ErrorShortBuffer
+	}
+	if string(data[:len(GAME_SNAPSHOT_MAGIC)]) != GAME_SNAPSHOT_MAGIC {
+		return ErrorNotASnapshot
+	}
+	version := binary.LittleEndian.Uint16(data[len(GAME_SNAPSHOT_MAGIC):])
+	if version != GAME_SNAPSHOT_VERSION {
+		return fmt.Errorf("%w: snapshot version %d, expected %d", ErrorUnsupportedFormat, version, GAME_SNAPSHOT_VERSION)
+	}
+
+	s := &snapshotReader{data: data[len(GAME_SNAPSHOT_MAGIC)+2:]}
+	bounds := utils.Rect{Min: s.vector(), Max: s.vector()}
+
+	count := s.count(16 + 16 + 16 + 16 + 8 + 1 + 4 + 8 + 4 + 1 + 2)
+	players := make(map[uuid.UUID]*Player, count)
+	for range count {
+		player := s.player()
+		player.rules = &g.rules
+		player.recomputeRadius()
+		players[player.PlayerID] = player
+	}
+
+	count = s.count(16 + 1 + 16 + 4 + 4)
+	food := make(map[uuid.UUID]*Food, count)
+	for range count {
+		f := &Food{ID: s.uuid(), Kind: FoodKind(s.uint8()), Position: s.vector(), Value: s.uint32(), Color: s.uint32()}
+		food[f.ID] = f
+	}
+
+	count = s.count(16 + 16 + 4)
+	viruses := make(map[uuid.UUID]*Virus, count)
+	for range count {
+		virus := &Virus{ID: s.uuid(), Position: s.vector(), Radius: s.uint32()}
+		viruses[virus.ID] = virus
+	}
+	if s.err != nil {
+		return s.err
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	g.config.Bounds = bounds
+	g.players = players
+	g.bots = make(map[uuid.UUID]*Bot)
+	g.humanCount.Store(0)
+	g.botCount.Store(0)
+	for _, player := range players {
+		g.count(player, 1)
+	}
+	g.food = food
+	g.viruses = viruses
+	g.resetIndex()
+	for _, player := range players {
+		if player.Alive {
+			g.reindex(player)
+		}
+	}
+	for _, f := range food {
+		g.index.Insert(f.ID, f.Position, FOOD_RADIUS)
+	}
+	for _, virus := range viruses {
+		g.index.Insert(virus.ID, virus.Position, float64(virus.Radius))
+	}
+	return nil
+}
+
+// snapshotReader decodes a saved game, remembering the first error so
+// fields can be read one after the other and checked once.
+type snapshotReader struct {
+	data []byte
+	err  error
+}
+
+func (s *snapshotReader) next(n int) []byte {
+	if s.err != nil {
+		return make([]byte, n)
+	}
+	if len(s.data) < n {
+		s.err = ErrorShortBuffer
+		return make([]byte, n)
+	}
+	b := s.data[:n]
+	s.data = s.data[n:]
+	return b
+}
+
+// count reads the number of items that follow, checking that many items
+// of at least size bytes fit in what is left.
+func (s *snapshotReader) count(size int) int {
+	count := s.uint32()
+	if s.err == nil && uint64(count)*uint64(size) > uint64(len(s.data)) {
+		s.err = ErrorShortBuffer
+	}
+	if s.err != nil {
+		return 0
+	}
+	return int(count)
+}
+
+func (s *snapshotReader) uint8() uint8   { return s.next(1)[0] }
+func (s *snapshotReader) uint16() uint16 { return binary.LittleEndian.Uint16(s.next(2)) }
+func (s *snapshotReader) uint32() uint32 { return binary.LittleEndian.Uint32(s.next(4)) }
+func (s *snapshotReader) uint64() uint64 { return binary.LittleEndian.Uint64(s.next(8)) }
+func (s *snapshotReader) uuid() uuid.UUID {
+	return uuid.UUID(s.next(16))
+}
+
+func (s *snapshotReader) vector() utils.Vector2D {
+	return utils.Vector2D{
+		X: math.Float64frombits(s.uint64()),
+		Y: math.Float64frombits(s.uint64()),
+	}
+}
+
+func (s *snapshotReader) player() *Player {
+	player := &Player{
+		PlayerID: s.uuid(),
+		OwnerID:  s.uuid(),
+		Position: s.vector(),
+		impulse:  s.vector(),
+		Mass:     s.uint64(),
+		Alive:    s.uint8() == 1,
+		Color:    s.uint32(),
+	}
+	player.mergeCooldown = time.Duration(s.uint64())
+	player.splitCells = int(s.uint32())
+	player.TeamID = s.uint8()
+	player.Username = string(s.next(int(s.uint16())))
+	player.restored = !player.IsCell()
+	return player
+}
@@ -1,19 +1,68 @@
 package galaxy
 
 import (
+	"errors"
 	"log"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
 
+	"galaxy.io/server/galaxy/utils"
 	pb "galaxy.io/server/proto"
 	"github.com/google/uuid"
 )
 
 const (
 	STARTING_RADIUS = 50
+
+	// MASS_TO_RADIUS_K relates the size of a player to its mass:
+	// radius = MASS_TO_RADIUS_K * sqrt(mass), so area grows linearly with mass.
+	MASS_TO_RADIUS_K = 10
+
+	// STARTING_MASS is the mass of a new player, giving it STARTING_RADIUS.
+	STARTING_MASS = (STARTING_RADIUS / MASS_TO_RADIUS_K) * (STARTING_RADIUS / MASS_TO_RADIUS_K)
+
+	// PLAYER_BASE_SPEED is the speed in world units per second of a player
+	// with the starting mass of its game, bigger players are slower.
+	PLAYER_BASE_SPEED = 400
+
+	// DEFAULT_MAX_MASS caps the mass of a player, half the world wide.
+	DEFAULT_MAX_MASS = 250_000
 )
 
+// massRules are the mass tunables of a game, see GameConfig, along with
+// the eat thresholds that follow from the sizes.
+type massRules struct {
+	start uint64
+	min   uint64
+	max   uint64
+	k     float64
+
+	eatSize    float64
+	eatOverlap float64
+
+	minSplit uint64
+	maxCells int
+
+	spawnProtection   time.Duration
+	passiveProtection bool
+
+	// palette is GameConfig.SkinPalette.
+	palette []uint32
+}
+
+var defaultMassRules = massRules{
+	start:      STARTING_MASS,
+	min:        STARTING_MASS,
+	max:        DEFAULT_MAX_MASS,
+	k:          MASS_TO_RADIUS_K,
+	eatSize:    EAT_SIZE_RATIO,
+	eatOverlap: EAT_OVERLAP_RATIO,
+	minSplit:   MIN_SPLIT_MASS,
+	maxCells:   DEFAULT_MAX_CELLS,
+}
+
 type Log struct {
 	sync.Mutex
 	// Puntuación obtenida
@@ -29,12 +78,22 @@ type Log struct {
 type Player struct {
 	sync.RWMutex
 	PlayerID uuid.UUID
+	// OwnerID is the player a split cell belongs to, uuid.Nil for players.
+	OwnerID uuid.UUID
 	ConnectionID uuid.UUID
-	Position *Vector2D
+	Position utils.Vector2D
+	Velocity utils.Vector2D
+	// Mass is what players gain by eating, Radius is derived from it.
+	Mass     uint64
 	Radius   uint32
+	// Alive is false once the player has been eaten, until it respawns.
+	Alive    bool
 	Username string
 	Stats Log
 
+	// TeamID is the team of the player in team mode, 0 outside of it.
+	TeamID uint8
+
 	// The skin the player currently is using,
 	// implemented for now as a simple RGB color.
 	Color uint32
@@ -42,6 +101,86 @@ type Player struct {
 	Skin *string
 
 	conn ClientConnection
+
+	// protocol is the protocol version negotiated with conn, 0 until its
+	// client said hello, see handshake.
+	protocol uint16
+
+	// direction is the last movement direction requested by the client,
+	// overwritten by every input, see SetInput.
+	direction utils.Vector2D
+
+	// actions are the PlayerInput actions requested since the last tick,
+	// one bit each however many times they were requested.
+	actions uint8
+
+	// cooldowns keep the actions from being triggered again too soon, on
+	// the clock of the player: the simulated time it was alive for.
+	// throttled tells whether an action was ignored on the last tick.
+	cooldowns CooldownTracker
+	clock     time.Duration
+	throttled bool
+
+	// boostLeft is the time left until a boost wears off, see Boost.
+	boostLeft time.Duration
+
+	// protection is the spawn protection left, see
+	// GameConfig.SpawnProtection.
+	protection time.Duration
+
+	// decayDebt is the mass lost to decay not yet taken from Mass.
+	decayDebt float64
+
+	// impulse is the velocity a split cell was shot at, it decays on every
+	// move.
+	impulse utils.Vector2D
+
+	// mergeCooldown is the time left until split cells can merge back.
+	mergeCooldown time.Duration
+
+	// level is the highest level the player reached this life, see
+	// EventLevelUp.
+	level int
+
+	// thresholds is the number of GameConfig.MassThresholds the mass of
+	// the player is at or above, see EventMassThreshold.
+	thresholds int
+
+	// splitCells is the number of cells split from the player.
+	splitCells int
+
+	// restored is true for players loaded from a snapshot until their
+	// client reconnects.
+	restored bool
+
+	// session is the token resuming the player, see Game.issueSession.
+	// generation counts the connections that took over the player, so a
+	// stale connection closing doesn't detach the current one.
+	// disconnectedAt is when the player lost its connection, zero while
+	// connected.
+	session        uuid.UUID
+	generation     uint64
+	disconnectedAt time.Time
+
+	// lastInput is when the client last sent an input, see
+	// GameConfig.IdleTimeout.
+	lastInput time.Time
+
+	// encoder tracks what the client was last sent, when the game sends
+	// delta updates.
+	encoder *DeltaEncoder
+
+	// removals tracks the entities the client was sent that left the
+	// game since.
+	removals removals
+
+	// heartbeats tracks the heartbeats sent to the client, see
+	// GameConfig.HeartbeatInterval.
+	heartbeats heartbeats
+
+	// rules are the mass rules of the game of the player, the defaults
+	// when nil.
+	rules *massRules
 }
 
 
@@ -49,25 +188,73 @@ func NewPlayer(connectionID uuid.UUID, conn ClientConnection) *Player {
 	return &Player{
 		// PlayerID: playerID,
 		ConnectionID: connectionID,
-		Position: randomPosition(),
+		Position: randomPosition().toVector(),
+		Mass: STARTING_MASS,
 		Radius: STARTING_RADIUS,
+		Alive: true,
 		Color: FoodColors[rand.Intn(len(FoodColors))],
 		Skin: nil,
 		conn: conn,
-		Username: "UNKNOWN",
+		Username: DEFAULT_USERNAME,
 		Stats: Log{},
 	}
 }
 
 func (p *Player) SendEvent(event *pb.Event) error {
 	err := p.conn.SendEvent(event)
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrorFrameDropped) {
 		log.Printf("error in sendEvent: %v", err)
 	}
 
 	return err
 }
 
+// SendBinary sends an encoded frame to the player's client, if connected.
+func (p *Player) SendBinary(data []byte) error {
+	p.RLock()
+	conn := p.conn
+	p.RUnlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.SendBinary(data)
+}
+
+// sendUrgent sends an encoded frame the client must not miss, see
+// UrgentSender.
+func (p *Player) sendUrgent(data []byte) error {
+	p.RLock()
+	conn := p.conn
+	p.RUnlock()
+
+	if conn == nil {
+		return nil
+	}
+	return sendUrgent(conn, data)
+}
+
+// saturated reports whether the player's client isn't keeping up with its
+// updates, see SaturatedConnection.
+func (p *Player) saturated() bool {
+	p.RLock()
+	conn := p.conn
+	p.RUnlock()
+	return isSaturated(conn)
+}
+
+// setConnection attaches the client of the player, for players created
+// before their connection.
+func (p *Player) setConnection(conn ClientConnection) {
+	p.Lock()
+	p.conn = conn
+	p.protocol = 0
+	p.removals = removals{}
+	p.encoder = nil
+	p.heartbeats = heartbeats{}
+	p.Unlock()
+}
+
 func (p *Player) Disconnect() {
 	log.Printf("disconnecting player %v", p.PlayerID)
 	if p.conn != nil {
@@ -75,7 +262,7 @@ func (p *Player) Disconnect() {
 	}
 }
 
-func (p *Player) UpdatePosition(position *Vector2D) {
+func (p *Player) UpdatePosition(position utils.Vector2D) {
 	// log.Printf("updating player position, player = %v, oldpos = %v, newpos = %v", p.PlayerID, p.Position, position)
 	p.Lock()
 	p.Position = position
@@ -86,32 +273,155 @@ func (p *Player) UpdatePlayerID(playerID uuid.UUID) {
 	p.PlayerID = playerID;
 }
 
+// UpdateUsername sets the username of the player, see SanitizeName.
 func (p *Player) UpdateUsername(username string) {
+	username = SanitizeName(username)
 	log.Printf("updating username for %v to %v", p.ConnectionID, username)
 	p.Username = username;
 }
 
+// UpdateColor sets the skin color of the player, see ValidateSkin.
 func (p *Player) UpdateColor(color uint32) {
-	p.Color = color;
+	skin, err := ValidateSkin(color, p.massRules().palette)
+	if err != nil {
+		log.Printf("invalid skin %06x for %v: %v", color, p.ConnectionID, err)
+	}
+	p.Color = skin;
 }
 
 func (p *Player) UpdateSkin(skin string) {
 	p.Skin = &skin;
 }
 
-func (p *Player) GetPosition() *Vector2D {
+func (p *Player) GetPosition() utils.Vector2D {
 	p.RLock()
 	defer p.RUnlock()
-	return &Vector2D{
-		X: p.Position.X,
-		Y: p.Position.Y,
+	return p.Position
+}
+
+// MaxSpeed returns how fast the player can move in world units per second.
+// Speed falls with the square root of the radius, classic agar pacing where
+// bigger players are slower without becoming completely stuck. Boosting
+// players are faster, see Boost.
+func (p *Player) MaxSpeed() float64 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.maxSpeed()
+}
+
+func (p *Player) maxSpeed() float64 {
+	speed := float64(PLAYER_BASE_SPEED)
+	if p.Radius != 0 {
+		rules := p.massRules()
+		speed *= math.Sqrt(rules.k * math.Sqrt(float64(rules.start)) / float64(p.Radius))
+	}
+	if p.boostLeft > 0 {
+		speed *= BOOST_SPEED_MULTIPLIER
 	}
+	return speed
+}
+
+// ApplyInput moves the player for dt towards dir at its maximum speed,
+// keeping it inside the world. A zero dir stops the player.
+func (p *Player) ApplyInput(dir utils.Vector2D, dt time.Duration) {
+	p.move(dir, dt, arena{bounds: worldBounds})
+}
+
+func (p *Player) move(dir utils.Vector2D, dt time.Duration, bounds arena) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.Velocity = dir.Normalize().Scale(p.maxSpeed()).Add(p.impulse)
+	p.Position = bounds.Clamp(p.Position.Add(p.Velocity.Scale(dt.Seconds())))
+
+	p.impulse = p.impulse.Scale(math.Exp(-SPLIT_IMPULSE_DAMPING * dt.Seconds()))
+	if p.impulse.LengthSquared() < 1 {
+		p.impulse = utils.Vector2D{}
+	}
+}
+
+// SetDirection records the direction the player wants to move in, applied
+// by the game on every tick until it changes.
+func (p *Player) SetDirection(dir utils.Vector2D) {
+	p.Lock()
+	p.direction = dir
+	p.Unlock()
+}
+
+func (p *Player) Direction() utils.Vector2D {
+	p.RLock()
+	defer p.RUnlock()
+	return p.direction
+}
+
+func (p *Player) snapshot() PlayerSnapshot {
+	p.RLock()
+	defer p.RUnlock()
+	return PlayerSnapshot{
+		PlayerID: p.PlayerID,
+		Position: p.Position,
+		Mass:     p.Mass,
+		Radius:   p.Radius,
+		Color:    p.Color,
+	}
+}
+
+// Score returns the player's score, its mass.
+func (p *Player) Score() uint64 {
+	p.RLock()
+	defer p.RUnlock()
+	return p.Mass
+}
+
+// addMass grows the player by mass.
+func (p *Player) addMass(mass uint64) {
+	p.Lock()
+	p.Mass += mass
+	p.recomputeRadius()
+	p.Unlock()
+}
+
+// decay shrinks the player by rate of its mass per second, down to the
+// minimum mass. Fractions of mass are carried over to the next tick.
+func (p *Player) decay(rate float64, dt time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	floor := p.massRules().min
+	if p.Mass <= floor {
+		p.decayDebt = 0
+		return
+	}
+
+	loss := float64(p.Mass)*rate*dt.Seconds() + p.decayDebt
+	whole := math.Floor(loss)
+	p.decayDebt = loss - whole
+	p.Mass -= min(uint64(whole), p.Mass-floor)
+	p.recomputeRadius()
+}
+
+func (p *Player) massRules() *massRules {
+	if p.rules == nil {
+		return &defaultMassRules
+	}
+	return p.rules
+}
+
+// recomputeRadius caps the mass at the maximum and derives the radius from
+// it, radius = k * sqrt(mass). The caller must hold the lock.
+func (p *Player) recomputeRadius() {
+	rules := p.massRules()
+	p.Mass = min(p.Mass, rules.max)
+	p.Radius = uint32(math.Round(rules.k * math.Sqrt(float64(p.Mass))))
 }
 
 func (p *Player) UpdateRadius(radius uint32) {
 	log.Printf("updating player radius, player = %v, old = %v, new = %v", p.PlayerID, p.Radius, radius);
 	p.Lock();
 	p.Radius = radius;
+	// Clients of World report radii directly, keep the mass consistent.
+	k := float64(radius) / p.massRules().k
+	p.Mass = uint64(math.Round(k * k))
 
 	p.Stats.Lock();
 	p.Stats.Score = radius;
@@ -0,0 +1,70 @@
+package galaxy
+
+import (
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+func TestMovementSlidesAlongEdges(t *testing.T) {
+	bounds := utils.Rect{Max: utils.Vector2D{X: 1000, Y: 1000}}
+	world := newArena(ShapeRectangle, bounds)
+
+	// Each move pushes out of a corner on one axis and along the edge on
+	// the other.
+	tests := []struct {
+		name      string
+		corner    utils.Vector2D
+		direction utils.Vector2D
+		slidesX   bool
+	}{
+		{"top left", utils.Vector2D{X: 0, Y: 0}, utils.Vector2D{X: -1, Y: 1}, false},
+		{"top right", utils.Vector2D{X: 1000, Y: 0}, utils.Vector2D{X: -1, Y: -1}, true},
+		{"bottom left", utils.Vector2D{X: 0, Y: 1000}, utils.Vector2D{X: 1, Y: 1}, true},
+		{"bottom right", utils.Vector2D{X: 1000, Y: 1000}, utils.Vector2D{X: 1, Y: -1}, false},
+	}
+	for _, test := range tests {
+		player := NewPlayer(uuid.New(), nil)
+		player.Mass = STARTING_MASS
+		player.Position = test.corner
+
+		player.move(test.direction, 100*time.Millisecond, world)
+		if !bounds.Contains(player.Position) {
+			t.Errorf("%s: moved out of the world to %v", test.name, player.Position)
+		}
+
+		slid, stuck := player.Position.Y != test.corner.Y, player.Position.X == test.corner.X
+		if test.slidesX {
+			slid, stuck = player.Position.X != test.corner.X, player.Position.Y == test.corner.Y
+		}
+		if !slid || !stuck {
+			t.Errorf("%s: moved from %v to %v, want a slide along the edge", test.name, test.corner, player.Position)
+		}
+	}
+}
+
+func TestMovementSlidesAlongCircularEdges(t *testing.T) {
+	bounds := utils.Rect{Max: utils.Vector2D{X: 1000, Y: 1000}}
+	world := newArena(ShapeCircle, bounds)
+	center := utils.Vector2D{X: 500, Y: 500}
+
+	// On the right edge, heading out and up: the player is kept on the
+	// circle and goes around it.
+	player := NewPlayer(uuid.New(), nil)
+	player.Mass = STARTING_MASS
+	player.Position = utils.Vector2D{X: 1000, Y: 500}
+	for range 5 {
+		player.move(utils.Vector2D{X: 1, Y: -1}.Normalize(), 100*time.Millisecond, world)
+		if distance := player.Position.Distance(center); distance > 500+1e-9 {
+			t.Fatalf("moved out of the circle to %v, %v from its center", player.Position, distance)
+		}
+	}
+	if player.Position.Y >= 500 {
+		t.Errorf("stuck at %v, want a slide up along the edge", player.Position)
+	}
+	if distance := player.Position.Distance(center); distance < 499 {
+		t.Errorf("pulled in to %v from the center, want to stay on the edge", distance)
+	}
+}
@@ -0,0 +1,162 @@
+package galaxy
+
+import (
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// QUADTREE_NODE_CAPACITY is the number of entities a node holds before
+	// splitting into four.
+	QUADTREE_NODE_CAPACITY = 8
+
+	// QUADTREE_MAX_DEPTH bounds how often nodes split, so stacked entities
+	// don't split them forever.
+	QUADTREE_MAX_DEPTH = 8
+)
+
+type quadNode struct {
+	bounds   utils.Rect
+	depth    int
+	entries  map[uuid.UUID]utils.Rect
+	children *[4]*quadNode
+}
+
+// Quadtree is a spatial index recursively splitting crowded areas into
+// quadrants, so an area with many entities is searched as finely as
+// needed while empty ones cost nothing. Entities are stored in the
+// smallest node fully holding their bounding box, those crossing the
+// bounds of the tree stay at the root.
+// Quadtree is not safe for concurrent use, Game guards it with its own
+// lock.
+type Quadtree struct {
+	root  *quadNode
+	nodes map[uuid.UUID]*quadNode
+}
+
+// NewQuadtree creates a quadtree covering bounds.
+func NewQuadtree(bounds utils.Rect) *Quadtree {
+	return &Quadtree{
+		root:  newQuadNode(bounds, 0),
+		nodes: make(map[uuid.UUID]*quadNode),
+	}
+}
+
+func newQuadNode(bounds utils.Rect, depth int) *quadNode {
+	return &quadNode{
+		bounds:  bounds,
+		depth:   depth,
+		entries: make(map[uuid.UUID]utils.Rect),
+	}
+}
+
+func (q *Quadtree) Insert(id uuid.UUID, position utils.Vector2D, radius float64) {
+	if _, exists := q.nodes[id]; exists {
+		q.Remove(id)
+	}
+
+	bounds := utils.RectAround(position, radius, radius)
+	node := q.root.locate(bounds)
+	node.entries[id] = bounds
+	q.nodes[id] = node
+
+	if node.children == nil && len(node.entries) > QUADTREE_NODE_CAPACITY && node.depth < QUADTREE_MAX_DEPTH {
+		q.split(node)
+	}
+}
+
+func (q *Quadtree) Remove(id uuid.UUID) {
+	node, exists := q.nodes[id]
+	if !exists {
+		return
+	}
+	delete(node.entries, id)
+	delete(q.nodes, id)
+}
+
+// Move updates the position and radius of id, in place as long as it stays
+// in the same node.
+func (q *Quadtree) Move(id uuid.UUID, position utils.Vector2D, radius float64) {
+	bounds := utils.RectAround(position, radius, radius)
+	if node, exists := q.nodes[id]; exists && q.root.locate(bounds) == node {
+		node.entries[id] = bounds
+		return
+	}
+	q.Insert(id, position, radius)
+}
+
+func (q *Quadtree) QueryRange(area utils.Rect) []uuid.UUID {
+	return q.root.query(area, nil)
+}
+
+// Len returns the number of entities in the quadtree.
+func (q *Quadtree) Len() int {
+	return len(q.nodes)
+}
+
+// split divides node into quadrants, moving down the entities fitting in
+// one of them.
+func (q *Quadtree) split(node *quadNode) {
+	center := node.bounds.Min.Lerp(node.bounds.Max, 0.5)
+	min, max := node.bounds.Min, node.bounds.Max
+	node.children = &[4]*quadNode{
+		newQuadNode(utils.Rect{Min: min, Max: center}, node.depth+1),
+		newQuadNode(utils.Rect{Min: utils.Vector2D{X: center.X, Y: min.Y}, Max: utils.Vector2D{X: max.X, Y: center.Y}}, node.depth+1),
+		newQuadNode(utils.Rect{Min: utils.Vector2D{X: min.X, Y: center.Y}, Max: utils.Vector2D{X: center.X, Y: max.Y}}, node.depth+1),
+		newQuadNode(utils.Rect{Min: center, Max: max}, node.depth+1),
+	}
+
+	for id, bounds := range node.entries {
+		if child := node.childFor(bounds); child != nil {
+			delete(node.entries, id)
+			child.entries[id] = bounds
+			q.nodes[id] = child
+		}
+	}
+}
+
+// locate returns the smallest existing node fully holding bounds, the root
+// if none does.
+func (n *quadNode) locate(bounds utils.Rect) *quadNode {
+	for {
+		child := n.childFor(bounds)
+		if child == nil {
+			return n
+		}
+		n = child
+	}
+}
+
+func (n *quadNode) childFor(bounds utils.Rect) *quadNode {
+	if n.children == nil {
+		return nil
+	}
+	for _, child := range n.children {
+		if containsRect(child.bounds, bounds) {
+			return child
+		}
+	}
+	return nil
+}
+
+func (n *quadNode) query(area utils.Rect, found []uuid.UUID) []uuid.UUID {
+	for id, bounds := range n.entries {
+		if bounds.Intersects(area) {
+			found = append(found, id)
+		}
+	}
+	if n.children != nil {
+		for _, child := range n.children {
+			if child.bounds.Intersects(area) {
+				found = child.query(area, found)
+			}
+		}
+	}
+	return found
+}
+
+// containsRect reports whether inner lies entirely within outer.
+func containsRect(outer utils.Rect, inner utils.Rect) bool {
+	return inner.Min.X >= outer.Min.X && inner.Min.Y >= outer.Min.Y &&
+		inner.Max.X <= outer.Max.X && inner.Max.Y <= outer.Max.Y
+}
@@ -0,0 +1,106 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+const (
+	// QUANTIZED_SNAPSHOT_VERSION is the first byte of entity lists with
+	// quantized positions, see GameConfig.QuantizePositions.
+	QUANTIZED_SNAPSHOT_VERSION = 3
+
+	// QUANTIZED_ENTITY_SIZE is the encoded size of an entity with a
+	// quantized position:
+	// kind (1) | network ID uint32 (4) | x uint16 (2) | y uint16 (2) |
+	// radius uint32 (4) | color uint32 (4), all little endian.
+	QUANTIZED_ENTITY_SIZE = 1 + 4 + 2 + 2 + 4 + 4
+
+	// QUANTIZATION_STEPS is the number of steps quantized coordinates
+	// divide the world in along each axis. Positions are off by at most
+	// half a step, a 10000 units wide world by 0.08 units.
+	QUANTIZATION_STEPS = math.MaxUint16
+)
+
+// encodeEntities encodes entities for clients, quantizing their positions
+// within the bounds of the world if the game is configured to.
+func (g *Game) encodeEntities(entities []Entity) []byte {
+	if !g.config.QuantizePositions {
+		return encodeEntities(entities)
+	}
+	return encodeQuantized(entities, g.config.Bounds)
+}
+
+// encodeQuantized encodes a list of entities with positions quantized
+// within bounds as:
+// version (1) | min x float32 (4) | min y float32 (4) | max x float32 (4) |
+// max y float32 (4) | count uint32 (4) | count entities of
+// QUANTIZED_ENTITY_SIZE bytes.
+func encodeQuantized(entities []Entity, bounds utils.Rect) []byte {
+	data := make([]byte, 0, 1+16+4+len(entities)*QUANTIZED_ENTITY_SIZE)
+	data = append(data, QUANTIZED_SNAPSHOT_VERSION)
+	for _, v := range []float64{bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y} {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(float32(v)))
+	}
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(entities)))
+	for _, entity := range entities {
+		data = append(data, byte(entity.Kind))
+		data = binary.LittleEndian.AppendUint32(data, entity.NetID)
+		data = binary.LittleEndian.AppendUint16(data, quantize(entity.Position.X, bounds.Min.X, bounds.Max.X))
+		data = binary.LittleEndian.AppendUint16(data, quantize(entity.Position.Y, bounds.Min.Y, bounds.Max.Y))
+		data = binary.LittleEndian.AppendUint32(data, entity.Radius)
+		data = binary.LittleEndian.AppendUint32(data, entity.Color)
+	}
+	return data
+}
+
+// decodeQuantized reverses encodeQuantized, data starting after the
+// version.
+func decodeQuantized(data []byte) ([]Entity, error) {
+	if len(data) < 16+4 {
+		return nil, ErrorShortBuffer
+	}
+	var bounds [4]float64
+	for i := range bounds {
+		bounds[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(data[i*4:])))
+	}
+	min, max := utils.Vector2D{X: bounds[0], Y: bounds[1]}, utils.Vector2D{X: bounds[2], Y: bounds[3]}
+
+	count := binary.LittleEndian.Uint32(data[16:20])
+	data = data[20:]
+	if uint64(len(data)) < uint64(count)*QUANTIZED_ENTITY_SIZE {
+		return nil, ErrorShortBuffer
+	}
+
+	entities := make([]Entity, count)
+	for i := range entities {
+		entities[i] = Entity{
+			Kind:  EntityKind(data[0]),
+			NetID: binary.LittleEndian.Uint32(data[1:5]),
+			Position: utils.Vector2D{
+				X: dequantize(binary.LittleEndian.Uint16(data[5:7]), min.X, max.X),
+				Y: dequantize(binary.LittleEndian.Uint16(data[7:9]), min.Y, max.Y),
+			},
+			Radius: binary.LittleEndian.Uint32(data[9:13]),
+			Color:  binary.LittleEndian.Uint32(data[13:17]),
+		}
+		data = data[QUANTIZED_ENTITY_SIZE:]
+	}
+	return entities, nil
+}
+
+// quantize maps v within [min, max] to one of QUANTIZATION_STEPS steps,
+// clamping values outside.
+func quantize(v float64, min float64, max float64) uint16 {
+	if !(max > min) {
+		return 0
+	}
+	step := math.Round((v - min) / (max - min) * QUANTIZATION_STEPS)
+	return uint16(math.Min(math.Max(step, 0), QUANTIZATION_STEPS))
+}
+
+func dequantize(step uint16, min float64, max float64) float64 {
+	return min + float64(step)/QUANTIZATION_STEPS*(max-min)
+}
@@ -0,0 +1,70 @@
+package galaxy
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+func TestQuantizedPositionsStayWithinHalfAStep(t *testing.T) {
+	random := rand.New(rand.NewPCG(1, 1))
+	for _, bounds := range []utils.Rect{
+		worldBounds,
+		{Min: utils.Vector2D{X: -2500, Y: 300}, Max: utils.Vector2D{X: 2500, Y: 20300}},
+	} {
+		step := utils.Vector2D{
+			X: bounds.Width() / QUANTIZATION_STEPS,
+			Y: bounds.Height() / QUANTIZATION_STEPS,
+		}
+		entities := []Entity{
+			{Kind: EntityPlayer, NetID: 1, Position: bounds.Min},
+			{Kind: EntityPlayer, NetID: 2, Position: bounds.Max},
+		}
+		for i := range 1000 {
+			entities = append(entities, Entity{
+				Kind:  EntityFood,
+				NetID: uint32(3 + i),
+				Position: utils.Vector2D{
+					X: bounds.Min.X + random.Float64()*bounds.Width(),
+					Y: bounds.Min.Y + random.Float64()*bounds.Height(),
+				},
+				Radius: 5,
+			})
+		}
+
+		data := encodeQuantized(entities, bounds)
+		if plain := encodeEntities(entities); len(data) >= len(plain) {
+			t.Errorf("quantized entities take %d bytes, no less than the %d of full positions", len(data), len(plain))
+		}
+		decoded, err := DecodeEntities(data)
+		if err != nil {
+			t.Fatalf("DecodeEntities: %v", err)
+		}
+		if len(decoded) != len(entities) {
+			t.Fatalf("decoded %d entities, want %d", len(decoded), len(entities))
+		}
+		for i, entity := range decoded {
+			want := entities[i].Position
+			if math.Abs(entity.Position.X-want.X) > step.X/2+1e-6 || math.Abs(entity.Position.Y-want.Y) > step.Y/2+1e-6 {
+				t.Errorf("bounds %v: %v decoded as %v, more than half a step of %v off", bounds, want, entity.Position, step)
+			}
+			if entity.NetID != entities[i].NetID || entity.Radius != entities[i].Radius {
+				t.Errorf("entity %d decoded as %+v, want %+v", i, entity, entities[i])
+			}
+		}
+	}
+}
+
+func TestQuantizeClampsOutsideTheBounds(t *testing.T) {
+	if step := quantize(-10, 0, 100); step != 0 {
+		t.Errorf("quantize below the bounds = %d, want 0", step)
+	}
+	if step := quantize(110, 0, 100); step != QUANTIZATION_STEPS {
+		t.Errorf("quantize above the bounds = %d, want %d", step, QUANTIZATION_STEPS)
+	}
+	if step := quantize(5, 10, 10); step != 0 {
+		t.Errorf("quantize within empty bounds = %d, want 0", step)
+	}
+}
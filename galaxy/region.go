@@ -0,0 +1,67 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// Region is an area of the map with its own rules, such as a central
+// danger zone where food is worth more but players decay faster. Players
+// are in the region their center is in. Multipliers of 0 mean 1, so a
+// Region only lists what it changes.
+type Region struct {
+	Name   string
+	Bounds utils.Rect
+
+	// FoodMultiplier scales the mass players gain from the pellets they eat
+	// in the region.
+	FoodMultiplier float64
+
+	// DecayMultiplier scales GameConfig.DecayRate for the players in the
+	// region.
+	DecayMultiplier float64
+}
+
+func (r Region) validate() error {
+	if r.FoodMultiplier < 0 || math.IsInf(r.FoodMultiplier, 0) || math.IsNaN(r.FoodMultiplier) {
+		return fmt.Errorf("%w: FoodMultiplier of region %q must not be negative, got %v", ErrorInvalidConfig, r.Name, r.FoodMultiplier)
+	}
+	if r.DecayMultiplier < 0 || math.IsInf(r.DecayMultiplier, 0) || math.IsNaN(r.DecayMultiplier) {
+		return fmt.Errorf("%w: DecayMultiplier of region %q must not be negative, got %v", ErrorInvalidConfig, r.Name, r.DecayMultiplier)
+	}
+	return nil
+}
+
+func (r Region) foodMultiplier() float64 {
+	if r.FoodMultiplier == 0 {
+		return 1
+	}
+	return r.FoodMultiplier
+}
+
+func (r Region) decayMultiplier() float64 {
+	if r.DecayMultiplier == 0 {
+		return 1
+	}
+	return r.DecayMultiplier
+}
+
+// regionAt returns the region position is in, the first one listed if it
+// is in many. Outside of every region the zero Region applies, changing
+// nothing.
+func (g *Game) regionAt(position utils.Vector2D) Region {
+	for _, region := range g.config.Regions {
+		if region.Bounds.Contains(position) {
+			return region
+		}
+	}
+	return Region{}
+}
+
+// foodValue returns the mass a pellet worth value gives a player at
+// position.
+func (g *Game) foodValue(value uint32, position utils.Vector2D) uint64 {
+	return uint64(math.Round(float64(value) * g.regionAt(position).foodMultiplier()))
+}
@@ -0,0 +1,87 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// dangerZone is a region in the middle of the world where pellets are worth
+// three times more and players decay four times faster.
+var dangerZone = Region{
+	Name:            "danger",
+	Bounds:          utils.RectAround(utils.Vector2D{X: 5000, Y: 5000}, 1000, 1000),
+	FoodMultiplier:  3,
+	DecayMultiplier: 4,
+}
+
+// dropFood puts a pellet worth value at position.
+func dropFood(g *Game, position utils.Vector2D, value uint32) {
+	g.Lock()
+	defer g.Unlock()
+	food := g.newFood()
+	food.Position, food.Value = position, value
+	g.food[food.ID] = food
+	g.index.Insert(food.ID, food.Position, FOOD_RADIUS)
+}
+
+func TestRegionAt(t *testing.T) {
+	config := testConfig()
+	inner := Region{Name: "inner", Bounds: utils.RectAround(utils.Vector2D{X: 5000, Y: 5000}, 100, 100)}
+	config.Regions = []Region{inner, dangerZone}
+	g := newTestGame(t, config)
+
+	tests := []struct {
+		position utils.Vector2D
+		want     string
+	}{
+		{utils.Vector2D{X: 5000, Y: 5000}, "inner"},
+		{utils.Vector2D{X: 5500, Y: 5000}, "danger"},
+		{utils.Vector2D{X: 6000, Y: 6000}, "danger"},
+		{utils.Vector2D{X: 1000, Y: 1000}, ""},
+	}
+	for _, test := range tests {
+		if region := g.regionAt(test.position); region.Name != test.want {
+			t.Errorf("regionAt(%v) = %q, want %q", test.position, region.Name, test.want)
+		}
+	}
+	if outside := g.regionAt(utils.Vector2D{}); outside.foodMultiplier() != 1 || outside.decayMultiplier() != 1 {
+		t.Error("outside of every region the rules change")
+	}
+}
+
+func TestRegionsScaleFoodAndDecay(t *testing.T) {
+	config := quietConfig()
+	config.Regions = []Region{dangerZone}
+	g := newTestGame(t, config)
+	inside := joinTestPlayer(t, g, 1000, 5000, 5000)
+	outside := joinTestPlayer(t, g, 1000, 1000, 1000)
+
+	dropFood(g, inside.GetPosition(), 10)
+	dropFood(g, outside.GetPosition(), 10)
+	g.Tick(time.Millisecond)
+	if inside.Score() != 1030 || outside.Score() != 1010 {
+		t.Errorf("pellets worth 10 gave %d inside and %d outside the region, want 30 and 10",
+			inside.Score()-1000, outside.Score()-1000)
+	}
+
+	g.config.DecayRate = 0.01
+	before := [2]uint64{inside.Score(), outside.Score()}
+	g.Tick(time.Second)
+	lostInside, lostOutside := before[0]-inside.Score(), before[1]-outside.Score()
+	if lostOutside == 0 || lostInside < 4*lostOutside-1 || lostInside > 4*lostOutside+1 {
+		t.Errorf("lost %d inside and %d outside the region, want four times more inside", lostInside, lostOutside)
+	}
+}
+
+func TestRegionsValidate(t *testing.T) {
+	for _, region := range []Region{{Name: "food", FoodMultiplier: -1}, {Name: "decay", DecayMultiplier: -0.5}} {
+		config := testConfig()
+		config.Regions = []Region{region}
+		if err := config.Validate(); !errors.Is(err, ErrorInvalidConfig) {
+			t.Errorf("region %q: Validate() = %v, want %v", region.Name, err, ErrorInvalidConfig)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package galaxy
+
+import (
+	"encoding/binary"
+)
+
+// REMOVALS_PROTOCOL_VERSION is the first protocol version whose
+// OpStateSnapshot frames end with a removals section, see DecodeRemovals.
+const REMOVALS_PROTOCOL_VERSION = 5
+
+// removals tracks the entities a client was sent, so it is told they left
+// the game even when they left its viewport first: an entity missing from
+// an update may just be out of sight, or unchanged in a delta. Removals of
+// a client skipped by a broadcast wait for the next update it gets.
+// removals isn't safe for concurrent use, the lock of the client guards
+// it.
+type removals struct {
+	seen    map[uint32]struct{}
+	pending []uint32
+}
+
+// forget queues the removal of the entities among removed the client saw.
+func (r *removals) forget(removed []uint32) {
+	for _, netID := range removed {
+		if _, seen := r.seen[netID]; seen {
+			delete(r.seen, netID)
+			r.pending = append(r.pending, netID)
+		}
+	}
+}
+
+// take records that the client is sent entities and returns the removals
+// to send along.
+func (r *removals) take(entities []Entity) []uint32 {
+	if r.seen == nil {
+		r.seen = make(map[uint32]struct{}, len(entities))
+	}
+	for _, entity := range entities {
+		r.seen[entity.NetID] = struct{}{}
+	}
+	pending := r.pending
+	r.pending = nil
+	return pending
+}
+
+func (p *Player) forgetRemoved(removed []uint32) {
+	p.Lock()
+	p.removals.forget(removed)
+	p.Unlock()
+}
+
+func (p *Player) takeRemovals(entities []Entity) []uint32 {
+	p.Lock()
+	defer p.Unlock()
+	return p.removals.take(entities)
+}
+
+func (s *Spectator) forgetRemoved(removed []uint32) {
+	s.Lock()
+	s.removals.forget(removed)
+	s.Unlock()
+}
+
+func (s *Spectator) takeRemovals(entities []Entity) []uint32 {
+	s.Lock()
+	defer s.Unlock()
+	return s.removals.take(entities)
+}
+
+// appendRemovals appends the removals section of an OpStateSnapshot frame:
+// removed network IDs uint32 (4 each) | removed uint32 (4), little endian.
+// The count comes last so clients find the section from the end of the
+// frame, whatever the entities before it are encoded as.
+func appendRemovals(data []byte, removed []uint32) []byte {
+	for _, netID := range removed {
+		data = binary.LittleEndian.AppendUint32(data, netID)
+	}
+	return binary.LittleEndian.AppendUint32(data, uint32(len(removed)))
+}
+
+// DecodeRemovals splits what follows the header of an OpStateSnapshot
+// frame, see DecodeSnapshotHeader, into the entities and the network IDs
+// of the entities the client was sent that left the game since its last
+// update. Clients drop those once they applied the entities. Only frames
+// of protocol version REMOVALS_PROTOCOL_VERSION and later have the section.
+func DecodeRemovals(payload []byte) ([]byte, []uint32, error) {
+	if len(payload) < 4 {
+		return nil, nil, ErrorShortBuffer
+	}
+	count := binary.LittleEndian.Uint32(payload[len(payload)-4:])
+	payload = payload[:len(payload)-4]
+	if uint64(len(payload)) < uint64(count)*4 {
+		return nil, nil, ErrorShortBuffer
+	}
+
+	start := len(payload) - int(count)*4
+	removed := make([]uint32, count)
+	for i := range removed {
+		removed[i] = binary.LittleEndian.Uint32(payload[start+i*4:])
+	}
+	return payload[:start], removed, nil
+}
+
+// drainRemovals returns the network IDs released since the last call, the
+// entities that left the game in between.
+func (g *Game) drainRemovals() []uint32 {
+	g.Lock()
+	defer g.Unlock()
+	removed := g.netIDs.removed
+	g.netIDs.removed = nil
+	return removed
+}
@@ -0,0 +1,61 @@
+package galaxy
+
+import (
+	"slices"
+	"testing"
+)
+
+// lastRemovals returns the removals of the last snapshot sent to conn.
+func lastRemovals(t *testing.T, conn *fakeConn) []uint32 {
+	t.Helper()
+
+	payloads := conn.payloads(OpStateSnapshot)
+	if len(payloads) == 0 {
+		t.Fatal("client sent no snapshot")
+	}
+	_, body, err := DecodeSnapshotHeader(payloads[len(payloads)-1])
+	if err != nil {
+		t.Fatalf("DecodeSnapshotHeader: %v", err)
+	}
+	_, removed, err := DecodeRemovals(body)
+	if err != nil {
+		t.Fatalf("DecodeRemovals: %v", err)
+	}
+	return removed
+}
+
+func TestRemovalsReachClientsThatLostSightFirst(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	conn, _ := sayHello(t, g, &fakeFactory{}, PROTOCOL_VERSION)
+	viewer := onlyPlayer(t, g)
+	place(g, viewer, 2000, 2000)
+
+	seen := joinTestPlayer(t, g, STARTING_MASS, 2100, 2000)
+	unseen := joinTestPlayer(t, g, STARTING_MASS, 9000, 9000)
+	g.Broadcast()
+	seenID, unseenID := g.netIDs.ids[seen.PlayerID], g.netIDs.ids[unseen.PlayerID]
+
+	// The seen player leaves the viewport, then the game.
+	place(g, seen, 9000, 2000)
+	g.Broadcast()
+	if removed := lastRemovals(t, conn); len(removed) != 0 {
+		t.Errorf("players leaving the viewport reported removed: %v", removed)
+	}
+	g.RemovePlayer(seen.PlayerID)
+	g.RemovePlayer(unseen.PlayerID)
+	g.Broadcast()
+
+	removed := lastRemovals(t, conn)
+	if !slices.Contains(removed, seenID) {
+		t.Errorf("removals %v miss the player seen before it left the viewport", removed)
+	}
+	if slices.Contains(removed, unseenID) {
+		t.Errorf("removals %v have a player the client never saw", removed)
+	}
+
+	// Removals are sent once.
+	g.Broadcast()
+	if removed := lastRemovals(t, conn); len(removed) != 0 {
+		t.Errorf("removals %v sent again", removed)
+	}
+}
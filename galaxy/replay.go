@@ -0,0 +1,345 @@
+package galaxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// REPLAY_MAGIC starts every replay.
+	REPLAY_MAGIC = "GXRP"
+
+	// REPLAY_VERSION follows the magic, bump it whenever the layout
+	// changes, that of the keyframes included. Version 2 keyframes carry
+	// network IDs instead of entity UUIDs, version 3 replays the seed and the
+	// players joining.
+	REPLAY_VERSION = 3
+
+	// REPLAY_HEADER_SIZE is the size of the header of a replay:
+	// magic (4) | version uint16 (2) | tick rate uint16 (2) |
+	// keyframe interval uint32 (4) | start unix nano int64 (8) |
+	// seed uint64 (8).
+	REPLAY_HEADER_SIZE = 4 + 2 + 2 + 4 + 8 + 8
+
+	// REPLAY_RECORD_HEADER_SIZE is the size of the header of every record
+	// following the replay header:
+	// time since start int64 (8) | player ID (16) | frame length uint32 (4),
+	// then the frame itself, an Opcode and its payload. Keyframes are
+	// OpStateSnapshot frames with every entity, from player uuid.Nil,
+	// without a SnapshotHeader since the record header times them. Players
+	// joining are OpHello frames from them, see DecodeJoin.
+	REPLAY_RECORD_HEADER_SIZE = 8 + 16 + 4
+
+	// MAX_REPLAY_FRAME_SIZE bounds the frames read back, so a corrupt
+	// length can't allocate gigabytes.
+	MAX_REPLAY_FRAME_SIZE = 64 << 20
+)
+
+var ErrorNotAReplay = fmt.Errorf("Not a replay")
+
+// Recorder writes the players joining a game, the input frames it receives
+// and periodic keyframes of its whole state to a replay, see
+// Game.SetRecorder. It is safe for concurrent use.
+type Recorder struct {
+	sync.Mutex
+	w     *bufio.Writer
+	start time.Time
+	err   error
+
+	keyframeInterval int
+	ticks            int
+}
+
+// NewRecorder writes the replay header to w and returns a recorder adding
+// a keyframe every keyframeInterval ticks of a game ticking at tickRate.
+// seed is the Game.Seed of the game, recreating a game with it replays the
+// same food and spawns.
+func NewRecorder(w io.Writer, tickRate int, keyframeInterval int, seed uint64) (*Recorder, error) {
+	r := &Recorder{
+		w:                bufio.NewWriter(w),
+		start:            time.Now(),
+		keyframeInterval: max(1, keyframeInterval),
+	}
+
+	header := make([]byte, 0, REPLAY_HEADER_SIZE)
+	header = append(header, REPLAY_MAGIC...)
+	header = binary.LittleEndian.AppendUint16(header, REPLAY_VERSION)
+	header = binary.LittleEndian.AppendUint16(header, uint16(tickRate))
+	header = binary.LittleEndian.AppendUint32(header, uint32(r.keyframeInterval))
+	header = binary.LittleEndian.AppendUint64(header, uint64(r.start.UnixNano()))
+	header = binary.LittleEndian.AppendUint64(header, seed)
+	if _, err := r.w.Write(header); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// record appends a frame from player to the replay. Once a write failed
+// the recorder stops, see Err.
+func (r *Recorder) record(player uuid.UUID, frame []byte) {
+	r.Lock()
+	defer r.Unlock()
+	r.write(player, frame)
+}
+
+func (r *Recorder) write(player uuid.UUID, frame []byte) {
+	if r.err != nil {
+		return
+	}
+
+	header := make([]byte, 0, REPLAY_RECORD_HEADER_SIZE)
+	header = binary.LittleEndian.AppendUint64(header, uint64(time.Since(r.start)))
+	header = append(header, player[:]...)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(frame)))
+	if _, r.err = r.w.Write(header); r.err != nil {
+		return
+	}
+	_, r.err = r.w.Write(frame)
+}
+
+// tick counts a game tick, recording a keyframe with encode every
+// keyframeInterval ticks.
+func (r *Recorder) tick(encode func() []byte) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.ticks%r.keyframeInterval == 0 {
+		r.write(uuid.Nil, EncodeFrame(OpStateSnapshot, encode()))
+	}
+	r.ticks++
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (r *Recorder) Flush() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.err == nil {
+		r.err = r.w.Flush()
+	}
+	return r.err
+}
+
+// Err returns the first error writing the replay.
+func (r *Recorder) Err() error {
+	r.Lock()
+	defer r.Unlock()
+	return r.err
+}
+
+// SetRecorder attaches a recorder to the game, nil detaches it. The game
+// doesn't flush it.
+func (g *Game) SetRecorder(recorder *Recorder) {
+	g.Lock()
+	g.recorder = recorder
+	g.Unlock()
+}
+
+func (g *Game) record(player uuid.UUID, frame []byte) {
+	g.RLock()
+	recorder := g.recorder
+	g.RUnlock()
+
+	if recorder != nil {
+		recorder.record(player, frame)
+	}
+}
+
+// recordJoin records player joining the game, the caller must hold the
+// lock. Bots aren't recorded, replaying the game spawns them again.
+func (g *Game) recordJoin(player *Player) {
+	if g.recorder == nil || player.IsCell() || g.isBot(player) {
+		return
+	}
+
+	player.RLock()
+	join := Join{Position: player.Position, Mass: player.Mass, Username: player.Username}
+	player.RUnlock()
+	g.recorder.record(player.PlayerID, EncodeFrame(OpHello, encodeJoin(join)))
+}
+
+// Join is the payload of the OpHello records of the players joining a
+// replayed game.
+type Join struct {
+	Position utils.Vector2D
+	Mass     uint64
+	Username string
+}
+
+// encodeJoin encodes the payload of a join record: x float64 (8) |
+// y float64 (8) | mass uint64 (8) | username, little endian.
+func encodeJoin(join Join) []byte {
+	data := make([]byte, 0, 8+8+8+len(join.Username))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(join.Position.X))
+	data = binary.LittleEndian.AppendUint64(data, math.Float64bits(join.Position.Y))
+	data = binary.LittleEndian.AppendUint64(data, join.Mass)
+	return append(data, join.Username...)
+}
+
+// DecodeJoin decodes the payload of the OpHello record of a player joining
+// a replayed game.
+func DecodeJoin(payload []byte) (Join, error) {
+	if len(payload) < 8+8+8 {
+		return Join{}, ErrorShortBuffer
+	}
+	return Join{
+		Position: utils.Vector2D{
+			X: math.Float64frombits(binary.LittleEndian.Uint64(payload)),
+			Y: math.Float64frombits(binary.LittleEndian.Uint64(payload[8:])),
+		},
+		Mass:     binary.LittleEndian.Uint64(payload[16:]),
+		Username: string(payload[24:]),
+	}, nil
+}
+
+// recordTick lets the recorder take a keyframe of every entity.
+func (g *Game) recordTick() {
+	g.RLock()
+	defer g.RUnlock()
+
+	if g.recorder != nil {
+		g.recorder.tick(func() []byte {
+			return encodeEntities(g.entities())
+		})
+	}
+}
+
+// entities returns every living player, pellet, virus and ejected mass,
+// the caller must hold the lock.
+func (g *Game) entities() []Entity {
+	entities := make([]Entity, 0, len(g.players)+len(g.food)+len(g.viruses))
+	for _, player := range g.players {
+		if player.IsAlive() {
+			entities = append(entities, g.withNetID(player.snapshot().entity()))
+		}
+	}
+	for _, food := range g.food {
+		entities = append(entities, g.withNetID(food.entity()))
+	}
+	for _, virus := range g.viruses {
+		entities = append(entities, g.withNetID(virus.entity()))
+	}
+	for _, ejected := range g.ejected {
+		entities = append(entities, g.withNetID(ejected.entity()))
+	}
+	sortByNetID(entities)
+	return entities
+}
+
+// ReplayHeader describes a replay.
+type ReplayHeader struct {
+	Version          uint16
+	TickRate         int
+	KeyframeInterval int
+	Start            time.Time
+
+	// Seed is the GameConfig.Seed recreating the recorded game.
+	Seed uint64
+}
+
+// ReplayRecord is a frame of a replay.
+type ReplayRecord struct {
+	// At is the time since the start of the recording.
+	At time.Duration
+
+	// PlayerID sent the frame, uuid.Nil for keyframes.
+	PlayerID uuid.UUID
+	Op       Opcode
+	Payload  []byte
+}
+
+// Replayer reads back a replay written by a Recorder.
+type Replayer struct {
+	r      *bufio.Reader
+	Header ReplayHeader
+}
+
+// NewReplayer reads the header of a replay.
+func NewReplayer(r io.Reader) (*Replayer, error) {
+	p := &Replayer{r: bufio.NewReader(r)}
+
+	header := make([]byte, REPLAY_HEADER_SIZE)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		return nil, err
+	}
+	if string(header[:4]) != REPLAY_MAGIC {
+		return nil, ErrorNotAReplay
+	}
+	p.Header = ReplayHeader{
+		Version:          binary.LittleEndian.Uint16(header[4:6]),
+		TickRate:         int(binary.LittleEndian.Uint16(header[6:8])),
+		KeyframeInterval: int(binary.LittleEndian.Uint32(header[8:12])),
+		Start:            time.Unix(0, int64(binary.LittleEndian.Uint64(header[12:20]))),
+		Seed:             binary.LittleEndian.Uint64(header[20:28]),
+	}
+	if p.Header.Version != REPLAY_VERSION {
+		return nil, fmt.Errorf("%w: replay version %d, expected %d", ErrorUnsupportedFormat, p.Header.Version, REPLAY_VERSION)
+	}
+	return p, nil
+}
+
+// Next returns the next record, io.EOF once the replay is over.
+func (p *Replayer) Next() (ReplayRecord, error) {
+	header := make([]byte, REPLAY_RECORD_HEADER_SIZE)
+	if _, err := io.ReadFull(p.r, header); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return ReplayRecord{}, ErrorShortBuffer
+		}
+		return ReplayRecord{}, err
+	}
+
+	length := binary.LittleEndian.Uint32(header[24:28])
+	if length > MAX_REPLAY_FRAME_SIZE {
+		return ReplayRecord{}, ErrorUnsupportedFormat
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return ReplayRecord{}, ErrorShortBuffer
+	}
+
+	op, payload, err := DecodeFrame(data)
+	if err != nil {
+		return ReplayRecord{}, err
+	}
+	return ReplayRecord{
+		At:       time.Duration(binary.LittleEndian.Uint64(header[0:8])),
+		PlayerID: uuid.UUID(header[8:24]),
+		Op:       op,
+		Payload:  payload,
+	}, nil
+}
+
+// Play emits every record at the cadence it was recorded at, until the
+// replay is over or ctx is done.
+func (p *Replayer) Play(ctx context.Context, emit func(ReplayRecord)) error {
+	start := time.Now()
+	for {
+		record, err := p.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if wait := record.At - time.Since(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+		emit(record)
+	}
+}
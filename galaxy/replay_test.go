@@ -0,0 +1,49 @@
+package galaxy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestReplayRecordsSeedAndJoins(t *testing.T) {
+	g := newTestGame(t, testConfig())
+
+	var replay bytes.Buffer
+	recorder, err := NewRecorder(&replay, DEFAULT_TICK_RATE, 10, g.Seed())
+	if err != nil {
+		t.Fatalf("NewRecorder: %v", err)
+	}
+	g.SetRecorder(recorder)
+	player := joinTestPlayer(t, g, 500, 100, 200)
+	g.recordTick()
+	if err := recorder.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+
+	replayer, err := NewReplayer(&replay)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+	if replayer.Header.Seed != g.Seed() {
+		t.Errorf("replay seed %d, want %d", replayer.Header.Seed, g.Seed())
+	}
+
+	record, err := replayer.Next()
+	if err != nil {
+		t.Fatalf("Next: %v", err)
+	}
+	if record.Op != OpHello || record.PlayerID != player.PlayerID {
+		t.Fatalf("first record is a %v from %v, want the join of %v", record.Op, record.PlayerID, player.PlayerID)
+	}
+	join, err := DecodeJoin(record.Payload)
+	if err != nil {
+		t.Fatalf("DecodeJoin: %v", err)
+	}
+	if join.Position != player.Position || join.Mass != 500 || join.Username != player.Username {
+		t.Errorf("join %+v, want the player at %v with mass 500 named %q", join, player.Position, player.Username)
+	}
+
+	if record, err = replayer.Next(); err != nil || record.Op != OpStateSnapshot {
+		t.Fatalf("second record %v, %v, want a keyframe", record.Op, err)
+	}
+}
@@ -0,0 +1,128 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// BOUNDS_PROTOCOL_VERSION is the first protocol version sent OpBounds
+// frames, older clients keep drawing the bounds they started with.
+const BOUNDS_PROTOCOL_VERSION = 7
+
+// Resize changes the bounds of the world while the game runs, such as to
+// shrink the arena of a battle royale. Players and viruses left outside
+// are pushed back into the new playable area, pellets and ejected mass
+// outside are removed and the pellets respawn inside over the next ticks.
+// Every client is told the new bounds, see OpBounds. Bounds must have a
+// positive width and height, otherwise the error wraps ErrorInvalidConfig
+// and the game is left untouched.
+func (g *Game) Resize(bounds utils.Rect) error {
+	if !(bounds.Width() > 0 && bounds.Height() > 0) || math.IsInf(bounds.Width(), 0) || math.IsInf(bounds.Height(), 0) {
+		return fmt.Errorf("%w: Bounds must have a positive width and height, got %v", ErrorInvalidConfig, bounds)
+	}
+
+	g.Lock()
+	g.config.Bounds = bounds
+	g.resized = true
+	world := g.arena()
+	for _, player := range inOrder(g, g.players) {
+		player.Lock()
+		player.Position = world.Clamp(player.Position)
+		player.Unlock()
+	}
+	for _, virus := range inOrder(g, g.viruses) {
+		virus.Position = world.Clamp(virus.Position)
+	}
+	for _, food := range inOrder(g, g.food) {
+		if world.Clamp(food.Position) != food.Position {
+			g.removeFood(food.ID)
+		}
+	}
+	for _, ejected := range inOrder(g, g.ejected) {
+		if world.Clamp(ejected.Position) != ejected.Position {
+			g.removeEjected(ejected.ID)
+		}
+	}
+	g.rebuildIndex()
+	clients := g.clients()
+	g.Unlock()
+
+	frame, text := encodeBoundsFrame(bounds)
+	for _, client := range clients {
+		sendBounds(client, frame, text)
+	}
+	return nil
+}
+
+// rebuildIndex indexes every entity of the game anew, for indexes laid out
+// over the old bounds. Entities keep their network IDs. The caller must
+// hold the lock.
+func (g *Game) rebuildIndex() {
+	g.index = netIndex{SpatialIndex: newIndex(g.config), ids: g.netIDs}
+	for _, player := range inOrder(g, g.players) {
+		if player.IsAlive() {
+			g.reindex(player)
+		}
+	}
+	for _, food := range inOrder(g, g.food) {
+		g.index.Insert(food.ID, food.Position, FOOD_RADIUS)
+	}
+	for _, virus := range inOrder(g, g.viruses) {
+		g.index.Insert(virus.ID, virus.Position, float64(virus.Radius))
+	}
+	for _, ejected := range inOrder(g, g.ejected) {
+		g.index.Insert(ejected.ID, ejected.Position, EJECT_RADIUS)
+	}
+}
+
+// sendResized sends the bounds of a resized game to a client that just
+// said hello, others draw the bounds they were configured with.
+func (g *Game) sendResized(client client) {
+	g.RLock()
+	resized, bounds := g.resized, g.config.Bounds
+	g.RUnlock()
+	if !resized {
+		return
+	}
+
+	frame, text := encodeBoundsFrame(bounds)
+	sendBounds(client, frame, text)
+}
+
+// encodeBoundsFrame encodes the OpBounds frame telling clients about
+// bounds, and its debugging counterpart:
+// min x | min y | max x | max y, float64 (8) each, little endian.
+func encodeBoundsFrame(bounds utils.Rect) ([]byte, string) {
+	data := make([]byte, 0, 4*8)
+	for _, v := range []float64{bounds.Min.X, bounds.Min.Y, bounds.Max.X, bounds.Max.Y} {
+		data = binary.LittleEndian.AppendUint64(data, math.Float64bits(v))
+	}
+	return EncodeFrame(OpBounds, data), debugFrame{Op: OpBounds.String(), Bounds: &bounds}.encode()
+}
+
+// DecodeBounds decodes the payload of an OpBounds frame.
+func DecodeBounds(payload []byte) (utils.Rect, error) {
+	if len(payload) < 4*8 {
+		return utils.Rect{}, ErrorShortBuffer
+	}
+	v := func(i int) float64 {
+		return math.Float64frombits(binary.LittleEndian.Uint64(payload[i*8:]))
+	}
+	return utils.Rect{
+		Min: utils.Vector2D{X: v(0), Y: v(1)},
+		Max: utils.Vector2D{X: v(2), Y: v(3)},
+	}, nil
+}
+
+func sendBounds(client client, frame []byte, text string) {
+	switch {
+	case client.ProtocolVersion() < BOUNDS_PROTOCOL_VERSION:
+	case client.debug():
+		client.sendText(text)
+	default:
+		client.SendBinary(frame)
+	}
+}
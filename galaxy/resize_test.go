@@ -0,0 +1,70 @@
+package galaxy
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+func TestResizePushesPlayersIn(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	outside := joinTestPlayer(t, g, STARTING_MASS, 9000, 8000)
+	inside := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	conn := connectTestPlayer(inside)
+
+	bounds := utils.Rect{Max: utils.Vector2D{X: 5000, Y: 5000}}
+	if err := g.Resize(bounds); err != nil {
+		t.Fatalf("Resize: %v", err)
+	}
+
+	if position := outside.GetPosition(); position != (utils.Vector2D{X: 5000, Y: 5000}) {
+		t.Errorf("player outside pushed to %v, want the nearest corner", position)
+	}
+	if position := inside.GetPosition(); position != (utils.Vector2D{X: 1000, Y: 1000}) {
+		t.Errorf("player inside moved to %v", position)
+	}
+	for _, food := range g.Snapshot().Food {
+		if bounds.Clamp(food.Position) != food.Position {
+			t.Fatalf("pellet left outside at %v", food.Position)
+		}
+	}
+
+	// The index was rebuilt: nothing is left where the pushed player was,
+	// and it is found where it is now.
+	g.RLock()
+	stale := g.index.QueryRange(utils.RectAround(utils.Vector2D{X: 9000, Y: 8000}, 100, 100))
+	moved := g.index.QueryRange(utils.RectAround(outside.GetPosition(), 10, 10))
+	g.RUnlock()
+	if len(stale) != 0 {
+		t.Errorf("index still has %d entities outside the bounds", len(stale))
+	}
+	if !slices.Contains(moved, outside.PlayerID) {
+		t.Error("index misses the pushed player where it is now")
+	}
+	if !ids(g.Viewport(outside))[outside.PlayerID] {
+		t.Error("viewport of the pushed player misses it")
+	}
+
+	payloads := conn.payloads(OpBounds)
+	if len(payloads) != 1 {
+		t.Fatalf("client sent %d bounds frames, want 1", len(payloads))
+	}
+	if sent, err := DecodeBounds(payloads[0]); err != nil || sent != bounds {
+		t.Errorf("client told bounds %v (%v), want %v", sent, err, bounds)
+	}
+}
+
+func TestResizeRejectsEmptyBounds(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	before := g.Bounds()
+
+	empty := utils.Rect{Min: utils.Vector2D{X: 100, Y: 100}, Max: utils.Vector2D{X: 100, Y: 500}}
+	if err := g.Resize(empty); !errors.Is(err, ErrorInvalidConfig) {
+		t.Errorf("Resize to %v: got %v, want %v", empty, err, ErrorInvalidConfig)
+	}
+	if g.Bounds() != before {
+		t.Errorf("rejected resize changed the bounds to %v", g.Bounds())
+	}
+}
@@ -0,0 +1,185 @@
+package galaxy
+
+import (
+	"fmt"
+	"math"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// SPAWN_ATTEMPTS bounds the search of a free spawn position, in a crowded
+// world the player spawns at the last candidate.
+const SPAWN_ATTEMPTS = 16
+
+var (
+	ErrorPlayerAlive = fmt.Errorf("Player is alive")
+	ErrorWorldFull   = fmt.Errorf("No free position left in the world")
+)
+
+// Death tells that a player was eaten and lost its mass to another one.
+type Death struct {
+	PlayerID uuid.UUID `json:"playerId"`
+	EatenBy  uuid.UUID `json:"eatenBy"`
+	Mass     uint64    `json:"mass"`
+}
+
+// IsAlive reports whether p is playing, eaten players stay in the game
+// until they respawn.
+func (p *Player) IsAlive() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.Alive
+}
+
+// SetOnDeath registers a function called with every death after the tick
+// it happened in, so the server can notify the eaten player.
+func (g *Game) SetOnDeath(onDeath func(Death)) {
+	g.Lock()
+	g.onDeath = onDeath
+	g.Unlock()
+}
+
+// kill moves the mass of prey and its split cells to eater. Cells are
+// removed, players are kept dead until they respawn. The caller must hold
+// the lock.
+func (g *Game) kill(prey *Player, eater *Player, result *TickResult) {
+	g.emitTransfer(eater, prey.Owner(), EntityPlayer, eater.absorb(prey))
+	result.EatenPlayers = append(result.EatenPlayers, prey.PlayerID)
+	if prey.IsCell() {
+		g.removeCell(prey)
+		return
+	}
+
+	// A player dies with its original cell, taking the split ones.
+	mass := prey.Score()
+	for _, cell := range g.cells(prey.PlayerID) {
+		gained := eater.absorb(cell)
+		mass += gained
+		g.emitTransfer(eater, prey.Owner(), EntityPlayer, gained)
+		g.removePlayer(cell.PlayerID)
+		result.EatenPlayers = append(result.EatenPlayers, cell.PlayerID)
+	}
+
+	prey.Lock()
+	prey.Alive = false
+	prey.Mass = 0
+	prey.impulse = utils.Vector2D{}
+	prey.splitCells = 0
+	prey.level = 0
+	prey.Unlock()
+	g.index.Remove(prey.PlayerID)
+	g.emit(EventPlayerEaten, prey.PlayerID, Event{EatenBy: eater.Owner(), Mass: mass})
+
+	result.Deaths = append(result.Deaths, Death{
+		PlayerID: prey.PlayerID,
+		EatenBy:  eater.Owner(),
+		Mass:     mass,
+	})
+}
+
+// Protected reports whether p is under spawn protection, see
+// GameConfig.SpawnProtection.
+func (p *Player) Protected() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.protection > 0
+}
+
+// Respawn brings back a dead player with the starting mass, at a random
+// position away from other players and viruses, under spawn protection if
+// the game grants it. It fails with ErrorWorldFull if there is no room
+// left, clients can retry later.
+func (g *Game) Respawn(id uuid.UUID) error {
+	g.Lock()
+	defer g.Unlock()
+
+	player, exists := g.players[id]
+	if !exists {
+		return ErrorPlayerNotFound
+	}
+	return g.respawn(player)
+}
+
+// respawn implements Respawn, the caller must hold the lock.
+func (g *Game) respawn(player *Player) error {
+	if player.IsAlive() {
+		return ErrorPlayerAlive
+	}
+
+	position, found := g.findFreePosition(g.rules.k * math.Sqrt(float64(g.rules.start)))
+	if !found {
+		return ErrorWorldFull
+	}
+
+	player.Lock()
+	player.Alive = true
+	player.Mass = g.rules.start
+	player.recomputeRadius()
+	player.Position = position
+	player.mergeCooldown = 0
+	player.protection = g.rules.spawnProtection
+	player.Unlock()
+
+	g.reindex(player)
+	return nil
+}
+
+// newPlayer returns a player of the starting mass for the client of
+// connectionID, with its ID, position and color drawn from the game's
+// source of randomness so games with the same seed spawn players alike. It
+// spawns away from other players and viruses unless the world is too
+// crowded. The caller must hold the lock.
+func (g *Game) newPlayer(connectionID uuid.UUID) *Player {
+	player := NewPlayer(connectionID, nil)
+	player.PlayerID = g.newID()
+	player.Mass = g.rules.start
+	player.Position = g.spawnPosition()
+	player.Color = FoodColors[g.rand.IntN(len(FoodColors))]
+	return player
+}
+
+// spawnPosition returns a free position for a player of the starting mass,
+// see findFreePosition, or a random one in a crowded world.
+func (g *Game) spawnPosition() utils.Vector2D {
+	position, found := g.findFreePosition(g.rules.k * math.Sqrt(float64(g.rules.start)))
+	if !found {
+		return g.randomPosition()
+	}
+	return position
+}
+
+// findFreePosition looks for a random position where a circle of the
+// given radius doesn't touch any player nor virus, giving up after
+// SPAWN_ATTEMPTS when the world is too crowded.
+func (g *Game) findFreePosition(radius float64) (utils.Vector2D, bool) {
+	for range SPAWN_ATTEMPTS {
+		position := g.randomPosition()
+		if g.isFree(position, radius) {
+			return position, true
+		}
+	}
+	return utils.Vector2D{}, false
+}
+
+// isFree reports whether a circle at position doesn't overlap any player
+// nor virus.
+func (g *Game) isFree(position utils.Vector2D, radius float64) bool {
+	for _, id := range g.index.QueryRange(utils.RectAround(position, radius, radius)) {
+		var otherPosition utils.Vector2D
+		var otherRadius float64
+		if player, isPlayer := g.players[id]; isPlayer {
+			otherPosition, otherRadius = player.circle()
+		} else if virus, isVirus := g.viruses[id]; isVirus {
+			otherPosition, otherRadius = virus.Position, float64(virus.Radius)
+		} else {
+			continue
+		}
+
+		reach := radius + otherRadius
+		if position.DistanceSquared(otherPosition) < reach*reach {
+			return false
+		}
+	}
+	return true
+}
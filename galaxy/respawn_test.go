@@ -0,0 +1,40 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRespawnFrameRevivesEatenPlayer(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	player := joinTestPlayer(t, g, STARTING_MASS, 100, 100)
+
+	g.Lock()
+	player.Lock()
+	player.Alive, player.Mass = false, 0
+	player.Unlock()
+	g.Unlock()
+
+	g.Dispatcher().Dispatch(player, EncodeFrame(OpRespawn, nil))
+
+	if !player.IsAlive() {
+		t.Fatal("player still dead after sending OpRespawn")
+	}
+	if mass := player.Score(); mass != g.rules.start {
+		t.Errorf("respawned with mass %d, want %d", mass, g.rules.start)
+	}
+}
+
+func TestRespawnAlivePlayer(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	player := joinTestPlayer(t, g, 500, 100, 100)
+
+	if err := g.Respawn(player.PlayerID); !errors.Is(err, ErrorPlayerAlive) {
+		t.Fatalf("Respawn of a living player: got %v, want %v", err, ErrorPlayerAlive)
+	}
+	// The frame of a client racing its own death changes nothing.
+	g.Dispatcher().Dispatch(player, EncodeFrame(OpRespawn, nil))
+	if mass := player.Score(); mass != 500 {
+		t.Errorf("mass %d after OpRespawn while alive, want 500", mass)
+	}
+}
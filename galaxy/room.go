@@ -0,0 +1,411 @@
+package galaxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrorRoomExists   = fmt.Errorf("Room already exists")
+	ErrorTooManyRooms = fmt.Errorf("Too many rooms")
+	ErrorServerFull   = fmt.Errorf("Every room is full")
+	ErrorRoomsClosed  = fmt.Errorf("Room manager is closed")
+	ErrorRoomFull     = fmt.Errorf("Room is full")
+)
+
+// room is a game run by a RoomManager.
+type room struct {
+	id     string
+	game   *Game
+	cancel context.CancelFunc
+
+	// members counts the connections of the room, including the ones still
+	// being upgraded. The room is torn down when it drops to 0 and no
+	// disconnected player may resume its session anymore.
+	members int
+
+	// joining counts the connections being upgraded.
+	joining int
+}
+
+// RoomManager runs many games concurrently, each in its own room with its
+// own tick loop. Rooms are created on demand and torn down once their last
+// connection leaves and the grace period of their last disconnected player
+// ran out. It is safe for concurrent use.
+type RoomManager struct {
+	sync.Mutex
+	factory  ConnectionFactory
+	config   GameConfig
+	maxRooms int
+	rooms    map[string]*room
+
+	// playersPerRoom is the capacity of the rooms players are matched
+	// into, see FindOrCreate.
+	playersPerRoom int
+
+	// maxConnections caps the members of a room, spectators included, 0
+	// means no limit.
+	maxConnections int
+
+	closed bool
+}
+
+// NewRoomManager creates a manager running up to maxRooms games with
+// config, 0 means no limit. Players not asking for a room are matched into
+// rooms of up to playersPerRoom players. config.Rand and config.Seed are
+// ignored, every room seeds its own, see CreateRoomWithSeed.
+func NewRoomManager(factory ConnectionFactory, config GameConfig, maxRooms int, playersPerRoom int) *RoomManager {
+	return &RoomManager{
+		factory:        factory,
+		config:         config,
+		maxRooms:       maxRooms,
+		rooms:          make(map[string]*room),
+		playersPerRoom: playersPerRoom,
+	}
+}
+
+// SetMaxConnectionsPerRoom caps the connections of every room, spectators
+// included. Joining a room over the limit fails with ErrorRoomFull and
+// matchmaking skips it. 0 means no limit.
+func (m *RoomManager) SetMaxConnectionsPerRoom(n int) {
+	m.Lock()
+	m.maxConnections = n
+	m.Unlock()
+}
+
+// full reports whether r reached the connection limit, the caller must
+// hold the lock.
+func (m *RoomManager) full(r *room) bool {
+	return m.maxConnections > 0 && r.members >= m.maxConnections
+}
+
+// CreateRoom starts a new game under id.
+func (m *RoomManager) CreateRoom(id string) (*Game, error) {
+	return m.CreateRoomWithSeed(id, 0)
+}
+
+// CreateRoomWithSeed starts a new game under id seeded with seed, to
+// replay a match from the Game.Seed of its room and its recorded inputs. A
+// seed of 0 seeds it from the time, like CreateRoom.
+func (m *RoomManager) CreateRoomWithSeed(id string, seed uint64) (*Game, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, exists := m.rooms[id]; exists {
+		return nil, ErrorRoomExists
+	}
+	r, err := m.createRoom(id, seed)
+	if err != nil {
+		return nil, err
+	}
+	return r.game, nil
+}
+
+// createRoom starts a game seeded with seed, the caller must hold the
+// lock.
+func (m *RoomManager) createRoom(id string, seed uint64) (*room, error) {
+	if m.closed {
+		return nil, ErrorRoomsClosed
+	}
+	if m.maxRooms > 0 && len(m.rooms) >= m.maxRooms {
+		return nil, ErrorTooManyRooms
+	}
+
+	// A source of randomness can't be shared between games.
+	config := m.config
+	config.Rand = nil
+	config.Seed = seed
+
+	game, err := NewGame(config)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &room{
+		id:     id,
+		game:   game,
+		cancel: cancel,
+	}
+	r.game.room = id
+	r.game.onReaped = func() { m.reaped(r) }
+	m.rooms[id] = r
+	go r.game.Run(ctx)
+
+	log.Printf("room %v created with seed %v", id, r.game.Seed())
+	return r, nil
+}
+
+// Room returns the game of a room.
+func (m *RoomManager) Room(id string) (*Game, bool) {
+	m.Lock()
+	defer m.Unlock()
+	r, exists := m.rooms[id]
+	if !exists {
+		return nil, false
+	}
+	return r.game, true
+}
+
+// Rooms returns the IDs of the running rooms.
+func (m *RoomManager) Rooms() []string {
+	m.Lock()
+	defer m.Unlock()
+	ids := make([]string, 0, len(m.rooms))
+	for id := range m.rooms {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// FindOrCreate returns the emptiest game with fewer than maxPlayers
+// players, only creating a new room when every one is full so players
+// aren't scattered across empty worlds. It fails with ErrorServerFull once
+// no room can be created. Like CreateRoom, a room nobody connects to is
+// never torn down.
+func (m *RoomManager) FindOrCreate(maxPlayers int) (*Game, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	r, err := m.findOrCreate(maxPlayers)
+	if err != nil {
+		return nil, err
+	}
+	return r.game, nil
+}
+
+// findOrCreate implements FindOrCreate, the caller must hold the lock.
+func (m *RoomManager) findOrCreate(maxPlayers int) (*room, error) {
+	var emptiest *room
+	emptiestLoad := 0
+	for _, r := range m.rooms {
+		// Spectators and resuming players aren't matched, so the
+		// MaxPlayers of the game only matters here, joining a room
+		// directly leaves it to the game.
+		load := r.load()
+		if load >= maxPlayers || m.full(r) || r.game.Full() || r.game.Closed() {
+			continue
+		}
+		// Ties go to the smallest ID, so matching is deterministic.
+		if emptiest == nil || load < emptiestLoad || (load == emptiestLoad && r.id < emptiest.id) {
+			emptiest, emptiestLoad = r, load
+		}
+	}
+	if emptiest != nil {
+		return emptiest, nil
+	}
+
+	r, err := m.createRoom(uuid.NewString(), 0)
+	if errors.Is(err, ErrorTooManyRooms) {
+		return nil, ErrorServerFull
+	}
+	return r, err
+}
+
+// load is the number of players of a room, counting connections still
+// joining so concurrent joins are spread too. Spectators don't count once
+// joined.
+func (r *room) load() int {
+	return max(r.game.PlayerCount(), r.joining)
+}
+
+// JoinRoom upgrades the request into a connection to the game of a room,
+// creating the room if needed. The connection is established by the game,
+// see Game.HandleNewConnection.
+func (m *RoomManager) JoinRoom(id string, w http.ResponseWriter, r *http.Request) error {
+	m.Lock()
+	rm, exists := m.rooms[id]
+	if !exists {
+		var err error
+		if rm, err = m.createRoom(id, 0); err != nil {
+			m.Unlock()
+			return err
+		}
+	}
+	if m.full(rm) {
+		m.Unlock()
+		return ErrorRoomFull
+	}
+	if rm.game.Closed() {
+		m.Unlock()
+		return ErrorGameClosed
+	}
+	m.reserve(rm)
+	m.Unlock()
+
+	m.join(rm, w, r)
+	return nil
+}
+
+// Matchmake upgrades the request into a connection to the game chosen by
+// FindOrCreate, with the configured players per room. Requests with
+// ?session=<token> go back to the room of the player they resume.
+func (m *RoomManager) Matchmake(w http.ResponseWriter, r *http.Request) error {
+	m.Lock()
+	rm := m.sessionRoom(r)
+	if rm != nil && m.full(rm) {
+		m.Unlock()
+		return ErrorRoomFull
+	}
+	if rm == nil {
+		var err error
+		if rm, err = m.findOrCreate(m.playersPerRoom); err != nil {
+			m.Unlock()
+			return err
+		}
+	}
+	m.reserve(rm)
+	m.Unlock()
+
+	m.join(rm, w, r)
+	return nil
+}
+
+// sessionRoom returns the room of the player the ?session= token of r
+// resumes, nil if there is none. The caller must hold the lock.
+func (m *RoomManager) sessionRoom(r *http.Request) *room {
+	token, err := uuid.Parse(r.URL.Query().Get("session"))
+	if err != nil {
+		return nil
+	}
+	for _, rm := range m.rooms {
+		if rm.game.hasSession(token) {
+			return rm
+		}
+	}
+	return nil
+}
+
+// reserve takes a seat of a room before upgrading, so the room isn't torn
+// down meanwhile. The caller must hold the lock.
+func (m *RoomManager) reserve(r *room) {
+	r.members++
+	r.joining++
+}
+
+// join hands the request to the game of rm, releasing the seat reserve
+// took if the game refused it without upgrading, such as when it is full.
+func (m *RoomManager) join(rm *room, w http.ResponseWriter, r *http.Request) {
+	factory := &roomFactory{ConnectionFactory: m.factory, manager: m, room: rm}
+	rm.game.HandleNewConnection(factory, w, r)
+
+	m.Lock()
+	rm.joining--
+	m.Unlock()
+	if !factory.upgraded {
+		m.leave(rm)
+	}
+}
+
+// HandleNewConnection joins the room of the ?room= query parameter, or
+// matches the player into one without it.
+func (m *RoomManager) HandleNewConnection(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("room")
+
+	var err error
+	if id == "" {
+		err = m.Matchmake(w, r)
+	} else {
+		err = m.JoinRoom(id, w, r)
+	}
+	if err != nil {
+		log.Printf("unable to join room %v: %v", id, err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// leave releases a seat of a room, tearing it down if it was the last one
+// and no disconnected player may resume its session, see reaped.
+func (m *RoomManager) leave(r *room) {
+	m.Lock()
+	defer m.Unlock()
+
+	r.members--
+	if r.members > 0 || r.game.resumable() {
+		return
+	}
+	m.teardown(r)
+}
+
+// reaped tears down a room left by its last connection once the grace
+// period of its last disconnected player ran out.
+func (m *RoomManager) reaped(r *room) {
+	m.Lock()
+	defer m.Unlock()
+
+	if r.members > 0 || m.rooms[r.id] != r || r.game.resumable() {
+		return
+	}
+	m.teardown(r)
+}
+
+// teardown closes the game of a room and forgets it, the caller must hold
+// the lock.
+func (m *RoomManager) teardown(r *room) {
+	r.cancel()
+	r.game.Close()
+	r.game.metrics.Players(r.id, 0)
+	if m.rooms[r.id] == r {
+		delete(m.rooms, r.id)
+	}
+	log.Printf("room %v closed", r.id)
+}
+
+// Stop stops the tick loops of every room but leaves their connections
+// open, for a server shutting down to flush and close them itself. No room
+// can be created afterwards, Close then frees the games.
+func (m *RoomManager) Stop() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.closed = true
+	for _, r := range m.rooms {
+		r.cancel()
+	}
+}
+
+// Close closes the game of every room, dropping their connections, see
+// Game.Close. No room can be created afterwards.
+func (m *RoomManager) Close() {
+	m.Lock()
+	defer m.Unlock()
+
+	m.closed = true
+	for id, r := range m.rooms {
+		r.cancel()
+		r.game.Close()
+		r.game.metrics.Players(id, 0)
+		delete(m.rooms, id)
+	}
+}
+
+// roomFactory creates the connection of a request joining a room, keeping
+// track of when it leaves.
+type roomFactory struct {
+	ConnectionFactory
+	manager *RoomManager
+	room    *room
+
+	// upgraded is set once the game tried to upgrade the request, from
+	// then on the connection releases its seat itself.
+	upgraded bool
+}
+
+func (f *roomFactory) NewFrameConnection(w http.ResponseWriter, r *http.Request, frameHandler func([]byte), onClose func(CloseReason, error)) (ClientConnection, error) {
+	f.upgraded = true
+	conn, err := f.ConnectionFactory.NewFrameConnection(w, r, frameHandler, func(reason CloseReason, err error) {
+		onClose(reason, err)
+		f.manager.leave(f.room)
+	})
+	if err != nil {
+		f.manager.leave(f.room)
+		return nil, err
+	}
+	return conn, nil
+}
@@ -0,0 +1,235 @@
+package galaxy
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// onlyPlayer returns the single player of g.
+func onlyPlayer(t *testing.T, g *Game) *Player {
+	t.Helper()
+
+	g.RLock()
+	defer g.RUnlock()
+	if len(g.players) != 1 {
+		t.Fatalf("game has %d players, want 1", len(g.players))
+	}
+	for _, player := range g.players {
+		return player
+	}
+	return nil
+}
+
+func TestRoomOutlivesDisconnectedSessions(t *testing.T) {
+	config := testConfig()
+	config.SessionGracePeriod = time.Minute
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, config, 0, 10)
+	defer rooms.Close()
+
+	connect(rooms.HandleNewConnection, "room=a")
+	game, _ := rooms.Room("a")
+	player := onlyPlayer(t, game)
+	factory.last().Close()
+
+	if _, exists := rooms.Room("a"); !exists {
+		t.Fatal("room torn down while its player may still resume its session")
+	}
+
+	game.reap(time.Now().Add(2 * config.SessionGracePeriod))
+	game.onReaped()
+	if _, exists := rooms.Room("a"); exists {
+		t.Fatalf("room kept after player %v was reaped", player.PlayerID)
+	}
+	if !game.Closed() {
+		t.Error("game of the torn down room still running")
+	}
+}
+
+func TestMatchmakeRoutesSessionsToTheirRoom(t *testing.T) {
+	config := testConfig()
+	config.SessionGracePeriod = time.Minute
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, config, 0, 10)
+	defer rooms.Close()
+
+	connect(rooms.HandleNewConnection, "room=a")
+	game, _ := rooms.Room("a")
+	player := onlyPlayer(t, game)
+	game.issueSession(player)
+	factory.last().Close()
+
+	// The empty room is where the player would be matched otherwise.
+	if _, err := rooms.CreateRoom("b"); err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	player.RLock()
+	token := player.session
+	player.RUnlock()
+	connect(rooms.HandleNewConnection, "session="+token.String())
+
+	player.RLock()
+	resumed := player.conn == factory.last()
+	player.RUnlock()
+	if !resumed {
+		t.Error("the session holder wasn't matched back into the room of its player")
+	}
+	if other, _ := rooms.Room("b"); other.PlayerCount() != 0 {
+		t.Errorf("room b has %d players, want 0", other.PlayerCount())
+	}
+}
+
+func TestMaxPlayersRejectsJoinsUntilOneLeaves(t *testing.T) {
+	config := testConfig()
+	config.MaxPlayers = 2
+	g := newTestGame(t, config)
+
+	first := joinTestPlayer(t, g, STARTING_MASS, 100, 100)
+	joinTestPlayer(t, g, STARTING_MASS, 500, 500)
+
+	extra := NewPlayer(uuid.New(), nil)
+	extra.PlayerID = uuid.New()
+	if err := g.AddPlayer(extra); !errors.Is(err, ErrorRoomFull) {
+		t.Fatalf("third player joining a game of 2: got %v, want %v", err, ErrorRoomFull)
+	}
+
+	g.RemovePlayer(first.PlayerID)
+	if err := g.AddPlayer(extra); err != nil {
+		t.Fatalf("joining after a player left: %v", err)
+	}
+}
+
+func TestRefusedJoinReleasesItsSeat(t *testing.T) {
+	config := testConfig()
+	config.MaxPlayers = 1
+	config.SessionGracePeriod = 0
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, config, 0, 10)
+	defer rooms.Close()
+
+	connect(rooms.HandleNewConnection, "room=a")
+	first := factory.last()
+	if response := connect(rooms.HandleNewConnection, "room=a"); response.Code != http.StatusServiceUnavailable {
+		t.Fatalf("joining a full game answered %d, want %d", response.Code, http.StatusServiceUnavailable)
+	}
+	if factory.last() != first {
+		t.Fatal("the join refused for a full game was upgraded")
+	}
+
+	// The refused join must not keep the room up once its player left.
+	first.Close()
+	if _, exists := rooms.Room("a"); exists {
+		t.Error("room kept after its only player left, the refused join leaked its seat")
+	}
+}
+
+func TestClosedGamesTakeNoSeat(t *testing.T) {
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, testConfig(), 0, 10)
+	defer rooms.Close()
+
+	game, err := rooms.CreateRoom("a")
+	if err != nil {
+		t.Fatalf("CreateRoom: %v", err)
+	}
+	game.Close()
+
+	if response := connect(rooms.HandleNewConnection, "room=a"); response.Code != http.StatusServiceUnavailable {
+		t.Errorf("joining a closed game answered %d, want %d", response.Code, http.StatusServiceUnavailable)
+	}
+	rooms.Lock()
+	members := rooms.rooms["a"].members
+	rooms.Unlock()
+	if members != 0 {
+		t.Errorf("closed room has %d members, want 0", members)
+	}
+
+	connect(rooms.HandleNewConnection, "")
+	if factory.last() == nil {
+		t.Fatal("matchmaking with only a closed room refused the player")
+	}
+	if game.PlayerCount() != 0 {
+		t.Error("matchmaking placed a player in a closed game")
+	}
+}
+
+func TestMatchmakingFillsBalancedRooms(t *testing.T) {
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, testConfig(), 3, 10)
+	defer rooms.Close()
+
+	for i := range 30 {
+		if response := connect(rooms.HandleNewConnection, ""); response.Code >= http.StatusBadRequest {
+			t.Fatalf("player %d refused: %d %s", i, response.Code, response.Body)
+		}
+	}
+
+	ids := rooms.Rooms()
+	if len(ids) != 3 {
+		t.Fatalf("30 players matched into %d rooms, want 3", len(ids))
+	}
+	for _, id := range ids {
+		game, _ := rooms.Room(id)
+		if count := game.PlayerCount(); count != 10 {
+			t.Errorf("room %v has %d players, want 10", id, count)
+		}
+	}
+
+	if _, err := rooms.FindOrCreate(10); !errors.Is(err, ErrorServerFull) {
+		t.Errorf("matching past the room cap: got %v, want %v", err, ErrorServerFull)
+	}
+}
+
+// joinRoom connects a player to room id of rooms, past its hello, returning
+// its connection.
+func joinRoom(t *testing.T, rooms *RoomManager, factory *fakeFactory, id string) *fakeConn {
+	t.Helper()
+
+	if response := connect(rooms.HandleNewConnection, "room="+id); response.Code >= http.StatusBadRequest {
+		t.Fatalf("joining room %v: %d %s", id, response.Code, response.Body)
+	}
+	conn := factory.last()
+	game, _ := rooms.Room(id)
+	game.RLock()
+	for _, player := range game.players {
+		player.Lock()
+		if player.conn == conn {
+			player.protocol = PROTOCOL_VERSION
+		}
+		player.Unlock()
+	}
+	game.RUnlock()
+	return conn
+}
+
+func TestBroadcastsStayInTheirRoom(t *testing.T) {
+	factory := &fakeFactory{}
+	rooms := NewRoomManager(factory, testConfig(), 0, 10)
+	defer rooms.Close()
+
+	inA := []*fakeConn{joinRoom(t, rooms, factory, "a"), joinRoom(t, rooms, factory, "a")}
+	inB := []*fakeConn{joinRoom(t, rooms, factory, "b"), joinRoom(t, rooms, factory, "b")}
+	before := make(map[*fakeConn]int)
+	for _, conn := range append(inA, inB...) {
+		before[conn] = len(conn.sent())
+	}
+
+	a, _ := rooms.Room("a")
+	a.Broadcast()
+	a.SendAll(EncodeFrame(OpPing, nil))
+
+	for i, conn := range inA {
+		if sent := len(conn.sent()) - before[conn]; sent != 2 {
+			t.Errorf("connection %d of room a got %d frames of its room, want 2", i, sent)
+		}
+	}
+	for i, conn := range inB {
+		if sent := len(conn.sent()) - before[conn]; sent != 0 {
+			t.Errorf("connection %d of room b got %d frames of room a", i, sent)
+		}
+	}
+}
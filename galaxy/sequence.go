@@ -0,0 +1,57 @@
+package galaxy
+
+import (
+	"encoding/binary"
+	"time"
+)
+
+// SNAPSHOT_HEADER_SIZE is the encoded size of the header starting every
+// OpStateSnapshot payload: sequence uint32 (4) | server time int64 (8),
+// unix milliseconds, little endian.
+const SNAPSHOT_HEADER_SIZE = 4 + 8
+
+// SnapshotHeader tells clients when a state snapshot was taken, so they
+// can interpolate between snapshots and spot out of order or dropped ones.
+// Sequence grows by one every broadcast and wraps around after
+// math.MaxUint32, compare sequences with SequenceAfter.
+type SnapshotHeader struct {
+	Sequence uint32    `json:"seq"`
+	Time     time.Time `json:"time"`
+}
+
+func (h SnapshotHeader) appendBinary(data []byte) []byte {
+	data = binary.LittleEndian.AppendUint32(data, h.Sequence)
+	return binary.LittleEndian.AppendUint64(data, uint64(h.Time.UnixMilli()))
+}
+
+// DecodeSnapshotHeader splits the payload of an OpStateSnapshot frame into
+// its header and the entities, encodeEntities or a delta, that follow.
+func DecodeSnapshotHeader(payload []byte) (SnapshotHeader, []byte, error) {
+	if len(payload) < SNAPSHOT_HEADER_SIZE {
+		return SnapshotHeader{}, nil, ErrorShortBuffer
+	}
+	header := SnapshotHeader{
+		Sequence: binary.LittleEndian.Uint32(payload[0:4]),
+		Time:     time.UnixMilli(int64(binary.LittleEndian.Uint64(payload[4:12]))),
+	}
+	return header, payload[SNAPSHOT_HEADER_SIZE:], nil
+}
+
+// SequenceAfter reports whether the sequence a comes after b, across
+// wrap-arounds: sequences less than half the range ahead of b are after
+// it.
+func SequenceAfter(a, b uint32) bool {
+	return int32(a-b) > 0
+}
+
+// nextSnapshotHeader returns the header of the next broadcast.
+func (g *Game) nextSnapshotHeader() SnapshotHeader {
+	return SnapshotHeader{Sequence: g.sequence.Add(1), Time: time.Now()}
+}
+
+// encodeSnapshot encodes the payload of an OpStateSnapshot frame.
+func encodeSnapshot(header SnapshotHeader, entities []byte) []byte {
+	data := make([]byte, 0, SNAPSHOT_HEADER_SIZE+len(entities))
+	data = header.appendBinary(data)
+	return append(data, entities...)
+}
@@ -0,0 +1,65 @@
+package galaxy
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSnapshotSequencesIncrease(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	factory := &fakeFactory{}
+	conn, _ := sayHello(t, g, factory, PROTOCOL_VERSION)
+
+	// Start close to the wrap-around.
+	g.sequence.Store(math.MaxUint32 - 3)
+	for range 8 {
+		g.Tick(time.Second / DEFAULT_TICK_RATE)
+		g.Broadcast()
+	}
+
+	var headers []SnapshotHeader
+	for _, payload := range conn.payloads(OpStateSnapshot) {
+		header, _, err := DecodeSnapshotHeader(payload)
+		if err != nil {
+			t.Fatalf("DecodeSnapshotHeader: %v", err)
+		}
+		headers = append(headers, header)
+	}
+	if len(headers) < 8 {
+		t.Fatalf("client sent %d snapshots, want at least 8", len(headers))
+	}
+	headers = headers[len(headers)-8:]
+	for i := 1; i < len(headers); i++ {
+		prev, next := headers[i-1], headers[i]
+		if next.Sequence != prev.Sequence+1 || !SequenceAfter(next.Sequence, prev.Sequence) {
+			t.Errorf("snapshot %d has sequence %d after %d", i, next.Sequence, prev.Sequence)
+		}
+		if next.Time.Before(prev.Time) {
+			t.Errorf("snapshot %d taken at %v, before the previous one at %v", i, next.Time, prev.Time)
+		}
+	}
+	if headers[len(headers)-1].Sequence > 10 {
+		t.Errorf("sequence didn't wrap around, ended at %d", headers[len(headers)-1].Sequence)
+	}
+}
+
+func TestSequenceAfter(t *testing.T) {
+	tests := []struct {
+		a, b uint32
+		want bool
+	}{
+		{1, 0, true},
+		{0, 1, false},
+		{5, 5, false},
+		{0, math.MaxUint32, true},
+		{math.MaxUint32, 0, false},
+		{math.MaxUint32 / 2, 0, true},
+		{math.MaxUint32/2 + 2, 0, false},
+	}
+	for _, test := range tests {
+		if got := SequenceAfter(test.a, test.b); got != test.want {
+			t.Errorf("SequenceAfter(%d, %d) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package galaxy
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// DEFAULT_SESSION_GRACE_PERIOD is how long a standard game waits for a
+	// disconnected client to come back.
+	DEFAULT_SESSION_GRACE_PERIOD = 30 * time.Second
+
+	// SESSION_REAP_INTERVAL is how often players whose grace period ran out
+	// are removed, and idle players disconnected.
+	SESSION_REAP_INTERVAL = time.Second
+)
+
+// issueSession gives player a session token and sends it to its client in
+// an OpSession frame. Clients present it as ?session=<token> to resume the
+// player within the grace period. It does nothing unless the game keeps
+// disconnected players.
+func (g *Game) issueSession(player *Player) {
+	if g.config.SessionGracePeriod <= 0 {
+		return
+	}
+
+	g.Lock()
+	if g.players[player.PlayerID] != player {
+		g.Unlock()
+		return
+	}
+	player.Lock()
+	if player.session == uuid.Nil {
+		player.session = uuid.New()
+	}
+	token := player.session
+	player.Unlock()
+	g.sessions[token] = player.PlayerID
+	g.Unlock()
+
+	if player.debug() {
+		player.sendText(debugFrame{Op: OpSession.String(), Session: &token}.encode())
+		return
+	}
+	player.sendUrgent(EncodeFrame(OpSession, token[:]))
+}
+
+// claimSession detaches the player resumed by token from its connection,
+// closing it if the server hasn't noticed it is dead yet, and returns the
+// generation the resuming connection attaches with.
+func (g *Game) claimSession(token uuid.UUID) (*Player, uint64, bool) {
+	g.RLock()
+	player, exists := g.players[g.sessions[token]]
+	if !exists {
+		g.RUnlock()
+		return nil, 0, false
+	}
+
+	player.Lock()
+	player.generation++
+	generation := player.generation
+	stale := player.conn
+	player.conn = nil
+	if player.disconnectedAt.IsZero() {
+		player.disconnectedAt = time.Now()
+	}
+	player.Unlock()
+	g.RUnlock()
+
+	// Its onClose no longer matches the generation of the player.
+	if stale != nil {
+		stale.Close()
+	}
+	return player, generation, true
+}
+
+// attach connects conn to player unless it was removed or another
+// connection claimed it since generation.
+func (g *Game) attach(player *Player, generation uint64, conn ClientConnection) bool {
+	g.RLock()
+	defer g.RUnlock()
+	if g.players[player.PlayerID] != player {
+		return false
+	}
+
+	player.Lock()
+	defer player.Unlock()
+	if player.generation != generation {
+		return false
+	}
+	player.conn = conn
+	player.protocol = 0
+	player.removals = removals{}
+	player.encoder = nil
+	player.heartbeats = heartbeats{}
+	player.disconnectedAt = time.Time{}
+	player.lastInput = time.Now()
+	return true
+}
+
+// disconnect handles the connection of player with generation closing for
+// reason. The player stops moving until it is resumed or reaped, or is
+// removed right away without a grace period.
+func (g *Game) disconnect(player *Player, generation uint64, reason CloseReason, err error) {
+	player.Lock()
+	if player.generation != generation {
+		player.Unlock()
+		return
+	}
+	logClose("player", player.PlayerID, reason, err)
+	if g.config.SessionGracePeriod <= 0 {
+		player.Unlock()
+		g.RemovePlayer(player.PlayerID)
+		return
+	}
+	player.conn = nil
+	player.direction = utils.Vector2D{}
+	player.actions = 0
+	player.disconnectedAt = time.Now()
+	player.Unlock()
+}
+
+// reapSessions removes the players disconnected for longer than the grace
+// period and disconnects idle ones until ctx is done.
+func (g *Game) reapSessions(ctx context.Context) {
+	ticker := time.NewTicker(SESSION_REAP_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			reaped := g.reap(now)
+			g.flushEvents()
+			if reaped && g.onReaped != nil {
+				g.onReaped()
+			}
+			g.disconnectIdle(now)
+		}
+	}
+}
+
+// reap removes the players disconnected for longer than the grace period,
+// reporting whether there were any.
+func (g *Game) reap(now time.Time) bool {
+	if g.config.SessionGracePeriod <= 0 {
+		return false
+	}
+
+	g.Lock()
+	defer g.Unlock()
+
+	reaped := false
+
+	for id, player := range g.players {
+		player.RLock()
+		since := player.disconnectedAt
+		player.RUnlock()
+
+		if since.IsZero() || now.Sub(since) < g.config.SessionGracePeriod {
+			continue
+		}
+		for _, cell := range g.cells(id) {
+			g.removePlayer(cell.PlayerID)
+		}
+		g.removePlayer(id)
+		g.emit(EventPlayerLeft, id, Event{})
+		log.Printf("player %v didn't come back, removing it", id)
+		reaped = true
+	}
+	return reaped
+}
+
+// resumable reports whether disconnected players may still resume their
+// session, see GameConfig.SessionGracePeriod.
+func (g *Game) resumable() bool {
+	g.RLock()
+	defer g.RUnlock()
+
+	for _, player := range g.players {
+		player.RLock()
+		disconnected := !player.disconnectedAt.IsZero()
+		player.RUnlock()
+		if disconnected {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSession reports whether token resumes a player of the game.
+func (g *Game) hasSession(token uuid.UUID) bool {
+	g.RLock()
+	defer g.RUnlock()
+	_, exists := g.players[g.sessions[token]]
+	return exists
+}
+
+// disconnectIdle closes the connections of the players that sent no input
+// for longer than the idle timeout.
+func (g *Game) disconnectIdle(now time.Time) {
+	if g.config.IdleTimeout <= 0 || g.Paused() {
+		return
+	}
+
+	var idle []ClientConnection
+	g.RLock()
+	for _, player := range g.players {
+		player.RLock()
+		if player.conn != nil && now.Sub(player.lastInput) > g.config.IdleTimeout {
+			idle = append(idle, player.conn)
+			log.Printf("player %v idle since %v, disconnecting it", player.PlayerID, player.lastInput)
+		}
+		player.RUnlock()
+	}
+	g.RUnlock()
+
+	// Closing runs onClose, which takes the locks, and waits for the
+	// client to acknowledge the close frame.
+	for _, conn := range idle {
+		go closeWithReason(conn, CLOSE_IDLE, "idle")
+	}
+}
@@ -0,0 +1,47 @@
+package galaxy
+
+import (
+	"fmt"
+)
+
+// SKIN_MASK keeps the RGB bits of a skin color.
+const SKIN_MASK = 0x00FFFFFF
+
+var ErrorSkinNotInPalette = fmt.Errorf("Skin color is not in the palette")
+
+// NewSkin packs an RGB color into a skin value.
+func NewSkin(r, g, b uint8) uint32 {
+	return uint32(r)<<16 | uint32(g)<<8 | uint32(b)
+}
+
+// ValidateSkin masks skin to its RGB bits. When palette isn't empty and the
+// color is not part of it, the closest palette color is returned together
+// with ErrorSkinNotInPalette, see GameConfig.SkinPalette.
+func ValidateSkin(skin uint32, palette []uint32) (uint32, error) {
+	skin &= SKIN_MASK
+	if len(palette) == 0 {
+		return skin, nil
+	}
+
+	closest, best := palette[0]&SKIN_MASK, -1
+	for _, color := range palette {
+		color &= SKIN_MASK
+		if color == skin {
+			return skin, nil
+		}
+		if d := colorDistance(color, skin); best < 0 || d < best {
+			closest, best = color, d
+		}
+	}
+	return closest, ErrorSkinNotInPalette
+}
+
+// colorDistance is the squared euclidean distance between two RGB colors.
+func colorDistance(a, b uint32) int {
+	distance := 0
+	for shift := 0; shift <= 16; shift += 8 {
+		d := int(a>>shift&0xFF) - int(b>>shift&0xFF)
+		distance += d * d
+	}
+	return distance
+}
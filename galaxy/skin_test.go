@@ -0,0 +1,55 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidateSkin(t *testing.T) {
+	palette := []uint32{NewSkin(255, 0, 0), NewSkin(0, 0, 255)}
+
+	tests := []struct {
+		name    string
+		skin    uint32
+		palette []uint32
+		want    uint32
+		err     error
+	}{
+		{"any color", 0x123456, nil, 0x123456, nil},
+		{"alpha masked", 0xFF123456, nil, 0x123456, nil},
+		{"in the palette", NewSkin(0, 0, 255), palette, NewSkin(0, 0, 255), nil},
+		{"in the palette with alpha", 0xFFFF0000, palette, NewSkin(255, 0, 0), nil},
+		{"closest is red", NewSkin(200, 10, 40), palette, NewSkin(255, 0, 0), ErrorSkinNotInPalette},
+		{"closest is blue", NewSkin(10, 60, 200), palette, NewSkin(0, 0, 255), ErrorSkinNotInPalette},
+	}
+	for _, test := range tests {
+		skin, err := ValidateSkin(test.skin, test.palette)
+		if skin != test.want || !errors.Is(err, test.err) {
+			t.Errorf("%s: ValidateSkin(%08x) = %06x, %v, want %06x, %v", test.name, test.skin, skin, err, test.want, test.err)
+		}
+	}
+}
+
+func TestSkinPalette(t *testing.T) {
+	config := testConfig()
+	config.SkinPalette = []uint32{NewSkin(0, 255, 0)}
+	g := newTestGame(t, config)
+
+	player := joinTestPlayer(t, g, STARTING_MASS, 1000, 1000)
+	player.UpdateColor(NewSkin(40, 200, 40))
+	if player.Color != NewSkin(0, 255, 0) {
+		t.Errorf("player of a game with a palette picked %06x, want %06x", player.Color, NewSkin(0, 255, 0))
+	}
+
+	// Players of other games pick what they want.
+	other := joinTestPlayer(t, newTestGame(t, testConfig()), STARTING_MASS, 1000, 1000)
+	other.UpdateColor(NewSkin(40, 200, 40))
+	if other.Color != NewSkin(40, 200, 40) {
+		t.Errorf("player of a game without a palette picked %06x, want %06x", other.Color, NewSkin(40, 200, 40))
+	}
+
+	config.SkinPalette = []uint32{0x01000000}
+	if err := config.Validate(); !errors.Is(err, ErrorInvalidConfig) {
+		t.Errorf("Validate with an alpha palette color: got %v, want %v", err, ErrorInvalidConfig)
+	}
+}
@@ -0,0 +1,152 @@
+package galaxy
+
+import (
+	"sync"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// SPECTATOR_SPEED is the speed of a free roaming camera, in world units per
+// second.
+const SPECTATOR_SPEED = 800
+
+// Spectator is a client watching a game without playing it. Its camera
+// follows the leader of the game until it sends a direction, then it roams
+// freely until it sends ActionSplit.
+type Spectator struct {
+	sync.Mutex
+	ID     uuid.UUID
+	Camera utils.Vector2D
+
+	// roaming is true while the camera moves by input instead of following
+	// the leader.
+	roaming   bool
+	direction utils.Vector2D
+
+	conn     ClientConnection
+	encoder  *DeltaEncoder
+	removals removals
+
+	// target is the player the spectator observes, uuid.Nil for none, see
+	// Game.Observe.
+	target uuid.UUID
+
+	// protocol is the protocol version negotiated with conn, see handshake.
+	protocol uint16
+}
+
+// SetInput moves the camera of the spectator, see Spectator.
+func (s *Spectator) SetInput(input PlayerInput) {
+	s.Lock()
+	defer s.Unlock()
+
+	if input.Actions&ActionSplit != 0 {
+		s.roaming = false
+		s.direction = utils.Vector2D{}
+		return
+	}
+	if input.Direction != (utils.Vector2D{}) {
+		s.roaming = true
+	}
+	s.direction = input.Direction
+}
+
+func (s *Spectator) move(dt time.Duration, bounds arena) {
+	s.Lock()
+	if s.roaming {
+		s.Camera = bounds.Clamp(s.Camera.Add(s.direction.Scale(SPECTATOR_SPEED * dt.Seconds())))
+	}
+	s.Unlock()
+}
+
+// follow centers the camera on position, unless the spectator is roaming,
+// and returns where the camera is.
+func (s *Spectator) follow(position utils.Vector2D, found bool) utils.Vector2D {
+	s.Lock()
+	defer s.Unlock()
+	if !s.roaming && found {
+		s.Camera = position
+	}
+	return s.Camera
+}
+
+// SendBinary sends an encoded frame to the spectator's client, if
+// connected.
+func (s *Spectator) SendBinary(data []byte) error {
+	s.Lock()
+	conn := s.conn
+	s.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.SendBinary(data)
+}
+
+func (s *Spectator) saturated() bool {
+	s.Lock()
+	conn := s.conn
+	s.Unlock()
+	return isSaturated(conn)
+}
+
+// AddSpectator makes s receive the broadcasts of the game. Spectators are
+// not players, they don't collide nor count as players.
+func (g *Game) AddSpectator(s *Spectator) {
+	s.Lock()
+	s.Camera = g.arena().Clamp(s.Camera)
+	s.Unlock()
+
+	g.Lock()
+	g.spectators[s.ID] = s
+	g.spectatorCount.Store(int64(len(g.spectators)))
+	g.Unlock()
+}
+
+func (g *Game) RemoveSpectator(id uuid.UUID) {
+	g.Lock()
+	delete(g.spectators, id)
+	g.spectatorCount.Store(int64(len(g.spectators)))
+	g.Unlock()
+}
+
+// PlayerCount returns the number of human players in the game, split
+// cells, bots and spectators excluded, for matchmaking to see how many
+// seats real players can still take. It doesn't take the lock.
+func (g *Game) PlayerCount() int {
+	return g.humans()
+}
+
+// TotalCount returns the number of players, bots and spectators of the
+// game, split cells excluded. It doesn't take the lock.
+func (g *Game) TotalCount() int {
+	return int(g.humanCount.Load() + g.botCount.Load() + g.spectatorCount.Load())
+}
+
+func (g *Game) SpectatorCount() int {
+	return int(g.spectatorCount.Load())
+}
+
+// leaderPosition returns the position of the best player of the game.
+func (g *Game) leaderPosition() (utils.Vector2D, bool) {
+	leaders := g.leaderboard(1)
+	if len(leaders) == 0 {
+		return utils.Vector2D{}, false
+	}
+	return g.players[leaders[0].PlayerID].GetPosition(), true
+}
+
+func (g *Game) encodeSpectatorView(s *Spectator, entities []Entity) []byte {
+	if g.config.KeyframeInterval <= 0 {
+		return g.encodeEntities(entities)
+	}
+
+	s.Lock()
+	defer s.Unlock()
+	if s.encoder == nil {
+		s.encoder = NewDeltaEncoder(g.config.KeyframeInterval)
+	}
+	return s.encoder.Encode(entities)
+}
@@ -0,0 +1,86 @@
+package galaxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestCountsUnderConcurrentJoinsAndLeaves(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+
+	// Each worker adds players and spectators, and removes every other.
+	const workers, joins = 8, 50
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range joins {
+				player := NewPlayer(uuid.New(), nil)
+				player.PlayerID = uuid.New()
+				g.AddPlayer(player)
+				spectator := &Spectator{ID: uuid.New()}
+				g.AddSpectator(spectator)
+				if i%2 == 0 {
+					g.RemovePlayer(player.PlayerID)
+					g.RemoveSpectator(spectator.ID)
+				}
+			}
+		}()
+	}
+	stop := make(chan struct{})
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if players, total := g.PlayerCount(), g.TotalCount(); players < 0 || total < players {
+				t.Errorf("%d players of %d in total", players, total)
+				return
+			}
+			g.Tick(time.Millisecond)
+		}
+	}()
+	wg.Wait()
+	close(stop)
+	<-read
+
+	const left = workers * joins / 2
+	if players := g.PlayerCount(); players != left {
+		t.Errorf("PlayerCount() = %d, want %d", players, left)
+	}
+	if spectators := g.SpectatorCount(); spectators != left {
+		t.Errorf("SpectatorCount() = %d, want %d", spectators, left)
+	}
+	if total := g.TotalCount(); total != 2*left {
+		t.Errorf("TotalCount() = %d, want %d", total, 2*left)
+	}
+}
+
+func TestCountsTellBotsApart(t *testing.T) {
+	config := quietConfig()
+	config.Bots = 3
+	config.MaxPlayers = 10
+	g := newTestGame(t, config)
+	g.Tick(time.Millisecond)
+	if players, total := g.PlayerCount(), g.TotalCount(); players != 0 || total != 3 {
+		t.Fatalf("%d players of %d in total with only bots, want 0 of 3", players, total)
+	}
+
+	// A human takes the seat of a bot, split cells count for nothing.
+	human := joinTestPlayer(t, g, 1000, 5000, 5000)
+	if err := g.SplitPlayer(human.PlayerID); err != nil {
+		t.Fatalf("SplitPlayer: %v", err)
+	}
+	g.Tick(time.Millisecond)
+	if players, total := g.PlayerCount(), g.TotalCount(); players != 1 || total != 3 {
+		t.Errorf("%d players of %d in total with a human, want 1 of 3", players, total)
+	}
+}
@@ -0,0 +1,266 @@
+package galaxy
+
+import (
+	"fmt"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	// MIN_SPLIT_MASS is the smallest mass a player can split at, so that
+	// both halves are at least a starting player.
+	MIN_SPLIT_MASS = 2 * STARTING_MASS
+
+	// DEFAULT_MAX_CELLS is the most cells a player has at once by default,
+	// see GameConfig.MaxCells.
+	DEFAULT_MAX_CELLS = 16
+
+	// SPLIT_SPEED is the initial speed, in world units per second, at which
+	// a split cell is shot forward.
+	SPLIT_SPEED = 1200
+
+	// SPLIT_IMPULSE_DAMPING is how fast a split cell loses its initial
+	// speed, a rate per second.
+	SPLIT_IMPULSE_DAMPING = 4
+
+	// SPLIT_MERGE_COOLDOWN is how long split cells must wait before they
+	// can merge back.
+	SPLIT_MERGE_COOLDOWN = 10 * time.Second
+
+	// SAME_SPOT_EPSILON is how close, in world units, a cell heading back
+	// to its owner must be to count as on top of it.
+	SAME_SPOT_EPSILON = 1e-6
+)
+
+var (
+	ErrorSplitTooSmall = fmt.Errorf("Player is too small to split")
+	ErrorAlreadySplit  = fmt.Errorf("Player is already split")
+	ErrorTooManyCells  = fmt.Errorf("Player has too many cells")
+)
+
+// Owner returns the ID of the player that controls p: its own PlayerID, or
+// OwnerID for a split cell.
+func (p *Player) Owner() uuid.UUID {
+	if p.OwnerID != uuid.Nil {
+		return p.OwnerID
+	}
+	return p.PlayerID
+}
+
+// IsCell reports whether p is a cell split from another player.
+func (p *Player) IsCell() bool {
+	return p.OwnerID != uuid.Nil
+}
+
+// Split halves the mass of p and returns the other half as a new cell, shot
+// forward in the direction p is moving. The cell has no ID yet, it gets
+// one when the caller adds it to the game, see Game.SplitPlayer. Players split again and again
+// until they have MaxCells cells, failing with ErrorTooManyCells, and
+// can't split below MinSplitMass. Cells themselves can't split.
+func (p *Player) Split() (*Player, error) {
+	p.Lock()
+	defer p.Unlock()
+
+	rules := p.massRules()
+	if p.IsCell() {
+		return nil, ErrorAlreadySplit
+	}
+	if 1+p.splitCells >= rules.maxCells {
+		return nil, ErrorTooManyCells
+	}
+	if p.Mass < rules.minSplit {
+		return nil, ErrorSplitTooSmall
+	}
+
+	dir := p.direction.Normalize()
+	if dir == (utils.Vector2D{}) {
+		dir = utils.Vector2D{X: 1}
+	}
+
+	p.splitCells++
+	return p.splitOff(p.PlayerID, p.Mass/2, dir), nil
+}
+
+// splitOff takes mass from p into a new cell of owner without an ID, shot
+// in the direction dir. The caller must hold the lock of p.
+func (p *Player) splitOff(owner uuid.UUID, mass uint64, dir utils.Vector2D) *Player {
+	p.Mass -= mass
+	p.recomputeRadius()
+	p.mergeCooldown = SPLIT_MERGE_COOLDOWN
+
+	cell := &Player{
+		OwnerID:       owner,
+		ConnectionID:  p.ConnectionID,
+		Position:      p.Position.Add(dir.Scale(float64(p.Radius))),
+		Mass:          mass,
+		Alive:         true,
+		Username:      p.Username,
+		Color:         p.Color,
+		Skin:          p.Skin,
+		TeamID:        p.TeamID,
+		direction:     p.direction,
+		impulse:       dir.Scale(SPLIT_SPEED),
+		mergeCooldown: SPLIT_MERGE_COOLDOWN,
+		protection:    p.protection,
+		rules:         p.rules,
+	}
+	cell.recomputeRadius()
+	return cell
+}
+
+// canMerge reports whether the merge cooldown of p is over.
+func (p *Player) canMerge() bool {
+	p.RLock()
+	defer p.RUnlock()
+	return p.mergeCooldown <= 0
+}
+
+// cooldown advances the timers of p by dt.
+func (p *Player) cooldown(dt time.Duration) {
+	p.Lock()
+	p.mergeCooldown = max(0, p.mergeCooldown-dt)
+	p.clock += dt
+	p.boostLeft = max(0, p.boostLeft-dt)
+	p.protection = max(0, p.protection-dt)
+	p.Unlock()
+}
+
+// SplitPlayer splits the player with the given ID and adds the new cell to
+// the game.
+func (g *Game) SplitPlayer(id uuid.UUID) error {
+	g.Lock()
+	defer g.Unlock()
+
+	player, exists := g.players[id]
+	if !exists {
+		return ErrorPlayerNotFound
+	}
+	return g.split(player)
+}
+
+// split splits player, the caller must hold the lock.
+func (g *Game) split(player *Player) error {
+	cell, err := player.Split()
+	if err != nil {
+		return err
+	}
+	cell.PlayerID = g.newID()
+	cell.Position = g.arena().Clamp(cell.Position)
+
+	g.players[cell.PlayerID] = cell
+	g.reindex(player)
+	g.reindex(cell)
+	return nil
+}
+
+// owner returns the player controlling the split cell p, if p is a cell
+// and its owner is still in the game.
+func (g *Game) owner(p *Player) (*Player, bool) {
+	if !p.IsCell() {
+		return nil, false
+	}
+	owner, exists := g.players[p.OwnerID]
+	return owner, exists
+}
+
+// direction returns where p moves: split cells follow their owner, and
+// head back to it once they can merge.
+func (g *Game) direction(p *Player) utils.Vector2D {
+	owner, exists := g.owner(p)
+	if !exists {
+		return p.Direction()
+	}
+	if p.canMerge() && owner.canMerge() {
+		// A cell already on top of its owner would jitter around it
+		// chasing a direction rounding errors make up.
+		position, target := p.GetPosition(), owner.GetPosition()
+		if position.EqualWithin(target, SAME_SPOT_EPSILON) {
+			return utils.Vector2D{}
+		}
+		return target.Sub(position)
+	}
+	return owner.Direction()
+}
+
+// cells returns the split cells of the player with the given ID.
+func (g *Game) cells(owner uuid.UUID) []*Player {
+	var cells []*Player
+	for _, player := range inOrder(g, g.players) {
+		if player.OwnerID == owner {
+			cells = append(cells, player)
+		}
+	}
+	return cells
+}
+
+// removeCell removes the split cell p, the caller must hold the lock.
+func (g *Game) removeCell(p *Player) {
+	if owner, exists := g.owner(p); exists {
+		owner.Lock()
+		owner.splitCells--
+		owner.Unlock()
+	}
+	g.removePlayer(p.PlayerID)
+}
+
+// merge fuses every split cell whose cooldown is over back into its owner
+// once both overlap, or into another cell of the owner it overlaps whose
+// cooldown is over too, the bigger one absorbing the smaller. Mass is
+// conserved. It returns the IDs of the merged cells.
+func (g *Game) merge() []uuid.UUID {
+	// Owners in the order of their first cell, so merges happen in the
+	// same order given the same state.
+	var owners []uuid.UUID
+	groups := make(map[uuid.UUID][]*Player)
+	for _, player := range inOrder(g, g.players) {
+		if player.IsCell() && player.canMerge() {
+			if _, exists := groups[player.OwnerID]; !exists {
+				owners = append(owners, player.OwnerID)
+			}
+			groups[player.OwnerID] = append(groups[player.OwnerID], player)
+		}
+	}
+
+	var merged []uuid.UUID
+	fuse := func(into *Player, cell *Player) {
+		into.addMass(cell.Score())
+		g.removeCell(cell)
+		g.reindex(into)
+		merged = append(merged, cell.PlayerID)
+	}
+
+	for _, ownerID := range owners {
+		cells := groups[ownerID]
+		owner, exists := g.players[ownerID]
+		if !exists {
+			continue
+		}
+		ownerCanMerge := owner.canMerge()
+
+		for i, cell := range cells {
+			if cell == nil {
+				continue
+			}
+			if ownerCanMerge && owner.Overlaps(cell) {
+				fuse(owner, cell)
+				continue
+			}
+
+			for j := i + 1; j < len(cells); j++ {
+				other := cells[j]
+				if other == nil || !cell.Overlaps(other) {
+					continue
+				}
+				if other.Score() > cell.Score() {
+					fuse(other, cell)
+					break
+				}
+				fuse(cell, other)
+				cells[j] = nil
+			}
+		}
+	}
+	return merged
+}
@@ -0,0 +1,102 @@
+package galaxy
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// totalMass returns the mass of every player of g, split cells included,
+// and how many there are.
+func totalMass(g *Game) (uint64, int) {
+	var mass uint64
+	var count int
+	g.ForEachPlayer(func(p PlayerView) {
+		mass += p.Mass
+		count++
+	})
+	return mass, count
+}
+
+func TestSplitAndMergeConserveMass(t *testing.T) {
+	g := newTestGame(t, quietConfig())
+	player := joinTestPlayer(t, g, 1000, 5000, 5000)
+	player.SetDirection(utils.Vector2D{X: 1})
+
+	for range 3 {
+		if err := g.SplitPlayer(player.PlayerID); err != nil {
+			t.Fatalf("SplitPlayer: %v", err)
+		}
+	}
+	if mass, count := totalMass(g); mass != 1000 || count != 4 {
+		t.Fatalf("%d cells of total mass %d after splitting, want 4 of 1000", count, mass)
+	}
+
+	player.SetDirection(utils.Vector2D{})
+	for step := 0; ; step++ {
+		if step*50 > int((4 * SPLIT_MERGE_COOLDOWN).Milliseconds()) {
+			t.Fatal("cells never merged back")
+		}
+		g.Tick(50 * time.Millisecond)
+		mass, count := totalMass(g)
+		if mass != 1000 {
+			t.Fatalf("total mass %d after %d ticks, want 1000", mass, step+1)
+		}
+		if count == 1 {
+			break
+		}
+	}
+	if player.Score() != 1000 {
+		t.Errorf("merged player has mass %d, want 1000", player.Score())
+	}
+}
+
+func TestSplitGates(t *testing.T) {
+	config := quietConfig()
+	config.MinSplitMass = 100
+	config.MaxCells = 3
+
+	t.Run("min split mass", func(t *testing.T) {
+		g := newTestGame(t, config)
+		player := joinTestPlayer(t, g, config.MinSplitMass-1, 5000, 5000)
+		if err := g.SplitPlayer(player.PlayerID); !errors.Is(err, ErrorSplitTooSmall) {
+			t.Errorf("splitting under MinSplitMass: got %v, want %v", err, ErrorSplitTooSmall)
+		}
+		if mass, count := totalMass(g); mass != config.MinSplitMass-1 || count != 1 {
+			t.Errorf("refused split left %d cells of total mass %d", count, mass)
+		}
+
+		player.Mass = config.MinSplitMass
+		if err := g.SplitPlayer(player.PlayerID); err != nil {
+			t.Errorf("splitting at MinSplitMass: %v", err)
+		}
+	})
+
+	t.Run("max cells", func(t *testing.T) {
+		g := newTestGame(t, config)
+		player := joinTestPlayer(t, g, 1000, 5000, 5000)
+		for range config.MaxCells - 1 {
+			if err := g.SplitPlayer(player.PlayerID); err != nil {
+				t.Fatalf("SplitPlayer under MaxCells: %v", err)
+			}
+		}
+		if err := g.SplitPlayer(player.PlayerID); !errors.Is(err, ErrorTooManyCells) {
+			t.Errorf("splitting at MaxCells: got %v, want %v", err, ErrorTooManyCells)
+		}
+		if mass, count := totalMass(g); mass != 1000 || count != config.MaxCells {
+			t.Errorf("refused split left %d cells of total mass %d, want %d of 1000", count, mass, config.MaxCells)
+		}
+	})
+
+	t.Run("cells", func(t *testing.T) {
+		g := newTestGame(t, config)
+		player := joinTestPlayer(t, g, 1000, 5000, 5000)
+		g.SplitPlayer(player.PlayerID)
+		cell := g.cells(player.PlayerID)[0]
+		if err := g.SplitPlayer(cell.PlayerID); !errors.Is(err, ErrorAlreadySplit) {
+			t.Errorf("splitting a cell: got %v, want %v", err, ErrorAlreadySplit)
+		}
+	})
+}
@@ -0,0 +1,74 @@
+package galaxy
+
+import "time"
+
+// GameStats summarizes the state of a game for operators.
+type GameStats struct {
+	// Players is the number of players, not counting split cells, and
+	// Food the number of pellets.
+	Players int
+	Food    int
+
+	// TotalMass is the mass of every living player and cell.
+	TotalMass uint64
+
+	// Leader is the username of the top player, empty without players.
+	Leader string
+
+	// TickRate is the configured number of ticks per second, AverageTick
+	// the mean duration of the ticks run so far.
+	TickRate    int
+	AverageTick time.Duration
+}
+
+// worldStats are the counters of GameStats maintained by Tick.
+type worldStats struct {
+	players   int
+	food      int
+	totalMass uint64
+	leader    string
+}
+
+// Stats summarizes the game. The world counters are those of the end of
+// the latest tick, so the values are consistent with each other even while
+// the game ticks concurrently. It doesn't scan the world, it is cheap
+// enough to serve an admin endpoint.
+func (g *Game) Stats() GameStats {
+	g.statsMutex.Lock()
+	defer g.statsMutex.Unlock()
+
+	stats := GameStats{
+		Players:   g.worldStats.players,
+		Food:      g.worldStats.food,
+		TotalMass: g.worldStats.totalMass,
+		Leader:    g.worldStats.leader,
+		TickRate:  g.config.TickRate,
+	}
+	if g.tickStats.Ticks > 0 {
+		stats.AverageTick = g.tickTotal / time.Duration(g.tickStats.Ticks)
+	}
+	return stats
+}
+
+// updateStats refreshes the counters of Stats, the caller must hold the
+// lock and have tracked the leader.
+func (g *Game) updateStats() {
+	stats := worldStats{food: len(g.food)}
+	for _, player := range g.players {
+		if !player.IsCell() {
+			stats.players++
+		}
+		if player.IsAlive() {
+			stats.totalMass += player.Score()
+		}
+	}
+	if leader, exists := g.players[g.leader]; exists {
+		leader.RLock()
+		stats.leader = leader.Username
+		leader.RUnlock()
+	}
+
+	g.statsMutex.Lock()
+	g.worldStats = stats
+	g.statsMutex.Unlock()
+}
@@ -0,0 +1,69 @@
+package galaxy
+
+import (
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// TeamColors are the skins of the teams, team i is drawn with
+// TeamColors[(i-1) % len(TeamColors)].
+var TeamColors = []uint32{
+	Red,
+	Blue,
+	Green,
+	Yellow,
+	Purple,
+	Orange,
+}
+
+var ErrorNoTeams = fmt.Errorf("Game is not in team mode")
+
+// TeamColor returns the skin of a team.
+func TeamColor(team uint8) uint32 {
+	return TeamColors[int(team-1)%len(TeamColors)]
+}
+
+// AssignTeam puts the player with the given ID, and its split cells, in
+// the team with the fewest players and gives them its color. It returns the
+// team, see GameConfig.Teams.
+func (g *Game) AssignTeam(id uuid.UUID) (uint8, error) {
+	g.Lock()
+	defer g.Unlock()
+
+	player, exists := g.players[id]
+	if !exists {
+		return 0, ErrorPlayerNotFound
+	}
+	team, err := g.assignTeam(player)
+	return team, err
+}
+
+// assignTeam implements AssignTeam, the caller must hold the lock.
+func (g *Game) assignTeam(player *Player) (uint8, error) {
+	if g.config.Teams <= 0 {
+		return 0, ErrorNoTeams
+	}
+
+	sizes := make([]int, g.config.Teams+1)
+	for _, other := range g.players {
+		if other != player && !other.IsCell() && other.TeamID != 0 {
+			sizes[other.TeamID]++
+		}
+	}
+
+	team := uint8(1)
+	for i := 2; i <= g.config.Teams; i++ {
+		if sizes[i] < sizes[team] {
+			team = uint8(i)
+		}
+	}
+
+	for _, p := range append([]*Player{player}, g.cells(player.PlayerID)...) {
+		p.Lock()
+		p.TeamID = team
+		p.Color = TeamColor(team)
+		p.Unlock()
+	}
+	return team, nil
+}
@@ -0,0 +1,62 @@
+package galaxy
+
+import (
+	"log"
+	"time"
+)
+
+// TickStats tells how the tick loop of a game keeps up with its budget,
+// the tick interval.
+type TickStats struct {
+	// Ticks is the number of ticks run.
+	Ticks uint64
+
+	// Overruns is the number of ticks that took longer than the budget.
+	Overruns uint64
+
+	// Worst is the duration of the slowest tick.
+	Worst time.Duration
+
+	// Last is the duration of the latest tick.
+	Last time.Duration
+}
+
+// TickStats returns how the tick loop is keeping up. The duration of a tick
+// covers the simulation and the broadcast that follows it.
+func (g *Game) TickStats() TickStats {
+	g.statsMutex.Lock()
+	defer g.statsMutex.Unlock()
+	return g.tickStats
+}
+
+// SetOnSlowTick registers a function called after every tick that took
+// longer than budget, e.g. to alert operators.
+func (g *Game) SetOnSlowTick(onSlowTick func(took time.Duration, budget time.Duration)) {
+	g.statsMutex.Lock()
+	g.onSlowTick = onSlowTick
+	g.statsMutex.Unlock()
+}
+
+// observeTick records the duration of a tick, the caller must not hold the
+// lock.
+func (g *Game) observeTick(took time.Duration, budget time.Duration) {
+	g.statsMutex.Lock()
+	g.tickStats.Ticks++
+	g.tickStats.Last = took
+	g.tickTotal += took
+	g.tickStats.Worst = max(g.tickStats.Worst, took)
+	slow := took > budget
+	if slow {
+		g.tickStats.Overruns++
+	}
+	onSlowTick := g.onSlowTick
+	g.statsMutex.Unlock()
+
+	if !slow {
+		return
+	}
+	log.Printf("slow tick in room %q, took %v of %v", g.room, took, budget)
+	if onSlowTick != nil {
+		onSlowTick(took, budget)
+	}
+}
@@ -0,0 +1,45 @@
+package utils
+
+import "math"
+
+// Circle is a disc of Radius around Center.
+type Circle struct {
+	Center Vector2D
+	Radius float64
+}
+
+// Contains reports whether point lies inside the circle, edge included.
+func (c Circle) Contains(point Vector2D) bool {
+	return point.DistanceSquared(c.Center) <= c.Radius*c.Radius
+}
+
+// Clamp returns the point of the circle closest to point, pulling points
+// outside back towards the center along the radius they are on. A point
+// pushed past the edge at an angle keeps the tangential part of its move,
+// sliding along the edge instead of stopping.
+func (c Circle) Clamp(point Vector2D) Vector2D {
+	offset := point.Sub(c.Center)
+	distanceSquared := offset.LengthSquared()
+	if distanceSquared <= c.Radius*c.Radius {
+		return point
+	}
+	return c.Center.Add(offset.Scale(c.Radius / math.Sqrt(distanceSquared)))
+}
+
+// Bounce clamps position to the circle like Clamp and reflects velocity off
+// the edge if position went past it, see Rect.Bounce.
+func (c Circle) Bounce(position Vector2D, velocity Vector2D) (Vector2D, Vector2D) {
+	if c.Contains(position) {
+		return position, velocity
+	}
+	normal := c.Center.Sub(position).Normalize()
+	if velocity.Dot(normal) < 0 {
+		velocity = velocity.Reflect(normal)
+	}
+	return c.Clamp(position), velocity
+}
+
+// BoundingRect returns the smallest rectangle holding the circle.
+func (c Circle) BoundingRect() Rect {
+	return RectAround(c.Center, c.Radius, c.Radius)
+}
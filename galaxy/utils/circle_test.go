@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCircleClamp(t *testing.T) {
+	c := Circle{Center: Vector2D{X: 100, Y: 100}, Radius: 50}
+
+	tests := []struct {
+		name        string
+		point, want Vector2D
+	}{
+		{"center", Vector2D{X: 100, Y: 100}, Vector2D{X: 100, Y: 100}},
+		{"inside", Vector2D{X: 120, Y: 80}, Vector2D{X: 120, Y: 80}},
+		{"on the edge", Vector2D{X: 150, Y: 100}, Vector2D{X: 150, Y: 100}},
+		{"past the right", Vector2D{X: 200, Y: 100}, Vector2D{X: 150, Y: 100}},
+		{"past the top", Vector2D{X: 100, Y: 0}, Vector2D{X: 100, Y: 50}},
+		{"past a diagonal", Vector2D{X: 200, Y: 200}, Vector2D{X: 100 + 50/math.Sqrt2, Y: 100 + 50/math.Sqrt2}},
+	}
+	for _, test := range tests {
+		if got := c.Clamp(test.point); got.Distance(test.want) > 1e-9 {
+			t.Errorf("%s: Clamp(%v) = %v, want %v", test.name, test.point, got, test.want)
+		}
+	}
+}
+
+func TestCircleBounce(t *testing.T) {
+	c := Circle{Radius: 10}
+
+	position, velocity := c.Bounce(Vector2D{X: 12}, Vector2D{X: 3, Y: 4})
+	if position != (Vector2D{X: 10}) || velocity != (Vector2D{X: -3, Y: 4}) {
+		t.Errorf("Bounce past the edge = %v, %v, want %v, %v", position, velocity, Vector2D{X: 10}, Vector2D{X: -3, Y: 4})
+	}
+	// Moving back in already, the velocity is kept.
+	if _, velocity := c.Bounce(Vector2D{X: 12}, Vector2D{X: -3, Y: 4}); velocity != (Vector2D{X: -3, Y: 4}) {
+		t.Errorf("Bounce moving back in changed the velocity to %v", velocity)
+	}
+}
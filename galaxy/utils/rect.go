@@ -0,0 +1,63 @@
+package utils
+
+// Rect is an axis aligned rectangle spanning from Min to Max.
+type Rect struct {
+	Min Vector2D
+	Max Vector2D
+}
+
+// RectAround returns the rectangle centered on center extending halfWidth
+// and halfHeight in each direction.
+func RectAround(center Vector2D, halfWidth float64, halfHeight float64) Rect {
+	return Rect{
+		Min: Vector2D{X: center.X - halfWidth, Y: center.Y - halfHeight},
+		Max: Vector2D{X: center.X + halfWidth, Y: center.Y + halfHeight},
+	}
+}
+
+func (r Rect) Width() float64 {
+	return r.Max.X - r.Min.X
+}
+
+func (r Rect) Height() float64 {
+	return r.Max.Y - r.Min.Y
+}
+
+// Clamp returns the point of the rectangle closest to point.
+func (r Rect) Clamp(point Vector2D) Vector2D {
+	return point.Clamp(r.Min, r.Max)
+}
+
+// Contains reports whether point lies inside the rectangle, edges included.
+func (r Rect) Contains(point Vector2D) bool {
+	return point.X >= r.Min.X && point.X <= r.Max.X &&
+		point.Y >= r.Min.Y && point.Y <= r.Max.Y
+}
+
+// Intersects reports whether the two rectangles share any point.
+func (r Rect) Intersects(other Rect) bool {
+	return r.Min.X <= other.Max.X && other.Min.X <= r.Max.X &&
+		r.Min.Y <= other.Max.Y && other.Min.Y <= r.Max.Y
+}
+
+// IntersectsCircle reports whether the circle touches the rectangle.
+func (r Rect) IntersectsCircle(center Vector2D, radius float64) bool {
+	return r.Clamp(center).DistanceSquared(center) <= radius*radius
+}
+
+// Bounce clamps position to the rectangle like Clamp and reflects velocity
+// off the edges position went past, so what hits a wall moves away from it
+// at the same speed instead of sliding along it.
+func (r Rect) Bounce(position Vector2D, velocity Vector2D) (Vector2D, Vector2D) {
+	if position.X < r.Min.X && velocity.X < 0 {
+		velocity = velocity.Reflect(Vector2D{X: 1})
+	} else if position.X > r.Max.X && velocity.X > 0 {
+		velocity = velocity.Reflect(Vector2D{X: -1})
+	}
+	if position.Y < r.Min.Y && velocity.Y < 0 {
+		velocity = velocity.Reflect(Vector2D{Y: 1})
+	} else if position.Y > r.Max.Y && velocity.Y > 0 {
+		velocity = velocity.Reflect(Vector2D{Y: -1})
+	}
+	return r.Clamp(position), velocity
+}
@@ -0,0 +1,25 @@
+package utils
+
+import "testing"
+
+func TestBounceOffEdges(t *testing.T) {
+	r := Rect{Max: Vector2D{X: 100, Y: 100}}
+
+	tests := []struct {
+		name                       string
+		position, velocity         Vector2D
+		wantPosition, wantVelocity Vector2D
+	}{
+		{"inside", Vector2D{X: 50, Y: 50}, Vector2D{X: -5, Y: 5}, Vector2D{X: 50, Y: 50}, Vector2D{X: -5, Y: 5}},
+		{"past left", Vector2D{X: -2, Y: 50}, Vector2D{X: -5, Y: 5}, Vector2D{X: 0, Y: 50}, Vector2D{X: 5, Y: 5}},
+		{"past right", Vector2D{X: 102, Y: 50}, Vector2D{X: 5, Y: 5}, Vector2D{X: 100, Y: 50}, Vector2D{X: -5, Y: 5}},
+		{"past bottom right corner", Vector2D{X: 102, Y: 103}, Vector2D{X: 5, Y: 5}, Vector2D{X: 100, Y: 100}, Vector2D{X: -5, Y: -5}},
+		{"past left moving back in", Vector2D{X: -2, Y: 50}, Vector2D{X: 5, Y: 0}, Vector2D{X: 0, Y: 50}, Vector2D{X: 5, Y: 0}},
+	}
+	for _, test := range tests {
+		position, velocity := r.Bounce(test.position, test.velocity)
+		if position != test.wantPosition || velocity != test.wantVelocity {
+			t.Errorf("%s: Bounce(%v, %v) = %v, %v, want %v, %v", test.name, test.position, test.velocity, position, velocity, test.wantPosition, test.wantVelocity)
+		}
+	}
+}
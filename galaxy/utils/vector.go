@@ -0,0 +1,125 @@
+// Package utils holds the math helpers used by the game simulation.
+package utils
+
+import "math"
+
+// Vector2D is a point or direction in world space. Unlike galaxy.Vector2D,
+// which mirrors the integer protobuf wire format, it uses floating point
+// coordinates so movement and collisions don't accumulate rounding errors.
+type Vector2D struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+func (v Vector2D) Add(other Vector2D) Vector2D {
+	return Vector2D{X: v.X + other.X, Y: v.Y + other.Y}
+}
+
+func (v Vector2D) Sub(other Vector2D) Vector2D {
+	return Vector2D{X: v.X - other.X, Y: v.Y - other.Y}
+}
+
+func (v Vector2D) Scale(f float64) Vector2D {
+	return Vector2D{X: v.X * f, Y: v.Y * f}
+}
+
+func (v Vector2D) Dot(other Vector2D) float64 {
+	return v.X*other.X + v.Y*other.Y
+}
+
+// Length doesn't overflow nor underflow for vectors whose squared length
+// would.
+func (v Vector2D) Length() float64 {
+	return math.Hypot(v.X, v.Y)
+}
+
+// LengthSquared avoids the square root of Length, use it when only comparing
+// magnitudes.
+func (v Vector2D) LengthSquared() float64 {
+	return v.Dot(v)
+}
+
+// Normalize returns the unit vector pointing in the same direction as v.
+// The zero vector has no direction and normalizes to itself.
+func (v Vector2D) Normalize() Vector2D {
+	length := v.Length()
+	if length == 0 {
+		return Vector2D{}
+	}
+	return v.Scale(1 / length)
+}
+
+func (v Vector2D) Distance(other Vector2D) float64 {
+	return v.Sub(other).Length()
+}
+
+// DistanceSquared avoids the square root of Distance, collision checks
+// should compare it against squared radii.
+func (v Vector2D) DistanceSquared(other Vector2D) float64 {
+	return v.Sub(other).LengthSquared()
+}
+
+// Clamp limits each coordinate of v to the range given by min and max.
+// Clamping each axis on its own means a point pushed past an edge slides
+// along it instead of stopping.
+func (v Vector2D) Clamp(min Vector2D, max Vector2D) Vector2D {
+	return Vector2D{
+		X: math.Min(math.Max(v.X, min.X), max.X),
+		Y: math.Min(math.Max(v.Y, min.Y), max.Y),
+	}
+}
+
+// EqualWithin reports whether v and other are at most eps apart on each
+// axis, floating point positions rarely being exactly equal.
+func (v Vector2D) EqualWithin(other Vector2D, eps float64) bool {
+	return math.Abs(v.X-other.X) <= eps && math.Abs(v.Y-other.Y) <= eps
+}
+
+// ClampLength returns v scaled down to a length of max in the same
+// direction, or v unchanged if it isn't longer than max.
+func (v Vector2D) ClampLength(max float64) Vector2D {
+	lengthSquared := v.LengthSquared()
+	if lengthSquared <= max*max {
+		return v
+	}
+	return v.Scale(max / math.Sqrt(lengthSquared))
+}
+
+// Lerp interpolates linearly from v to to, t is clamped to [0, 1]. It
+// returns exactly v for t = 0 and exactly to for t = 1.
+func (v Vector2D) Lerp(to Vector2D, t float64) Vector2D {
+	t = math.Min(math.Max(t, 0), 1)
+	return Vector2D{
+		X: v.X*(1-t) + to.X*t,
+		Y: v.Y*(1-t) + to.Y*t,
+	}
+}
+
+// FromAngle returns the unit vector at radians counterclockwise from the
+// positive X axis.
+func FromAngle(radians float64) Vector2D {
+	return Vector2D{X: math.Cos(radians), Y: math.Sin(radians)}
+}
+
+// Angle returns the angle of v in radians from the positive X axis, in
+// [-Pi, Pi]. The zero vector has angle 0.
+func (v Vector2D) Angle() float64 {
+	return math.Atan2(v.Y, v.X)
+}
+
+// Reflect returns v mirrored off a surface with the given normal, such as a
+// velocity bouncing off a wall. The normal must be a unit vector for the
+// length of v to be kept, the normals of axis aligned walls are those of
+// the axes.
+func (v Vector2D) Reflect(normal Vector2D) Vector2D {
+	return v.Sub(normal.Scale(2 * v.Dot(normal)))
+}
+
+// Rotate returns v rotated counterclockwise by radians, keeping its length.
+func (v Vector2D) Rotate(radians float64) Vector2D {
+	sin, cos := math.Sincos(radians)
+	return Vector2D{
+		X: v.X*cos - v.Y*sin,
+		Y: v.X*sin + v.Y*cos,
+	}
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+const eps = 1e-12
+
+func TestVectorArithmetic(t *testing.T) {
+	a, b := Vector2D{X: 3, Y: 4}, Vector2D{X: -1, Y: 2}
+
+	tests := []struct {
+		name string
+		got  Vector2D
+		want Vector2D
+	}{
+		{"Add", a.Add(b), Vector2D{X: 2, Y: 6}},
+		{"Sub", a.Sub(b), Vector2D{X: 4, Y: 2}},
+		{"Scale", a.Scale(0.5), Vector2D{X: 1.5, Y: 2}},
+		{"Scale by zero", a.Scale(0), Vector2D{}},
+		{"Normalize", a.Normalize(), Vector2D{X: 0.6, Y: 0.8}},
+		{"Normalize axis", Vector2D{X: 0, Y: -7}.Normalize(), Vector2D{X: 0, Y: -1}},
+		{"Normalize zero", Vector2D{}.Normalize(), Vector2D{}},
+	}
+	for _, test := range tests {
+		if !test.got.EqualWithin(test.want, eps) {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestVectorMagnitudes(t *testing.T) {
+	a, b := Vector2D{X: 3, Y: 4}, Vector2D{X: -1, Y: 2}
+
+	tests := []struct {
+		name string
+		got  float64
+		want float64
+	}{
+		{"Dot", a.Dot(b), 5},
+		{"Dot perpendicular", Vector2D{X: 1}.Dot(Vector2D{Y: 1}), 0},
+		{"Length", a.Length(), 5},
+		{"LengthSquared", a.LengthSquared(), 25},
+		{"Length zero", Vector2D{}.Length(), 0},
+		{"Distance", a.Distance(b), math.Sqrt(20)},
+		{"DistanceSquared", a.DistanceSquared(b), 20},
+		{"Distance to itself", a.Distance(a), 0},
+	}
+	for _, test := range tests {
+		if math.Abs(test.got-test.want) > eps {
+			t.Errorf("%s: got %v, want %v", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestNormalizeKeepsDirection(t *testing.T) {
+	for _, v := range []Vector2D{{X: 1e-300, Y: 1e-300}, {X: 1e300, Y: -1e300}, {X: -2, Y: 0}} {
+		n := v.Normalize()
+		if math.Abs(n.Length()-1) > 1e-9 {
+			t.Errorf("%v normalized to %v of length %v, want 1", v, n, n.Length())
+		}
+		if n.Dot(v) <= 0 {
+			t.Errorf("%v normalized to %v, pointing away", v, n)
+		}
+	}
+}
+
+func TestClampAtCorners(t *testing.T) {
+	min, max := Vector2D{X: 0, Y: 0}, Vector2D{X: 100, Y: 50}
+
+	tests := []struct {
+		name  string
+		point Vector2D
+		want  Vector2D
+	}{
+		{"inside", Vector2D{X: 40, Y: 20}, Vector2D{X: 40, Y: 20}},
+		{"past top left", Vector2D{X: -10, Y: -10}, Vector2D{X: 0, Y: 0}},
+		{"past top right", Vector2D{X: 110, Y: -10}, Vector2D{X: 100, Y: 0}},
+		{"past bottom left", Vector2D{X: -10, Y: 60}, Vector2D{X: 0, Y: 50}},
+		{"past bottom right", Vector2D{X: 110, Y: 60}, Vector2D{X: 100, Y: 50}},
+		{"past left edge only", Vector2D{X: -10, Y: 30}, Vector2D{X: 0, Y: 30}},
+	}
+	for _, test := range tests {
+		if got := test.point.Clamp(min, max); got != test.want {
+			t.Errorf("%s: Clamp(%v) = %v, want %v", test.name, test.point, got, test.want)
+		}
+	}
+}
+
+func TestReflectOffAxisWalls(t *testing.T) {
+	v := Vector2D{X: 3, Y: -4}
+
+	tests := []struct {
+		name   string
+		normal Vector2D
+		want   Vector2D
+	}{
+		{"left wall", Vector2D{X: 1}, Vector2D{X: -3, Y: -4}},
+		{"right wall", Vector2D{X: -1}, Vector2D{X: -3, Y: -4}},
+		{"top wall", Vector2D{Y: 1}, Vector2D{X: 3, Y: 4}},
+		{"bottom wall", Vector2D{Y: -1}, Vector2D{X: 3, Y: 4}},
+	}
+	for _, test := range tests {
+		if got := v.Reflect(test.normal); !got.EqualWithin(test.want, eps) {
+			t.Errorf("%s: Reflect(%v) = %v, want %v", test.name, test.normal, got, test.want)
+		}
+	}
+}
+
+func TestReflectKeepsLength(t *testing.T) {
+	v := Vector2D{X: -7.5, Y: 2.25}
+	for angle := 0.0; angle < 2*math.Pi; angle += 0.1 {
+		reflected := v.Reflect(FromAngle(angle))
+		if math.Abs(reflected.Length()-v.Length()) > 1e-9 {
+			t.Errorf("reflecting %v off a unit normal at %v changed its length to %v", v, angle, reflected.Length())
+		}
+	}
+}
+
+func TestLerp(t *testing.T) {
+	from, to := Vector2D{X: 0.1, Y: -3e7}, Vector2D{X: 0.7, Y: 1.0 / 3}
+
+	tests := []struct {
+		name string
+		t    float64
+		want Vector2D
+	}{
+		{"start", 0, from},
+		{"end", 1, to},
+		{"before the start", -2, from},
+		{"past the end", 5, to},
+	}
+	for _, test := range tests {
+		// The endpoints are exact, not merely close.
+		if got := from.Lerp(to, test.t); got != test.want {
+			t.Errorf("%s: Lerp(%v) = %v, want %v", test.name, test.t, got, test.want)
+		}
+	}
+
+	want := Vector2D{X: 0.4, Y: (-3e7 + 1.0/3) / 2}
+	if got := from.Lerp(to, 0.5); !got.EqualWithin(want, 1e-6) {
+		t.Errorf("Lerp(0.5) = %v, want %v", got, want)
+	}
+}
+
+func TestRotateAtCardinalAngles(t *testing.T) {
+	v := Vector2D{X: 2}
+
+	tests := []struct {
+		radians float64
+		want    Vector2D
+	}{
+		{0, Vector2D{X: 2}},
+		{math.Pi / 2, Vector2D{Y: 2}},
+		{math.Pi, Vector2D{X: -2}},
+		{3 * math.Pi / 2, Vector2D{Y: -2}},
+		{2 * math.Pi, Vector2D{X: 2}},
+		{-math.Pi / 2, Vector2D{Y: -2}},
+	}
+	for _, test := range tests {
+		if got := v.Rotate(test.radians); !got.EqualWithin(test.want, eps) {
+			t.Errorf("Rotate(%v) = %v, want %v", test.radians, got, test.want)
+		}
+		if got := FromAngle(test.radians).Scale(2); !got.EqualWithin(test.want, eps) {
+			t.Errorf("FromAngle(%v) = %v, want %v", test.radians, got, test.want)
+		}
+	}
+
+	for _, v := range []Vector2D{{X: 1}, {Y: 1}, {X: -1}, {Y: -1}} {
+		if got := FromAngle(v.Angle()); !got.EqualWithin(v, eps) {
+			t.Errorf("FromAngle(%v.Angle()) = %v", v, got)
+		}
+	}
+}
+
+func TestRotateComposes(t *testing.T) {
+	v := Vector2D{X: 3, Y: -4}
+	angles := []float64{0.1, -0.7, math.Pi / 3, 2.5, -math.Pi, 10}
+
+	for _, a := range angles {
+		for _, b := range angles {
+			if got, want := v.Rotate(a).Rotate(b), v.Rotate(a+b); !got.EqualWithin(want, 1e-9) {
+				t.Errorf("Rotate(%v).Rotate(%v) = %v, want Rotate(%v) = %v", a, b, got, a+b, want)
+			}
+		}
+		if got := v.Rotate(a).Length(); math.Abs(got-5) > 1e-9 {
+			t.Errorf("Rotate(%v) changed the length to %v", a, got)
+		}
+	}
+}
+
+func TestClampLength(t *testing.T) {
+	tests := []struct {
+		name string
+		v    Vector2D
+		max  float64
+		want Vector2D
+	}{
+		{"zero vector", Vector2D{}, 5, Vector2D{}},
+		{"zero vector to zero", Vector2D{}, 0, Vector2D{}},
+		{"exactly at max", Vector2D{X: 3, Y: 4}, 5, Vector2D{X: 3, Y: 4}},
+		{"under max", Vector2D{X: -1, Y: 1}, 5, Vector2D{X: -1, Y: 1}},
+		{"over max", Vector2D{X: 6, Y: -8}, 5, Vector2D{X: 3, Y: -4}},
+		{"to zero", Vector2D{X: 6, Y: -8}, 0, Vector2D{}},
+	}
+	for _, test := range tests {
+		got := test.v.ClampLength(test.max)
+		if !got.EqualWithin(test.want, eps) {
+			t.Errorf("%s: %v.ClampLength(%v) = %v, want %v", test.name, test.v, test.max, got, test.want)
+		}
+		// Vectors that fit are returned as is, not rescaled.
+		if test.v.Length() <= test.max && got != test.v {
+			t.Errorf("%s: %v.ClampLength(%v) changed it to %v", test.name, test.v, test.max, got)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package galaxy
+
+import (
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// PlayerView is a copy of the state of a player at a point in time,
+// changing it changes nothing in the game.
+type PlayerView struct {
+	ID uuid.UUID
+
+	// OwnerID is the player a split cell belongs to, uuid.Nil for players.
+	OwnerID uuid.UUID
+
+	Username string
+	Position utils.Vector2D
+	Velocity utils.Vector2D
+	Mass     uint64
+	Radius   uint32
+	Alive    bool
+	TeamID   uint8
+	Color    uint32
+
+	// Skin is the skin of the player, empty for none.
+	Skin string
+
+	// Connected is false while the player waits for its client to resume
+	// its session, see GameConfig.SessionGracePeriod.
+	Connected bool
+}
+
+func (p *Player) view() PlayerView {
+	p.RLock()
+	defer p.RUnlock()
+
+	view := PlayerView{
+		ID:        p.PlayerID,
+		OwnerID:   p.OwnerID,
+		Username:  p.Username,
+		Position:  p.Position,
+		Velocity:  p.Velocity,
+		Mass:      p.Mass,
+		Radius:    p.Radius,
+		Alive:     p.Alive,
+		TeamID:    p.TeamID,
+		Color:     p.Color,
+		Connected: p.conn != nil,
+	}
+	if p.Skin != nil {
+		view.Skin = *p.Skin
+	}
+	return view
+}
+
+// ForEachPlayer calls fn with a view of every player of the game, split
+// cells included, ordered by network ID. The views are copied at once
+// under the lock, so they are consistent with each other, and fn runs
+// after releasing it: it may call back into the game, and ticks go on
+// meanwhile.
+func (g *Game) ForEachPlayer(fn func(p PlayerView)) {
+	g.RLock()
+	players := inOrder(g, g.players)
+	views := make([]PlayerView, len(players))
+	for i, player := range players {
+		views[i] = player.view()
+	}
+	g.RUnlock()
+
+	for _, view := range views {
+		fn(view)
+	}
+}
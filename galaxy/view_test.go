@@ -0,0 +1,63 @@
+package galaxy
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+// Run with -race: views are read while ticks move, split and replace the
+// players they copy.
+func TestForEachPlayerWhileTicking(t *testing.T) {
+	g := newTestGame(t, testConfig())
+	var players []*Player
+	for i := range 10 {
+		player := joinTestPlayer(t, g, 500, 1000+float64(i)*500, 1000)
+		player.SetDirection(utils.FromAngle(float64(i)))
+		players = append(players, player)
+	}
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for tick := 0; ; tick++ {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			g.Tick(time.Second / DEFAULT_TICK_RATE)
+			if tick%10 == 0 {
+				g.SplitPlayer(players[tick/10%len(players)].PlayerID)
+			}
+			if tick%15 == 0 {
+				joining := NewPlayer(uuid.New(), nil)
+				joining.PlayerID = uuid.New()
+				g.AddPlayer(joining)
+				g.RemovePlayer(joining.PlayerID)
+			}
+		}
+	}()
+
+	for range 200 {
+		g.ForEachPlayer(func(view PlayerView) {
+			// Views are copies, changing one changes nothing.
+			view.Mass, view.Position = 0, utils.Vector2D{}
+			// Calling back into the game doesn't deadlock.
+			g.Player(view.ID)
+		})
+	}
+	close(stop)
+	wg.Wait()
+
+	g.ForEachPlayer(func(view PlayerView) {
+		if view.Alive && view.Mass == 0 {
+			t.Errorf("player %v has no mass after its view was changed", view.ID)
+		}
+	})
+}
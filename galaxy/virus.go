@@ -0,0 +1,212 @@
+package galaxy
+
+import (
+	"log"
+	"math"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+)
+
+const (
+	DEFAULT_VIRUS_COUNT  = 20
+	DEFAULT_VIRUS_RADIUS = 60
+
+	// VIRUS_COLOR is the color viruses are drawn with, green.
+	VIRUS_COLOR = 0x33CC33
+
+	// VIRUS_SPLIT_CELLS is the number of cells a player hitting a virus
+	// bursts into, less if it isn't big enough for all of them to have the
+	// starting mass of the game.
+	VIRUS_SPLIT_CELLS = 8
+
+	// DEFAULT_VIRUS_FEED_MASS is the ejected mass a virus absorbs before
+	// shooting a new one in the default config, 7 ejections.
+	DEFAULT_VIRUS_FEED_MASS = 7 * EJECT_MASS
+
+	// VIRUS_SHOT_SPEED is the initial speed of the viruses shot by fed
+	// ones, in world units per second, and VIRUS_SHOT_DAMPING how fast they
+	// slow down, a rate per second, carrying them around 400 units.
+	VIRUS_SHOT_SPEED   = 1200
+	VIRUS_SHOT_DAMPING = 3
+)
+
+// Virus is a hazard. Players big enough to cover it burst into many cells,
+// smaller ones pass over it. Viruses stay where they spawn unless fed, see
+// GameConfig.VirusFeedMass.
+type Virus struct {
+	ID       uuid.UUID
+	Position utils.Vector2D
+	Radius   uint32
+
+	// Velocity is the speed of a virus shot by a fed one, slowing down to
+	// a stop.
+	Velocity utils.Vector2D
+
+	// fed is the ejected mass absorbed since the virus last shot. shot
+	// viruses aren't replaced when popped, so feeding doesn't grow the
+	// number of viruses for good.
+	fed  uint64
+	shot bool
+}
+
+func (v *Virus) entity() Entity {
+	return Entity{
+		Kind:     EntityVirus,
+		ID:       v.ID,
+		Position: v.Position,
+		Radius:   v.Radius,
+		Color:    VIRUS_COLOR,
+	}
+}
+
+// SpawnVirus places n viruses at random free positions of the world, fewer
+// if it is too crowded.
+func (g *Game) SpawnVirus(n int) {
+	g.Lock()
+	defer g.Unlock()
+	for range n {
+		g.spawnVirus()
+	}
+}
+
+// spawnVirus places a virus away from players and other viruses, the
+// caller must hold the lock.
+func (g *Game) spawnVirus() {
+	radius := g.config.VirusRadius
+	if radius <= 0 {
+		radius = DEFAULT_VIRUS_RADIUS
+	}
+
+	position, found := g.findFreePosition(radius)
+	if !found {
+		log.Printf("no room left for a virus")
+		return
+	}
+
+	virus := &Virus{
+		ID:       g.newID(),
+		Position: position,
+		Radius:   uint32(radius),
+	}
+	g.viruses[virus.ID] = virus
+	g.index.Insert(virus.ID, virus.Position, float64(virus.Radius))
+}
+
+// move advances a shot virus by dt, slowing it down and bouncing it off
+// the edges of the world.
+func (v *Virus) move(dt time.Duration, bounds arena) {
+	if v.Velocity == (utils.Vector2D{}) {
+		return
+	}
+
+	v.Position, v.Velocity = bounds.Bounce(v.Position.Add(v.Velocity.Scale(dt.Seconds())), v.Velocity)
+	v.Velocity = v.Velocity.Scale(math.Exp(-VIRUS_SHOT_DAMPING * dt.Seconds()))
+	if v.Velocity.LengthSquared() < 1 {
+		v.Velocity = utils.Vector2D{}
+	}
+}
+
+// feedViruses makes viruses absorb the ejected mass over their center.
+// Once a virus absorbed GameConfig.VirusFeedMass, it shoots a new virus
+// away from the side the last mass came from. It returns the IDs of the
+// viruses shot, the caller must hold the lock.
+func (g *Game) feedViruses() []uuid.UUID {
+	if g.config.VirusFeedMass == 0 {
+		return nil
+	}
+
+	var shot []uuid.UUID
+	for _, virus := range inOrder(g, g.viruses) {
+		radius := float64(virus.Radius)
+		for _, id := range g.inRange(utils.RectAround(virus.Position, radius, radius)) {
+			ejected, isEjected := g.ejected[id]
+			if !isEjected || virus.Position.DistanceSquared(ejected.Position) >= radius*radius {
+				continue
+			}
+
+			// The mass comes from the feeder, the new virus leaves on the
+			// other side.
+			dir := virus.Position.Sub(ejected.Position).Normalize()
+			if dir == (utils.Vector2D{}) {
+				dir = ejected.Velocity.Normalize()
+			}
+			g.removeEjected(id)
+
+			virus.fed += ejected.Mass
+			if virus.fed < g.config.VirusFeedMass {
+				continue
+			}
+			virus.fed = 0
+			if dir == (utils.Vector2D{}) {
+				dir = utils.FromAngle(g.rand.Float64() * 2 * math.Pi)
+			}
+			shot = append(shot, g.shootVirus(virus, dir))
+		}
+	}
+	return shot
+}
+
+// shootVirus spawns a virus next to from, moving away in the direction
+// dir, and returns its ID. The caller must hold the lock.
+func (g *Game) shootVirus(from *Virus, dir utils.Vector2D) uuid.UUID {
+	virus := &Virus{
+		ID:       g.newID(),
+		Position: g.arena().Clamp(from.Position.Add(dir.Scale(2 * float64(from.Radius)))),
+		Radius:   from.Radius,
+		Velocity: dir.Scale(VIRUS_SHOT_SPEED),
+		shot:     true,
+	}
+	g.viruses[virus.ID] = virus
+	g.index.Insert(virus.ID, virus.Position, float64(virus.Radius))
+	return virus.ID
+}
+
+func (g *Game) removeVirus(id uuid.UUID) {
+	delete(g.viruses, id)
+	g.index.Remove(id)
+}
+
+// burst splits player into up to VIRUS_SPLIT_CELLS cells of equal mass,
+// shot in every direction, within the MaxCells of its owner. It reports
+// whether the player could split at all. The caller must hold the lock.
+func (g *Game) burst(player *Player) bool {
+	owner, exists := g.owner(player)
+	if !exists {
+		owner = player
+	}
+
+	owner.RLock()
+	room := owner.massRules().maxCells - 1 - owner.splitCells
+	owner.RUnlock()
+
+	player.Lock()
+	pieces := min(VIRUS_SPLIT_CELLS, player.Mass/g.rules.start, uint64(max(0, room))+1)
+	if pieces < 2 {
+		player.Unlock()
+		return false
+	}
+
+	mass := player.Mass / pieces
+	offset := player.direction.Angle()
+	cells := make([]*Player, 0, pieces-1)
+	for i := range pieces - 1 {
+		angle := offset + 2*math.Pi*float64(i+1)/float64(pieces)
+		cells = append(cells, player.splitOff(owner.PlayerID, mass, utils.FromAngle(angle)))
+	}
+	player.Unlock()
+
+	owner.Lock()
+	owner.splitCells += len(cells)
+	owner.Unlock()
+
+	for _, cell := range cells {
+		cell.PlayerID = g.newID()
+		cell.Position = g.arena().Clamp(cell.Position)
+		g.players[cell.PlayerID] = cell
+		g.reindex(cell)
+	}
+	g.reindex(player)
+	return true
+}
@@ -0,0 +1,70 @@
+package galaxy
+
+import (
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy/utils"
+)
+
+// addVirus puts a virus at position.
+func addVirus(g *Game, position utils.Vector2D) *Virus {
+	g.Lock()
+	defer g.Unlock()
+	virus := &Virus{ID: g.newID(), Position: position, Radius: DEFAULT_VIRUS_RADIUS}
+	g.viruses[virus.ID] = virus
+	g.index.Insert(virus.ID, virus.Position, float64(virus.Radius))
+	return virus
+}
+
+// feed shoots a blob of ejected mass into virus from its left.
+func feed(g *Game, virus *Virus) {
+	g.Lock()
+	defer g.Unlock()
+	ejected := &EjectedMass{
+		ID:       g.newID(),
+		Position: virus.Position.Sub(utils.Vector2D{X: 10}),
+		Velocity: utils.Vector2D{X: EJECT_SPEED},
+		Mass:     EJECT_MASS,
+	}
+	g.ejected[ejected.ID] = ejected
+	g.index.Insert(ejected.ID, ejected.Position, EJECT_RADIUS)
+}
+
+func TestFedVirusesShootAwayFromTheFeeder(t *testing.T) {
+	config := quietConfig()
+	config.VirusFeedMass = 3 * EJECT_MASS
+	g := newTestGame(t, config)
+	virus := addVirus(g, utils.Vector2D{X: 5000, Y: 5000})
+
+	for i := range 2 {
+		feed(g, virus)
+		if result := g.Tick(time.Millisecond); len(result.ShotViruses) != 0 {
+			t.Fatalf("virus shot after %d of 3 feedings", i+1)
+		}
+	}
+	if len(g.ejected) != 0 {
+		t.Fatalf("%d blobs left, the virus didn't absorb them", len(g.ejected))
+	}
+
+	feed(g, virus)
+	result := g.Tick(time.Millisecond)
+	if len(result.ShotViruses) != 1 {
+		t.Fatalf("virus shot %d viruses once fed enough, want 1", len(result.ShotViruses))
+	}
+	shot := g.viruses[result.ShotViruses[0]]
+	if shot.Velocity.X <= 0 || shot.Position.X <= virus.Position.X {
+		t.Errorf("shot virus at %v moving %v, want it moving right, away from the feeder", shot.Position, shot.Velocity)
+	}
+	if virus.fed != 0 {
+		t.Errorf("virus kept %d fed mass after shooting", virus.fed)
+	}
+
+	// The shot virus slows down to a stop.
+	for range 100 {
+		g.Tick(50 * time.Millisecond)
+	}
+	if shot.Velocity != (utils.Vector2D{}) {
+		t.Errorf("shot virus still moving at %v", shot.Velocity)
+	}
+}
@@ -1,7 +1,9 @@
 package galaxy
 
 import (
+	"errors"
 	"log"
+	"math"
 	"math/rand/v2"
 	"net/http"
 	"os"
@@ -9,9 +11,11 @@ import (
 	"sync"
 	"time"
 
+	"galaxy.io/server/galaxy/utils"
 	"galaxy.io/server/proto"
 	pb "galaxy.io/server/proto"
 	"github.com/google/uuid"
+	protobuf "google.golang.org/protobuf/proto"
 )
 
 const (
@@ -35,6 +39,22 @@ func (v *Vector2D) toPacket() *pb.Vector2D {
 	}
 }
 
+// toVector converts the integer wire position to world space.
+func (v *Vector2D) toVector() utils.Vector2D {
+	return utils.Vector2D{
+		X: float64(v.X),
+		Y: float64(v.Y),
+	}
+}
+
+// vectorToPacket rounds a world space position to the integer wire format.
+func vectorToPacket(v utils.Vector2D) *pb.Vector2D {
+	return (&Vector2D{
+		X: uint32(math.Round(max(v.X, 0))),
+		Y: uint32(math.Round(max(v.Y, 0))),
+	}).toPacket()
+}
+
 func VectorFromPacket(packet *pb.Vector2D) *Vector2D {
 	var x uint32
 	var y uint32
@@ -119,7 +139,7 @@ func NewWorld(factory ConnectionFactory) *World {
 
 func (w *World) sendEvent(player *Player, event *pb.Event) {
 	err := player.SendEvent(event)
-	if err != nil {
+	if err != nil && !errors.Is(err, ErrorFrameDropped) {
 		log.Printf("deleting player %v", player.PlayerID.String())
 		w.removePlayer(player)
 	}
@@ -133,9 +153,14 @@ func (w *World) HandleNewConnection(writer http.ResponseWriter, r *http.Request)
 		w.handlePlayerOperation(connectionID, operation)
 	}
 
-	conn, err := w.connectionFactory.NewConnection(writer, r, operationHandler)
+	onClose := func(reason CloseReason, err error) {
+		// Close may be called while holding the players lock.
+		go w.handleConnectionClosed(connectionID)
+	}
+
+	conn, err := w.connectionFactory.NewConnection(writer, r, operationHandler, onClose)
 	if err != nil {
-		log.Printf("Error creating connection: %v", err)
+		logConnectionError(r, err)
 		return
 	}
 
@@ -143,7 +168,29 @@ func (w *World) HandleNewConnection(writer http.ResponseWriter, r *http.Request)
 	w.registerPlayer(player)
 }
 
+func (w *World) handleConnectionClosed(connectionID uuid.UUID) {
+	w.playersMutex.Lock()
+	player, exists := w.playersConnection[connectionID]
+	delete(w.playersConnection, connectionID)
+	w.playersMutex.Unlock()
+
+	if !exists {
+		return
+	}
+
+	log.Printf("connection closed, id = %v", connectionID)
+	w.removePlayer(player)
+}
+
+// broadcastEvent sends event to every player. It is marshaled once and the
+// same bytes are queued on every connection, which never modify them.
 func (w *World) broadcastEvent(event *pb.Event) {
+	data, err := protobuf.Marshal(event)
+	if err != nil {
+		log.Printf("error marshaling event %v: %v", event.EventType.String(), err)
+		return
+	}
+
 	w.playersMutex.RLock()
 	defer w.playersMutex.RUnlock()
 
@@ -152,7 +199,11 @@ func (w *World) broadcastEvent(event *pb.Event) {
 			log.Printf("sending event: %v to %v", event.EventType.String(), player.ConnectionID.String())
 		}
 
-		w.sendEvent(player, event)
+		if err := player.SendBinary(data); err != nil && !errors.Is(err, ErrorFrameDropped) {
+			log.Printf("deleting player %v", player.PlayerID.String())
+			// removePlayer takes the players lock held here.
+			go w.removePlayer(player)
+		}
 	}
 }
 
@@ -196,7 +247,7 @@ func (w *World) broadcastNewPlayer(player *Player) {
 		EventData: &pb.Event_NewPlayerEvent{
 			NewPlayerEvent: &pb.NewPlayerEvent{
 				PlayerID: player.PlayerID[:],
-				Position: player.Position.toPacket(),
+				Position: vectorToPacket(player.GetPosition()),
 				Radius:   &player.Radius,
 				Color:    &player.Color,
 				Skin:     player.Skin,
@@ -218,7 +269,7 @@ func (w *World) sendJoin(player *Player) {
 		EventData: &pb.Event_JoinEvent{
 			JoinEvent: &pb.JoinEvent{
 				PlayerID: player.PlayerID[:],
-				Position: player.Position.toPacket(),
+				Position: vectorToPacket(player.GetPosition()),
 				Radius:   &player.Radius,
 				Color:    &player.Color,
 				Skin:     player.Skin,
@@ -244,7 +295,7 @@ func (w *World) sendState(receiver *Player) {
 			EventData: &pb.Event_NewPlayerEvent{
 				NewPlayerEvent: &pb.NewPlayerEvent{
 					PlayerID: player.PlayerID[:],
-					Position: player.Position.toPacket(),
+					Position: vectorToPacket(player.GetPosition()),
 					Radius:   &player.Radius,
 					Color:    &player.Color,
 					Skin:     player.Skin,
@@ -264,8 +315,8 @@ func (w *World) sendState(receiver *Player) {
 
 	for _, food := range w.food {
 		pbFoods = append(pbFoods, &pb.Food{
-			Position: food.position.toPacket(),
-			Color:    &food.color,
+			Position: vectorToPacket(food.Position),
+			Color:    &food.Color,
 		})
 	}
 	event := &pb.Event{
@@ -343,8 +394,8 @@ func (w *World) operationJoin(player *Player, joinOperation *pb.JoinOperation) {
 		return
 	}
 	player.UpdatePlayerID(playerID)
-	player.UpdateUsername(*joinOperation.Username)
-	player.UpdateColor(*joinOperation.Color)
+	player.UpdateUsername(joinOperation.GetUsername())
+	player.UpdateColor(joinOperation.GetColor())
 	if joinOperation.Skin != nil {
 		player.UpdateSkin(*joinOperation.Skin)
 	}
@@ -372,9 +423,9 @@ func (w *World) operationJoin(player *Player, joinOperation *pb.JoinOperation) {
 
 		for _, savedPlayer := range w.savedPlayers {
 			if savedPlayer.PlayerID == player.PlayerID.String() {
-				player.UpdatePosition(&Vector2D{
-					X: savedPlayer.X,
-					Y: savedPlayer.Y,
+				player.UpdatePosition(utils.Vector2D{
+					X: float64(savedPlayer.X),
+					Y: float64(savedPlayer.Y),
 				})
 				player.UpdateRadius(savedPlayer.Score)
 				break
@@ -397,7 +448,7 @@ func (w *World) operationPlayerMove(player *Player, moveOperation *pb.MoveOperat
 		return
 	}
 	// TODO: check for cheaters
-	player.UpdatePosition(VectorFromPacket(moveOperation.Position))
+	player.UpdatePosition(VectorFromPacket(moveOperation.Position).toVector())
 
 	// broadcast the movement to all the players
 	playerIDBytes, _ := player.PlayerID.MarshalBinary()
@@ -406,7 +457,7 @@ func (w *World) operationPlayerMove(player *Player, moveOperation *pb.MoveOperat
 		EventData: &pb.Event_PlayerMoveEvent{
 			PlayerMoveEvent: &pb.PlayerMoveEvent{
 				PlayerID: playerIDBytes,
-				Position: player.Position.toPacket(),
+				Position: vectorToPacket(player.GetPosition()),
 			},
 		},
 	}
@@ -421,7 +472,7 @@ func (w *World) operationPlayerEatFood(player *Player, operation *pb.EatFoodOper
 	foodPos := VectorFromPacket(operation.FoodPosition)
 	w.foodMutex.Lock()
 	for i, f := range w.food {
-		if f.position == *foodPos {
+		if f.Position == foodPos.toVector() {
 			w.food = append(w.food[:i], w.food[i+1:]...)
 		}
 	}
@@ -3,7 +3,7 @@ module galaxy.io/server
 go 1.23
 
 require (
-	github.com/google/uuid v1.6.0 // indirect
-	github.com/gorilla/websocket v1.5.3 // indirect
-	google.golang.org/protobuf v1.36.6 // indirect
+	github.com/google/uuid v1.6.0
+	github.com/gorilla/websocket v1.5.3
+	google.golang.org/protobuf v1.36.6
 )
@@ -0,0 +1,324 @@
+// Package loadtest drives a running server with headless bots speaking the
+// game protocol over real websockets. The server never imports it, see
+// cmd/galaxy-bots for the binary.
+package loadtest
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	"galaxy.io/server/galaxy/utils"
+	"github.com/google/uuid"
+	ws "github.com/gorilla/websocket"
+)
+
+const (
+	// DEFAULT_INPUT_RATE is how many inputs a bot sends per second by
+	// default.
+	DEFAULT_INPUT_RATE = 10
+
+	// DEFAULT_PING_INTERVAL is how often a bot measures its latency with
+	// OpPing frames by default.
+	DEFAULT_PING_INTERVAL = time.Second
+
+	// DEFAULT_RECONNECT_DELAY is how long a bot waits before reconnecting
+	// after its connection dropped by default.
+	DEFAULT_RECONNECT_DELAY = time.Second
+
+	// TURN_PROBABILITY is the chance a bot picks a new direction with every
+	// input, ACTION_PROBABILITY the chance it splits, ejects or boosts.
+	TURN_PROBABILITY   = 0.1
+	ACTION_PROBABILITY = 0.02
+)
+
+var (
+	ErrorRejected   = fmt.Errorf("Server rejected the hello")
+	ErrorInvalidURL = fmt.Errorf("Invalid server URL")
+)
+
+// BotConfig configures a BotClient. Zero values mean defaults.
+type BotConfig struct {
+	// URL is the game endpoint of the server, such as
+	// ws://localhost:8080/game.
+	URL string
+
+	// InputRate is how many OpInput frames the bot sends per second.
+	InputRate int
+
+	PingInterval   time.Duration
+	ReconnectDelay time.Duration
+
+	// Deltas tells the bot the server sends delta updates, see
+	// galaxy.GameConfig.KeyframeInterval: both encodings start with the
+	// same version byte, so the bot can't tell them apart.
+	Deltas bool
+
+	Dialer *ws.Dialer
+}
+
+// Stats are what a bot measured since it started.
+type Stats struct {
+	// RTT is the latest round-trip time of an OpPing frame.
+	RTT time.Duration
+
+	// Snapshots counts the OpStateSnapshot frames decoded, SnapshotRate
+	// is how many arrived per second on average.
+	Snapshots    uint64
+	SnapshotRate float64
+
+	// Reconnects counts the attempts to resume the session after the
+	// connection dropped, Deaths the times the bot was eaten and joined
+	// again.
+	Reconnects uint64
+	Deaths     uint64
+
+	// DecodeErrors counts the frames the bot couldn't make sense of.
+	DecodeErrors uint64
+}
+
+// BotClient plays a game like a real client: it dials the server, says
+// hello, sends valid random inputs at the configured rate and decodes the
+// snapshots it gets. Dropped connections resume the session, and a bot
+// that is eaten joins again as a new player. It is safe to read its stats
+// while it runs.
+type BotClient struct {
+	config  BotConfig
+	session uuid.UUID
+
+	// started is when Run started, in unix nanoseconds.
+	started      atomic.Int64
+	rtt          atomic.Int64
+	snapshots    atomic.Uint64
+	reconnects   atomic.Uint64
+	deaths       atomic.Uint64
+	decodeErrors atomic.Uint64
+}
+
+func NewBotClient(config BotConfig) *BotClient {
+	if config.InputRate <= 0 {
+		config.InputRate = DEFAULT_INPUT_RATE
+	}
+	if config.PingInterval <= 0 {
+		config.PingInterval = DEFAULT_PING_INTERVAL
+	}
+	if config.ReconnectDelay <= 0 {
+		config.ReconnectDelay = DEFAULT_RECONNECT_DELAY
+	}
+	if config.Dialer == nil {
+		config.Dialer = ws.DefaultDialer
+	}
+	return &BotClient{config: config}
+}
+
+// Stats returns what the bot measured so far.
+func (b *BotClient) Stats() Stats {
+	stats := Stats{
+		RTT:          time.Duration(b.rtt.Load()),
+		Snapshots:    b.snapshots.Load(),
+		Reconnects:   b.reconnects.Load(),
+		Deaths:       b.deaths.Load(),
+		DecodeErrors: b.decodeErrors.Load(),
+	}
+	if started := b.started.Load(); started != 0 {
+		if elapsed := time.Since(time.Unix(0, started)).Seconds(); elapsed > 0 {
+			stats.SnapshotRate = float64(stats.Snapshots) / elapsed
+		}
+	}
+	return stats
+}
+
+// Run plays until ctx is done, reconnecting whenever the connection drops.
+// It only returns early if the server can't be reached at all or rejects
+// the bot.
+func (b *BotClient) Run(ctx context.Context) error {
+	b.started.Store(time.Now().UnixNano())
+	connected := false
+	for {
+		err := b.play(ctx)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, ErrorRejected) || errors.Is(err, ErrorInvalidURL) {
+			return err
+		}
+
+		var dial dialError
+		if errors.As(err, &dial) {
+			if !connected {
+				return err
+			}
+		} else {
+			connected = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(b.config.ReconnectDelay):
+		}
+		if b.session != uuid.Nil {
+			b.reconnects.Add(1)
+		}
+	}
+}
+
+// dialError is a failure to connect, as opposed to a connection dropping.
+type dialError struct{ error }
+
+func (e dialError) Unwrap() error { return e.error }
+
+// play connects once and plays until the connection drops or ctx is done.
+func (b *BotClient) play(ctx context.Context) error {
+	target, err := url.Parse(b.config.URL)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrorInvalidURL, err)
+	}
+	if b.session != uuid.Nil {
+		query := target.Query()
+		query.Set("session", b.session.String())
+		target.RawQuery = query.Encode()
+	}
+
+	conn, _, err := b.config.Dialer.DialContext(ctx, target.String(), nil)
+	if err != nil {
+		return dialError{err}
+	}
+
+	hello := binary.LittleEndian.AppendUint16(nil, galaxy.PROTOCOL_VERSION)
+	if err := conn.WriteMessage(ws.BinaryMessage, galaxy.EncodeFrame(galaxy.OpHello, hello)); err != nil {
+		conn.Close()
+		return err
+	}
+
+	// The reader is done with the session before the next connection.
+	done := make(chan error, 1)
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		done <- b.read(conn)
+	}()
+	defer func() {
+		conn.Close()
+		<-finished
+	}()
+
+	inputs := time.NewTicker(time.Second / time.Duration(b.config.InputRate))
+	defer inputs.Stop()
+	pings := time.NewTicker(b.config.PingInterval)
+	defer pings.Stop()
+
+	direction := randomDirection()
+	for {
+		var frame []byte
+		select {
+		case <-ctx.Done():
+			conn.WriteMessage(ws.CloseMessage, ws.FormatCloseMessage(ws.CloseNormalClosure, ""))
+			return ctx.Err()
+		case err := <-done:
+			return err
+		case <-inputs.C:
+			if rand.Float64() < TURN_PROBABILITY {
+				direction = randomDirection()
+			}
+			frame = galaxy.EncodeFrame(galaxy.OpInput, galaxy.EncodeInput(galaxy.PlayerInput{
+				Direction: direction,
+				Actions:   randomActions(),
+			}))
+		case now := <-pings.C:
+			frame = galaxy.EncodeFrame(galaxy.OpPing, binary.LittleEndian.AppendUint64(nil, uint64(now.UnixNano())))
+		}
+		if err := conn.WriteMessage(ws.BinaryMessage, frame); err != nil {
+			return err
+		}
+	}
+}
+
+// read decodes the frames of the server until the connection fails, or
+// the bot dies and has to join again.
+func (b *BotClient) read(conn *ws.Conn) error {
+	var (
+		version uint16
+		deltas  = galaxy.NewDeltaDecoder()
+	)
+	for {
+		kind, frame, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if kind != ws.BinaryMessage {
+			continue
+		}
+		op, payload, err := galaxy.DecodeFrame(frame)
+		if err != nil {
+			b.decodeErrors.Add(1)
+			continue
+		}
+
+		switch op {
+		case galaxy.OpHello:
+			if len(payload) < 5 || payload[0] == 0 {
+				return ErrorRejected
+			}
+			version = binary.LittleEndian.Uint16(payload[3:5])
+		case galaxy.OpSession:
+			if len(payload) >= 16 {
+				b.session = uuid.UUID(payload[:16])
+			}
+		case galaxy.OpPing:
+			if len(payload) >= 8 {
+				sentAt := time.Unix(0, int64(binary.LittleEndian.Uint64(payload)))
+				b.rtt.Store(int64(time.Since(sentAt)))
+			}
+		case galaxy.OpStateSnapshot:
+			if err := b.decodeSnapshot(payload, version, deltas); err != nil {
+				b.decodeErrors.Add(1)
+				continue
+			}
+			b.snapshots.Add(1)
+		case galaxy.OpDeath:
+			// Join again as a new player rather than respawning, so bots
+			// keep exercising joins.
+			b.deaths.Add(1)
+			b.session = uuid.Nil
+			return nil
+		}
+	}
+}
+
+func (b *BotClient) decodeSnapshot(payload []byte, version uint16, deltas *galaxy.DeltaDecoder) error {
+	_, body, err := galaxy.DecodeSnapshotHeader(payload)
+	if err != nil {
+		return err
+	}
+	if version >= galaxy.REMOVALS_PROTOCOL_VERSION {
+		if body, _, err = galaxy.DecodeRemovals(body); err != nil {
+			return err
+		}
+	}
+	if b.config.Deltas {
+		return deltas.Apply(body)
+	}
+	_, err = galaxy.DecodeEntities(body)
+	return err
+}
+
+func randomDirection() utils.Vector2D {
+	angle := rand.Float64() * 2 * math.Pi
+	return utils.Vector2D{X: math.Cos(angle), Y: math.Sin(angle)}
+}
+
+func randomActions() uint8 {
+	if rand.Float64() >= ACTION_PROBABILITY {
+		return 0
+	}
+	actions := []uint8{galaxy.ActionSplit, galaxy.ActionEject, galaxy.ActionBoost}
+	return actions[rand.IntN(len(actions))]
+}
@@ -0,0 +1,66 @@
+package loadtest
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	"galaxy.io/server/websockets"
+)
+
+// gameServer serves the rooms of a real game server over httptest,
+// returning the websocket URL of its game endpoint.
+func gameServer(t *testing.T) string {
+	t.Helper()
+
+	factory := websockets.NewServer()
+	rooms := galaxy.NewRoomManager(factory, galaxy.DefaultGameConfig(), 1, 10)
+	server := httptest.NewServer(http.HandlerFunc(rooms.HandleNewConnection))
+	t.Cleanup(func() {
+		server.Close()
+		rooms.Close()
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http") + "/game"
+}
+
+func TestBotPlaysAGame(t *testing.T) {
+	bot := NewBotClient(BotConfig{
+		URL:          gameServer(t),
+		InputRate:    50,
+		PingInterval: 20 * time.Millisecond,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	if err := bot.Run(ctx); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	stats := bot.Stats()
+	if stats.Snapshots == 0 || stats.SnapshotRate <= 0 {
+		t.Errorf("bot decoded %d snapshots at %v/s, want some", stats.Snapshots, stats.SnapshotRate)
+	}
+	if stats.RTT <= 0 {
+		t.Error("bot never measured its round-trip time")
+	}
+	if stats.DecodeErrors != 0 {
+		t.Errorf("bot failed to decode %d frames", stats.DecodeErrors)
+	}
+}
+
+func TestBotRunFailsWithoutServer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/game"
+	server.Close()
+	bot := NewBotClient(BotConfig{URL: url})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := bot.Run(ctx); err == nil {
+		t.Error("Run returned no error although it never connected")
+	}
+}
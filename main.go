@@ -1,30 +1,162 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
 	"log"
-	"os"
 	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"galaxy.io/server/galaxy"
 	"galaxy.io/server/websockets"
 )
 
+const (
+	// MAX_ROOMS is the number of games the server runs at most.
+	MAX_ROOMS = 32
+
+	// PLAYERS_PER_ROOM is the capacity of the rooms players are matched
+	// into.
+	PLAYERS_PER_ROOM = 50
+
+	// MAX_CONNECTIONS and MAX_CONNECTIONS_PER_ROOM keep a connection flood
+	// from exhausting the server's memory.
+	MAX_CONNECTIONS          = 4096
+	MAX_CONNECTIONS_PER_ROOM = 128
+
+	// SHUTDOWN_TIMEOUT bounds how long the server waits for connections to
+	// drain when stopped.
+	SHUTDOWN_TIMEOUT = 10 * time.Second
+)
+
 func main() {
-	wsFactory := &websockets.WebsocketFactory{}
+	var options []websockets.Option
+
+	if origins := os.Getenv("GALAXY_ALLOWED_ORIGINS"); origins != "" {
+		allowed := strings.Split(origins, ",")
+		for i := range allowed {
+			allowed[i] = strings.TrimSpace(allowed[i])
+		}
+		options = append(options, websockets.WithAllowedOrigins(allowed...))
+	} else {
+		log.Printf("GALAXY_ALLOWED_ORIGINS not set, only accepting same-origin connections")
+	}
+
+	if proxies := os.Getenv("GALAXY_TRUSTED_PROXIES"); proxies != "" {
+		var trusted []netip.Prefix
+		for _, proxy := range strings.Split(proxies, ",") {
+			prefix, err := netip.ParsePrefix(strings.TrimSpace(proxy))
+			if err != nil {
+				log.Fatalf("invalid GALAXY_TRUSTED_PROXIES: %v", err)
+			}
+			trusted = append(trusted, prefix)
+		}
+		options = append(options, websockets.WithTrustedProxies(trusted...))
+	}
+
+	// The JSON frames of the debug console cost many times the bandwidth
+	// of binary ones, they must never be enabled in production.
+	if os.Getenv("GALAXY_DEBUG_PROTOCOL") == "1" {
+		log.Printf("GALAXY_DEBUG_PROTOCOL set, clients may ask for JSON frames")
+		options = append(options, websockets.WithSubprotocols(galaxy.DEBUG_SUBPROTOCOL))
+	}
+
+	// Artificial latency on every frame sent, for reproducing high pings
+	// locally, such as GALAXY_SIMULATE_LAG=150ms/50ms. Development only.
+	if lagSpec := os.Getenv("GALAXY_SIMULATE_LAG"); lagSpec != "" {
+		lag, err := websockets.ParseLag(lagSpec)
+		if err != nil {
+			log.Fatalf("invalid GALAXY_SIMULATE_LAG: %v", err)
+		}
+		log.Printf("GALAXY_SIMULATE_LAG set, delaying every frame by %v", lag)
+		options = append(options, websockets.WithLag(lag))
+	}
 
-	world := galaxy.NewWorld(wsFactory)
+	wsServer := websockets.NewServer(options...)
+	wsServer.SetMaxConnections(MAX_CONNECTIONS)
+
+	world := galaxy.NewWorld(wsServer)
 
 	http.HandleFunc("/ws", func(w http.ResponseWriter, r *http.Request) {
 		world.HandleNewConnection(w, r)
 	})
 
+	// The server authoritative games, clients exchange opcode frames and
+	// pick a room with ?room= or get matched into one.
+	rooms := galaxy.NewRoomManager(wsServer, galaxy.DefaultGameConfig(), MAX_ROOMS, PLAYERS_PER_ROOM)
+	rooms.SetMaxConnectionsPerRoom(MAX_CONNECTIONS_PER_ROOM)
+	// Stopping the games leaves their connections to the server, which
+	// flushes them and says "server restarting" before the rooms close.
+	wsServer.OnShutdown(rooms.Stop)
+
+	http.HandleFunc("/game", func(w http.ResponseWriter, r *http.Request) {
+		rooms.HandleNewConnection(w, r)
+	})
+
+	// Room introspection for operators, only mounted with a token to
+	// present as "Authorization: Bearer <token>".
+	if token := os.Getenv("GALAXY_DEBUG_TOKEN"); token != "" {
+		http.Handle("/debug/game", rooms.DebugHandler(bearerAuth(token)))
+	}
+
 	ip := os.Getenv("GALAXY_SERVER_IP")
 	port := os.Getenv("GALAXY_SERVER_PORT")
+	httpServer := &http.Server{Addr: ip + ":" + port}
+
+	// Serve wss when given a certificate.
+	certFile := os.Getenv("GALAXY_TLS_CERT")
+	keyFile := os.Getenv("GALAXY_TLS_KEY")
+	useTLS := certFile != "" && keyFile != ""
+	if useTLS {
+		httpServer = websockets.NewTLSServer(httpServer.Addr, nil)
+	}
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+		<-stop
+
+		log.Printf("shutting down, draining %v connections", wsServer.Len())
+		ctx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+		defer cancel()
+		if err := wsServer.Shutdown(ctx); err != nil {
+			log.Printf("error shutting down connections: %v", err)
+		}
+		rooms.Close()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("error shutting down http server: %v", err)
+		}
+	}()
 
 	log.Printf("server started in %v:%v", ip, port)
-	err := http.ListenAndServe(ip+":"+port, nil)
-	if err != nil {
-		log.Fatal("ListenAndServe: %v", err)
+	var err error
+	if useTLS {
+		err = httpServer.ListenAndServeTLS(certFile, keyFile)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("ListenAndServe: %v", err)
 	}
 
 }
+
+// bearerAuth only lets through requests authorized with token.
+func bearerAuth(token string) func(next http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
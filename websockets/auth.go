@@ -0,0 +1,47 @@
+package websockets
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// PlayerIdentity is who an authenticated connection belongs to.
+type PlayerIdentity struct {
+	PlayerID uuid.UUID
+	Username string
+}
+
+// Authenticator validates an upgrade request before it is upgraded,
+// returning the identity of the player making it.
+type Authenticator func(r *http.Request) (PlayerIdentity, error)
+
+// WithAuthenticator rejects upgrade requests that authenticator refuses with
+// a 401, without upgrading them.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(c *Connection) {
+		c.authenticator = authenticator
+	}
+}
+
+// TokenFromRequest returns the token of an upgrade request, taken from a
+// bearer Authorization header or else the token query parameter. Browsers
+// can't set headers on websocket requests, hence the query parameter.
+func TokenFromRequest(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, found := strings.CutPrefix(header, "Bearer "); found {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+// Identity returns the identity the authenticator assigned to the
+// connection, if any.
+func (c *Connection) Identity() (PlayerIdentity, bool) {
+	if c.identity == nil {
+		return PlayerIdentity{}, false
+	}
+	return *c.identity, true
+}
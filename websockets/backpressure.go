@@ -0,0 +1,71 @@
+package websockets
+
+import (
+	"sync"
+	"time"
+)
+
+// readGate holds the read pump while reads are paused, see PauseReads.
+type readGate struct {
+	mutex sync.Mutex
+
+	// resumed is closed by ResumeReads, nil while reading.
+	resumed chan struct{}
+}
+
+// PauseReads stops the connection from reading messages once the one being
+// handled, if any, returns, until ResumeReads. A handler that can't keep up
+// calls it to push back on its client: messages then wait in the socket
+// buffers and TCP flow control slows the client down, instead of piling up
+// in the process. Pausing an already paused connection does nothing.
+//
+// Control frames travel in the same stream as messages, so while paused
+// the pongs of the client aren't read either. The read deadline is lifted
+// meanwhile so the connection isn't dropped for them, and pings still go
+// out, but a client vanishing while paused is only noticed once reads
+// resume or writing to it times out. Pause briefly, and resume without
+// waiting on the client.
+func (c *Connection) PauseReads() {
+	c.reads.mutex.Lock()
+	defer c.reads.mutex.Unlock()
+	if c.reads.resumed == nil {
+		c.reads.resumed = make(chan struct{})
+	}
+}
+
+// ResumeReads lets a connection paused with PauseReads read again. It does
+// nothing if it isn't paused.
+func (c *Connection) ResumeReads() {
+	c.reads.mutex.Lock()
+	defer c.reads.mutex.Unlock()
+	if c.reads.resumed != nil {
+		close(c.reads.resumed)
+		c.reads.resumed = nil
+	}
+}
+
+// ReadsPaused reports whether the connection is paused with PauseReads.
+func (c *Connection) ReadsPaused() bool {
+	c.reads.mutex.Lock()
+	defer c.reads.mutex.Unlock()
+	return c.reads.resumed != nil
+}
+
+// waitReads holds the read pump while reads are paused. Closing the
+// connection resumes them, the pump reads on until the peer acknowledges
+// the close frame.
+func (c *Connection) waitReads() {
+	c.reads.mutex.Lock()
+	resumed := c.reads.resumed
+	c.reads.mutex.Unlock()
+	if resumed == nil {
+		return
+	}
+
+	c.conn.SetReadDeadline(time.Time{})
+	select {
+	case <-resumed:
+	case <-c.closed:
+	}
+	c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+}
@@ -2,6 +2,7 @@ package websockets
 
 import (
 	"log"
+	"net"
 	"net/http"
 
 	"galaxy.io/server/galaxy"
@@ -21,17 +22,73 @@ func (c *Client) SendEvent(event *pb.Event) error {
 	return c.conn.SendBinary(data)
 }
 
+func (c *Client) SendBinary(data []byte) error {
+	return c.conn.SendBinary(data)
+}
+
+// SendBinaryCopy sends a copy of data, see Connection.SendBinaryCopy.
+func (c *Client) SendBinaryCopy(data []byte) error {
+	return c.conn.SendBinaryCopy(data)
+}
+
+// SendUrgent sends data ahead of the regular frames, see
+// Connection.SendBinaryPriority.
+func (c *Client) SendUrgent(data []byte) error {
+	return c.conn.SendBinaryPriority(data, PriorityHigh)
+}
+
+// SendText sends text in a text frame, for debugging clients.
+func (c *Client) SendText(text string) error {
+	return c.conn.SendText(text)
+}
+
+// Debug reports whether the client negotiated galaxy.DEBUG_SUBPROTOCOL,
+// which is only offered WithSubprotocols.
+func (c *Client) Debug() bool {
+	return c.conn.Subprotocol() == galaxy.DEBUG_SUBPROTOCOL
+}
+
+// RemoteAddr returns the address of the client, see
+// Connection.RemoteAddr.
+func (c *Client) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Saturated reports whether the client isn't keeping up with what is sent
+// to it, see Connection.Saturated.
+func (c *Client) Saturated() bool {
+	return c.conn.Saturated()
+}
+
+// MaxMessageSize returns the size of the largest message read from the
+// client, see Connection.MaxMessageSize.
+func (c *Client) MaxMessageSize() int64 {
+	return c.conn.MaxMessageSize()
+}
+
+// CloseWithReason closes the connection with a close frame carrying code
+// and reason, see Connection.CloseWithReason.
+func (c *Client) CloseWithReason(code int, reason string) {
+	log.Printf("closing connection %v: %v", c.conn.ID(), reason)
+	c.conn.CloseWithReason(code, reason)
+}
+
 func (c *Client) Close() {
-	log.Printf("closing connection")
+	log.Printf("closing connection %v", c.conn.ID())
 	c.conn.Close()
 }
 
-type WebsocketFactory struct{}
+// WebsocketFactory creates the websocket connections of a server, applying
+// Options to every one of them.
+type WebsocketFactory struct {
+	Options []Option
+}
 
 func (f *WebsocketFactory) NewConnection(
 	w http.ResponseWriter,
 	r *http.Request,
 	operationHandler func(*pb.Operation),
+	onClose func(CloseReason, error),
 ) (galaxy.ClientConnection, error) {
 	handler := func(data []byte)  {
 		operation := &pb.Operation{}
@@ -43,7 +100,24 @@ func (f *WebsocketFactory) NewConnection(
 		operationHandler(operation)
 	}
 
-	conn, err := Upgrade(w, r, handler)
+	opts := append([]Option{WithOnClose(onClose)}, f.Options...)
+	conn, err := Upgrade(w, r, handler, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{conn: conn}, nil
+}
+
+func (f *WebsocketFactory) NewFrameConnection(
+	w http.ResponseWriter,
+	r *http.Request,
+	frameHandler func([]byte),
+	onClose func(CloseReason, error),
+) (galaxy.ClientConnection, error) {
+	// Frames carry no length, clients split them by message.
+	opts := append([]Option{WithOnClose(onClose), WithSeparateMessages()}, f.Options...)
+	conn, err := Upgrade(w, r, frameHandler, opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,183 @@
+package websockets
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+const (
+	defaultWriteWait       = 0
+	defaultPongWait        = 60 * time.Second
+	defaultMaxMessageSize  = 512
+	defaultReadBufferSize  = 1024
+	defaultWriteBufferSize = 1024
+	defaultMaxCoalesce     = 64
+
+	sendBufferSize = 2048
+
+	// urgentBufferSize is the size of the separate buffer of high priority
+	// frames, which are few and far between.
+	urgentBufferSize = 256
+
+	// saturationPercent is how full the send buffer is, in percent, when a
+	// connection reports itself as Saturated.
+	saturationPercent = 75
+
+	// closeGracePeriod bounds how long CloseWithReason waits for the peer
+	// to acknowledge the close frame.
+	closeGracePeriod = time.Second
+)
+
+// Config holds the timeouts and sizes of a connection. Zero fields take the
+// default values.
+type Config struct {
+	// WriteWait is the time allowed to write a message to the peer,
+	// zero means writes never time out. A write that times out ends the
+	// connection like any other write error, it isn't retried: gorilla's
+	// connections may have sent part of the message already and fail
+	// every write after their first failed one. Keep it generous for
+	// clients on flaky networks, or leave it zero and rely on
+	// SlowConsumerTimeout.
+	WriteWait time.Duration
+
+	// PongWait is the time allowed to read the next pong from the peer
+	// before the connection is considered dead.
+	PongWait time.Duration
+
+	// PingPeriod is how often pings are sent, it must be shorter than
+	// PongWait. Defaults to nine tenths of PongWait.
+	PingPeriod time.Duration
+
+	// MaxMessageSize is the maximum size in bytes of an inbound message.
+	// Coalesced outbound messages stay within it too, single frames larger
+	// than it are still sent as they are.
+	MaxMessageSize int64
+
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// WriteBufferPool lends connections their write buffer only while they
+	// write, instead of each keeping its own, which adds up over
+	// thousands of mostly idle connections. Connections sharing a pool,
+	// such as every connection of a Server, should share WriteBufferSize
+	// too. nil gives every connection its own buffer, see NewBufferPool.
+	WriteBufferPool ws.BufferPool
+
+	// MaxCoalesce is the maximum number of queued binary frames merged
+	// into a single websocket message, unless WithSeparateMessages.
+	MaxCoalesce int
+
+	// MaxMessagesPerSecond caps the inbound message rate, allowing bursts
+	// of up to one second worth of messages. Zero means unlimited.
+	MaxMessagesPerSecond int
+
+	// CloseOnRateLimit closes the connection with a policy violation when
+	// the rate limit is exceeded instead of dropping the excess messages.
+	CloseOnRateLimit bool
+
+	// HandlerTimeout is how long the handler of a message may run before
+	// it is logged as hung, with the ID of its connection. The next
+	// message is still only handled once it returns, handlers run one at
+	// a time and in order. Zero means no timeout.
+	HandlerTimeout time.Duration
+
+	// CloseOnHandlerTimeout closes the connection with an internal error
+	// when a handler exceeds HandlerTimeout, so a hung handler doesn't
+	// hang its connection too.
+	CloseOnHandlerTimeout bool
+
+	// SlowConsumerTimeout closes connections whose peer stops draining
+	// what is sent to it: once frames stay queued, or a write stays
+	// unfinished, that long without a single message written, the
+	// connection is closed with a policy violation, "slow consumer".
+	// Otherwise a peer whose TCP window is stuck is only noticed when a
+	// write times out, which with WriteWait zero never happens. It should
+	// stay well above WriteWait and any Lag. Zero disables the watchdog.
+	SlowConsumerTimeout time.Duration
+
+	// CompressionThreshold is the size in bytes from which messages are
+	// compressed when WithCompression negotiated it, coalesced frames
+	// counting together. Deflating smaller ones costs more CPU than it
+	// saves bandwidth, and can even grow them. Zero compresses every
+	// message.
+	CompressionThreshold int
+
+	// TimestampPings stamps the automatic pings with the server time, see
+	// DecodePingTime, so clients can estimate their clock offset. Off by
+	// default, some clients expect empty pings.
+	TimestampPings bool
+}
+
+// NewBufferPool returns a pool of write buffers to share between
+// connections, see Config.WriteBufferPool.
+func NewBufferPool() ws.BufferPool {
+	return &sync.Pool{}
+}
+
+// DefaultConfig returns the configuration used when none is given.
+func DefaultConfig() Config {
+	return Config{
+		WriteWait:       defaultWriteWait,
+		PongWait:        defaultPongWait,
+		PingPeriod:      (defaultPongWait * 9) / 10,
+		MaxMessageSize:  defaultMaxMessageSize,
+		ReadBufferSize:  defaultReadBufferSize,
+		WriteBufferSize: defaultWriteBufferSize,
+		MaxCoalesce:     defaultMaxCoalesce,
+	}
+}
+
+// WithConfig sets the timeouts and sizes of the connection. Upgrade fails if
+// the resulting configuration is invalid.
+func WithConfig(config Config) Option {
+	return func(c *Connection) {
+		c.config = config
+	}
+}
+
+// withDefaults fills the zero fields of the config with the default values.
+func (cfg Config) withDefaults() Config {
+	if cfg.PongWait == 0 {
+		cfg.PongWait = defaultPongWait
+	}
+	if cfg.PingPeriod == 0 {
+		cfg.PingPeriod = (cfg.PongWait * 9) / 10
+	}
+	if cfg.MaxMessageSize == 0 {
+		cfg.MaxMessageSize = defaultMaxMessageSize
+	}
+	if cfg.ReadBufferSize == 0 {
+		cfg.ReadBufferSize = defaultReadBufferSize
+	}
+	if cfg.WriteBufferSize == 0 {
+		cfg.WriteBufferSize = defaultWriteBufferSize
+	}
+	if cfg.MaxCoalesce == 0 {
+		cfg.MaxCoalesce = defaultMaxCoalesce
+	}
+	return cfg
+}
+
+func (cfg Config) validate() error {
+	if cfg.WriteWait < 0 || cfg.PongWait < 0 || cfg.PingPeriod < 0 || cfg.HandlerTimeout < 0 || cfg.SlowConsumerTimeout < 0 {
+		return fmt.Errorf("%w: negative timeout", ErrorInvalidConfig)
+	}
+	if cfg.PingPeriod >= cfg.PongWait {
+		return fmt.Errorf("%w: ping period %v must be shorter than pong wait %v", ErrorInvalidConfig, cfg.PingPeriod, cfg.PongWait)
+	}
+	if cfg.MaxMessageSize < 0 || cfg.ReadBufferSize < 0 || cfg.WriteBufferSize < 0 || cfg.MaxMessagesPerSecond < 0 || cfg.MaxCoalesce < 0 || cfg.CompressionThreshold < 0 {
+		return fmt.Errorf("%w: negative size", ErrorInvalidConfig)
+	}
+	return nil
+}
+
+// writeDeadline returns the deadline for a write starting now.
+func (cfg Config) writeDeadline() time.Time {
+	if cfg.WriteWait == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(cfg.WriteWait)
+}
@@ -1,25 +1,38 @@
 package websockets
 
 import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
+	"net/netip"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"galaxy.io/server/galaxy"
+	"github.com/google/uuid"
 	ws "github.com/gorilla/websocket"
 )
 
-const (
-	writeWait      = 0
-	pongWait       = 60 * time.Second
-	pingPeriod     = (pongWait * 9) / 10
-	maxMessageSize = 512
-)
-
 // MessageHandler defines a function that processes binary messaeges
 type MessageHandler func([]byte)
 
+// TextHandler defines a function that processes text messages
+type TextHandler func(string)
+
+// Logger receives the log output of a connection. *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...any)
+}
+
 // MessageSender defines an interface for sending messages
 type MessageSender interface {
 	SendBinary(data []byte) error
@@ -27,48 +40,646 @@ type MessageSender interface {
 }
 
 type Connection struct {
-	conn      *ws.Conn
-	send      chan []byte
-	handler   MessageHandler
-	closeOnce sync.Once
-	closed    chan struct{}
+	id          uuid.UUID
+	conn        rawConn
+	send        chan frame
+	urgent      chan frame
+	handler     MessageHandler
+	textHandler TextHandler
+	middleware  []Middleware
+	config      Config
+	logger      Logger
+	overflow    OverflowPolicy
+	separate    bool
+	state       atomic.Int32
+	closeOnce   sync.Once
+	closed      chan struct{}
+	readDone    chan struct{}
+
+	// draining is closed by CloseGracefully to stop accepting frames, and
+	// flushed by the write pump once it wrote every queued one.
+	drainOnce sync.Once
+	draining  chan struct{}
+	flushed   chan struct{}
+
+	// connectedAt is when the upgrade completed, lastActivity the unix nano
+	// time of the last message read.
+	connectedAt  time.Time
+	lastActivity atomic.Int64
+
+	// sendHighWater is the longest the send buffer got, see SendHighWater.
+	sendHighWater atomic.Int64
+
+	// bytesRead and bytesWritten add up the messages read and written, see
+	// BytesRead.
+	bytesRead    atomic.Uint64
+	bytesWritten atomic.Uint64
+
+	// messages, when set, receives the inbound binary messages instead of
+	// handler.
+	messages chan []byte
+
+	onCloseMutex sync.Mutex
+	onClose      func(reason CloseReason, err error)
+
+	// cause is why the connection closed, recorded by whichever path
+	// closed it first, see WithOnClose.
+	cause atomic.Pointer[closeCause]
+
+	onWriteError func(error)
+
+	// failure is the read or write error that ended the connection, see
+	// Err.
+	failure atomic.Pointer[error]
+
+	// hubs hold the connection while it is open.
+	hubs []*Hub
+
+	metrics Metrics
+
+	checkOrigin   func(r *http.Request) bool
+	authenticator Authenticator
+	identity      *PlayerIdentity
+
+	compression      bool
+	compressionLevel atomic.Int32
+
+	// subprotocols are offered to clients in order of preference.
+	subprotocols []string
+
+	// remoteAddr is the address of the client, forwarded by one of
+	// trustedProxies if it came through them.
+	remoteAddr     net.Addr
+	trustedProxies []netip.Prefix
+
+	// pingSentAt is the unix nano time of the last unanswered ping.
+	pingSentAt atomic.Int64
+	rtt        rttTracker
+	pings      pingWaiters
+
+	// lag delays the frames sent, nil for none, see WithLag.
+	lag *lagQueue
+
+	// reads pauses the read pump, see PauseReads.
+	reads readGate
+
+	// watch tracks the progress of the write pump, see
+	// Config.SlowConsumerTimeout.
+	watch sendWatch
+}
+
+// State is the lifecycle stage of a connection.
+type State int32
+
+const (
+	StateOpen State = iota
+	// StateClosing means Close has started but the socket isn't closed yet,
+	// e.g. while waiting for the peer to acknowledge a close frame.
+	StateClosing
+	StateClosed
+)
+
+func (s State) String() string {
+	switch s {
+	case StateOpen:
+		return "open"
+	case StateClosing:
+		return "closing"
+	case StateClosed:
+		return "closed"
+	default:
+		return fmt.Sprintf("State(%d)", int32(s))
+	}
 }
 
-var upgrader = ws.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
-	// TODO: actually check the origin
-	CheckOrigin: func(r *http.Request) bool { return true },
+// Priority tells how important a frame is, see SendBinaryPriority.
+type Priority int
+
+const (
+	// PriorityLow is for routine frames, like state updates superseded by
+	// the next one. It is what SendBinary uses.
+	PriorityLow Priority = iota
+
+	// PriorityHigh is for frames the peer must not miss, like telling a
+	// player it died.
+	PriorityHigh
+)
+
+// frame is a single queued message along with its websocket opcode.
+type frame struct {
+	messageType int
+	data        []byte
+
+	// due is when the frame may be written, zero for right away, see Lag.
+	due time.Time
 }
 
-func Upgrade(w http.ResponseWriter, r *http.Request, handler MessageHandler) (*Connection, error) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// Option configures a Connection at Upgrade time.
+type Option func(*Connection)
+
+type overflowMode int
 
+const (
+	overflowDrop overflowMode = iota
+	overflowBlock
+	overflowClose
+)
+
+// OverflowPolicy decides what SendBinary does when the send buffer is full.
+type OverflowPolicy struct {
+	mode    overflowMode
+	timeout time.Duration
+}
+
+var (
+	// OverflowDrop discards the oldest queued frame to make room for the new
+	// one, SendBinary then queues it and returns ErrorFrameDropped. This is
+	// the default policy.
+	OverflowDrop = OverflowPolicy{mode: overflowDrop}
+
+	// OverflowClose closes the connection and returns ErrorBufferFull.
+	OverflowClose = OverflowPolicy{mode: overflowClose}
+)
+
+// OverflowBlockWithTimeout makes SendBinary wait up to d for the writer to
+// free a slot, returning ErrorSendTimeout if it doesn't.
+func OverflowBlockWithTimeout(d time.Duration) OverflowPolicy {
+	return OverflowPolicy{mode: overflowBlock, timeout: d}
+}
+
+// WithOverflowPolicy sets the policy applied when the send buffer is full.
+func WithOverflowPolicy(policy OverflowPolicy) Option {
+	return func(c *Connection) {
+		c.overflow = policy
+	}
+}
+
+// WithSeparateMessages writes every binary frame as its own websocket
+// message instead of coalescing queued ones, see Config.MaxCoalesce, for
+// protocols telling frames apart by the message they arrive in.
+func WithSeparateMessages() Option {
+	return func(c *Connection) {
+		c.separate = true
+	}
+}
+
+// WithCheckOrigin sets the function deciding whether the request origin is
+// acceptable. When no origin policy is configured only same-origin requests
+// are accepted.
+func WithCheckOrigin(checkOrigin func(r *http.Request) bool) Option {
+	return func(c *Connection) {
+		c.checkOrigin = checkOrigin
+	}
+}
+
+// WithAllowedOrigins accepts requests whose Origin header matches one of
+// origins (e.g. "https://galaxy.example.com"), as well as requests without
+// an Origin header, which browsers always send.
+func WithAllowedOrigins(origins ...string) Option {
+	return WithCheckOrigin(func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+
+		for _, allowed := range origins {
+			if strings.EqualFold(origin, allowed) {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// WithTextHandler sets the handler receiving text messages. Without one,
+// inbound text messages are discarded.
+func WithTextHandler(handler TextHandler) Option {
+	return func(c *Connection) {
+		c.textHandler = handler
+	}
+}
+
+// WithMessageChannel delivers inbound binary messages to the channel of
+// Messages, buffering up to size of them, instead of a MessageHandler. This
+// lets consumers select over it alongside their own timers. Reads stop
+// while the channel is full. It can't be combined with a handler, Upgrade
+// fails if both are given.
+func WithMessageChannel(size int) Option {
+	return func(c *Connection) {
+		c.messages = make(chan []byte, size)
+	}
+}
+
+// WithCompression negotiates permessage-deflate with clients that support
+// it. Compression trades CPU time on every write for smaller frames, which
+// pays off for large repetitive state updates but not for tiny messages.
+// See SetCompressionLevel and Config.CompressionThreshold to tune the
+// tradeoff.
+func WithCompression() Option {
+	return func(c *Connection) {
+		c.compression = true
+	}
+}
+
+// WithSubprotocols accepts the websocket subprotocols, in order of
+// preference, negotiating the first one the client also asks for. See
+// Subprotocol.
+func WithSubprotocols(subprotocols ...string) Option {
+	return func(c *Connection) {
+		c.subprotocols = subprotocols
+	}
+}
+
+// WithLogger routes the connection's log output to logger instead of the
+// standard logger.
+func WithLogger(logger Logger) Option {
+	return func(c *Connection) {
+		c.logger = logger
+	}
+}
+
+// CloseReason is galaxy.CloseReason, telling WithOnClose callbacks why the
+// connection closed.
+type CloseReason = galaxy.CloseReason
+
+const (
+	CloseNormal       = galaxy.CloseNormal
+	CloseKicked       = galaxy.CloseKicked
+	CloseIdle         = galaxy.CloseIdle
+	CloseNetworkError = galaxy.CloseNetworkError
+	CloseOverflow     = galaxy.CloseOverflow
+	CloseRateLimited  = galaxy.CloseRateLimited
+	CloseTimeout      = galaxy.CloseTimeout
+	CloseInternal     = galaxy.CloseInternal
+)
+
+// WithOnClose registers a callback invoked exactly once when the connection
+// closes, whatever caused it, with the reason and the error that caused it,
+// nil for clean closes.
+func WithOnClose(onClose func(reason CloseReason, err error)) Option {
+	return func(c *Connection) {
+		c.onClose = onClose
+	}
+}
+
+// WithOnWriteError registers a callback invoked with the error of a failed
+// write, from gorilla or the network, before the connection is closed
+// because of it, see Err. Connections closed on purpose never call it, telling
+// flaky networks apart from deliberate disconnects.
+func WithOnWriteError(onWriteError func(error)) Option {
+	return func(c *Connection) {
+		c.onWriteError = onWriteError
+	}
+}
+
+// WithHub registers the connection in hub once upgraded, unregistering it
+// when it closes. Connections can be in many hubs, such as the one of the
+// server and the one of their game room.
+func WithHub(hub *Hub) Option {
+	return func(c *Connection) {
+		c.hubs = append(c.hubs, hub)
+	}
+}
+
+// Upgrade upgrades the HTTP request to a websocket connection and starts its
+// read and write pumps on their own goroutines, so it returns as soon as the
+// handshake is done. Every inbound message is delivered to handler. Requests
+// that aren't websocket handshakes get a 426 and an error wrapping
+// ErrorNotWebSocket, other failed handshakes a ws.HandshakeError.
+func Upgrade(w http.ResponseWriter, r *http.Request, handler MessageHandler, opts ...Option) (*Connection, error) {
+	c, err := newConnection(handler, opts...)
 	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 		return nil, err
 	}
 
+	if !ws.IsWebSocketUpgrade(r) {
+		w.Header().Set("Upgrade", "websocket")
+		http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
+		return nil, fmt.Errorf("%w: %w", ErrorUpgradeFailed, ErrorNotWebSocket)
+	}
+
+	if c.authenticator != nil {
+		identity, err := c.authenticator(r)
+		if err != nil {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return nil, fmt.Errorf("%w: %w", ErrorUnauthorized, err)
+		}
+		c.identity = &identity
+	}
+
+	upgrader := ws.Upgrader{
+		ReadBufferSize:    c.config.ReadBufferSize,
+		WriteBufferSize:   c.config.WriteBufferSize,
+		WriteBufferPool:   c.config.WriteBufferPool,
+		CheckOrigin:       c.checkOrigin,
+		EnableCompression: c.compression,
+		Subprotocols:      c.subprotocols,
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrorUpgradeFailed, err)
+	}
+	c.remoteAddr = remoteAddr(conn.RemoteAddr(), r, c.trustedProxies)
+	c.start(conn)
+	return c, nil
+}
+
+// newConnection creates a connection configured by opts, not attached to
+// any transport yet.
+func newConnection(handler MessageHandler, opts ...Option) (*Connection, error) {
 	c := &Connection{
-		conn:    conn,
-		send:    make(chan []byte, 2048),
-		handler: handler,
-		closed:  make(chan struct{}),
+		id:       uuid.New(),
+		send:     make(chan frame, sendBufferSize),
+		urgent:   make(chan frame, urgentBufferSize),
+		handler:  handler,
+		closed:   make(chan struct{}),
+		readDone: make(chan struct{}),
+		draining: make(chan struct{}),
+		flushed:  make(chan struct{}),
+		config:   DefaultConfig(),
+		logger:   log.Default(),
+		metrics:  NopMetrics{},
 	}
+	c.compressionLevel.Store(flate.BestSpeed)
 
-	go c.readPump()
-	go c.writePump()
+	for _, opt := range opts {
+		opt(c)
+	}
 
+	if c.handler != nil && c.messages != nil {
+		return nil, ErrorHandlerAndChannel
+	}
+	if c.handler != nil {
+		c.handler = chain(c.handler, c.middleware)
+	}
+
+	c.config = c.config.withDefaults()
+	if err := c.config.validate(); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
+// start runs the connection over conn, a websocket whose handshake is done.
+func (c *Connection) start(conn rawConn) {
+	c.conn = conn
+	c.connectedAt = time.Now()
+	c.lastActivity.Store(c.connectedAt.UnixNano())
+	c.metrics.ConnectionOpened()
+	for _, hub := range c.hubs {
+		hub.Register(c)
+	}
+
+	go c.readPump()
+	go c.writePump()
+	if c.config.SlowConsumerTimeout > 0 {
+		go c.watchSends()
+	}
+}
+
+// Close tears down the connection immediately.
 func (c *Connection) Close() {
+	c.shutdown(nil)
+}
+
+// CloseWithReason sends a close frame carrying code and text, e.g.
+// ws.CloseNormalClosure and "kicked", waits briefly for the peer to
+// acknowledge it and then tears down the connection. Its onClose gets
+// CloseKicked with a *ws.CloseError carrying them.
+func (c *Connection) CloseWithReason(code int, text string) {
+	c.closeWithReason(CloseKicked, code, text)
+}
+
+// closeWithReason closes the connection like CloseWithReason, reporting
+// reason to its onClose instead of CloseKicked.
+func (c *Connection) closeWithReason(reason CloseReason, code int, text string) {
+	c.closeFor(reason, &ws.CloseError{Code: code, Text: text})
+	c.shutdown(ws.FormatCloseMessage(code, text))
+}
+
+// closeCause is why a connection closed, see closeFor.
+type closeCause struct {
+	reason CloseReason
+	err    error
+}
+
+// closeFor records reason and err as the cause of the connection closing,
+// unless it already is closing or a cause was recorded first.
+func (c *Connection) closeFor(reason CloseReason, err error) {
+	if c.IsClosed() {
+		return
+	}
+	c.cause.CompareAndSwap(nil, &closeCause{reason: reason, err: err})
+}
+
+// readFailed records why the read pump stopped for err: the peer going
+// silent, or the connection breaking without a close frame. Peers closing
+// it themselves close it cleanly, or fail it with an unexpected code.
+func (c *Connection) readFailed(err error) {
+	var closeErr *ws.CloseError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &closeErr):
+		if closeErr.Code == ws.CloseAbnormalClosure {
+			c.closeFor(CloseNetworkError, err)
+		}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		c.closeFor(CloseIdle, err)
+	default:
+		c.closeFor(CloseNetworkError, err)
+	}
+}
+
+// closeCause returns why the connection closed: the recorded cause, else
+// the read or write failure, else a clean close.
+func (c *Connection) closeCause() closeCause {
+	if cause := c.cause.Load(); cause != nil {
+		return *cause
+	}
+	if err := c.Err(); err != nil {
+		return closeCause{reason: CloseNetworkError, err: err}
+	}
+	return closeCause{reason: CloseNormal}
+}
+
+// CloseGracefully stops accepting frames, waits up to timeout for the ones
+// already queued to be written and then tears down the connection, so a
+// frame sent right before still reaches the peer. Close is for forced
+// disconnects.
+func (c *Connection) CloseGracefully(timeout time.Duration) {
+	c.drainOnce.Do(func() { close(c.draining) })
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-c.flushed:
+	case <-c.closed:
+	case <-timer.C:
+		c.logf("timed out flushing %d frames before closing", len(c.send)+len(c.urgent))
+	}
+	c.Close()
+}
+
+// isDraining reports whether CloseGracefully was called.
+func (c *Connection) isDraining() bool {
+	select {
+	case <-c.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c *Connection) shutdown(closeMessage []byte) {
 	c.closeOnce.Do(func() {
+		c.state.Store(int32(StateClosing))
 		close(c.closed)
-		close(c.send)
+
+		if closeMessage != nil {
+			deadline := time.Now().Add(closeGracePeriod)
+			if err := c.conn.WriteControl(ws.CloseMessage, closeMessage, deadline); err == nil {
+				// The read pump exits once the peer answers with its own
+				// close frame.
+				select {
+				case <-c.readDone:
+				case <-time.After(closeGracePeriod):
+				}
+			}
+		}
+
 		c.conn.Close()
+		c.state.Store(int32(StateClosed))
+		c.metrics.ConnectionClosed()
+		for _, hub := range c.hubs {
+			hub.Unregister(c)
+		}
+
+		c.onCloseMutex.Lock()
+		onClose := c.onClose
+		c.onCloseMutex.Unlock()
+		if onClose != nil {
+			cause := c.closeCause()
+			onClose(cause.reason, cause.err)
+		}
 	})
 }
 
+// SendBufferLen returns the number of frames waiting to be written.
+func (c *Connection) SendBufferLen() int {
+	return len(c.send)
+}
+
+// SendHighWater returns the most frames the send buffer ever held at once,
+// to tell whether its size fits the load. Urgent frames don't count.
+func (c *Connection) SendHighWater() int {
+	return int(c.sendHighWater.Load())
+}
+
+// observeSendLen raises the high-water mark to the current length of the
+// send buffer.
+func (c *Connection) observeSendLen() {
+	n := int64(len(c.send))
+	for {
+		high := c.sendHighWater.Load()
+		if n <= high || c.sendHighWater.CompareAndSwap(high, n) {
+			return
+		}
+	}
+}
+
+// SendBufferCap returns how many frames the send buffer holds.
+func (c *Connection) SendBufferCap() int {
+	return cap(c.send)
+}
+
+// Saturated reports whether the send buffer is nearly full, so callers can
+// hold back updates that aren't critical instead of piling them up until
+// the overflow policy kicks in.
+func (c *Connection) Saturated() bool {
+	return c.SendBufferLen() >= c.SendBufferCap()*saturationPercent/100
+}
+
+// SetOnClose replaces the callback invoked when the connection closes.
+// It has no effect once the connection is already closed.
+func (c *Connection) SetOnClose(onClose func(reason CloseReason, err error)) {
+	c.onCloseMutex.Lock()
+	c.onClose = onClose
+	c.onCloseMutex.Unlock()
+}
+
+// SetCompressionLevel sets the flate level used for subsequent messages, from
+// flate.HuffmanOnly to flate.BestCompression. Higher levels shrink frames
+// further at a steep CPU cost, the default flate.BestSpeed is usually the
+// right call for a game server. It has no effect unless compression was
+// negotiated.
+func (c *Connection) SetCompressionLevel(level int) error {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		return ErrorInvalidCompressionLevel
+	}
+	c.compressionLevel.Store(int32(level))
+	return nil
+}
+
+// Messages returns the channel of inbound binary messages, nil unless the
+// connection was upgraded WithMessageChannel. It is closed once the
+// connection stops reading, when it closes.
+func (c *Connection) Messages() <-chan []byte {
+	return c.messages
+}
+
+// ID returns the identifier assigned to the connection at Upgrade time,
+// included in every log line about it.
+func (c *Connection) ID() uuid.UUID {
+	return c.id
+}
+
+// MaxMessageSize returns the size in bytes of the largest message the
+// connection reads, see Config.MaxMessageSize.
+func (c *Connection) MaxMessageSize() int64 {
+	return c.config.MaxMessageSize
+}
+
+// Subprotocol returns the negotiated subprotocol, empty if there is none.
+func (c *Connection) Subprotocol() string {
+	return c.conn.Subprotocol()
+}
+
+// ConnectedAt returns when the connection was upgraded.
+func (c *Connection) ConnectedAt() time.Time {
+	return c.connectedAt
+}
+
+// LastActivity returns when the last message was read from the peer, or
+// ConnectedAt until one is. Pongs don't count, only messages the peer
+// sent on its own.
+func (c *Connection) LastActivity() time.Time {
+	return time.Unix(0, c.lastActivity.Load())
+}
+
+// BytesRead returns the size of every message read from the peer added
+// up, and BytesWritten of every message written to it, control frames and
+// websocket framing aside. Messages are counted before compression.
+func (c *Connection) BytesRead() uint64 {
+	return c.bytesRead.Load()
+}
+
+func (c *Connection) BytesWritten() uint64 {
+	return c.bytesWritten.Load()
+}
+
+// RTT returns the round-trip time to the peer averaged over the last few
+// pings, or zero until the first pong arrives.
+func (c *Connection) RTT() time.Duration {
+	return c.rtt.average()
+}
+
+// State returns the current lifecycle stage of the connection.
+func (c *Connection) State() State {
+	return State(c.state.Load())
+}
+
 func (c *Connection) IsClosed() bool {
 	select {
 	case <-c.closed:
@@ -78,96 +689,485 @@ func (c *Connection) IsClosed() bool {
 	}
 }
 
+// SendBinary queues data to be sent as a binary message. data is written
+// as is, possibly coalesced with other frames into the same message but
+// never modified, so the same slice can be sent to many connections. The
+// connection owns data from then on: the caller must neither modify nor
+// reuse it, even once SendBinary returned, as it is written later by the
+// write pump. Callers encoding into a reused buffer use SendBinaryCopy.
 func (c *Connection) SendBinary(data []byte) (err error) {
+	return c.enqueue(c.send, frame{messageType: ws.BinaryMessage, data: data})
+}
+
+// SendBinaryCopy queues a copy of data like SendBinary, so the caller
+// keeps ownership of data and can reuse it right away, for pooled scratch
+// buffers.
+func (c *Connection) SendBinaryCopy(data []byte) error {
+	return c.SendBinary(bytes.Clone(data))
+}
+
+// SendBinaryPriority queues data like SendBinary, with the given priority.
+// High priority frames have their own buffer and are written before any
+// low priority one, so a backlog of low priority frames being dropped by
+// the overflow policy never holds them back. Saturated only reflects low
+// priority frames.
+func (c *Connection) SendBinaryPriority(data []byte, priority Priority) error {
+	if priority == PriorityHigh {
+		return c.enqueue(c.urgent, frame{messageType: ws.BinaryMessage, data: data})
+	}
+	return c.SendBinary(data)
+}
+
+// SendText queues s to be sent as a text message, following the same
+// overflow policy as SendBinary.
+func (c *Connection) SendText(s string) error {
+	return c.enqueue(c.send, frame{messageType: ws.TextMessage, data: []byte(s)})
+}
+
+func (c *Connection) enqueue(queue chan frame, f frame) error {
+	if c.lag != nil {
+		f.due = c.lag.due()
+	}
+	err := c.tryEnqueue(queue, f)
+	switch {
+	case err == nil, errors.Is(err, ErrorFrameDropped):
+		c.metrics.MessageSent(len(f.data))
+		if queue == c.send {
+			c.observeSendLen()
+		}
+	case errors.Is(err, ErrorBufferFull), errors.Is(err, ErrorSendTimeout):
+		c.metrics.FrameDropped()
+	}
+	return err
+}
+
+func (c *Connection) tryEnqueue(queue chan frame, f frame) error {
 	select {
 	case <-c.closed:
-		log.Printf("connection closed, returning error")
+		c.logf("connection closed, returning error")
 		return ErrorConnectionClosed
 	default:
+	}
+	if c.isDraining() {
+		return ErrorConnectionClosed
+	}
+
+	select {
+	case queue <- f:
+		return nil
+	default:
+	}
+
+	switch c.overflow.mode {
+	case overflowClose:
+		c.closeFor(CloseOverflow, ErrorBufferFull)
+		c.Close()
+		return ErrorBufferFull
+	case overflowBlock:
+		timer := time.NewTimer(c.overflow.timeout)
+		defer timer.Stop()
 		select {
-		case c.send <- data:
+		case queue <- f:
 			return nil
-		default:
-			// Buffer probably full
-			// c.Close()
-			return ErrorBufferFull
+		case <-c.closed:
+			return ErrorConnectionClosed
+		case <-timer.C:
+			return ErrorSendTimeout
+		}
+	default:
+		// The writer may free a slot meanwhile, then nothing is dropped.
+		var err error
+		for {
+			select {
+			case queue <- f:
+				return err
+			case <-c.closed:
+				return ErrorConnectionClosed
+			default:
+			}
+
+			// Make room by discarding the oldest queued frame.
+			select {
+			case <-queue:
+				c.metrics.FrameDropped()
+				err = ErrorFrameDropped
+			default:
+			}
 		}
 	}
 }
 
+// SendBinaryContext queues data for sending, waiting for room in the send
+// buffer until ctx is done or the connection closes.
+func (c *Connection) SendBinaryContext(ctx context.Context, data []byte) error {
+	select {
+	case <-c.closed:
+		return ErrorConnectionClosed
+	default:
+	}
+	if c.isDraining() {
+		return ErrorConnectionClosed
+	}
+
+	f := frame{messageType: ws.BinaryMessage, data: data}
+	if c.lag != nil {
+		f.due = c.lag.due()
+	}
+	select {
+	case c.send <- f:
+		c.metrics.MessageSent(len(data))
+		c.observeSendLen()
+		return nil
+	case <-c.closed:
+		return ErrorConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// logf logs through the connection's logger, tagging the line with the
+// connection ID shortened to its first 8 hex digits, plenty to tell
+// connections apart in a log.
+func (c *Connection) logf(format string, v ...any) {
+	c.logger.Printf("[%.8s] "+format, append([]any{c.id}, v...)...)
+}
+
 func (c *Connection) readPump() {
 	defer c.Close()
-	var tzero time.Time
-	c.conn.SetReadLimit(maxMessageSize)
-	c.conn.SetReadDeadline(tzero)
-	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(tzero)
+	defer close(c.readDone)
+	if c.messages != nil {
+		defer close(c.messages)
+	}
+	c.conn.SetReadLimit(c.config.MaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(c.config.PongWait))
+	c.conn.SetPongHandler(func(payload string) error {
+		now := time.Now()
+		switch {
+		case payload != "" && c.pings.answer(payload):
+			// Answered an on-demand Ping, which measures itself.
+		case c.config.TimestampPings:
+			// The pong carries the time its ping was sent at.
+			if sentAt, ok := DecodePingTime([]byte(payload)); ok {
+				c.pingSentAt.Store(0)
+				c.rtt.add(now.Sub(sentAt))
+			}
+		case payload == "":
+			if sentAt := c.pingSentAt.Swap(0); sentAt != 0 {
+				c.rtt.add(now.Sub(time.Unix(0, sentAt)))
+			}
+		}
+		c.conn.SetReadDeadline(now.Add(c.config.PongWait))
 		return nil
 	})
 
+	limiter := newRateLimiter(c.config.MaxMessagesPerSecond)
+
 	for {
-		_, message, err := c.conn.ReadMessage()
+		c.waitReads()
+		messageType, message, err := c.conn.ReadMessage()
 		if err != nil {
-			if ws.IsUnexpectedCloseError(err, ws.CloseGoingAway, ws.CloseAbnormalClosure) {
-				log.Printf("error during websocket pump: %v", err)
+			// Peers closing the connection themselves is a clean
+			// shutdown, not an error.
+			if ws.IsUnexpectedCloseError(err, ws.CloseNormalClosure, ws.CloseGoingAway, ws.CloseNoStatusReceived, ws.CloseAbnormalClosure) && !c.IsClosed() {
+				c.logf("error during websocket pump: %v", c.fail(ErrorReadFailed, err))
 			}
-			c.Close()
+			c.readFailed(err)
+			// The deferred Close runs after readDone is closed, so a
+			// pending CloseWithReason isn't kept waiting.
 			return
 		}
+		c.lastActivity.Store(time.Now().UnixNano())
+		c.bytesRead.Add(uint64(len(message)))
+		c.metrics.MessageReceived(len(message))
+
+		if limiter != nil && !limiter.allow(time.Now()) {
+			if c.config.CloseOnRateLimit {
+				// Closing waits for this pump to see the peer's close
+				// frame, so it can't run on this goroutine.
+				go c.closeWithReason(CloseRateLimited, ws.ClosePolicyViolation, "rate limit exceeded")
+			}
+			continue
+		}
 
-		if c.handler != nil {
-			c.handler(message)
+		// Messages arriving while closing are dropped, the loop goes on
+		// until the peer acknowledges the close frame.
+		if c.IsClosed() {
+			continue
 		}
+
+		switch messageType {
+		case ws.BinaryMessage:
+			// Empty frames carry nothing to decode.
+			if len(message) == 0 {
+				continue
+			}
+			if c.messages != nil {
+				select {
+				case c.messages <- message:
+				case <-c.closed:
+					return
+				}
+			} else if c.handler != nil {
+				c.dispatch(func() { c.handler(message) })
+			}
+		case ws.TextMessage:
+			if c.textHandler != nil {
+				c.dispatch(func() { c.textHandler(string(message)) })
+			}
+		}
+	}
+}
+
+// dispatch runs a handler, recovering from its panics so a bug handling one
+// message only takes down its connection instead of the process. The
+// connection is closed with an internal error close frame. Past
+// Config.HandlerTimeout the late handler is logged, and the connection
+// closed if configured so, but the next message is only read once it
+// returned: handlers rely on running one at a time, in order.
+func (c *Connection) dispatch(handler func()) {
+	timeout := c.config.HandlerTimeout
+	if timeout == 0 {
+		c.recovering(handler)
+		return
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		c.recovering(handler)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return
+	case <-timer.C:
+	}
+
+	c.logf("handler still running after %v", timeout)
+	if !c.config.CloseOnHandlerTimeout {
+		<-done
+		return
 	}
+	// Like for the rate limit, closing can't run on the read pump. Once
+	// closing nothing is handled anymore, the pump only reads on to see
+	// the peer's close frame.
+	go c.closeWithReason(CloseTimeout, ws.CloseInternalServerErr, "handler timed out")
+	select {
+	case <-done:
+	case <-c.closed:
+	}
+}
+
+// recovering runs handler for dispatch, recovering from its panics.
+func (c *Connection) recovering(handler func()) {
+	defer func() {
+		if r := recover(); r != nil {
+			c.logf("handler panicked: %v\n%s", r, debug.Stack())
+			// Like for the rate limit, closing can't run on the read
+			// pump.
+			go c.closeWithReason(CloseInternal, ws.CloseInternalServerErr, "internal error")
+		}
+	}()
+	handler()
 }
 
 func (c *Connection) writePump() {
-	var tzero time.Time
-	// ticker := time.NewTicker(pingPeriod)
+	ticker := time.NewTicker(c.config.PingPeriod)
+	// The whole connection is closed, not only the socket: the read pump
+	// may be paused or busy in a handler and not notice for a while, and
+	// meanwhile senders would queue frames nobody writes.
 	defer func() {
-		// ticker.Stop()
-		c.conn.Close()
+		ticker.Stop()
+		c.Close()
 	}()
 
+	// gorilla's compression level isn't safe to change concurrently with
+	// writes, so it is applied from this goroutine only.
+	var compressionLevel int32 = flate.BestSpeed
+
+	// pending holds a frame pulled while coalescing that couldn't be merged
+	// into the previous message.
+	var pending *frame
+
+	// draining becomes nil once CloseGracefully was called, the queue only
+	// shrinks from then on.
+	draining := c.draining
+	var flushOnce sync.Once
+
 	for {
+		if draining == nil && pending == nil && len(c.send) == 0 && len(c.urgent) == 0 {
+			flushOnce.Do(func() { close(c.flushed) })
+		}
+
+		// A busy send queue must not delay pings, or the peer times us out
+		// while we are actively writing to it.
 		select {
-		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(tzero)
-			if !ok {
-				c.conn.WriteMessage(ws.CloseMessage, []byte{})
+		case <-ticker.C:
+			if err := c.writePing(); err != nil {
+				c.writeFailed(err, 0)
 				return
 			}
+		default:
+		}
 
-			w, err := c.conn.NextWriter(ws.BinaryMessage)
-			if err != nil {
+		var message frame
+		if pending != nil {
+			message, pending = *pending, nil
+		} else if len(c.urgent) > 0 {
+			message = <-c.urgent
+		} else {
+			select {
+			case message = <-c.urgent:
+			case message = <-c.send:
+			case <-c.closed:
 				return
+			case <-draining:
+				draining = nil
+				continue
+
+			case <-ticker.C:
+				if err := c.writePing(); err != nil {
+					c.writeFailed(err, 0)
+					return
+				}
+				continue
 			}
+		}
 
-			w.Write(message)
+		if !message.due.IsZero() && !c.waitUntil(message.due) {
+			return
+		}
+		if level := c.compressionLevel.Load(); level != compressionLevel {
+			c.conn.SetCompressionLevel(int(level))
+			compressionLevel = level
+		}
 
-			for range len(c.send) {
-				w.Write(<-c.send)
-			}
+		batch := [][]byte{message.data}
+		written := len(message.data)
 
-			if err := w.Close(); err != nil {
-				log.Printf("error while closing writepump %v", err)
-				return
+		// Only binary frames are coalesced, text frames carry standalone
+		// documents. Senders may drop queued frames concurrently under
+		// OverflowDrop, so never block waiting for the reported length.
+		// Messages stay within MaxMessageSize since the peer enforces the
+		// same read limit, frames that don't fit start the next message.
+		if message.messageType == ws.BinaryMessage && !c.separate {
+			size := int64(len(message.data))
+		coalesce:
+			for range min(len(c.send), c.config.MaxCoalesce-1) {
+				select {
+				case queued := <-c.send:
+					if queued.messageType != ws.BinaryMessage || size+int64(len(queued.data)) > c.config.MaxMessageSize || time.Now().Before(queued.due) {
+						pending = &queued
+						break coalesce
+					}
+					size += int64(len(queued.data))
+					batch = append(batch, queued.data)
+					written += len(queued.data)
+				default:
+					break coalesce
+				}
 			}
+		}
 
-		case <-c.closed:
+		if c.compression && c.config.CompressionThreshold > 0 {
+			c.conn.EnableWriteCompression(written >= c.config.CompressionThreshold)
+		}
+		c.conn.SetWriteDeadline(c.config.writeDeadline())
+		c.watch.writing.Store(true)
+		err := c.writeMessage(message.messageType, batch)
+		c.watch.writing.Store(false)
+		if err != nil {
+			c.writeFailed(err, len(batch))
 			return
-
-		// case <-ticker.C:
-		// 	log.Printf("ticker clock")
-		// 	c.conn.SetWriteDeadline(tzero)
-		// 	if err := c.conn.WriteMessage(ws.PingMessage, nil); err != nil {
-		// 		return
-		// 	}
 		}
+		c.watch.written.Add(1)
+		c.bytesWritten.Add(uint64(written))
+		c.metrics.MessageWritten(written)
+	}
+}
+
+// writeMessage writes batch to the peer as a single message.
+func (c *Connection) writeMessage(messageType int, batch [][]byte) error {
+	w, err := c.conn.NextWriter(messageType)
+	if err != nil {
+		return err
+	}
+	for _, data := range batch {
+		w.Write(data)
+	}
+	return w.Close()
+}
+
+// writeFailed records err as the write error ending the connection and
+// reports it to the OnWriteError callback, unless the write failed because
+// the connection was being closed on purpose. lost is the number of frames
+// the failed write took down with it, counted as dropped.
+func (c *Connection) writeFailed(err error, lost int) {
+	for range lost {
+		c.metrics.FrameDropped()
+	}
+	if c.IsClosed() {
+		return
+	}
+
+	err = c.fail(ErrorWriteFailed, err)
+	c.logf("%v, lost %d frames", err, lost)
+	if c.onWriteError != nil {
+		c.onWriteError(err)
+	}
+}
+
+// fail records the error that ended the connection, the first one wins.
+// It returns err wrapped in ErrorConnectionClosed and kind, so callers can
+// tell it apart with errors.Is.
+func (c *Connection) fail(kind error, err error) error {
+	err = fmt.Errorf("%w: %w: %w", ErrorConnectionClosed, kind, err)
+	c.failure.CompareAndSwap(nil, &err)
+	return err
+}
+
+// Err returns the error that ended the connection, wrapping
+// ErrorConnectionClosed and either ErrorReadFailed or ErrorWriteFailed, or
+// nil while it is open and once closed on purpose by either side.
+func (c *Connection) Err() error {
+	if err := c.failure.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func (c *Connection) writePing() error {
+	c.conn.SetWriteDeadline(c.config.writeDeadline())
+	now := time.Now()
+	c.pingSentAt.Store(now.UnixNano())
+	var payload []byte
+	if c.config.TimestampPings {
+		payload = binary.LittleEndian.AppendUint64(nil, uint64(now.UnixNano()))
 	}
+	return c.conn.WriteMessage(ws.PingMessage, payload)
 }
 
 var (
 	ErrorConnectionClosed = fmt.Errorf("Connection closed")
 	ErrorBufferFull       = fmt.Errorf("Send buffer full")
+	ErrorSendTimeout      = fmt.Errorf("Timed out waiting for send buffer")
+	ErrorReadFailed       = fmt.Errorf("Read failed")
+	ErrorWriteFailed      = fmt.Errorf("Write failed")
+	ErrorUpgradeFailed    = fmt.Errorf("Upgrade failed")
+	ErrorUnauthorized     = fmt.Errorf("Unauthorized")
+	ErrorInvalidConfig    = fmt.Errorf("Invalid websocket config")
+
+	// ErrorNotWebSocket is galaxy.ErrorNotWebSocket, so games recognize
+	// it behind their ConnectionFactory.
+	ErrorNotWebSocket = galaxy.ErrorNotWebSocket
+
+	// ErrorFrameDropped is galaxy.ErrorFrameDropped, returned under
+	// OverflowDrop when an older frame was discarded. Unlike ErrorBufferFull,
+	// which OverflowClose returns, the frame was queued and the connection
+	// stays open.
+	ErrorFrameDropped = galaxy.ErrorFrameDropped
+
+	ErrorInvalidCompressionLevel = fmt.Errorf("Invalid compression level")
+	ErrorHandlerAndChannel       = fmt.Errorf("Connection can't have both a handler and a message channel")
 )
@@ -0,0 +1,983 @@
+package websockets
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	"galaxy.io/server/galaxy/utils"
+	ws "github.com/gorilla/websocket"
+)
+
+// upgradeServer serves Upgrade over httptest, handing every connection it
+// accepts to conns.
+func upgradeServer(t testing.TB, handler MessageHandler, opts ...Option) (*httptest.Server, chan *Connection) {
+	t.Helper()
+
+	conns := make(chan *Connection, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, handler, opts...)
+		if err != nil {
+			t.Errorf("Upgrade: %v", err)
+			return
+		}
+		conns <- c
+	}))
+	t.Cleanup(server.Close)
+	return server, conns
+}
+
+// dial opens a client websocket to server.
+func dial(t testing.TB, server *httptest.Server) *ws.Conn {
+	t.Helper()
+	return dialWith(t, server, ws.DefaultDialer)
+}
+
+func dialWith(t testing.TB, server *httptest.Server, dialer *ws.Dialer) *ws.Conn {
+	t.Helper()
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	client, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+// countingConn counts the bytes read from a net.Conn.
+type countingConn struct {
+	net.Conn
+	read *atomic.Int64
+}
+
+func (c countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.read.Add(int64(n))
+	return n, err
+}
+
+// countingDialer returns a dialer negotiating compression if compress is
+// set, adding up in read the bytes its connections read off the wire.
+func countingDialer(compress bool, read *atomic.Int64) *ws.Dialer {
+	return &ws.Dialer{
+		EnableCompression: compress,
+		NetDial: func(network, addr string) (net.Conn, error) {
+			conn, err := net.Dial(network, addr)
+			if err != nil {
+				return nil, err
+			}
+			return countingConn{Conn: conn, read: read}, nil
+		},
+	}
+}
+
+// snapshotFrame returns the OpStateSnapshot frame of a viewport of
+// players, the bulk of what game servers send.
+func snapshotFrame(players int) []byte {
+	random := rand.New(rand.NewPCG(1, 1))
+	entities := make([]galaxy.Entity, players)
+	for i := range entities {
+		entities[i] = galaxy.Entity{
+			Kind:     galaxy.EntityPlayer,
+			NetID:    uint32(i + 1),
+			Position: utils.Vector2D{X: random.Float64() * 10000, Y: random.Float64() * 10000},
+			Radius:   uint32(50 + random.IntN(200)),
+			Color:    galaxy.FoodColors[random.IntN(len(galaxy.FoodColors))],
+		}
+	}
+	snapshot := galaxy.StateSnapshot{Header: galaxy.SnapshotHeader{Sequence: 1, Time: time.Now()}, Entities: entities}
+	return galaxy.EncodeFrame(galaxy.OpStateSnapshot, galaxy.BinaryCodec{}.EncodeSnapshot(snapshot))
+}
+
+func TestUpgradeRunsPumpsInBackground(t *testing.T) {
+	received := make(chan []byte, 1)
+	server, conns := upgradeServer(t, func(data []byte) { received <- data })
+	client := dial(t, server)
+
+	var c *Connection
+	select {
+	case c = <-conns:
+	case <-time.After(time.Second):
+		t.Fatal("Upgrade didn't return after the handshake")
+	}
+	defer c.Close()
+
+	// Reading and writing at once needs both pumps running.
+	if err := c.SendBinary([]byte("to client")); err != nil {
+		t.Fatalf("SendBinary: %v", err)
+	}
+	if err := client.WriteMessage(ws.BinaryMessage, []byte("to server")); err != nil {
+		t.Fatalf("WriteMessage: %v", err)
+	}
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	messageType, data, err := client.ReadMessage()
+	if err != nil {
+		t.Fatalf("ReadMessage: %v", err)
+	}
+	if messageType != ws.BinaryMessage || string(data) != "to client" {
+		t.Errorf("client got %v %q, want a binary %q", messageType, data, "to client")
+	}
+
+	select {
+	case data := <-received:
+		if string(data) != "to server" {
+			t.Errorf("handler got %q, want %q", data, "to server")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler never got the client's message")
+	}
+}
+
+// upgradeError serves a single Upgrade of request, returning the response
+// and the error of Upgrade.
+func upgradeError(t *testing.T, request func(url string) *http.Request) (*http.Response, error) {
+	t.Helper()
+
+	errs := make(chan error, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, err := Upgrade(w, r, func([]byte) {}, WithLogger(log.New(io.Discard, "", 0)))
+		if err == nil {
+			c.Close()
+		}
+		errs <- err
+	}))
+	defer server.Close()
+
+	response, err := http.DefaultClient.Do(request(server.URL))
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	response.Body.Close()
+	return response, <-errs
+}
+
+func TestUpgradeRejectsPlainHTTP(t *testing.T) {
+	response, err := upgradeError(t, func(url string) *http.Request {
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		return request
+	})
+
+	if !errors.Is(err, ErrorNotWebSocket) {
+		t.Errorf("Upgrade of a plain GET: got %v, want %v", err, ErrorNotWebSocket)
+	}
+	if response.StatusCode != http.StatusUpgradeRequired || response.Header.Get("Upgrade") != "websocket" {
+		t.Errorf("status %d, Upgrade %q, want %d asking for a websocket", response.StatusCode, response.Header.Get("Upgrade"), http.StatusUpgradeRequired)
+	}
+}
+
+func TestUpgradeReportsFailedHandshakes(t *testing.T) {
+	_, err := upgradeError(t, func(url string) *http.Request {
+		request, _ := http.NewRequest(http.MethodGet, url, nil)
+		request.Header.Set("Connection", "Upgrade")
+		request.Header.Set("Upgrade", "websocket")
+		request.Header.Set("Sec-WebSocket-Version", "8")
+		return request
+	})
+
+	var handshake ws.HandshakeError
+	if !errors.As(err, &handshake) {
+		t.Errorf("Upgrade of a bad handshake: got %v, want a %T", err, handshake)
+	}
+	if errors.Is(err, ErrorNotWebSocket) {
+		t.Error("a bad websocket handshake was reported as a plain request")
+	}
+}
+
+// closedFor upgrades a connection with opts, lets send drive the client
+// and returns the reason the connection reported to its onClose.
+func closedFor(t *testing.T, handler MessageHandler, send func(client *ws.Conn), opts ...Option) CloseReason {
+	t.Helper()
+
+	reasons := make(chan CloseReason, 1)
+	opts = append(opts,
+		WithLogger(log.New(io.Discard, "", 0)),
+		WithOnClose(func(reason CloseReason, err error) { reasons <- reason }),
+	)
+	server, _ := upgradeServer(t, handler, opts...)
+	client := dial(t, server)
+	send(client)
+
+	// Reading answers the close frame of the server.
+	client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := client.ReadMessage(); err != nil {
+			break
+		}
+	}
+	select {
+	case reason := <-reasons:
+		return reason
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection never closed")
+		return 0
+	}
+}
+
+func TestCloseReasons(t *testing.T) {
+	message := func(client *ws.Conn) {
+		client.WriteMessage(ws.BinaryMessage, []byte("message"))
+	}
+
+	t.Run("rate limited", func(t *testing.T) {
+		config := DefaultConfig()
+		config.MaxMessagesPerSecond = 1
+		config.CloseOnRateLimit = true
+		flood := func(client *ws.Conn) {
+			for range 10 {
+				message(client)
+			}
+		}
+		if reason := closedFor(t, func([]byte) {}, flood, WithConfig(config)); reason != CloseRateLimited {
+			t.Errorf("closed for %v, want %v", reason, CloseRateLimited)
+		}
+	})
+
+	t.Run("handler timeout", func(t *testing.T) {
+		config := DefaultConfig()
+		config.HandlerTimeout = 10 * time.Millisecond
+		config.CloseOnHandlerTimeout = true
+		release := make(chan struct{})
+		defer close(release)
+		slow := func([]byte) { <-release }
+		if reason := closedFor(t, slow, message, WithConfig(config)); reason != CloseTimeout {
+			t.Errorf("closed for %v, want %v", reason, CloseTimeout)
+		}
+	})
+
+	t.Run("handler panic", func(t *testing.T) {
+		panicking := func([]byte) { panic("bug") }
+		if reason := closedFor(t, panicking, message); reason != CloseInternal {
+			t.Errorf("closed for %v, want %v", reason, CloseInternal)
+		}
+	})
+}
+
+func TestOnCloseRunsOnceAfterReadError(t *testing.T) {
+	var calls atomic.Int32
+	reasons := make(chan CloseReason, 4)
+	c, conn := startFake(t, func([]byte) {}, WithOnClose(func(reason CloseReason, err error) {
+		calls.Add(1)
+		reasons <- reason
+	}))
+
+	conn.failRead(errorFakeTransport)
+	select {
+	case reason := <-reasons:
+		if reason != CloseNetworkError {
+			t.Errorf("closed for %v, want %v", reason, CloseNetworkError)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onClose never ran after the read failed")
+	}
+
+	c.Close()
+	c.CloseWithReason(ws.CloseNormalClosure, "again")
+	if n := calls.Load(); n != 1 {
+		t.Errorf("onClose ran %d times, want 1", n)
+	}
+}
+
+func BenchmarkSnapshotCompression(b *testing.B) {
+	frame := snapshotFrame(50)
+	for _, compress := range []bool{false, true} {
+		name := "uncompressed"
+		var opts []Option
+		if compress {
+			name, opts = "compressed", []Option{WithCompression()}
+		}
+
+		b.Run(name, func(b *testing.B) {
+			server, conns := upgradeServer(b, func([]byte) {}, opts...)
+			var read atomic.Int64
+			client := dialWith(b, server, countingDialer(compress, &read))
+			c := <-conns
+			defer c.Close()
+
+			read.Store(0)
+			b.ResetTimer()
+			for range b.N {
+				if err := c.SendBinary(frame); err != nil {
+					b.Fatalf("SendBinary: %v", err)
+				}
+				if _, _, err := client.ReadMessage(); err != nil {
+					b.Fatalf("ReadMessage: %v", err)
+				}
+			}
+			b.ReportMetric(float64(read.Load())/float64(b.N), "wire-bytes/op")
+		})
+	}
+}
+
+func TestPingsFlowUnderSustainedLoad(t *testing.T) {
+	config := DefaultConfig()
+	config.PingPeriod = 20 * time.Millisecond
+	config.WriteWait = 20 * time.Millisecond
+	c, conn := startFake(t, nil, WithConfig(config), WithOverflowPolicy(OverflowDrop))
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		frame := []byte("snapshot")
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				c.SendBinary(frame)
+			}
+		}
+	}()
+
+	start := time.Now()
+	conn.next(t, ws.PingMessage)
+	if elapsed := time.Since(start); elapsed > config.PingPeriod+config.WriteWait+50*time.Millisecond {
+		t.Errorf("first ping written after %v, want within %v", elapsed, config.PingPeriod+config.WriteWait)
+	}
+}
+
+func TestCoalescedMessagesFitMaxMessageSize(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxMessageSize = 512
+
+	// Writes block until read, so the frames pile up behind the first.
+	conn := newFakeConn()
+	conn.writes = make(chan written)
+	c, _ := startOver(t, conn, nil, WithConfig(config))
+
+	frame := make([]byte, 200)
+	const frames = 20
+	for range frames {
+		if err := c.SendBinary(frame); err != nil {
+			t.Fatalf("SendBinary: %v", err)
+		}
+	}
+
+	total, messages := 0, 0
+	for total < frames*len(frame) {
+		message := conn.next(t, ws.BinaryMessage)
+		if int64(len(message.data)) > config.MaxMessageSize {
+			t.Errorf("message of %d bytes written, over MaxMessageSize %d", len(message.data), config.MaxMessageSize)
+		}
+		total += len(message.data)
+		messages++
+	}
+	if messages == frames {
+		t.Error("no frames were coalesced")
+	}
+}
+
+func TestHandlerPanicClosesOnlyItsConnection(t *testing.T) {
+	handled := make(chan string, 4)
+	handler := func(data []byte) {
+		if string(data) == "panic" {
+			panic("handler bug")
+		}
+		handled <- string(data)
+	}
+	panicking, panickingConn := startFake(t, handler)
+	other, otherConn := startFake(t, handler)
+
+	panickingConn.receive([]byte("panic"))
+	select {
+	case <-panickingConn.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection of the panicking handler never closed")
+	}
+	if !panicking.IsClosed() {
+		t.Error("connection of the panicking handler not reported closed")
+	}
+
+	otherConn.receive([]byte("still here"))
+	select {
+	case data := <-handled:
+		if data != "still here" {
+			t.Errorf("other connection handled %q", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("other connection stopped handling messages")
+	}
+	if other.IsClosed() {
+		t.Error("other connection closed by the panic")
+	}
+}
+
+func TestSlowHandlersKeepMessagesInOrder(t *testing.T) {
+	config := DefaultConfig()
+	config.HandlerTimeout = 5 * time.Millisecond
+	var running atomic.Int32
+	handled := make(chan string, 8)
+	handler := func(data []byte) {
+		if running.Add(1) != 1 {
+			t.Errorf("handler of %q ran alongside another", data)
+		}
+		if string(data) == "slow" {
+			time.Sleep(10 * config.HandlerTimeout)
+		}
+		handled <- string(data)
+		running.Add(-1)
+	}
+	c, conn := startFake(t, handler, WithConfig(config), WithLogger(log.New(io.Discard, "", 0)))
+
+	want := []string{"slow", "second", "third"}
+	for _, message := range want {
+		conn.receive([]byte(message))
+	}
+	for _, message := range want {
+		select {
+		case data := <-handled:
+			if data != message {
+				t.Fatalf("handled %q, want %q", data, message)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("%q never handled", message)
+		}
+	}
+	if c.IsClosed() {
+		t.Error("connection closed for a slow handler without CloseOnHandlerTimeout")
+	}
+}
+
+func TestSendBinaryCopyOwnsItsFrame(t *testing.T) {
+	// Writes block until read, so the frame is still queued behind the
+	// first one when the buffer is reused.
+	conn := newFakeConn()
+	conn.writes = make(chan written)
+	c, _ := startOver(t, conn, nil)
+	c.SendText("blocking")
+
+	buffer := []byte("first")
+	if err := c.SendBinaryCopy(buffer); err != nil {
+		t.Fatalf("SendBinaryCopy: %v", err)
+	}
+	copy(buffer, "reuse")
+
+	if w := conn.next(t, ws.BinaryMessage); string(w.data) != "first" {
+		t.Errorf("sent %q, want %q", w.data, "first")
+	}
+}
+
+// droppedMetrics counts the frames dropped.
+type droppedMetrics struct {
+	NopMetrics
+	dropped atomic.Int32
+}
+
+func (m *droppedMetrics) FrameDropped() {
+	m.dropped.Add(1)
+}
+
+func TestNextWriterErrorSurfaces(t *testing.T) {
+	writeErrors := make(chan error, 1)
+	reasons := make(chan CloseReason, 1)
+	metrics := &droppedMetrics{}
+	conn := newFakeConn()
+	conn.nextWriterErr = errorFakeTransport
+	c, _ := startOver(t, conn, nil,
+		WithMetrics(metrics),
+		WithOnWriteError(func(err error) { writeErrors <- err }),
+		WithOnClose(func(reason CloseReason, err error) { reasons <- reason }),
+	)
+
+	if err := c.SendBinary([]byte("lost")); err != nil {
+		t.Fatalf("SendBinary: %v", err)
+	}
+	select {
+	case err := <-writeErrors:
+		if !errors.Is(err, ErrorWriteFailed) || !errors.Is(err, errorFakeTransport) {
+			t.Errorf("write error %v, want the NextWriter error wrapped in %v", err, ErrorWriteFailed)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("the NextWriter error was never reported")
+	}
+	select {
+	case reason := <-reasons:
+		if reason != CloseNetworkError {
+			t.Errorf("closed for %v, want %v", reason, CloseNetworkError)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection never closed after the failed write")
+	}
+
+	if err := c.Err(); !errors.Is(err, errorFakeTransport) {
+		t.Errorf("Err() = %v, want the NextWriter error", err)
+	}
+	if dropped := metrics.dropped.Load(); dropped != 1 {
+		t.Errorf("%d frames counted dropped, want the lost one", dropped)
+	}
+}
+
+func TestSeparateMessagesAreNotCoalesced(t *testing.T) {
+	conn := newFakeConn()
+	conn.writes = make(chan written)
+	c, _ := startOver(t, conn, nil, WithSeparateMessages())
+
+	frames := []string{"first", "second", "third"}
+	for _, frame := range frames {
+		c.SendBinary([]byte(frame))
+	}
+	for _, frame := range frames {
+		if w := conn.next(t, ws.BinaryMessage); string(w.data) != frame {
+			t.Fatalf("wrote %q, want %q on its own", w.data, frame)
+		}
+	}
+}
+
+// BenchmarkConnectionChurn opens a connection, sends it a snapshot and
+// closes it on every iteration, with and without a shared write buffer
+// pool. Buffers are sized for whole snapshots, where sharing them pays:
+// the pool saves their bytes on every connection, B/op.
+func BenchmarkConnectionChurn(b *testing.B) {
+	frame := snapshotFrame(20)
+	for _, pooled := range []bool{false, true} {
+		name := "own buffers"
+		config := DefaultConfig()
+		config.WriteBufferSize = 32 * 1024
+		if pooled {
+			name, config.WriteBufferPool = "shared pool", NewBufferPool()
+		}
+
+		b.Run(name, func(b *testing.B) {
+			server, conns := upgradeServer(b, func([]byte) {}, WithConfig(config), WithLogger(log.New(io.Discard, "", 0)))
+			b.ReportAllocs()
+			for range b.N {
+				client := dial(b, server)
+				c := <-conns
+				c.SendBinary(frame)
+				if _, _, err := client.ReadMessage(); err != nil {
+					b.Fatalf("ReadMessage: %v", err)
+				}
+				c.Close()
+				client.Close()
+			}
+		})
+	}
+}
+
+func TestWriteFailureClosesConnection(t *testing.T) {
+	conn := newFakeConn()
+	conn.nextWriterErr = errorFakeTransport
+	// Reads never fail, only the write pump can notice.
+	c, _ := startOver(t, conn, nil)
+
+	c.SendBinary([]byte("lost"))
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("connection still open after its write failed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	if err := c.SendBinary([]byte("after")); !errors.Is(err, ErrorConnectionClosed) {
+		t.Errorf("SendBinary after the write failed: got %v, want %v", err, ErrorConnectionClosed)
+	}
+	select {
+	case <-conn.closed:
+	case <-time.After(2 * time.Second):
+		t.Error("socket left open after the write failed")
+	}
+}
+
+// TestTimedOutWriteIsFinal checks that a write timing out ends the
+// connection even though the transport would take the next one, gorilla's
+// never does after a failed write.
+func TestTimedOutWriteIsFinal(t *testing.T) {
+	conn := newFakeConn()
+	conn.failNextWrite = os.ErrDeadlineExceeded
+	c, _ := startOver(t, conn, nil)
+
+	c.SendBinary([]byte("timed out"))
+	select {
+	case <-conn.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("connection still open after its write timed out")
+	}
+	if err := c.Err(); !errors.Is(err, ErrorWriteFailed) || !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Err() = %v, want the timed out write", err)
+	}
+	select {
+	case w := <-conn.writes:
+		if w.messageType == ws.BinaryMessage {
+			t.Errorf("timed out write retried: %q", w.data)
+		}
+	default:
+	}
+}
+
+func TestCompressionThreshold(t *testing.T) {
+	config := DefaultConfig()
+	config.CompressionThreshold = 100
+	conn := newFakeConn()
+	c, _ := startOver(t, conn, nil, WithConfig(config), WithCompression(), WithSeparateMessages())
+
+	c.SendBinary(make([]byte, 10))
+	conn.next(t, ws.BinaryMessage)
+	c.SendBinary(make([]byte, 1000))
+	conn.next(t, ws.BinaryMessage)
+
+	conn.mutex.Lock()
+	defer conn.mutex.Unlock()
+	if len(conn.compressed) != 2 || conn.compressed[0] || !conn.compressed[1] {
+		t.Errorf("compression enabled %v, want only for the message over the threshold", conn.compressed)
+	}
+}
+
+// BenchmarkCompressionThreshold sends acks too small to be worth
+// compressing along with snapshots, compressing everything or only what is
+// over the threshold.
+func BenchmarkCompressionThreshold(b *testing.B) {
+	ack := snapshotFrame(0)
+	snapshot := snapshotFrame(50)
+	for _, threshold := range []int{0, 256} {
+		config := DefaultConfig()
+		config.CompressionThreshold = threshold
+
+		b.Run(fmt.Sprintf("threshold %d", threshold), func(b *testing.B) {
+			server, conns := upgradeServer(b, func([]byte) {}, WithConfig(config), WithCompression(), WithSeparateMessages())
+			client := dialWith(b, server, &ws.Dialer{EnableCompression: true})
+			c := <-conns
+			defer c.Close()
+
+			b.ResetTimer()
+			for range b.N {
+				for range 9 {
+					c.SendBinary(ack)
+				}
+				c.SendBinary(snapshot)
+				for range 10 {
+					if _, _, err := client.ReadMessage(); err != nil {
+						b.Fatalf("ReadMessage: %v", err)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCloseGracefullyFlushesQueuedFrames(t *testing.T) {
+	// Writes block until read, so the death is still queued when closing.
+	conn := newFakeConn()
+	conn.writes = make(chan written)
+	c, _ := startOver(t, conn, nil)
+	c.SendText("blocking")
+	if err := c.SendBinary([]byte("death")); err != nil {
+		t.Fatalf("SendBinary: %v", err)
+	}
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		c.CloseGracefully(2 * time.Second)
+	}()
+	for !c.isDraining() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := c.SendBinary([]byte("late")); !errors.Is(err, ErrorConnectionClosed) {
+		t.Errorf("SendBinary while closing: got %v, want %v", err, ErrorConnectionClosed)
+	}
+
+	if w := conn.next(t, ws.BinaryMessage); string(w.data) != "death" {
+		t.Errorf("flushed %q, want %q", w.data, "death")
+	}
+	select {
+	case <-closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("CloseGracefully didn't return once the queue was flushed")
+	}
+	if !c.IsClosed() {
+		t.Error("connection still open after CloseGracefully")
+	}
+}
+
+// saturated runs a connection whose write pump is stuck writing a text
+// frame nobody reads and whose send buffer is full of "low" frames.
+func saturated(t *testing.T, opts ...Option) (*Connection, *fakeConn) {
+	t.Helper()
+
+	conn := newFakeConn()
+	conn.writes = make(chan written)
+	c, _ := startOver(t, conn, nil, opts...)
+	c.SendText("blocking")
+	for len(c.send) > 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for range cap(c.send) {
+		if err := c.SendBinary([]byte("low")); err != nil {
+			t.Fatalf("SendBinary filling the buffer: %v", err)
+		}
+	}
+	return c, conn
+}
+
+func TestPriorityFramesOvertakeSaturatedQueue(t *testing.T) {
+	c, conn := saturated(t)
+	if err := c.SendBinary([]byte("low")); !errors.Is(err, ErrorFrameDropped) {
+		t.Fatalf("SendBinary over the full buffer: got %v, want %v", err, ErrorFrameDropped)
+	}
+	if err := c.SendBinaryPriority([]byte("death"), PriorityHigh); err != nil {
+		t.Fatalf("SendBinaryPriority: %v", err)
+	}
+
+	if w := conn.next(t, ws.BinaryMessage); !bytes.HasPrefix(w.data, []byte("death")) {
+		t.Errorf("first binary message written is %.16q, want the priority frame", w.data)
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	t.Run("drop", func(t *testing.T) {
+		dropped := &droppedMetrics{}
+		c, _ := saturated(t, WithOverflowPolicy(OverflowDrop), WithMetrics(dropped))
+		if err := c.SendBinary([]byte("new")); !errors.Is(err, ErrorFrameDropped) {
+			t.Errorf("SendBinary: got %v, want %v", err, ErrorFrameDropped)
+		}
+		if dropped.dropped.Load() != 1 || len(c.send) != cap(c.send) {
+			t.Errorf("%d frames dropped and %d queued, want 1 and %d", dropped.dropped.Load(), len(c.send), cap(c.send))
+		}
+		if c.IsClosed() {
+			t.Error("dropping a frame closed the connection")
+		}
+	})
+
+	t.Run("block with timeout", func(t *testing.T) {
+		timeout := 20 * time.Millisecond
+		c, _ := saturated(t, WithOverflowPolicy(OverflowBlockWithTimeout(timeout)))
+		start := time.Now()
+		if err := c.SendBinary([]byte("new")); !errors.Is(err, ErrorSendTimeout) {
+			t.Errorf("SendBinary: got %v, want %v", err, ErrorSendTimeout)
+		}
+		if elapsed := time.Since(start); elapsed < timeout {
+			t.Errorf("SendBinary gave up after %v, want %v", elapsed, timeout)
+		}
+		if c.IsClosed() {
+			t.Error("timing out closed the connection")
+		}
+	})
+
+	t.Run("block until freed", func(t *testing.T) {
+		c, conn := saturated(t, WithOverflowPolicy(OverflowBlockWithTimeout(2*time.Second)))
+		// The writer moves on once the text frame is read.
+		go func() { <-conn.writes }()
+		if err := c.SendBinary([]byte("new")); err != nil {
+			t.Errorf("SendBinary once a slot was freed: %v", err)
+		}
+	})
+
+	t.Run("close", func(t *testing.T) {
+		c, _ := saturated(t, WithOverflowPolicy(OverflowClose))
+		if err := c.SendBinary([]byte("new")); !errors.Is(err, ErrorBufferFull) {
+			t.Errorf("SendBinary: got %v, want %v", err, ErrorBufferFull)
+		}
+		if !c.IsClosed() {
+			t.Error("connection still open after overflowing")
+		}
+		if err := c.SendBinary([]byte("after")); !errors.Is(err, ErrorConnectionClosed) {
+			t.Errorf("SendBinary after closing: got %v, want %v", err, ErrorConnectionClosed)
+		}
+	})
+}
+
+func TestClockOffset(t *testing.T) {
+	serverTime := time.Unix(1_700_000_000, 0)
+	// The client clock is 3s behind, and the ping takes 40ms each way.
+	receivedAt := serverTime.Add(40 * time.Millisecond).Add(-3 * time.Second)
+	if offset := ClockOffset(serverTime, receivedAt, 80*time.Millisecond); offset != 3*time.Second {
+		t.Errorf("ClockOffset = %v, want 3s", offset)
+	}
+
+	if _, ok := DecodePingTime(nil); ok {
+		t.Error("DecodePingTime accepted an empty ping")
+	}
+}
+
+func TestTimestampedPingsMeasureRTT(t *testing.T) {
+	config := DefaultConfig()
+	config.PingPeriod = 20 * time.Millisecond
+	config.TimestampPings = true
+	server, conns := upgradeServer(t, func([]byte) {}, WithConfig(config))
+	client := dial(t, server)
+	c := <-conns
+
+	// The client answers pings 30ms late.
+	const delay = 30 * time.Millisecond
+	stamps := make(chan time.Time, 16)
+	client.SetPingHandler(func(payload string) error {
+		sentAt, ok := DecodePingTime([]byte(payload))
+		if !ok {
+			t.Errorf("ping carries %d bytes, want a time", len(payload))
+		}
+		select {
+		case stamps <- sentAt:
+		default:
+		}
+		time.Sleep(delay)
+		return client.WriteControl(ws.PongMessage, []byte(payload), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	sentAt := <-stamps
+	if offset := time.Since(sentAt); offset < 0 || offset > time.Second {
+		t.Errorf("ping stamped %v ago, want about now", offset)
+	}
+	deadline := time.Now().Add(time.Second)
+	for c.RTT() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("no round trip measured")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rtt := c.RTT(); rtt < delay {
+		t.Errorf("RTT = %v, want at least the %v the client took to answer", rtt, delay)
+	}
+}
+
+func TestPauseReads(t *testing.T) {
+	config := DefaultConfig()
+	config.PongWait = 60 * time.Millisecond
+	config.PingPeriod = 20 * time.Millisecond
+	// The handler pushes back on the first message, like one falling
+	// behind would.
+	messages := make(chan string, 8)
+	var conn atomic.Pointer[Connection]
+	server, conns := upgradeServer(t, func(message []byte) {
+		if string(message) == "before" {
+			conn.Load().PauseReads()
+		}
+		messages <- string(message)
+	}, WithConfig(config))
+	client := dial(t, server)
+	c := <-conns
+	conn.Store(c)
+
+	pings := make(chan struct{}, 64)
+	client.SetPingHandler(func(payload string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return client.WriteControl(ws.PongMessage, []byte(payload), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	send := func(message string) {
+		t.Helper()
+		if err := client.WriteMessage(ws.BinaryMessage, []byte(message)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	send("before")
+	if got := <-messages; got != "before" {
+		t.Fatalf("handler got %q, want %q", got, "before")
+	}
+	if !c.ReadsPaused() {
+		t.Fatal("ReadsPaused() = false after PauseReads")
+	}
+	send("during")
+
+	// Paused for several PongWaits: nothing is read, yet pings go out and
+	// the connection stays up.
+	for len(pings) > 0 {
+		<-pings
+	}
+	select {
+	case got := <-messages:
+		t.Fatalf("handler got %q while paused", got)
+	case <-time.After(3 * config.PongWait):
+	}
+	if len(pings) == 0 {
+		t.Error("no ping sent while paused")
+	}
+	if c.IsClosed() {
+		t.Fatal("paused connection closed for the pongs it didn't read")
+	}
+
+	c.ResumeReads()
+	select {
+	case got := <-messages:
+		if got != "during" {
+			t.Errorf("handler got %q after resuming, want %q", got, "during")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("message sent while paused never handled")
+	}
+	if c.ReadsPaused() {
+		t.Error("ReadsPaused() = true after ResumeReads")
+	}
+
+	// Closing a paused connection doesn't wait on it to resume.
+	c.PauseReads()
+	closed := make(chan struct{})
+	go func() {
+		c.Close()
+		close(closed)
+	}()
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close blocked on paused reads")
+	}
+}
+
+func TestByteCounters(t *testing.T) {
+	var handled atomic.Int64
+	c, conn := startFake(t, func([]byte) { handled.Add(1) })
+	for _, size := range []int{10, 20, 30} {
+		conn.receive(make([]byte, size))
+	}
+	deadline := time.Now().Add(time.Second)
+	for handled.Load() < 3 {
+		if time.Now().After(deadline) {
+			t.Fatal("messages never handled")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if read := c.BytesRead(); read != 60 {
+		t.Errorf("BytesRead() = %d, want 60", read)
+	}
+
+	// The "low" frames queued behind the blocked write go out coalesced.
+	c, conn = saturated(t)
+	binary := uint64(len("low") * cap(c.send))
+	var got uint64
+	var messages int
+	for got < binary {
+		got += uint64(len(conn.next(t, ws.BinaryMessage).data))
+		messages++
+	}
+	if messages >= cap(c.send) {
+		t.Fatalf("%d frames written as %d messages, not coalesced", cap(c.send), messages)
+	}
+	want := binary + uint64(len("blocking"))
+	deadline = time.Now().Add(time.Second)
+	for c.BytesWritten() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("BytesWritten() = %d, want %d", c.BytesWritten(), want)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -0,0 +1,84 @@
+package websockets
+
+import (
+	"errors"
+	"sync"
+)
+
+// Hub fans out messages to a set of connections, such as every player in a
+// game room. It is safe for concurrent use.
+type Hub struct {
+	sync.RWMutex
+	connections map[*Connection]struct{}
+}
+
+func NewHub() *Hub {
+	return &Hub{
+		connections: make(map[*Connection]struct{}),
+	}
+}
+
+func (h *Hub) Register(c *Connection) {
+	h.Lock()
+	h.connections[c] = struct{}{}
+	h.Unlock()
+}
+
+func (h *Hub) Unregister(c *Connection) {
+	h.Lock()
+	delete(h.connections, c)
+	h.Unlock()
+}
+
+// Len returns the number of registered connections.
+func (h *Hub) Len() int {
+	h.RLock()
+	defer h.RUnlock()
+	return len(h.connections)
+}
+
+// list returns the registered connections.
+func (h *Hub) list() []*Connection {
+	h.RLock()
+	defer h.RUnlock()
+	connections := make([]*Connection, 0, len(h.connections))
+	for c := range h.connections {
+		connections = append(connections, c)
+	}
+	return connections
+}
+
+// Broadcast sends data to every open registered connection concurrently and
+// waits for all the sends to be queued. Every connection gets the same
+// slice, connections never modify what they send and neither must the
+// caller once Broadcast is called. Connections found closing or closed
+// are unregistered.
+func (h *Hub) Broadcast(data []byte) {
+	h.RLock()
+	connections := make([]*Connection, 0, len(h.connections))
+	var stale []*Connection
+	for c := range h.connections {
+		if c.State() != StateOpen {
+			stale = append(stale, c)
+			continue
+		}
+		connections = append(connections, c)
+	}
+	h.RUnlock()
+
+	for _, c := range stale {
+		h.Unregister(c)
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.SendBinary(data); errors.Is(err, ErrorConnectionClosed) {
+				h.Unregister(c)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,89 @@
+package websockets
+
+import (
+	"testing"
+	"time"
+
+	pb "galaxy.io/server/proto"
+	ws "github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBroadcastUnregistersClosedConnections(t *testing.T) {
+	hub := NewHub()
+	open, openConn := startFake(t, nil)
+	closed, _ := startFake(t, nil)
+	hub.Register(open)
+	hub.Register(closed)
+	closed.Close()
+
+	hub.Broadcast([]byte("snapshot"))
+
+	if n := hub.Len(); n != 1 {
+		t.Errorf("hub has %d connections after broadcasting, want 1", n)
+	}
+	if w := openConn.next(t, ws.BinaryMessage); string(w.data) != "snapshot" {
+		t.Errorf("open connection got %q, want %q", w.data, "snapshot")
+	}
+}
+
+func BenchmarkHubBroadcast(b *testing.B) {
+	hub := NewHub()
+	for range 1000 {
+		hub.Register(startDiscarding(b))
+	}
+	frame := snapshotFrame(50)
+
+	b.ResetTimer()
+	for range b.N {
+		hub.Broadcast(frame)
+	}
+	b.StopTimer()
+	// Let the write pumps catch up before closing.
+	time.Sleep(10 * time.Millisecond)
+}
+
+// BenchmarkEventBroadcast compares marshaling an event for every client
+// with marshaling it once and sending everyone the same bytes, as World
+// broadcasts do.
+func BenchmarkEventBroadcast(b *testing.B) {
+	clients := make([]*Client, 500)
+	for i := range clients {
+		clients[i] = &Client{conn: startDiscarding(b)}
+	}
+	eventType := pb.EventType_EvNewPlayer
+	radius, color, username := uint32(120), uint32(0xff8800), "player"
+	event := &pb.Event{
+		EventType: &eventType,
+		EventData: &pb.Event_NewPlayerEvent{NewPlayerEvent: &pb.NewPlayerEvent{
+			PlayerID: make([]byte, 16),
+			Position: &pb.Vector2D{X: &radius, Y: &radius},
+			Radius:   &radius,
+			Color:    &color,
+			Username: &username,
+		}},
+	}
+
+	b.Run("per connection", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			for _, client := range clients {
+				client.SendEvent(event)
+			}
+		}
+	})
+	b.Run("shared", func(b *testing.B) {
+		b.ReportAllocs()
+		for range b.N {
+			data, err := proto.Marshal(event)
+			if err != nil {
+				b.Fatalf("Marshal: %v", err)
+			}
+			for _, client := range clients {
+				client.SendBinary(data)
+			}
+		}
+	})
+	// Let the write pumps catch up before closing.
+	time.Sleep(10 * time.Millisecond)
+}
@@ -0,0 +1,102 @@
+package websockets
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrorInvalidLag = fmt.Errorf("Invalid lag")
+
+// Lag is artificial latency added to the frames a connection sends, to
+// reproduce high pings locally when working on client interpolation. It is
+// a development tool, never enable it in production.
+type Lag struct {
+	// Delay is how long every frame is held back before it is written.
+	Delay time.Duration
+
+	// Jitter spreads the delays uniformly over Delay ± Jitter, 0 holds
+	// every frame exactly Delay. Frames still leave in the order they were
+	// sent: one never overtakes another sent before it with a longer delay.
+	Jitter time.Duration
+}
+
+// ParseLag parses a lag written as a delay, such as "150ms", or a delay
+// and a jitter, such as "150ms/50ms".
+func ParseLag(s string) (Lag, error) {
+	delay, jitter, hasJitter := strings.Cut(s, "/")
+
+	var lag Lag
+	var err error
+	if lag.Delay, err = time.ParseDuration(strings.TrimSpace(delay)); err != nil {
+		return Lag{}, fmt.Errorf("%w: %w", ErrorInvalidLag, err)
+	}
+	if hasJitter {
+		if lag.Jitter, err = time.ParseDuration(strings.TrimSpace(jitter)); err != nil {
+			return Lag{}, fmt.Errorf("%w: %w", ErrorInvalidLag, err)
+		}
+	}
+	if lag.Delay < 0 || lag.Jitter < 0 || lag.Jitter > lag.Delay {
+		return Lag{}, fmt.Errorf("%w: %q, jitter can't exceed the delay", ErrorInvalidLag, s)
+	}
+	return lag, nil
+}
+
+func (l Lag) String() string {
+	if l.Jitter == 0 {
+		return l.Delay.String()
+	}
+	return l.Delay.String() + "/" + l.Jitter.String()
+}
+
+// WithLag delays every frame the connection sends by lag, see Lag.
+func WithLag(lag Lag) Option {
+	return func(c *Connection) {
+		c.lag = &lagQueue{lag: lag}
+	}
+}
+
+// lagQueue gives every frame the time it is due to be written.
+type lagQueue struct {
+	lag Lag
+
+	mutex sync.Mutex
+	last  time.Time
+}
+
+// due returns when a frame sent now is to be written, never before the
+// frame sent before it.
+func (q *lagQueue) due() time.Time {
+	delay := q.lag.Delay
+	if q.lag.Jitter > 0 {
+		delay += time.Duration(rand.Int64N(int64(2*q.lag.Jitter)+1)) - q.lag.Jitter
+	}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	due := time.Now().Add(delay)
+	if due.Before(q.last) {
+		due = q.last
+	}
+	q.last = due
+	return due
+}
+
+// waitUntil waits until due, returning false if the connection closed
+// meanwhile.
+func (c *Connection) waitUntil(due time.Time) bool {
+	wait := time.Until(due)
+	if wait <= 0 {
+		return true
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-c.closed:
+		return false
+	}
+}
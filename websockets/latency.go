@@ -0,0 +1,145 @@
+package websockets
+
+import (
+	"context"
+	"encoding/binary"
+	"sync"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// rttWindow is the number of round trips averaged by Connection.RTT.
+const rttWindow = 8
+
+// rttTracker keeps a rolling window of round-trip samples.
+type rttTracker struct {
+	sync.Mutex
+	samples [rttWindow]time.Duration
+	count   int
+	next    int
+}
+
+func (t *rttTracker) add(sample time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.samples[t.next] = sample
+	t.next = (t.next + 1) % rttWindow
+	if t.count < rttWindow {
+		t.count++
+	}
+}
+
+func (t *rttTracker) average() time.Duration {
+	t.Lock()
+	defer t.Unlock()
+
+	if t.count == 0 {
+		return 0
+	}
+
+	var total time.Duration
+	for _, sample := range t.samples[:t.count] {
+		total += sample
+	}
+	return total / time.Duration(t.count)
+}
+
+// PING_TIME_SIZE is the size of the payload of timestamped pings: server
+// time int64 (8), unix nanoseconds, little endian. See
+// Config.TimestampPings.
+const PING_TIME_SIZE = 8
+
+// DecodePingTime returns the server time a timestamped ping was sent at.
+func DecodePingTime(payload []byte) (time.Time, bool) {
+	if len(payload) != PING_TIME_SIZE {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(payload))), true
+}
+
+// ClockOffset estimates how far ahead of a client clock the server clock
+// is, from a ping sent at serverTime received at receivedAt by the client
+// clock, given the round-trip time rtt. The ping took about rtt/2 to
+// arrive.
+func ClockOffset(serverTime time.Time, receivedAt time.Time, rtt time.Duration) time.Duration {
+	return serverTime.Add(rtt / 2).Sub(receivedAt)
+}
+
+// pingWaiters tracks the on-demand pings waiting for their pong, keyed by
+// the payload they were sent with. Automatic pings carry no payload, or
+// the time they were sent at with Config.TimestampPings. Keys are small
+// counters, never mistaken for a time.
+type pingWaiters struct {
+	sync.Mutex
+	next    uint64
+	waiters map[string]chan struct{}
+}
+
+func (p *pingWaiters) add() (string, chan struct{}) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.waiters == nil {
+		p.waiters = make(map[string]chan struct{})
+	}
+	p.next++
+	key := string(binary.LittleEndian.AppendUint64(nil, p.next))
+	pong := make(chan struct{})
+	p.waiters[key] = pong
+	return key, pong
+}
+
+func (p *pingWaiters) remove(key string) {
+	p.Lock()
+	delete(p.waiters, key)
+	p.Unlock()
+}
+
+// answer wakes the ping waiting for a pong with payload, if any, and
+// reports whether there was one.
+func (p *pingWaiters) answer(payload string) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	pong, waiting := p.waiters[payload]
+	if waiting {
+		close(pong)
+		delete(p.waiters, payload)
+	}
+	return waiting
+}
+
+// Ping sends a ping to the peer and waits for its pong, until ctx is done
+// or the connection closes. Unlike a queued write succeeding, it confirms
+// the peer is really there. The round trip counts towards RTT like the
+// automatic pings do.
+func (c *Connection) Ping(ctx context.Context) error {
+	if c.IsClosed() {
+		return ErrorConnectionClosed
+	}
+
+	key, pong := c.pings.add()
+	defer c.pings.remove(key)
+
+	deadline := c.config.writeDeadline()
+	if ctxDeadline, ok := ctx.Deadline(); ok && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+
+	sentAt := time.Now()
+	if err := c.conn.WriteControl(ws.PingMessage, []byte(key), deadline); err != nil {
+		return err
+	}
+
+	select {
+	case <-pong:
+		c.rtt.add(time.Since(sentAt))
+		return nil
+	case <-c.closed:
+		return ErrorConnectionClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,40 @@
+package websockets
+
+// Metrics receives the activity of connections, so it can be exported to
+// Prometheus, statsd or anything else. Implementations must be safe for
+// concurrent use and cheap, they are called on every message.
+type Metrics interface {
+	// ConnectionOpened and ConnectionClosed track the active connections.
+	ConnectionOpened()
+	ConnectionClosed()
+
+	// MessageReceived is called with the size of every inbound message.
+	MessageReceived(bytes int)
+
+	// MessageSent is called with the size of every message queued for
+	// sending, MessageWritten with the size of every message written to
+	// the socket, coalesced frames adding up to one message.
+	MessageSent(bytes int)
+	MessageWritten(bytes int)
+
+	// FrameDropped is called for every outbound message lost because the
+	// send buffer was full.
+	FrameDropped()
+}
+
+// NopMetrics discards every metric, it is the default.
+type NopMetrics struct{}
+
+func (NopMetrics) ConnectionOpened()   {}
+func (NopMetrics) ConnectionClosed()   {}
+func (NopMetrics) MessageReceived(int) {}
+func (NopMetrics) MessageSent(int)     {}
+func (NopMetrics) MessageWritten(int)  {}
+func (NopMetrics) FrameDropped()       {}
+
+// WithMetrics reports the activity of the connection to metrics.
+func WithMetrics(metrics Metrics) Option {
+	return func(c *Connection) {
+		c.metrics = metrics
+	}
+}
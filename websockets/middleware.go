@@ -0,0 +1,38 @@
+package websockets
+
+import "time"
+
+// Middleware wraps a MessageHandler, like HTTP middleware, to layer
+// concerns such as metrics or limits over the handler doing the game
+// logic. It must call next to pass the message on.
+type Middleware func(next MessageHandler) MessageHandler
+
+// WithMiddleware wraps the MessageHandler of the connection in middleware,
+// the first one being the outermost. Options applying middleware add to
+// each other. Connections delivering messages WithMessageChannel have no
+// handler to wrap.
+func WithMiddleware(middleware ...Middleware) Option {
+	return func(c *Connection) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+// chain wraps handler in middleware, the first one being the outermost.
+func chain(handler MessageHandler, middleware []Middleware) MessageHandler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		handler = middleware[i](handler)
+	}
+	return handler
+}
+
+// MetricsMiddleware calls observe with the size of every message and the
+// time the rest of the chain took to handle it.
+func MetricsMiddleware(observe func(bytes int, took time.Duration)) Middleware {
+	return func(next MessageHandler) MessageHandler {
+		return func(message []byte) {
+			start := time.Now()
+			next(message)
+			observe(len(message), time.Since(start))
+		}
+	}
+}
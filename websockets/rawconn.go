@@ -0,0 +1,26 @@
+package websockets
+
+import (
+	"io"
+	"time"
+)
+
+// rawConn is the websocket transport a Connection runs over, the subset of
+// gorilla's *websocket.Conn the pumps use. Keeping it behind an interface
+// lets the read and write pumps run over a fake transport.
+type rawConn interface {
+	ReadMessage() (messageType int, data []byte, err error)
+	NextWriter(messageType int) (io.WriteCloser, error)
+	WriteMessage(messageType int, data []byte) error
+	WriteControl(messageType int, data []byte, deadline time.Time) error
+
+	SetReadLimit(limit int64)
+	SetReadDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	SetPongHandler(h func(appData string) error)
+	SetCompressionLevel(level int) error
+	EnableWriteCompression(enable bool)
+	Subprotocol() string
+
+	Close() error
+}
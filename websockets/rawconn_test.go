@@ -0,0 +1,187 @@
+package websockets
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+var errorFakeTransport = errors.New("fake transport failure")
+
+// written is a message the pumps wrote to a fakeConn.
+type written struct {
+	messageType int
+	data        []byte
+}
+
+// fakeConn is a rawConn the pumps run over in tests. Messages queued with
+// receive are read by the read pump, the ones written are sent to writes.
+type fakeConn struct {
+	mutex sync.Mutex
+
+	reads     chan []byte
+	readErr   chan error
+	writes    chan written
+	closed    chan struct{}
+	closeOnce sync.Once
+
+	// nextWriterErr, when set, fails NextWriter.
+	nextWriterErr error
+
+	// failNextWrite, when set, fails the next NextWriter only.
+	failNextWrite error
+
+	compressed []bool
+}
+
+func newFakeConn() *fakeConn {
+	return &fakeConn{
+		reads:   make(chan []byte, 64),
+		readErr: make(chan error, 1),
+		writes:  make(chan written, 4096),
+		closed:  make(chan struct{}),
+	}
+}
+
+// startFake runs a connection built with opts over a new fakeConn.
+func startFake(t testing.TB, handler MessageHandler, opts ...Option) (*Connection, *fakeConn) {
+	t.Helper()
+	return startOver(t, newFakeConn(), handler, opts...)
+}
+
+// startDiscarding runs a connection over a fakeConn throwing away what is
+// written to it, for benchmarks.
+func startDiscarding(t testing.TB, opts ...Option) *Connection {
+	t.Helper()
+
+	conn := newFakeConn()
+	conn.writes = nil
+	c, _ := startOver(t, conn, func([]byte) {}, opts...)
+	return c
+}
+
+func startOver(t testing.TB, conn *fakeConn, handler MessageHandler, opts ...Option) (*Connection, *fakeConn) {
+	t.Helper()
+
+	opts = append([]Option{WithLogger(log.New(io.Discard, "", 0))}, opts...)
+	c, err := newConnection(handler, opts...)
+	if err != nil {
+		t.Fatalf("newConnection: %v", err)
+	}
+	c.start(conn)
+	t.Cleanup(c.Close)
+	return c, conn
+}
+
+// receive has the read pump read data as a binary message.
+func (f *fakeConn) receive(data []byte) {
+	f.reads <- data
+}
+
+// failRead makes the pending and next reads fail with err.
+func (f *fakeConn) failRead(err error) {
+	f.readErr <- err
+}
+
+func (f *fakeConn) ReadMessage() (int, []byte, error) {
+	select {
+	case data := <-f.reads:
+		return ws.BinaryMessage, data, nil
+	case err := <-f.readErr:
+		return 0, nil, err
+	case <-f.closed:
+		return 0, nil, &ws.CloseError{Code: ws.CloseNormalClosure}
+	}
+}
+
+func (f *fakeConn) NextWriter(messageType int) (io.WriteCloser, error) {
+	f.mutex.Lock()
+	err := f.nextWriterErr
+	if f.failNextWrite != nil {
+		err, f.failNextWrite = f.failNextWrite, nil
+	}
+	f.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	return &fakeWriter{conn: f, messageType: messageType}, nil
+}
+
+func (f *fakeConn) WriteMessage(messageType int, data []byte) error {
+	select {
+	case <-f.closed:
+		return ws.ErrCloseSent
+	default:
+	}
+	if f.writes != nil {
+		f.writes <- written{messageType: messageType, data: bytes.Clone(data)}
+	}
+	return nil
+}
+
+// WriteControl answers close frames like a peer would, by closing.
+func (f *fakeConn) WriteControl(messageType int, data []byte, deadline time.Time) error {
+	if f.writes != nil {
+		f.writes <- written{messageType: messageType, data: bytes.Clone(data)}
+	}
+	if messageType == ws.CloseMessage {
+		f.Close()
+	}
+	return nil
+}
+
+func (f *fakeConn) SetReadLimit(limit int64)                    {}
+func (f *fakeConn) SetReadDeadline(t time.Time) error           { return nil }
+func (f *fakeConn) SetWriteDeadline(t time.Time) error          { return nil }
+func (f *fakeConn) SetPongHandler(h func(appData string) error) {}
+func (f *fakeConn) SetCompressionLevel(level int) error         { return nil }
+func (f *fakeConn) Subprotocol() string                         { return "" }
+
+func (f *fakeConn) EnableWriteCompression(enable bool) {
+	f.mutex.Lock()
+	f.compressed = append(f.compressed, enable)
+	f.mutex.Unlock()
+}
+
+func (f *fakeConn) Close() error {
+	f.closeOnce.Do(func() { close(f.closed) })
+	return nil
+}
+
+// next returns the next message of type messageType written to f.
+func (f *fakeConn) next(t testing.TB, messageType int) written {
+	t.Helper()
+
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case w := <-f.writes:
+			if w.messageType == messageType {
+				return w
+			}
+		case <-timeout:
+			t.Fatalf("no message of type %d written", messageType)
+		}
+	}
+}
+
+// fakeWriter buffers a message until it is closed.
+type fakeWriter struct {
+	conn        *fakeConn
+	messageType int
+	buffer      bytes.Buffer
+}
+
+func (w *fakeWriter) Write(p []byte) (int, error) {
+	return w.buffer.Write(p)
+}
+
+func (w *fakeWriter) Close() error {
+	return w.conn.WriteMessage(w.messageType, w.buffer.Bytes())
+}
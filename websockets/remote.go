@@ -0,0 +1,74 @@
+package websockets
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies trusts the X-Forwarded-For header of requests coming
+// from proxies, e.g. the load balancer in front of the server, to tell the
+// address of the client. Requests from anywhere else can't spoof it, their
+// header is ignored. Without trusted proxies the header is always ignored.
+func WithTrustedProxies(proxies ...netip.Prefix) Option {
+	return func(c *Connection) {
+		c.trustedProxies = append(c.trustedProxies, proxies...)
+	}
+}
+
+// RemoteAddr returns the address of the client, as forwarded by a trusted
+// proxy if there is one in between, see WithTrustedProxies.
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+// remoteAddr returns the address of the client of r, which came from peer.
+// Forwarded addresses are read right to left, the first one that isn't a
+// trusted proxy is the client, since each proxy appends the address it
+// got the request from.
+func remoteAddr(peer net.Addr, r *http.Request, trusted []netip.Prefix) net.Addr {
+	if len(trusted) == 0 || !isTrusted(addrOf(peer), trusted) {
+		return peer
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	var client netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		client = addr
+		if !isTrusted(addr, trusted) {
+			break
+		}
+	}
+	if !client.IsValid() {
+		return peer
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(client.Unmap(), 0))
+}
+
+func addrOf(addr net.Addr) netip.Addr {
+	if tcp, ok := addr.(*net.TCPAddr); ok {
+		return tcp.AddrPort().Addr().Unmap()
+	}
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addrPort.Addr().Unmap()
+}
+
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
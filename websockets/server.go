@@ -0,0 +1,225 @@
+package websockets
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	pb "galaxy.io/server/proto"
+	ws "github.com/gorilla/websocket"
+)
+
+// drainPollInterval is how often Shutdown checks whether the send buffers
+// are empty.
+const drainPollInterval = 10 * time.Millisecond
+
+var (
+	ErrorServerShuttingDown = fmt.Errorf("Server is shutting down")
+	ErrorTooManyConnections = fmt.Errorf("Too many connections")
+)
+
+// Server creates the connections of a game server like WebsocketFactory and
+// keeps track of them, so they can all be shut down cleanly on deploys.
+type Server struct {
+	factory      WebsocketFactory
+	hub          *Hub
+	shuttingDown atomic.Bool
+
+	// connections counts the open connections and those being upgraded,
+	// up to maxConnections when it isn't 0.
+	connections    atomic.Int64
+	maxConnections atomic.Int64
+
+	onShutdownMutex sync.Mutex
+	onShutdown      []func()
+}
+
+// NewServer creates a server applying options to every connection.
+func NewServer(options ...Option) *Server {
+	hub := NewHub()
+	return &Server{
+		factory: WebsocketFactory{Options: append(options, WithHub(hub))},
+		hub:     hub,
+	}
+}
+
+// OnShutdown registers a function called by Shutdown once no more
+// connections are accepted, e.g. to stop the games' tick loops.
+func (s *Server) OnShutdown(f func()) {
+	s.onShutdownMutex.Lock()
+	s.onShutdown = append(s.onShutdown, f)
+	s.onShutdownMutex.Unlock()
+}
+
+// Use wraps the message handler of every connection created afterwards in
+// middleware, see WithMiddleware. It must be called before serving.
+func (s *Server) Use(middleware ...Middleware) {
+	s.factory.Options = append(s.factory.Options, WithMiddleware(middleware...))
+}
+
+// Len returns the number of open connections.
+func (s *Server) Len() int {
+	return s.hub.Len()
+}
+
+// SetMaxConnections caps the connections the server holds at once, counting
+// the ones being upgraded. Requests over the limit get a 503 instead of
+// being upgraded. 0 means no limit.
+func (s *Server) SetMaxConnections(n int) {
+	s.maxConnections.Store(int64(n))
+}
+
+// Connections returns the number of connections counted against the limit
+// of SetMaxConnections.
+func (s *Server) Connections() int {
+	return int(s.connections.Load())
+}
+
+func (s *Server) NewConnection(
+	w http.ResponseWriter,
+	r *http.Request,
+	operationHandler func(*pb.Operation),
+	onClose func(CloseReason, error),
+) (galaxy.ClientConnection, error) {
+	release, err := s.acquire(w)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.factory.NewConnection(w, r, operationHandler, release.after(onClose))
+	if err != nil {
+		release.run()
+	}
+	return conn, err
+}
+
+func (s *Server) NewFrameConnection(
+	w http.ResponseWriter,
+	r *http.Request,
+	frameHandler func([]byte),
+	onClose func(CloseReason, error),
+) (galaxy.ClientConnection, error) {
+	release, err := s.acquire(w)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := s.factory.NewFrameConnection(w, r, frameHandler, release.after(onClose))
+	if err != nil {
+		release.run()
+	}
+	return conn, err
+}
+
+// acquire counts a new connection, answering the request with a 503 if the
+// server is shutting down or full. The connection is counted until the
+// returned release runs.
+func (s *Server) acquire(w http.ResponseWriter) (*release, error) {
+	if s.shuttingDown.Load() {
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return nil, ErrorServerShuttingDown
+	}
+
+	count := s.connections.Add(1)
+	if limit := s.maxConnections.Load(); limit > 0 && count > limit {
+		s.connections.Add(-1)
+		http.Error(w, http.StatusText(http.StatusServiceUnavailable), http.StatusServiceUnavailable)
+		return nil, ErrorTooManyConnections
+	}
+	return &release{f: func() { s.connections.Add(-1) }}, nil
+}
+
+// release frees what a connection holds exactly once, whether its upgrade
+// fails or it closes.
+type release struct {
+	once sync.Once
+	f    func()
+}
+
+func (r *release) run() {
+	r.once.Do(r.f)
+}
+
+// after returns onClose followed by the release.
+func (r *release) after(onClose func(CloseReason, error)) func(CloseReason, error) {
+	return func(reason CloseReason, err error) {
+		if onClose != nil {
+			onClose(reason, err)
+		}
+		r.run()
+	}
+}
+
+// Shutdown stops accepting connections, runs the OnShutdown functions, lets
+// every connection write what it has queued and then closes them with a
+// "server restarting" close frame. If ctx is done before the buffers are
+// drained the connections are torn down without a close frame, either way
+// ctx.Err() is returned.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.shuttingDown.Store(true)
+
+	s.onShutdownMutex.Lock()
+	onShutdown := s.onShutdown
+	s.onShutdownMutex.Unlock()
+	for _, f := range onShutdown {
+		f()
+	}
+
+	connections := s.hub.list()
+	if err := drain(ctx, connections); err != nil {
+		for _, c := range connections {
+			c.Close()
+		}
+		return err
+	}
+
+	var wg sync.WaitGroup
+	for _, c := range connections {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.CloseWithReason(ws.CloseServiceRestart, "server restarting")
+		}()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		// The close frames time out on their own after closeGracePeriod.
+		return ctx.Err()
+	}
+}
+
+// drain waits until the send buffers of connections are empty.
+func drain(ctx context.Context, connections []*Connection) error {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for {
+		pending := false
+		for _, c := range connections {
+			if !c.IsClosed() && c.SendBufferLen() > 0 {
+				pending = true
+				break
+			}
+		}
+		if !pending {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
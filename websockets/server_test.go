@@ -0,0 +1,52 @@
+package websockets
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"galaxy.io/server/galaxy"
+	ws "github.com/gorilla/websocket"
+)
+
+func TestShutdownRestartsRoomPlayers(t *testing.T) {
+	server := NewServer()
+	rooms := galaxy.NewRoomManager(server, galaxy.DefaultGameConfig(), 0, 10)
+	server.OnShutdown(rooms.Stop)
+	defer rooms.Close()
+
+	httpServer := httptest.NewServer(http.HandlerFunc(rooms.HandleNewConnection))
+	t.Cleanup(httpServer.Close)
+	client := dial(t, httpServer)
+
+	deadline := time.Now().Add(2 * time.Second)
+	for server.Len() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("player never joined a room")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// Reading answers the close frame of the server.
+	closed := make(chan error, 1)
+	go func() {
+		client.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for {
+			if _, _, err := client.ReadMessage(); err != nil {
+				closed <- err
+				return
+			}
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if err := <-closed; !ws.IsCloseError(err, ws.CloseServiceRestart) {
+		t.Errorf("player closed with %v, want %v", err, ws.CloseServiceRestart)
+	}
+}
@@ -0,0 +1,47 @@
+package websockets
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+// readHeaderTimeout bounds how long a client may take to send the headers
+// of its request, so slow handshakes can't hold connections open.
+const readHeaderTimeout = 10 * time.Second
+
+// TLSConfig returns the TLS settings servers should use for wss: TLS 1.2
+// at least, with only forward secret AEAD cipher suites. TLS 1.3 suites
+// aren't configurable and are all sane.
+func TLSConfig() *tls.Config {
+	return &tls.Config{
+		MinVersion:       tls.VersionTLS12,
+		CurvePreferences: []tls.CurveID{tls.X25519, tls.CurveP256},
+		CipherSuites: []uint16{
+			tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+			tls.TLS_ECDHE_ECDSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_RSA_WITH_AES_256_GCM_SHA384,
+			tls.TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305,
+			tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+		},
+	}
+}
+
+// NewTLSServer returns an http.Server serving handler on addr with
+// TLSConfig, to be started with ListenAndServeTLS. Keeping the server
+// lets callers shut it down.
+func NewTLSServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		TLSConfig:         TLSConfig(),
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
+
+// ListenAndServeTLS serves handler on addr over TLS with the certificate
+// and key of certFile and keyFile, see NewTLSServer.
+func ListenAndServeTLS(addr string, certFile string, keyFile string, handler http.Handler) error {
+	return NewTLSServer(addr, handler).ListenAndServeTLS(certFile, keyFile)
+}
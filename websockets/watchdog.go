@@ -0,0 +1,58 @@
+package websockets
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// ErrorSlowConsumer is the error of connections closed because their peer
+// stopped draining them, see Config.SlowConsumerTimeout.
+var ErrorSlowConsumer = fmt.Errorf("Peer stopped reading")
+
+// sendWatch is what the write pump tells the watchdog about its progress.
+type sendWatch struct {
+	// writing is set while the pump writes a message.
+	writing atomic.Bool
+
+	// written counts the messages the pump finished writing.
+	written atomic.Uint64
+}
+
+// busy reports whether the connection has frames waiting or being written.
+func (c *Connection) busy() bool {
+	return c.watch.writing.Load() || len(c.send) > 0 || len(c.urgent) > 0
+}
+
+// watchSends closes the connection once it stays busy for
+// Config.SlowConsumerTimeout without a message being written, checking a
+// few times per timeout. It returns when the connection closes.
+func (c *Connection) watchSends() {
+	timeout := c.config.SlowConsumerTimeout
+	ticker := time.NewTicker(timeout / 4)
+	defer ticker.Stop()
+
+	// since is when the pump was last seen idle or making progress.
+	since, written := time.Now(), c.watch.written.Load()
+	for {
+		select {
+		case <-c.closed:
+			return
+		case now := <-ticker.C:
+			if n := c.watch.written.Load(); n != written || !c.busy() {
+				since, written = now, n
+				continue
+			}
+			if now.Sub(since) < timeout {
+				continue
+			}
+
+			c.logf("closing slow consumer, %d frames queued and nothing written for %v", len(c.send)+len(c.urgent), now.Sub(since).Round(time.Millisecond))
+			c.closeFor(CloseOverflow, ErrorSlowConsumer)
+			c.shutdown(ws.FormatCloseMessage(ws.ClosePolicyViolation, "slow consumer"))
+			return
+		}
+	}
+}
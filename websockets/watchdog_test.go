@@ -0,0 +1,82 @@
+package websockets
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	ws "github.com/gorilla/websocket"
+)
+
+// watchedConfig closes connections stuck for timeout.
+func watchedConfig(timeout time.Duration) Config {
+	config := DefaultConfig()
+	config.SlowConsumerTimeout = timeout
+	return config
+}
+
+func TestWatchdogClosesStuckPeers(t *testing.T) {
+	type closed struct {
+		reason CloseReason
+		err    error
+	}
+	closes := make(chan closed, 1)
+	// The peer takes the write of the text frame but never finishes it.
+	c, conn := saturated(t, WithConfig(watchedConfig(40*time.Millisecond)), WithOnClose(func(reason CloseReason, err error) {
+		closes <- closed{reason, err}
+	}))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for !c.IsClosed() {
+		if time.Now().After(deadline) {
+			t.Fatal("stuck connection never closed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Unblock the pump to get at the close frame.
+	for w := range conn.writes {
+		if w.messageType != ws.CloseMessage {
+			continue
+		}
+		want := ws.FormatCloseMessage(ws.ClosePolicyViolation, "slow consumer")
+		if string(w.data) != string(want) {
+			t.Errorf("close frame %q, want %q", w.data, want)
+		}
+		break
+	}
+	select {
+	case closed := <-closes:
+		if closed.reason != CloseOverflow || !errors.Is(closed.err, ErrorSlowConsumer) {
+			t.Errorf("closed for %v (%v), want %v (%v)", closed.reason, closed.err, CloseOverflow, ErrorSlowConsumer)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onClose never ran")
+	}
+}
+
+func TestWatchdogSparesDrainingPeers(t *testing.T) {
+	timeout := 40 * time.Millisecond
+
+	t.Run("busy", func(t *testing.T) {
+		c, conn := startFake(t, nil, WithConfig(watchedConfig(timeout)))
+		for range 4 * timeout / (5 * time.Millisecond) {
+			if err := c.SendBinary([]byte("frame")); err != nil {
+				t.Fatalf("SendBinary: %v", err)
+			}
+			conn.next(t, ws.BinaryMessage)
+			time.Sleep(5 * time.Millisecond)
+		}
+		if c.IsClosed() {
+			t.Error("watchdog closed a peer reading everything sent")
+		}
+	})
+
+	t.Run("idle", func(t *testing.T) {
+		c, _ := startFake(t, nil, WithConfig(watchedConfig(timeout)))
+		time.Sleep(4 * timeout)
+		if c.IsClosed() {
+			t.Error("watchdog closed a connection with nothing to send")
+		}
+	})
+}